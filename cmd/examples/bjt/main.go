@@ -10,6 +10,7 @@ import (
 	"github.com/edp1096/toy-spice/pkg/circuit"
 	"github.com/edp1096/toy-spice/pkg/device"
 	"github.com/edp1096/toy-spice/pkg/netlist"
+	"github.com/edp1096/toy-spice/pkg/post"
 	"github.com/edp1096/toy-spice/pkg/util"
 )
 
@@ -220,39 +221,16 @@ func main() {
 	timePoints := len(results["TIME"])
 	fmt.Printf("\nTransient analysis completed with %d time points\n", timePoints)
 
-	var vin_max, vin_min, vout_max, vout_min float64
-	vin_max = results["V(in)"][0]
-	vin_min = results["V(in)"][0]
-	vout_max = results["V(out)"][0]
-	vout_min = results["V(out)"][0]
-
-	steadyStateIdx := 0
-	for i, t := range results["TIME"] {
-		if t >= 3e-3 {
-			steadyStateIdx = i
-			break
-		}
+	times := results["TIME"]
+	vin_pp, err := post.PeakToPeak(times, results["V(in)"], 3e-3, times[timePoints-1])
+	if err != nil {
+		log.Fatalf("error measuring input signal: %v", err)
 	}
-
-	for i := steadyStateIdx; i < timePoints; i++ {
-		if results["V(in)"][i] > vin_max {
-			vin_max = results["V(in)"][i]
-		}
-		if results["V(in)"][i] < vin_min {
-			vin_min = results["V(in)"][i]
-		}
-
-		if results["V(out)"][i] > vout_max {
-			vout_max = results["V(out)"][i]
-		}
-		if results["V(out)"][i] < vout_min {
-			vout_min = results["V(out)"][i]
-		}
+	vout_pp, err := post.PeakToPeak(times, results["V(out)"], 3e-3, times[timePoints-1])
+	if err != nil {
+		log.Fatalf("error measuring output signal: %v", err)
 	}
 
-	vin_pp := vin_max - vin_min
-	vout_pp := vout_max - vout_min
-
 	fmt.Println("\nSignal Analysis (steady state):")
 	fmt.Printf("  Input signal: %.3f Vpp\n", vin_pp)
 	fmt.Printf("  Output signal: %.3f Vpp\n", vout_pp)
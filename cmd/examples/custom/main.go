@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/edp1096/toy-spice/pkg/analysis"
+	"github.com/edp1096/toy-spice/pkg/device"
+	"github.com/edp1096/toy-spice/pkg/matrix"
+	"github.com/edp1096/toy-spice/pkg/netlist"
+	"github.com/edp1096/toy-spice/pkg/util"
+)
+
+// load - Custom resistive load of m parallel resistors. eg. "U1 2 0 2k m=2"
+type load struct {
+	device.BaseDevice
+	g float64
+}
+
+func newLoad(elem device.Element) (device.Device, error) {
+	if elem.Model != nil || len(elem.Args) != 1 {
+		return nil, fmt.Errorf("load requires resistance only")
+	}
+	r, err := netlist.ParseValue(elem.Args[0])
+	if err != nil {
+		return nil, err
+	}
+	m := 1.0
+	if value, ok := elem.Params["m"]; ok {
+		m, err = strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid m: %v", err)
+		}
+	}
+	if r <= 0 || m <= 0 {
+		return nil, fmt.Errorf("resistance and m must be positive")
+	}
+	return &load{BaseDevice: device.BaseDevice{Name: elem.Name, NodeNames: elem.Nodes, Value: r}, g: m / r}, nil
+}
+
+func (l *load) GetType() string { return "U" }
+
+func (l *load) Stamp(mat matrix.DeviceMatrix, status *device.CircuitStatus) error {
+	if status.Mode == device.ACAnalysis {
+		return l.StampAC(mat, status)
+	}
+	return l.stamp(mat.AddElement)
+}
+
+// StampAC - Required by AC analyses, setup fails for devices without it
+func (l *load) StampAC(mat matrix.DeviceMatrix, status *device.CircuitStatus) error {
+	return l.stamp(func(i, j int, value float64) { mat.AddComplexElement(i, j, value, 0) })
+}
+
+func (l *load) stamp(add func(i, j int, value float64)) error {
+	n1, n2 := l.Nodes[0], l.Nodes[1]
+	if n1 != 0 {
+		add(n1, n1, l.g)
+	}
+	if n2 != 0 {
+		add(n2, n2, l.g)
+	}
+	if n1 != 0 && n2 != 0 {
+		add(n1, n2, -l.g)
+		add(n2, n1, -l.g)
+	}
+	return nil
+}
+
+const input = `* Divider with custom load
+V1 1 0 dc 10
+R1 1 2 1k
+U1 2 0 2k m=2
+.op
+`
+
+func main() {
+	fmt.Print("===== Custom Device Example =====\n\n")
+
+	err := device.Register("U", device.Factory{Nodes: 2, New: newLoad})
+	if err != nil {
+		log.Fatalf("error registering device: %v", err)
+	}
+
+	results, err := analysis.RunNetlist(input)
+	if err != nil {
+		log.Fatalf("error running netlist: %v", err)
+	}
+	values := results[0].Values
+	fmt.Printf("  V(2) = %s\n", util.FormatValueFactor(values["V(2)"][0], "V"))
+	fmt.Printf("  I(R1) = %s\n", util.FormatValueFactor(values["I(R1)"][0], "A"))
+
+	fmt.Println("\nDone!")
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/edp1096/toy-spice/pkg/circuit"
 	"github.com/edp1096/toy-spice/pkg/device"
 	"github.com/edp1096/toy-spice/pkg/netlist"
+	"github.com/edp1096/toy-spice/pkg/post"
 	"github.com/edp1096/toy-spice/pkg/util"
 )
 
@@ -139,26 +140,14 @@ func main() {
 		}
 	}
 
-	var vin_min, vin_max, vout_min, vout_max float64
-	vin_min = results["V(1)"][0]
-	vin_max = results["V(1)"][0]
-	vout_min = results["V(3)"][0]
-	vout_max = results["V(3)"][0]
-
-	for i := 1; i < timePoints; i++ {
-		if results["V(1)"][i] < vin_min {
-			vin_min = results["V(1)"][i]
-		}
-		if results["V(1)"][i] > vin_max {
-			vin_max = results["V(1)"][i]
-		}
-
-		if results["V(3)"][i] < vout_min {
-			vout_min = results["V(3)"][i]
-		}
-		if results["V(3)"][i] > vout_max {
-			vout_max = results["V(3)"][i]
-		}
+	times := results["TIME"]
+	vin_min, vin_max, err := post.Extremes(times, results["V(1)"], times[0], times[lastIdx])
+	if err != nil {
+		log.Fatalf("error measuring input voltage: %v", err)
+	}
+	vout_min, vout_max, err := post.Extremes(times, results["V(3)"], times[0], times[lastIdx])
+	if err != nil {
+		log.Fatalf("error measuring output voltage: %v", err)
 	}
 
 	fmt.Println("\nVoltage Analysis:")
@@ -110,22 +110,24 @@ func main() {
 	fmt.Println("DC Sweep Results:")
 	fmt.Print("=================\n\n")
 
-	sweepPoints := len(results["SWEEP1"])
+	// Diode current is current out of swept source
+	vsweeps := results[analysis.SweepName("Vsweep")]
+	idiodes := make([]float64, len(vsweeps))
+	for i, current := range results["I(Vsweep)"] {
+		idiodes[i] = -current
+	}
+
+	sweepPoints := len(vsweeps)
 	fmt.Printf("Number of sweep points: %d\n\n", sweepPoints)
 
 	fmt.Println("Vsweep(V)    Vdiode(V)    Idiode(mA)    Conductance(mS)")
 	fmt.Println("----------------------------------------------------------")
 
 	for i := range sweepPoints {
-		vsweep := results["SWEEP1"][i]
+		vsweep := vsweeps[i]
 		vdiode := results["V(2)"][i]
 
-		var idiode float64
-		if id, ok := results["I(D1)"]; ok {
-			idiode = id[i]
-		} else {
-			idiode = -results["I(Vsweep)"][i]
-		}
+		idiode := idiodes[i]
 
 		conductance := 0.0
 		if vdiode > 0.01 {
@@ -139,12 +141,7 @@ func main() {
 
 	thresholdIdx := 0
 	for i := range sweepPoints {
-		var idiode float64
-		if id, ok := results["I(D1)"]; ok {
-			idiode = id[i]
-		} else {
-			idiode = -results["I(Vsweep)"][i]
-		}
+		idiode := idiodes[i]
 
 		if idiode*1000.0 >= 1.0 {
 			thresholdIdx = i
@@ -161,12 +158,7 @@ func main() {
 	maxCurrentIdx := 0
 
 	for i := 0; i < sweepPoints; i++ {
-		var idiode float64
-		if id, ok := results["I(D1)"]; ok {
-			idiode = id[i]
-		} else {
-			idiode = -results["I(Vsweep)"][i]
-		}
+		idiode := idiodes[i]
 
 		if math.Abs(idiode) > math.Abs(maxCurrent) {
 			maxCurrent = idiode
@@ -174,7 +166,7 @@ func main() {
 		}
 	}
 
-	fmt.Printf("  Maximum current: %.3f mA at %.3f V\n", maxCurrent*1000.0, results["SWEEP1"][maxCurrentIdx])
+	fmt.Printf("  Maximum current: %.3f mA at %.3f V\n", maxCurrent*1000.0, vsweeps[maxCurrentIdx])
 
 	fmt.Println("\nDone!")
 }
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/edp1096/toy-spice/pkg/analysis"
+	"github.com/edp1096/toy-spice/pkg/util"
+)
+
+const input = `* RC low pass filter and resistor divider
+V1 1 0 ac 1
+R1 1 2 1k
+C1 2 0 1u
+V2 3 0 dc 5
+R3 3 4 1k
+R4 4 0 1k
+.op
+.ac dec 7 10 10k
+.print ac vdb(2)
+`
+
+func main() {
+	fmt.Print("===== Netlist Example =====\n\n")
+
+	results, err := analysis.RunNetlist(input)
+	if err != nil {
+		log.Fatalf("error running netlist: %v", err)
+	}
+
+	for _, result := range results {
+		for _, warning := range result.Warnings {
+			fmt.Printf("Warning: %s\n", warning)
+		}
+		fmt.Printf("Analysis: %s %v\n\n", result.Analysis, result.Parameters)
+
+		switch result.Analysis {
+		case "op":
+			fmt.Printf("  V(4) = %s\n\n", util.FormatValueFactor(result.Values["V(4)"][0], "V"))
+		case "ac":
+			freqs := result.Values["FREQ"]
+			gains := result.Values["V(2)_DB"]
+			for i := range freqs {
+				fmt.Printf("  %12s  %8.3f dB\n", util.FormatValueFactor(freqs[i], "Hz"), gains[i])
+			}
+		}
+	}
+
+	fmt.Println("\nDone!")
+}
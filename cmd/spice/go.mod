@@ -1,3 +0,0 @@
-module spice
-
-go 1.23.4
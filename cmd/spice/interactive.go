@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/edp1096/toy-spice/pkg/analysis"
+	"github.com/edp1096/toy-spice/pkg/netlist"
+)
+
+const shellHelp = `Commands:
+  source <file>           load netlist
+  op                      operating point
+  tran <tstep> <tstop> [tstart [tmax]] [uic]
+  ac <dec|oct|lin> <points> <fstart> <fstop>
+  disto <dec|oct|lin> <points> <fstart> <fstop> [f2overf1]
+  hb <f0> <harmonics>     harmonic balance
+  stb <dec|oct|lin> <points> <fstart> <fstop> [probe]
+  dc <source> <start> <stop> <incr> [<source2> <start2> <stop2> <incr2>]
+  run                     analysis of netlist
+  print [all|v(node) i(device) ...]
+  alter <device>=<value>  change device value. eg. alter r1=2k
+  show                    list elements and alterations
+  reset                   drop alterations and results
+  help, quit`
+
+// shell - Interpreter of interactive commands over netlist and analysis packages.
+// Analysis commands run netlist with analysis card replaced, like ngspice control mode.
+type shell struct {
+	out      io.Writer
+	fileName string
+	content  string             // Netlist without alterations
+	altered  map[string]float64 // Device values changed by alter. Key is lower case name
+	results  map[string][]float64
+}
+
+func newShell(out io.Writer) *shell {
+	return &shell{out: out, altered: make(map[string]float64)}
+}
+
+// execute - Run command line. quit reports false
+func (sh *shell) execute(line string) (bool, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || strings.HasPrefix(fields[0], "*") {
+		return true, nil
+	}
+	command := strings.ToLower(fields[0])
+	args := fields[1:]
+
+	switch command {
+	case "quit", "exit":
+		return false, nil
+	case "help":
+		fmt.Fprintln(sh.out, shellHelp)
+	case "source", "load":
+		if len(args) != 1 {
+			return true, fmt.Errorf("usage: source <file>")
+		}
+		return true, sh.source(args[0])
+	case "op", "tran", "ac", "dc", "disto", "hb", "stb":
+		return true, sh.simulate("." + strings.Join(fields, " "))
+	case "run":
+		return true, sh.simulate("")
+	case "print":
+		return true, sh.print(args)
+	case "alter":
+		return true, sh.alter(strings.Join(args, " "))
+	case "show":
+		return true, sh.show()
+	case "reset":
+		clear(sh.altered)
+		sh.results = nil
+		fmt.Fprintln(sh.out, "Alterations and results dropped")
+	default:
+		return true, fmt.Errorf("unknown command: %s, try help", fields[0])
+	}
+	return true, nil
+}
+
+func (sh *shell) source(fileName string) error {
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+	_, err = netlist.ParseDir(string(content), filepath.Dir(fileName))
+	if err != nil {
+		return fmt.Errorf("parsing netlist: %v", err)
+	}
+
+	sh.fileName = fileName
+	sh.content = string(content)
+	clear(sh.altered)
+	sh.results = nil
+	fmt.Fprintf(sh.out, "Loaded %s\n", fileName)
+	return nil
+}
+
+// parse - Netlist with analysis card appended and alterations applied
+func (sh *shell) parse(card string) (*netlist.NetlistData, error) {
+	if sh.fileName == "" {
+		return nil, fmt.Errorf("no netlist loaded, use source <file>")
+	}
+
+	input := sh.content
+	if card != "" {
+		// Last analysis card wins
+		input += "\n" + card + "\n"
+	}
+	ckt, err := netlist.ParseDir(input, filepath.Dir(sh.fileName))
+	if err != nil {
+		return nil, fmt.Errorf("parsing netlist: %v", err)
+	}
+
+	for name, value := range sh.altered {
+		elem := findElement(ckt, name)
+		if elem == nil {
+			return nil, fmt.Errorf("device not found: %s", name)
+		}
+		elem.Value = value
+	}
+	return ckt, nil
+}
+
+func (sh *shell) simulate(card string) error {
+	ckt, err := sh.parse(card)
+	if err != nil {
+		return err
+	}
+
+	results, warnings, err := simulate(ckt)
+	for _, warning := range warnings {
+		fmt.Fprintf(sh.out, "Warning: %s\n", warning)
+	}
+	if err != nil {
+		return err
+	}
+
+	sh.results = results
+	fmt.Fprintf(sh.out, "Analysis done, %d results\n", len(results))
+	return nil
+}
+
+func (sh *shell) print(names []string) error {
+	if sh.results == nil {
+		return fmt.Errorf("no results, run analysis first")
+	}
+
+	results := sh.results
+	if len(names) > 0 && !strings.EqualFold(names[0], "all") {
+		var err error
+		results, err = analysis.SelectResults(sh.results, names)
+		if err != nil {
+			return err
+		}
+	}
+	printResults(sh.out, results)
+	return nil
+}
+
+func (sh *shell) alter(args string) error {
+	name, valueText, ok := strings.Cut(args, "=")
+	if !ok {
+		// alter r1 2k
+		fields := strings.Fields(args)
+		if len(fields) != 2 {
+			return fmt.Errorf("usage: alter <device>=<value>")
+		}
+		name, valueText = fields[0], fields[1]
+	}
+	name = strings.ToLower(strings.TrimSpace(name))
+	value, err := netlist.ParseValue(strings.TrimSpace(valueText))
+	if err != nil {
+		return fmt.Errorf("invalid value: %v", err)
+	}
+
+	ckt, err := sh.parse("")
+	if err != nil {
+		return err
+	}
+	if findElement(ckt, name) == nil {
+		return fmt.Errorf("device not found: %s", name)
+	}
+
+	sh.altered[name] = value
+	fmt.Fprintf(sh.out, "%s = %g\n", name, value)
+	return nil
+}
+
+func (sh *shell) show() error {
+	ckt, err := sh.parse("")
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(sh.out, "%s: %s\n", sh.fileName, ckt.Title)
+	for _, elem := range ckt.Elements {
+		fmt.Fprintf(sh.out, "  %-10s %-4s %v %g\n", elem.Name, elem.Type, elem.Nodes, elem.Value)
+	}
+
+	names := make([]string, 0, len(sh.altered))
+	for name := range sh.altered {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) > 0 {
+		fmt.Fprintf(sh.out, "Altered: %s\n", strings.Join(names, ", "))
+	}
+	return nil
+}
+
+func findElement(ckt *netlist.NetlistData, name string) *netlist.Element {
+	index := slices.IndexFunc(ckt.Elements, func(elem netlist.Element) bool {
+		return strings.EqualFold(elem.Name, name)
+	})
+	if index < 0 {
+		return nil
+	}
+	return &ckt.Elements[index]
+}
+
+// interactive - spice -i [netlist_file]. Reads commands from stdin until quit or EOF
+func interactive(fileName string) {
+	sh := newShell(os.Stdout)
+	if fileName != "" {
+		_, err := sh.execute("source " + fileName)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Print("spice> ")
+		if !scanner.Scan() {
+			fmt.Println()
+			return
+		}
+		more, err := sh.execute(scanner.Text())
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+		if !more {
+			return
+		}
+	}
+}
@@ -1,226 +1,255 @@
-package main // import "spice"
+package main
 
 import (
 	"flag"
 	"fmt"
-	"log"
+	"io"
 	"os"
-	"sort"
+	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/edp1096/toy-spice/pkg/analysis"
 	"github.com/edp1096/toy-spice/pkg/circuit"
+	"github.com/edp1096/toy-spice/pkg/matrix"
 	"github.com/edp1096/toy-spice/pkg/netlist"
+	"github.com/edp1096/toy-spice/pkg/plot"
+	"github.com/edp1096/toy-spice/pkg/table"
 	"github.com/edp1096/toy-spice/pkg/util"
 )
 
-func printResults(results map[string][]float64) {
-	fmt.Println("\nAnalysis Results:")
-	fmt.Println("================")
+var plotFile = flag.String("plot", "", "write Bode or waveform plot to file (.png, .svg, or .gp/.py script with .dat data)")
+var bestEffort = flag.Bool("besteffort", false, "accept unconverged transient timepoints with warning instead of aborting")
+var noBypass = flag.Bool("nobypass", false, "evaluate nonlinear device models every Newton iteration")
+var interactiveMode = flag.Bool("i", false, "interactive shell, netlist file is optional")
+var rawOutput = flag.Bool("raw", false, "keep adaptive timepoints of transient instead of tstep grid")
+var solverName = flag.String("solver", matrix.DefaultSolver, "linear solver backend: "+strings.Join(matrix.SolverNames(), ", "))
+var touchstoneFile = flag.String("touchstone", "", "write S-parameters of .sp analysis to Touchstone file (.s2p)")
+var showStats = flag.Bool("stats", false, "print Newton iteration, timestep, factorization and phase time statistics")
+var strictStamps = flag.Bool("strict", false, "fail on out of bounds matrix stamps instead of warning")
+var scaleMatrix = flag.Bool("scale", false, "equilibrate rows and columns of matrix before factorization")
+var dcShunt = flag.Float64("dcshunt", 0, "resistance to ground at nodes without DC path in DC analyses, eg. 1e12 (default .options dcshunt, none)")
+var solverLog = flag.String("solverlog", "", "write solver warnings to file, none discards them (default stderr)")
+var saveNodeSetFile = flag.String("savenodeset", "", "write .nodeset of operating point to file, embed replaces .nodeset of netlist")
+var multiStart = flag.Int("multistart", 0, "find distinct operating points from this many random starts")
+var analysesFlag = flag.String("analyses", "", "comma separated analyses of netlist to run, eg. op,tran (default all)")
+var efficiency = flag.String("efficiency", "", "average power efficiency of transient, inputs:outputs[:from[:to]]. eg. vin:rload:1m")
+var digits = flag.Int("digits", 0, "significant digits of printed values (default 3 decimals)")
+var scientific = flag.Bool("sci", false, "print values in scientific notation instead of engineering factors. eg. 1.500e-03 V")
+var pageRows = flag.Int("pagerows", 0, "repeat header of result tables every this many rows, 0 prints header once")
+var transposeTable = flag.Bool("transpose", false, "print result tables with variables as rows")
+var dotFile = flag.String("dot", "", "write graphviz graph of circuit topology to file before analysis")
+var showInfo = flag.Bool("info", false, "print device counts, unknowns, model usage and devices of netlist without analysis")
+var noLibrary = flag.Bool("nolib", false, "require .model card for every model instead of default model library")
+
+func plotResults(fileName string, results map[string][]float64, outputs []string) error {
+	var names []string
+	for _, output := range outputs {
+		// Selected results are keyed by V(...) and I(...) only. eg. VDB(2) -> V(2)
+		name := strings.ToUpper(output[:1]) + output[strings.Index(output, "("):]
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	var figure *plot.Figure
+	var err error
+	if _, isAC := results["FREQ"]; isAC {
+		figure, err = plot.Bode(results, names)
+	} else {
+		figure, err = plot.Waveform(results, names)
+	}
+	if err != nil {
+		return err
+	}
+
+	return figure.Save(fileName, 800, 600)
+}
+
+func printResults(w io.Writer, results map[string][]float64) {
+	fmt.Fprintln(w, "\nAnalysis Results:")
+	fmt.Fprintln(w, "================")
 
 	// AC
 	if freqs, isAC := results["FREQ"]; isAC {
-		fmt.Printf("\nAC Analysis Results (%d frequency points):\n", len(freqs))
-		fmt.Println("Frequency      Node Voltages (Magnitude/Phase)        Branch Currents (Magnitude/Phase)")
-		fmt.Println("-----------------------------------------------------------------------------")
-
-		var voltageNames, currentNames []string
-		for name := range results {
-			if strings.HasSuffix(name, "_MAG") {
-				baseName := strings.TrimSuffix(name, "_MAG")
-				if strings.HasPrefix(baseName, "V(") {
-					voltageNames = append(voltageNames, baseName)
-				} else if strings.HasPrefix(baseName, "I(") {
-					currentNames = append(currentNames, baseName)
+		fmt.Fprintf(w, "\nAC Analysis Results (%d frequency points, magnitude<phase):\n", len(freqs))
+
+		var names []string
+		for _, name := range analysis.ResultNames(results) {
+			if baseName, ok := strings.CutSuffix(name, "_MAG"); ok {
+				// S-parameters and loop gain are printed with voltages
+				if strings.HasPrefix(baseName, "V(") || strings.HasPrefix(baseName, "I(") ||
+					strings.HasPrefix(baseName, "S") || baseName == "LOOPGAIN" {
+					if _, ok := results[baseName+"_PHASE"]; ok {
+						names = append(names, baseName)
+					}
 				}
 			}
 		}
-		sort.Strings(voltageNames)
-		sort.Strings(currentNames)
 
+		t := newResultTable(append([]string{"Frequency"}, names...))
 		for i, freq := range freqs {
-			fmt.Printf("%-13s", util.FormatFrequency(freq))
-
-			// Node voltage
-			for _, name := range voltageNames {
-				magName := name + "_MAG"
-				phaseName := name + "_PHASE"
-				if mag, ok := results[magName]; ok {
-					if phase, ok := results[phaseName]; ok {
-						magStr := util.FormatMagnitude(mag[i])
-						phaseStr := util.FormatPhase(phase[i])
-						fmt.Printf("%s=%s<%sdeg  ", name, magStr, phaseStr)
-					}
-				}
+			row := []string{strings.TrimSpace(util.FormatFrequency(freq))}
+			for _, name := range names {
+				magStr := strings.TrimSpace(util.FormatMagnitude(results[name+"_MAG"][i]))
+				phaseStr := strings.TrimSpace(util.FormatPhase(results[name+"_PHASE"][i]))
+				row = append(row, magStr+"<"+phaseStr+"deg")
 			}
-
-			// Branch current
-			for _, name := range currentNames {
-				magName := name + "_MAG"
-				phaseName := name + "_PHASE"
-				if mag, ok := results[magName]; ok {
-					if phase, ok := results[phaseName]; ok {
-						magStr := util.FormatMagnitude(mag[i])
-						phaseStr := util.FormatPhase(phase[i])
-						fmt.Printf("%s=%s<%sdeg  ", name, magStr, phaseStr)
-					}
-				}
-			}
-			fmt.Println()
+			t.AddRow(row...)
 		}
+		t.Write(w)
 		return
 	}
 
+	var names []string
+	for _, name := range analysis.ResultNames(results) {
+		if strings.HasPrefix(name, "V(") || strings.HasPrefix(name, "I(") {
+			names = append(names, name)
+		} else if info := analysis.DescribeResult(name); !info.Axis && info.Unit != "" {
+			// Magnetic core
+			names = append(names, name)
+		}
+	}
+	row := func(first []string, i int) []string {
+		for _, name := range names {
+			first = append(first, util.FormatValueFactor(results[name][i], unitOf(name)))
+		}
+		return first
+	}
+
 	// DC Sweep
 	if sweep1, isDC := results["SWEEP1"]; isDC {
-		fmt.Printf("\nDC Sweep Analysis Results (%d points):\n", len(sweep1))
-		fmt.Println("Sweep Values    Node Voltages        Branch Currents")
-		fmt.Println("------------------------------------------------")
-
-		var voltageNames, currentNames []string
-		for name := range results {
-			if name == "SWEEP1" || name == "SWEEP2" {
-				continue
-			}
-			if strings.HasPrefix(name, "V(") {
-				voltageNames = append(voltageNames, name)
-			} else if strings.HasPrefix(name, "I(") {
-				currentNames = append(currentNames, name)
-			}
-		}
-		sort.Strings(voltageNames)
-		sort.Strings(currentNames)
+		fmt.Fprintf(w, "\nDC Sweep Analysis Results (%d points):\n", len(sweep1))
 
-		_, hasNested := results["SWEEP2"]
+		sweep2, hasNested := results["SWEEP2"]
+		sweepUnit := analysis.DescribeResult("SWEEP1").Unit
+		header := []string{"Sweep"}
+		if hasNested {
+			header = []string{"Sweep1", "Sweep2"}
+		}
+		t := newResultTable(append(header, names...))
 		for i := range sweep1 {
+			first := []string{util.FormatValueFactor(sweep1[i], sweepUnit)}
 			if hasNested {
-				sweep2 := results["SWEEP2"]
-				fmt.Printf("V1=%-9s V2=%-9s  ",
-					util.FormatValueFactor(sweep1[i], "V"),
-					util.FormatValueFactor(sweep2[i], "V"))
-			} else {
-				fmt.Printf("V=%-9s  ", util.FormatValueFactor(sweep1[i], "V"))
-			}
-
-			for _, name := range voltageNames {
-				if values, ok := results[name]; ok {
-					fmt.Printf("%s=%s  ", name, util.FormatValueFactor(values[i], "V"))
-				}
+				first = append(first, util.FormatValueFactor(sweep2[i], sweepUnit))
 			}
-			for _, name := range currentNames {
-				if values, ok := results[name]; ok {
-					fmt.Printf("%s=%s  ", name, util.FormatValueFactor(values[i], "A"))
-				}
-			}
-			fmt.Println()
+			t.AddRow(row(first, i)...)
 		}
+		t.Write(w)
 		return
 	}
 
 	// Operating point
 	if len(results["TIME"]) <= 1 {
-		var voltageNames, currentNames []string
-		for name := range results {
+		voltages, currents := newResultTable([]string{"Node", "Voltage"}), newResultTable([]string{"Branch", "Current"})
+		for _, name := range names {
+			value := util.FormatValueFactor(results[name][0], unitOf(name))
 			if strings.HasPrefix(name, "V(") {
-				voltageNames = append(voltageNames, name)
+				voltages.AddRow(name, value)
 			} else if strings.HasPrefix(name, "I(") {
-				currentNames = append(currentNames, name)
+				currents.AddRow(name, value)
 			}
 		}
-		sort.Strings(voltageNames)
-		sort.Strings(currentNames)
 
-		fmt.Println("\nNode Voltages:")
-		for _, name := range voltageNames {
-			if values, ok := results[name]; ok {
-				fmt.Printf("%s = %s\n", name, util.FormatValueFactor(values[0], "V"))
-			}
-		}
-		fmt.Println("\nBranch Currents:")
-		for _, name := range currentNames {
-			if values, ok := results[name]; ok {
-				fmt.Printf("%s = %s\n", name, util.FormatValueFactor(values[0], "A"))
-			}
-		}
+		fmt.Fprintln(w, "\nNode Voltages:")
+		voltages.Write(w)
+		fmt.Fprintln(w, "\nBranch Currents:")
+		currents.Write(w)
 		return
 	}
 
 	// Transient
 	times := results["TIME"]
-	fmt.Printf("\nTransient Analysis Results (%d time points):\n", len(times))
-	fmt.Println("Time        Node Voltages        Branch Currents")
-	fmt.Println("------------------------------------------------")
-
-	var voltageNames, currentNames []string
-	for name := range results {
-		if name == "TIME" {
-			continue
-		}
-		if strings.HasPrefix(name, "V(") {
-			voltageNames = append(voltageNames, name)
-		} else if strings.HasPrefix(name, "I(") {
-			currentNames = append(currentNames, name)
-		}
+	fmt.Fprintf(w, "\nTransient Analysis Results (%d time points):\n", len(times))
+	t := newResultTable(append([]string{"Time"}, names...))
+	for i, time := range times {
+		t.AddRow(row([]string{util.FormatValueFactor(time, "s")}, i)...)
 	}
-	sort.Strings(voltageNames)
-	sort.Strings(currentNames)
+	t.Write(w)
+}
 
-	for i, t := range times {
-		fmt.Printf("%9s  ", util.FormatValueFactor(t, "s"))
+// newResultTable - Result table paged and transposed by flags
+func newResultTable(header []string) *table.Table {
+	t := table.New(header...)
+	t.PageRows, t.Transpose = *pageRows, *transposeTable
+	return t
+}
 
-		// Node voltage
-		for _, name := range voltageNames {
-			if values, ok := results[name]; ok {
-				fmt.Printf("%s=%s  ", name, util.FormatValueFactor(values[i], "V"))
-			}
-		}
-		// Branch current
-		for _, name := range currentNames {
-			if values, ok := results[name]; ok {
-				fmt.Printf("%s=%s  ", name, util.FormatValueFactor(values[i], "A"))
-			}
-		}
-		fmt.Println()
-	}
+// unitOf - Unit of result by metadata of analysis results
+func unitOf(name string) string {
+	return analysis.DescribeResult(name).Unit
 }
 
-func procWithPrintSystem() {
+func procWithPrintSystem(fileName string, opts *runOptions) {
 	var err error
 
 	// 1. Open and read netlist
-	fmt.Printf("\n[1] Reading netlist file: %s\n", flag.Arg(0))
-	content, err := os.ReadFile(flag.Arg(0))
+	fmt.Printf("\n[1] Reading netlist file: %s\n", fileName)
+	content, err := os.ReadFile(fileName)
 	if err != nil {
-		log.Fatalf("Error reading netlist file: %v", err)
+		fatalf("Error reading netlist file: %v", err)
 	}
 	fmt.Printf("File contents:\n%s\n", string(content))
 
 	// 2. Parse netlist
 	fmt.Println("\n[2] Parsing netlist")
-	ckt, err := netlist.Parse(string(content))
+	ckt, err := netlist.ParseDir(string(content), filepath.Dir(fileName))
 	if err != nil {
-		log.Fatalf("Error parsing netlist: %v", err)
+		fatalf("Error parsing netlist: %v", err)
+	}
+	for _, warning := range ckt.Warnings {
+		fmt.Printf("Warning: %s\n", warning)
+	}
+	lintWarnings, err := netlist.Lint(ckt)
+	for _, warning := range lintWarnings {
+		fmt.Printf("Warning: %s\n", warning)
+	}
+	if err != nil {
+		fatalf("Error checking netlist: %v", err)
 	}
-	fmt.Printf("Analysis type: %v\n", ckt.Analysis)
 	fmt.Printf("Circuit elements: %d\n", len(ckt.Elements))
 	for i, elem := range ckt.Elements {
 		fmt.Printf("Element %d: %s (type: %s, nodes: %v)\n",
 			i, elem.Name, elem.Type, elem.Nodes)
 	}
 
+	runAnalyses(ckt, opts, func(ckt *netlist.NetlistData, opts *runOptions) {
+		procAnalysisWithPrintSystem(fileName, ckt, opts)
+	})
+}
+
+// procAnalysisWithPrintSystem - Steps of procWithPrintSystem after parse for one analysis of netlist
+func procAnalysisWithPrintSystem(fileName string, ckt *netlist.NetlistData, opts *runOptions) {
+	var err error
+
 	// 3. Setup circuit
+	fmt.Printf("Analysis type: %v\n", ckt.Analysis)
 	fmt.Println("\n[3] Creating circuit structure")
-	isComplex := ckt.Analysis == netlist.AnalysisAC
+	isComplex := ckt.Analysis == netlist.AnalysisAC || ckt.Analysis == netlist.AnalysisSP || ckt.Analysis == netlist.AnalysisDISTO || ckt.Analysis == netlist.AnalysisHB || ckt.Analysis == netlist.AnalysisSTB
 	circuit := circuit.NewWithComplex(ckt.Title, isComplex)
 
 	// 3.1 Map nodes and branches
 	err = circuit.AssignNodeBranchMaps(ckt.Elements)
 	if err != nil {
-		log.Fatalf("Error creating circuit mappings: %v", err)
+		fatalf("Error creating circuit mappings: %v", err)
 	}
 
 	// 3.2 Create matrix
-	circuit.CreateMatrix()
+	circuit.Solver = *solverName
+	circuit.NoBypass = *noBypass
+	circuit.StrictStamps = *strictStamps
+	circuit.ScaleMatrix = *scaleMatrix
+	circuit.FloatingShunt = ckt.Options["dcshunt"]
+	if *dcShunt > 0 {
+		circuit.FloatingShunt = *dcShunt
+	}
+	if temp, ok := ckt.Options["temp"]; ok {
+		circuit.SetTemperature(temp)
+	}
+	err = circuit.CreateMatrix()
+	if err != nil {
+		fatalf("Error creating matrix: %v", err)
+	}
 
 	// 3.2.1 Print elements
 	fmt.Println("\n=== Circuit Element Details ===")
@@ -233,7 +262,7 @@ func procWithPrintSystem() {
 		fmt.Printf("Node mapping:\n")
 		nodeMap := circuit.GetNodeMap()
 		for j, nodeName := range elem.Nodes {
-			if nodeName == "0" || nodeName == "gnd" {
+			if netlist.IsGround(nodeName) {
 				fmt.Printf("  Node %d: %s -> Ground (0)\n", j, nodeName)
 			} else {
 				fmt.Printf("  Node %d: %s -> %d\n", j, nodeName, nodeMap[nodeName])
@@ -241,14 +270,14 @@ func procWithPrintSystem() {
 		}
 
 		// For voltage sources
-		if elem.Type == "V" || elem.Type == "L" {
+		if netlist.HasBranch(elem) {
 			branchMap := circuit.GetBranchMap()
 			branchIdx := branchMap[elem.Name]
 			fmt.Printf("Branch index: %d\n", branchIdx)
 			fmt.Printf("Expected matrix contributions:\n")
 			n1 := nodeMap[elem.Nodes[0]]
 			n2 := 0 // Ground case
-			if elem.Nodes[1] != "0" && elem.Nodes[1] != "gnd" {
+			if !netlist.IsGround(elem.Nodes[1]) {
 				n2 = nodeMap[elem.Nodes[1]]
 			}
 
@@ -278,7 +307,7 @@ func procWithPrintSystem() {
 
 			n1 := nodeMap[elem.Nodes[0]]
 			n2 := 0
-			if elem.Nodes[1] != "0" && elem.Nodes[1] != "gnd" {
+			if !netlist.IsGround(elem.Nodes[1]) {
 				n2 = nodeMap[elem.Nodes[1]]
 			}
 
@@ -302,158 +331,262 @@ func procWithPrintSystem() {
 	// 3.4 Create devices and stamp
 	err = circuit.SetupDevices(ckt.Elements)
 	if err != nil {
-		log.Fatalf("Error setting up devices: %v", err)
+		fatalf("Error setting up devices: %v", err)
+	}
+	for _, warning := range circuit.Warnings() {
+		fmt.Printf("Warning: %s\n", warning)
 	}
 	circuit.GetMatrix().PrintSystem() // Print sparse matrix
 
 	// 4. Setup analyzer
 	fmt.Println("\n[4] Setting up analyzer")
-	var analyzer analysis.Analysis
+	analyzer, err := analysis.FromNetlist(ckt)
+	if err != nil {
+		fatal(err)
+	}
+	applyFlags(analyzer)
 	switch ckt.Analysis {
 	case netlist.AnalysisOP:
-		analyzer = analysis.NewOP()
 		fmt.Println("Created Operating Point analyzer")
 	case netlist.AnalysisTRAN:
 		param := ckt.TranParam
-		analyzer = analysis.NewTransient(param.TStart, param.TStop, param.TStep, param.TMax, param.UIC)
 		fmt.Printf("Created Transient analyzer (step=%g, stop=%g, start=%g, maxstep=%g, uic=%v)\n", param.TStep, param.TStop, param.TStart, param.TMax, param.UIC)
-	case netlist.AnalysisAC:
-		param := ckt.ACParam
-		analyzer = analysis.NewAC(param.FStart, param.FStop, param.Points, param.Sweep)
-	case netlist.AnalysisDC:
-		param := ckt.DCParam
-		if param.Source2 != "" {
-			// nested sweep
-			analyzer = analysis.NewDCSweep(
-				[]string{param.Source1, param.Source2},
-				[]float64{param.Start1, param.Start2},
-				[]float64{param.Stop1, param.Stop2},
-				[]float64{param.Increment1, param.Increment2},
-			)
-		} else {
-			// single sweep
-			analyzer = analysis.NewDCSweep(
-				[]string{param.Source1},
-				[]float64{param.Start1},
-				[]float64{param.Stop1},
-				[]float64{param.Increment1},
-			)
-		}
-	default:
-		log.Fatal("Unsupported analysis type")
 	}
 
 	err = analyzer.Setup(circuit)
 	if err != nil {
-		log.Fatalf("Analysis setup failed: %v", err)
+		fatalf("Analysis setup failed: %v", err)
 	}
 	fmt.Println("Analyzer setup completed")
 
 	// 5. Run analysis
 	fmt.Println("\n[5] Executing analysis")
+	finish := startProgress(analyzer)
 	err = analyzer.Execute()
+	finish()
+	if tran, ok := analyzer.(*analysis.Transient); ok {
+		for _, warning := range tran.Warnings() {
+			fmt.Printf("Warning: %s\n", warning)
+		}
+	}
 	if err != nil {
-		log.Fatalf("Analysis execution failed: %v", err)
+		fatalf("Analysis execution failed: %v", err)
+	}
+	condition, err := circuit.GetMatrix().ConditionEstimate()
+	if err == nil {
+		fmt.Printf("Condition estimate of last factored matrix: %.3g\n", condition)
 	}
 
 	// 6. Print result
 	fmt.Println("\n[6] Analysis completed - Results:")
-	printResults(analyzer.GetResults())
+	results := analyzer.GetResults()
+	if len(ckt.Outputs) > 0 {
+		results, err = analysis.SelectResults(results, ckt.Outputs)
+		if err != nil {
+			fatalf("Error selecting outputs: %v", err)
+		}
+	}
+	err = opts.writeResults(analyzer, results)
+	if err != nil {
+		fatalf("Error writing results: %v", err)
+	}
+	printMargins(analyzer)
+	err = printSolutions(analyzer)
+	if err != nil {
+		fatalf("Error finding operating points: %v", err)
+	}
+	err = printMeasures(analyzer, ckt)
+	if err != nil {
+		fatalf("Error: %v", err)
+	}
+	err = printEfficiency(analyzer)
+	if err != nil {
+		fatalf("Error: %v", err)
+	}
+	if *showStats {
+		printStats(analyzer)
+	}
+
+	if *plotFile != "" {
+		err = plotResults(*plotFile, results, ckt.Outputs)
+		if err != nil {
+			fatalf("Error writing plot: %v", err)
+		}
+		fmt.Printf("\nPlot written to %s\n", *plotFile)
+	}
+
+	if *touchstoneFile != "" {
+		sp, ok := analyzer.(*analysis.SPAnalysis)
+		if !ok {
+			fatal("Touchstone output requires .sp analysis")
+		}
+		err = writeTouchstone(*touchstoneFile, sp)
+		if err != nil {
+			fatalf("Error writing touchstone file: %v", err)
+		}
+		fmt.Printf("\nS-parameters written to %s\n", *touchstoneFile)
+	}
+
+	if *saveNodeSetFile != "" {
+		written, err := saveNodeSet(fileName, analyzer)
+		if err != nil {
+			fatalf("Error writing nodeset: %v", err)
+		}
+		fmt.Printf("\nNodeset written to %s\n", written)
+	}
+}
+
+func writeTouchstone(fileName string, sp *analysis.SPAnalysis) error {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return sp.WriteTouchstone(f)
 }
 
-func procPrint() {
+func procPrint(fileName string, opts *runOptions) {
 	var err error
 
 	// 1. Open and read netlist
-	content, err := os.ReadFile(flag.Arg(0))
+	content, err := os.ReadFile(fileName)
 	if err != nil {
-		log.Fatalf("Error reading netlist file: %v", err)
+		fatalf("Error reading netlist file: %v", err)
 	}
 
 	// 2. Parse netlist
-	ckt, err := netlist.Parse(string(content))
+	ckt, err := netlist.ParseDir(string(content), filepath.Dir(fileName))
 	if err != nil {
-		log.Fatalf("Error parsing netlist: %v", err)
+		fatalf("Error parsing netlist: %v", err)
 	}
 
-	// 3. Setup circuit
-	isComplex := ckt.Analysis == netlist.AnalysisAC
-	circuit := circuit.NewWithComplex(ckt.Title, isComplex)
+	runAnalyses(ckt, opts, func(ckt *netlist.NetlistData, opts *runOptions) {
+		procAnalysisPrint(fileName, ckt, opts)
+	})
+}
 
-	// 3.1 Map nodes and branches
-	err = circuit.AssignNodeBranchMaps(ckt.Elements)
+// procAnalysisPrint - Steps of procPrint after parse for one analysis of netlist
+func procAnalysisPrint(fileName string, ckt *netlist.NetlistData, opts *runOptions) {
+	// 3. Setup circuit and run analysis
+	analyzer, warnings, err := runAnalysis(ckt)
+	if !opts.quiet {
+		for _, warning := range warnings {
+			fmt.Printf("Warning: %s\n", warning)
+		}
+	}
 	if err != nil {
-		log.Fatalf("Error creating circuit mappings: %v", err)
+		fatalf("Error: %v", err)
 	}
-
-	// 3.2 Create matrix
-	circuit.CreateMatrix()
-
-	// 3.3 Setup Model parameters - Must run before SetupDevices
-	circuit.Models = ckt.Models
-
-	// 3.4 Create devices and stamp
-	err = circuit.SetupDevices(ckt.Elements)
+	results, err := selectResults(analyzer, ckt)
 	if err != nil {
-		log.Fatalf("Error setting up devices: %v", err)
+		fatalf("Error: %v", err)
 	}
-	// circuit.GetMatrix().PrintSystem()
 
-	// 4. Setup analyzer
-	var analyzer analysis.Analysis
-	switch ckt.Analysis {
-	case netlist.AnalysisOP:
-		analyzer = analysis.NewOP()
-	case netlist.AnalysisTRAN:
-		param := ckt.TranParam
-		analyzer = analysis.NewTransient(param.TStart, param.TStop, param.TStep, param.TMax, param.UIC)
-	case netlist.AnalysisAC:
-		param := ckt.ACParam
-		analyzer = analysis.NewAC(param.FStart, param.FStop, param.Points, param.Sweep)
-	case netlist.AnalysisDC:
-		param := ckt.DCParam
-		if param.Source2 != "" {
-			// nested sweep
-			analyzer = analysis.NewDCSweep(
-				[]string{param.Source1, param.Source2},
-				[]float64{param.Start1, param.Start2},
-				[]float64{param.Stop1, param.Stop2},
-				[]float64{param.Increment1, param.Increment2},
-			)
-		} else {
-			// single sweep
-			analyzer = analysis.NewDCSweep(
-				[]string{param.Source1},
-				[]float64{param.Start1},
-				[]float64{param.Stop1},
-				[]float64{param.Increment1},
-			)
+	// 4. Print result
+	err = opts.writeResults(analyzer, results)
+	if err != nil {
+		fatalf("Error writing results: %v", err)
+	}
+	if !opts.quiet {
+		printMargins(analyzer)
+		err = printSolutions(analyzer)
+		if err != nil {
+			fatalf("Error finding operating points: %v", err)
+		}
+		err = printMeasures(analyzer, ckt)
+		if err != nil {
+			fatalf("Error: %v", err)
 		}
-	default:
-		log.Fatal("Unsupported analysis type")
+		err = printEfficiency(analyzer)
+		if err != nil {
+			fatalf("Error: %v", err)
+		}
+	}
+	if *showStats {
+		printStats(analyzer)
 	}
 
-	err = analyzer.Setup(circuit)
-	if err != nil {
-		log.Fatalf("Analysis setup failed: %v", err)
+	if *plotFile != "" {
+		err = plotResults(*plotFile, results, ckt.Outputs)
+		if err != nil {
+			fatalf("Error writing plot: %v", err)
+		}
+		if !opts.quiet {
+			fmt.Printf("\nPlot written to %s\n", *plotFile)
+		}
 	}
 
-	// 5. Run analysis
-	err = analyzer.Execute()
-	if err != nil {
-		log.Fatalf("Analysis execution failed: %v", err)
+	if *touchstoneFile != "" {
+		sp, ok := analyzer.(*analysis.SPAnalysis)
+		if !ok {
+			fatal("Touchstone output requires .sp analysis")
+		}
+		err = writeTouchstone(*touchstoneFile, sp)
+		if err != nil {
+			fatalf("Error writing touchstone file: %v", err)
+		}
+		if !opts.quiet {
+			fmt.Printf("\nS-parameters written to %s\n", *touchstoneFile)
+		}
 	}
 
-	// 6. Print result
-	printResults(analyzer.GetResults())
+	if *saveNodeSetFile != "" {
+		written, err := saveNodeSet(fileName, analyzer)
+		if err != nil {
+			fatalf("Error writing nodeset: %v", err)
+		}
+		if !opts.quiet {
+			fmt.Printf("\nNodeset written to %s\n", written)
+		}
+	}
 }
 
+const usage = `Usage: spice [-plot file.png|file.svg|file.gp|file.py] [-touchstone file.s2p] [-cpuprofile file] [-memprofile file] <netlist_file>
+       spice -i [netlist_file]
+       spice run [flags] <netlist_file>
+       spice test [-golden dir] [-update] <netlist_dir>
+       spice verify
+       spice serve [-addr :8080] [-jobs 4]`
+
 func main() {
 	flag.Parse()
+	netlist.DefaultLibrary = !*noLibrary
+	util.SetNumberFormat(util.NumberFormat{Digits: *digits, Scientific: *scientific})
+	defer redirectSolverLog()()
+	startProfiling()
+	defer stopProfiling()
+
+	if *interactiveMode {
+		interactive(flag.Arg(0))
+		return
+	}
+	switch flag.Arg(0) {
+	case "serve":
+		serve(flag.Args()[1:])
+		return
+	case "run":
+		run(flag.Args()[1:])
+		return
+	case "test":
+		runTests(flag.Args()[1:])
+		return
+	case "verify":
+		runVerify()
+		return
+	}
 	if flag.NArg() != 1 {
-		log.Fatal("Usage: spice <netlist_file>")
+		fatal(usage)
 	}
 
-	// procPrint()
-	procWithPrintSystem()
+	if *dotFile != "" {
+		writeDOT(flag.Arg(0), *dotFile)
+	}
+	if *showInfo {
+		printInfo(flag.Arg(0))
+		return
+	}
+	enableProgress()
+	// procPrint(flag.Arg(0), &runOptions{})
+	procWithPrintSystem(flag.Arg(0), &runOptions{})
 }
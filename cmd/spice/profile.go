@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+)
+
+var cpuProfile = flag.String("cpuprofile", "", "write CPU profile to file")
+var memProfile = flag.String("memprofile", "", "write heap profile to file at exit")
+
+// stopProfiling - Stop profiles started by startProfiling, also on fatal and exit paths
+var stopProfiling = func() {}
+
+// startProfiling - Start CPU profile of -cpuprofile. Stop of stopProfiling writes it and -memprofile once
+func startProfiling() {
+	var cpuFile *os.File
+	if *cpuProfile != "" {
+		f, err := os.Create(*cpuProfile)
+		if err != nil {
+			log.Fatalf("creating CPU profile: %v", err)
+		}
+		err = pprof.StartCPUProfile(f)
+		if err != nil {
+			log.Fatalf("starting CPU profile: %v", err)
+		}
+		cpuFile = f
+	}
+
+	var once sync.Once
+	stopProfiling = func() {
+		once.Do(func() {
+			if cpuFile != nil {
+				pprof.StopCPUProfile()
+				cpuFile.Close()
+			}
+			if *memProfile != "" {
+				f, err := os.Create(*memProfile)
+				if err != nil {
+					log.Printf("creating heap profile: %v", err)
+					return
+				}
+				defer f.Close()
+				runtime.GC()
+				err = pprof.WriteHeapProfile(f)
+				if err != nil {
+					log.Printf("writing heap profile: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// exit - os.Exit after profiles are written
+func exit(code int) {
+	stopProfiling()
+	os.Exit(code)
+}
+
+// fatal - log.Fatal after profiles are written
+func fatal(v ...any) {
+	log.Output(2, fmt.Sprint(v...))
+	exit(1)
+}
+
+// fatalf - log.Fatalf after profiles are written
+func fatalf(format string, v ...any) {
+	log.Output(2, fmt.Sprintf(format, v...))
+	exit(1)
+}
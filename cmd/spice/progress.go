@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/edp1096/toy-spice/pkg/analysis"
+)
+
+var noProgress = flag.Bool("noprogress", false, "do not show progress of transient and sweep analyses")
+
+// Progress of analyses is written here when not nil. Set only for terminal of command line runs
+var progressOutput io.Writer
+
+// Redraw interval of progress bar
+const progressInterval = 100 * time.Millisecond
+
+// enableProgress - Progress bar on stderr when it is terminal
+func enableProgress() {
+	if *noProgress {
+		return
+	}
+	fi, err := os.Stderr.Stat()
+	if err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		progressOutput = os.Stderr
+	}
+}
+
+// progressBar - Percentage, bar and estimated time remaining of analysis on one terminal line
+type progressBar struct {
+	w        io.Writer
+	label    string
+	start    time.Time
+	drawn    time.Time
+	fraction float64
+}
+
+// startProgress - Show progress of transient and sweep analyses. Returned func clears bar and prints summary
+func startProgress(analyzer analysis.Analysis) func() {
+	var label string
+	switch analyzer.(type) {
+	case *analysis.Transient:
+		label = "Transient"
+	case *analysis.SPAnalysis:
+		label = "S-parameters"
+	case *analysis.DistoAnalysis:
+		label = "Distortion"
+	case *analysis.StabilityAnalysis:
+		label = "Stability"
+	case *analysis.ACAnalysis:
+		label = "AC sweep"
+	case *analysis.DCSweep:
+		label = "DC sweep"
+	}
+	p, ok := analyzer.(interface{ SetProgress(analysis.ProgressFunc) })
+	if progressOutput == nil || label == "" || !ok {
+		return func() {}
+	}
+
+	bar := &progressBar{w: progressOutput, label: label, start: time.Now()}
+	p.SetProgress(bar.update)
+	return func() {
+		p.SetProgress(nil)
+		bar.finish()
+	}
+}
+
+func (b *progressBar) update(done, total float64) {
+	if total <= 0 {
+		return
+	}
+	b.fraction = min(max(done/total, 0), 1)
+
+	now := time.Now()
+	if now.Sub(b.drawn) < progressInterval {
+		return
+	}
+	b.drawn = now
+
+	const width = 30
+	filled := int(b.fraction * width)
+	eta := "--"
+	if b.fraction > 0 {
+		elapsed := now.Sub(b.start)
+		eta = time.Duration(float64(elapsed) * (1 - b.fraction) / b.fraction).Round(100 * time.Millisecond).String()
+	}
+	fmt.Fprintf(b.w, "\r%s [%s%s] %5.1f%% ETA %-8s", b.label, strings.Repeat("#", filled), strings.Repeat(" ", width-filled), b.fraction*100, eta)
+}
+
+// finish - Replace bar by summary line
+func (b *progressBar) finish() {
+	fmt.Fprintf(b.w, "\r\033[K%s %.1f%% done in %v\n", b.label, b.fraction*100, time.Since(b.start).Round(time.Millisecond))
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/edp1096/toy-spice/pkg/analysis"
+	"github.com/edp1096/toy-spice/pkg/netlist"
+	"github.com/edp1096/toy-spice/pkg/util"
+)
+
+// runOptions - Flags of run command. Analysis parameters are netlist values. eg. 5m
+type runOptions struct {
+	analysis string // Only analysis to run, card of netlist or parameters by flags. op, tran, ac, dc, sp, disto, hb, stb
+
+	tStart, tStop, tStep, tMax string
+	fStart, fStop, points      string
+	sweep                      string
+
+	out       string // Result file
+	format    string // text, csv, json, vcd, wavejson. Empty is by extension of out
+	threshold string // Logic threshold of vcd and wavejson. Empty is midpoint of each waveform, and real values in vcd
+	quiet     bool   // No result tables and messages, errors only
+	verbose   bool   // Print netlist, mappings and matrix system too
+}
+
+func (o *runOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.analysis, "analysis", "", "only analysis to run, of netlist or by flags: op, tran, ac, dc, sp, disto, hb, stb")
+	fs.StringVar(&o.tStart, "tstart", "", "transient start time")
+	fs.StringVar(&o.tStop, "tstop", "", "transient stop time")
+	fs.StringVar(&o.tStep, "tstep", "", "transient print step")
+	fs.StringVar(&o.tMax, "tmax", "", "transient max timestep")
+	fs.StringVar(&o.fStart, "fstart", "", "AC/SP start frequency")
+	fs.StringVar(&o.fStop, "fstop", "", "AC/SP stop frequency")
+	fs.StringVar(&o.points, "points", "", "AC/SP number of points")
+	fs.StringVar(&o.sweep, "sweep", "", "AC/SP sweep type: dec, oct, lin")
+	fs.StringVar(&o.out, "out", "", "write results to file")
+	fs.StringVar(&o.format, "format", "", "format of -out file: text, csv, json, vcd, wavejson (default by extension)")
+	fs.StringVar(&o.threshold, "threshold", "", "logic threshold digitizing vcd and wavejson waveforms (default midpoint of each waveform)")
+	fs.BoolVar(&o.quiet, "quiet", false, "print errors only")
+	fs.BoolVar(&o.verbose, "v", false, "print netlist, node mappings and matrix system")
+}
+
+// Analysis types by name of -analysis
+var analysisTypes = map[string]netlist.AnalysisType{
+	"op":    netlist.AnalysisOP,
+	"tran":  netlist.AnalysisTRAN,
+	"ac":    netlist.AnalysisAC,
+	"dc":    netlist.AnalysisDC,
+	"sp":    netlist.AnalysisSP,
+	"disto": netlist.AnalysisDISTO,
+	"hb":    netlist.AnalysisHB,
+	"stb":   netlist.AnalysisSTB,
+}
+
+// split - Netlists of analyses to run, selected by -analysis or -analyses. Analysis of -analysis
+// not in netlist is run alone on netlist with its parameters of flags
+func (o *runOptions) split(ckt *netlist.NetlistData) ([]*netlist.NetlistData, error) {
+	var names []string
+	if *analysesFlag != "" {
+		names = strings.Split(*analysesFlag, ",")
+	}
+	if o.analysis != "" {
+		analysis, ok := analysisTypes[strings.ToLower(o.analysis)]
+		if !ok {
+			return nil, fmt.Errorf("unknown analysis: %s", o.analysis)
+		}
+		if !slices.Contains(ckt.Analyses, analysis) {
+			c := *ckt
+			c.Analysis = analysis
+			return []*netlist.NetlistData{&c}, nil
+		}
+		names = []string{o.analysis}
+	}
+	return ckt.Split(names...)
+}
+
+// apply - Override analysis parameters of netlist of one analysis by flags, after split
+func (o *runOptions) apply(ckt *netlist.NetlistData) error {
+	values := []struct {
+		flag   string
+		text   string
+		target *float64
+	}{
+		{"tstart", o.tStart, &ckt.TranParam.TStart},
+		{"tstop", o.tStop, &ckt.TranParam.TStop},
+		{"tstep", o.tStep, &ckt.TranParam.TStep},
+		{"tmax", o.tMax, &ckt.TranParam.TMax},
+		{"fstart", o.fStart, &ckt.ACParam.FStart},
+		{"fstop", o.fStop, &ckt.ACParam.FStop},
+	}
+	for _, v := range values {
+		if v.text == "" {
+			continue
+		}
+		value, err := netlist.ParseValue(v.text)
+		if err != nil {
+			return fmt.Errorf("invalid -%s: %v", v.flag, err)
+		}
+		*v.target = value
+	}
+
+	if o.points != "" {
+		points, err := strconv.Atoi(o.points)
+		if err != nil {
+			return fmt.Errorf("invalid -points: %v", err)
+		}
+		ckt.ACParam.Points = points
+	}
+	if o.sweep != "" {
+		sweep := strings.ToUpper(o.sweep)
+		if !slices.Contains([]string{"DEC", "OCT", "LIN"}, sweep) {
+			return fmt.Errorf("invalid -sweep: %s", o.sweep)
+		}
+		ckt.ACParam.Sweep = sweep
+	}
+
+	switch ckt.Analysis {
+	case netlist.AnalysisTRAN:
+		param := ckt.TranParam
+		if param.TStep <= 0 || param.TStop <= 0 {
+			return fmt.Errorf("transient analysis requires tstep and tstop")
+		}
+		if param.TStart >= param.TStop {
+			return fmt.Errorf("transient tstart %g must be less than tstop %g", param.TStart, param.TStop)
+		}
+	case netlist.AnalysisAC, netlist.AnalysisSP, netlist.AnalysisDISTO, netlist.AnalysisSTB:
+		param := ckt.ACParam
+		if param.Sweep == "LIST" {
+			break
+		}
+		if param.Sweep == "" || param.Points <= 0 || param.FStart <= 0 || param.FStop < param.FStart {
+			return fmt.Errorf("frequency sweep requires sweep type, points, fstart and fstop")
+		}
+	case netlist.AnalysisHB:
+		if ckt.HBParam.F0 <= 0 || ckt.HBParam.Harmonics < 1 {
+			return fmt.Errorf("harmonic balance requires .hb fundamental frequency and harmonics in netlist")
+		}
+	case netlist.AnalysisDC:
+		if ckt.DCParam.Source1 == "" {
+			return fmt.Errorf("DC analysis requires .dc sweep in netlist")
+		}
+	}
+
+	return nil
+}
+
+// writeResults - Print result tables unless quiet and write -out file
+func (o *runOptions) writeResults(analyzer analysis.Analysis, results map[string][]float64) error {
+	if !o.quiet {
+		printResults(os.Stdout, results)
+	}
+	if o.out == "" {
+		return nil
+	}
+
+	format := strings.ToLower(o.format)
+	if format == "" {
+		format = "text"
+		switch strings.ToLower(filepath.Ext(o.out)) {
+		case ".csv":
+			format = "csv"
+		case ".json":
+			format = "json"
+		case ".vcd":
+			format = "vcd"
+		}
+	}
+
+	d := digitizer{}
+	if o.threshold != "" {
+		threshold, err := netlist.ParseValue(o.threshold)
+		if err != nil {
+			return fmt.Errorf("invalid -threshold: %v", err)
+		}
+		d = digitizer{threshold: threshold, fixed: true}
+	}
+
+	f, err := os.Create(o.out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	switch format {
+	case "text":
+		printResults(f, results)
+	case "csv":
+		err = writeCSV(f, results)
+		if err != nil {
+			return err
+		}
+	case "json":
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(analysis.NewResult(analyzer, results))
+		if err != nil {
+			return err
+		}
+	case "vcd":
+		module := strings.TrimSuffix(filepath.Base(o.out), filepath.Ext(o.out))
+		err = writeVCD(f, results, module, d.fixed, d)
+		if err != nil {
+			return err
+		}
+	case "wavejson":
+		err = writeWaveJSON(f, results, d)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown format: %s", o.format)
+	}
+
+	if !o.quiet {
+		fmt.Printf("\nResults written to %s\n", o.out)
+	}
+	return f.Close()
+}
+
+// writeCSV - One column per result, sweep variable first. eg. TIME,V(1),V(2)
+func writeCSV(w io.Writer, results map[string][]float64) error {
+	names := analysis.ResultNames(results)
+	if len(names) == 0 {
+		return fmt.Errorf("no results")
+	}
+
+	rows := len(results[names[0]])
+	cw := csv.NewWriter(w)
+	var header []string
+	for _, name := range names {
+		// Results of other length. eg. OP TIME
+		if len(results[name]) == rows {
+			header = append(header, name)
+		}
+	}
+	cw.Write(header)
+
+	for i := range rows {
+		record := make([]string, len(header))
+		for j, name := range header {
+			record[j] = strconv.FormatFloat(results[name][i], 'g', -1, 64)
+		}
+		cw.Write(record)
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// run - spice run [flags] <netlist_file>. Flags may follow netlist file
+func run(args []string) {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	fs.StringVar(plotFile, "plot", *plotFile, "write Bode or waveform plot to file (.png, .svg, or .gp/.py script with .dat data)")
+	fs.BoolVar(rawOutput, "raw", *rawOutput, "keep adaptive timepoints of transient instead of tstep grid")
+	fs.StringVar(solverName, "solver", *solverName, "linear solver backend")
+	fs.BoolVar(bestEffort, "besteffort", *bestEffort, "accept unconverged transient timepoints with warning")
+	fs.BoolVar(noBypass, "nobypass", *noBypass, "evaluate nonlinear device models every Newton iteration")
+	fs.StringVar(touchstoneFile, "touchstone", *touchstoneFile, "write S-parameters of .sp analysis to Touchstone file (.s2p)")
+	fs.IntVar(digits, "digits", *digits, "significant digits of printed values (default 3 decimals)")
+	fs.BoolVar(scientific, "sci", *scientific, "print values in scientific notation instead of engineering factors")
+	fs.IntVar(pageRows, "pagerows", *pageRows, "repeat header of result tables every this many rows, 0 prints header once")
+	fs.BoolVar(transposeTable, "transpose", *transposeTable, "print result tables with variables as rows")
+	fs.StringVar(dotFile, "dot", *dotFile, "write graphviz graph of circuit topology to file before analysis")
+	fs.BoolVar(showInfo, "info", *showInfo, "print device counts, unknowns, model usage and devices of netlist without analysis")
+	fs.BoolVar(noLibrary, "nolib", *noLibrary, "require .model card for every model")
+	fs.BoolVar(showStats, "stats", *showStats, "print Newton iteration, timestep and solver time statistics")
+	fs.BoolVar(noProgress, "noprogress", *noProgress, "do not show progress of transient and sweep analyses")
+	fs.BoolVar(strictStamps, "strict", *strictStamps, "fail on out of bounds matrix stamps instead of warning")
+	fs.IntVar(multiStart, "multistart", *multiStart, "find distinct operating points from this many random starts")
+	fs.StringVar(analysesFlag, "analyses", *analysesFlag, "comma separated analyses of netlist to run, eg. op,tran (default all)")
+	fs.StringVar(efficiency, "efficiency", *efficiency, "average power efficiency of transient, inputs:outputs[:from[:to]]. eg. vin:rload:1m")
+	fs.BoolVar(scaleMatrix, "scale", *scaleMatrix, "equilibrate rows and columns of matrix before factorization")
+	fs.Float64Var(dcShunt, "dcshunt", *dcShunt, "resistance to ground at nodes without DC path in DC analyses, eg. 1e12 (default .options dcshunt, none)")
+	fs.StringVar(solverLog, "solverlog", *solverLog, "write solver warnings to file, none discards them (default stderr)")
+	fs.StringVar(saveNodeSetFile, "savenodeset", *saveNodeSetFile, "write .nodeset of operating point to file, embed replaces .nodeset of netlist")
+	opts := &runOptions{}
+	opts.register(fs)
+
+	var files []string
+	for {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		files = append(files, args[0])
+		args = args[1:]
+	}
+	if len(files) != 1 {
+		fatal("Usage: spice run [flags] <netlist_file>")
+	}
+	netlist.DefaultLibrary = !*noLibrary
+	util.SetNumberFormat(util.NumberFormat{Digits: *digits, Scientific: *scientific})
+	if opts.quiet && *solverLog == "" {
+		*solverLog = "none"
+	}
+	defer redirectSolverLog()()
+	if opts.quiet && opts.verbose {
+		fatal("-quiet and -v are exclusive")
+	}
+	if *dotFile != "" {
+		writeDOT(files[0], *dotFile)
+	}
+	if *showInfo {
+		printInfo(files[0])
+		return
+	}
+
+	if opts.quiet {
+		analysis.Logger.SetOutput(io.Discard)
+	} else {
+		enableProgress()
+	}
+
+	if opts.verbose {
+		procWithPrintSystem(files[0], opts)
+		return
+	}
+	procPrint(files[0], opts)
+}
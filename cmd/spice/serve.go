@@ -0,0 +1,284 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/edp1096/toy-spice/pkg/analysis"
+	"github.com/edp1096/toy-spice/pkg/netlist"
+)
+
+// simulateRequest - Body of POST /simulate and POST /jobs
+type simulateRequest struct {
+	Netlist  string `json:"netlist"`
+	Analysis string `json:"analysis,omitempty"` // Analysis card run instead of analyses of netlist. eg. ".tran 1u 1m"
+}
+
+// simulateResponse - Result is of canonical schema of analysis.Result, same as run -format json
+type simulateResponse struct {
+	Result   *analysis.Result `json:"result,omitempty"`
+	Warnings []string         `json:"warnings,omitempty"`
+	Error    string           `json:"error,omitempty"`
+}
+
+const (
+	jobRunning  = "running"
+	jobDone     = "done"
+	jobFailed   = "failed"
+	jobCanceled = "canceled"
+)
+
+type job struct {
+	ID       string           `json:"id"`
+	Status   string           `json:"status"`
+	Created  time.Time        `json:"created"`
+	Finished time.Time        `json:"finished,omitzero"`
+	Result   *analysis.Result `json:"result,omitempty"`
+	Warnings []string         `json:"warnings,omitempty"`
+	Error    string           `json:"error,omitempty"`
+}
+
+// jobServer - Simulation jobs. Long transients run in background and are polled by id.
+// Finished jobs are kept for ttl, at most maxKept of them
+type jobServer struct {
+	mu     sync.Mutex
+	jobs   map[string]*job
+	nextID int
+	// Limit of concurrently running simulations
+	slots   chan struct{}
+	ttl     time.Duration
+	maxKept int
+}
+
+func newJobServer(maxJobs int, ttl time.Duration, maxKept int) *jobServer {
+	return &jobServer{
+		jobs:    make(map[string]*job),
+		slots:   make(chan struct{}, maxJobs),
+		ttl:     ttl,
+		maxKept: maxKept,
+	}
+}
+
+// prune - Drop finished jobs older than ttl, then oldest ones over maxKept. Called with mu held
+func (js *jobServer) prune(now time.Time) {
+	var finished []*job
+	for id, j := range js.jobs {
+		if j.Status == jobRunning {
+			continue
+		}
+		if now.Sub(j.Finished) > js.ttl {
+			delete(js.jobs, id)
+			continue
+		}
+		finished = append(finished, j)
+	}
+	if len(finished) <= js.maxKept {
+		return
+	}
+	slices.SortFunc(finished, func(a, b *job) int { return a.Finished.Compare(b.Finished) })
+	for _, j := range finished[:len(finished)-js.maxKept] {
+		delete(js.jobs, j.ID)
+	}
+}
+
+func serve(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("addr", ":8080", "listen address")
+	maxJobs := flags.Int("jobs", 4, "max concurrently running simulations")
+	keep := flags.Duration("keep", time.Hour, "time finished jobs and their results are kept")
+	maxKept := flags.Int("maxkept", 100, "max finished jobs kept, oldest are dropped first")
+	flags.Parse(args)
+
+	js := newJobServer(max(*maxJobs, 1), *keep, max(*maxKept, 0))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /simulate", js.handleSimulate)
+	mux.HandleFunc("POST /jobs", js.handleCreateJob)
+	mux.HandleFunc("GET /jobs", js.handleListJobs)
+	mux.HandleFunc("GET /jobs/{id}", js.handleGetJob)
+	mux.HandleFunc("DELETE /jobs/{id}", js.handleDeleteJob)
+
+	log.Printf("Simulation server listening on %s", *addr)
+	fatal(http.ListenAndServe(*addr, mux))
+}
+
+// runRequest - Parse and simulate netlist of request. Includes are not resolved on server.
+// Analysis card of request is run instead of analyses of netlist
+func runRequest(req simulateRequest) (*analysis.Result, []string, error) {
+	input := req.Netlist
+	card := strings.Fields(req.Analysis)
+	if len(card) > 0 {
+		input += "\n" + req.Analysis + "\n"
+	}
+
+	ckt, err := netlist.ParseFS(input, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing netlist: %v", err)
+	}
+	if len(card) > 0 {
+		// Card of same analysis in netlist is replaced by last one, others are not selected
+		netlists, err := ckt.Split(strings.TrimPrefix(strings.ToLower(card[0]), "."))
+		if err != nil {
+			return nil, nil, fmt.Errorf("analysis of request: %v", err)
+		}
+		ckt = netlists[0]
+	}
+
+	analyzer, warnings, err := runAnalysis(ckt)
+	if err != nil {
+		return nil, warnings, err
+	}
+	results, err := selectResults(analyzer, ckt)
+	if err != nil {
+		return nil, warnings, err
+	}
+
+	return analysis.NewResult(analyzer, results), warnings, nil
+}
+
+// handleSimulate - Run simulation and answer results directly
+func (js *jobServer) handleSimulate(w http.ResponseWriter, r *http.Request) {
+	req, err := decodeSimulateRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, simulateResponse{Error: err.Error()})
+		return
+	}
+
+	js.slots <- struct{}{}
+	result, warnings, err := runRequest(req)
+	<-js.slots
+
+	if err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, simulateResponse{Warnings: warnings, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, simulateResponse{Result: result, Warnings: warnings})
+}
+
+// handleCreateJob - Start simulation in background and answer job id
+func (js *jobServer) handleCreateJob(w http.ResponseWriter, r *http.Request) {
+	req, err := decodeSimulateRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, simulateResponse{Error: err.Error()})
+		return
+	}
+
+	js.mu.Lock()
+	js.prune(time.Now())
+	js.nextID++
+	j := &job{ID: strconv.Itoa(js.nextID), Status: jobRunning, Created: time.Now()}
+	js.jobs[j.ID] = j
+	status := *j
+	js.mu.Unlock()
+
+	go js.run(j, req)
+
+	w.Header().Set("Location", "/jobs/"+j.ID)
+	writeJSON(w, http.StatusAccepted, status)
+}
+
+func (js *jobServer) run(j *job, req simulateRequest) {
+	js.slots <- struct{}{}
+	result, warnings, err := runRequest(req)
+	<-js.slots
+
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	// Deleted while running. Analyses can not be interrupted, result is dropped
+	if j.Status == jobCanceled {
+		return
+	}
+
+	j.Finished = time.Now()
+	defer js.prune(j.Finished)
+	j.Warnings = warnings
+	if err != nil {
+		j.Status = jobFailed
+		j.Error = err.Error()
+		return
+	}
+	j.Status = jobDone
+	j.Result = result
+}
+
+// handleListJobs - Status of all jobs without results
+func (js *jobServer) handleListJobs(w http.ResponseWriter, r *http.Request) {
+	js.mu.Lock()
+	js.prune(time.Now())
+	list := make([]job, 0, len(js.jobs))
+	for _, j := range js.jobs {
+		status := *j
+		status.Result = nil
+		list = append(list, status)
+	}
+	js.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (js *jobServer) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	js.mu.Lock()
+	js.prune(time.Now())
+	j, ok := js.jobs[r.PathValue("id")]
+	var status job
+	if ok {
+		status = *j
+	}
+	js.mu.Unlock()
+
+	if !ok {
+		writeJSON(w, http.StatusNotFound, simulateResponse{Error: "job not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// handleDeleteJob - Remove job. Running job is marked canceled and its result discarded
+func (js *jobServer) handleDeleteJob(w http.ResponseWriter, r *http.Request) {
+	js.mu.Lock()
+	j, ok := js.jobs[r.PathValue("id")]
+	if ok {
+		if j.Status == jobRunning {
+			j.Status = jobCanceled
+		}
+		delete(js.jobs, j.ID)
+	}
+	js.mu.Unlock()
+
+	if !ok {
+		writeJSON(w, http.StatusNotFound, simulateResponse{Error: "job not found"})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func decodeSimulateRequest(r *http.Request) (simulateRequest, error) {
+	var req simulateRequest
+
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 16<<20))
+	if err := decoder.Decode(&req); err != nil {
+		return req, fmt.Errorf("invalid request body: %v", err)
+	}
+	if req.Netlist == "" {
+		return req, fmt.Errorf("netlist is empty")
+	}
+
+	return req, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/edp1096/toy-spice/pkg/analysis"
+	"github.com/edp1096/toy-spice/pkg/circuit"
+	"github.com/edp1096/toy-spice/pkg/matrix"
+	"github.com/edp1096/toy-spice/pkg/netlist"
+	"github.com/edp1096/toy-spice/pkg/table"
+	"github.com/edp1096/toy-spice/pkg/util"
+)
+
+// simulate - Check, setup and run analysis of parsed netlist.
+// Returns results (selected by .print/.plot when given) and parse/lint warnings.
+func simulate(ckt *netlist.NetlistData) (map[string][]float64, []string, error) {
+	analyzer, warnings, err := runAnalysis(ckt)
+	if err != nil {
+		return nil, warnings, err
+	}
+
+	results, err := selectResults(analyzer, ckt)
+	if err != nil {
+		return nil, warnings, err
+	}
+
+	return results, warnings, nil
+}
+
+// configureCircuit - Circuit options by flags
+func configureCircuit(c *circuit.Circuit) {
+	c.Solver = *solverName
+	c.NoBypass = *noBypass
+	c.StrictStamps = *strictStamps
+	c.ScaleMatrix = *scaleMatrix
+	if *dcShunt > 0 {
+		c.FloatingShunt = *dcShunt
+	}
+}
+
+// loadCircuit - Parsed netlist file and its circuit without analysis
+func loadCircuit(fileName string) (*netlist.NetlistData, *circuit.Circuit) {
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		fatalf("Error reading netlist file: %v", err)
+	}
+	ckt, err := netlist.ParseDir(string(content), filepath.Dir(fileName))
+	if err != nil {
+		fatalf("Error parsing netlist: %v", err)
+	}
+	c, err := circuit.FromNetlist(ckt, configureCircuit)
+	if err != nil {
+		fatalf("Error: %v", err)
+	}
+	return ckt, c
+}
+
+// writeDOT - Graphviz topology of netlist for -dot, before analysis so it is written when analysis fails
+func writeDOT(fileName, dotFile string) {
+	_, c := loadCircuit(fileName)
+	f, err := os.Create(dotFile)
+	if err != nil {
+		fatalf("Error writing graph: %v", err)
+	}
+	defer f.Close()
+	err = c.ExportDOT(f)
+	if err != nil {
+		fatalf("Error writing graph: %v", err)
+	}
+	fmt.Printf("Circuit graph written to %s\n", dotFile)
+}
+
+// printInfo - Summary and device listing of netlist for -info, without analysis
+func printInfo(fileName string) {
+	ckt, c := loadCircuit(fileName)
+	summary := c.Summary()
+	fmt.Printf("Circuit: %s\n\n", ckt.Title)
+	fmt.Print(summary)
+
+	fmt.Println("\nDevice Instances:")
+	t := table.New("Name", "Type", "Nodes", "Model")
+	for _, inst := range summary.Instances {
+		t.AddRow(inst.Name, inst.Type, strings.Join(inst.Nodes, " "), inst.Model)
+	}
+	t.Write(os.Stdout)
+}
+
+// runAnalysis - Check, setup and execute analyzer of parsed netlist
+func runAnalysis(ckt *netlist.NetlistData) (analysis.Analysis, []string, error) {
+	warnings := append([]string{}, ckt.Warnings...)
+	lintWarnings, err := netlist.Lint(ckt)
+	warnings = append(warnings, lintWarnings...)
+	if err != nil {
+		return nil, warnings, fmt.Errorf("checking netlist: %v", err)
+	}
+
+	// Setup circuit
+	circuit, err := circuit.FromNetlist(ckt, configureCircuit)
+	if circuit != nil {
+		warnings = append(warnings, circuit.Warnings()...)
+	}
+	if err != nil {
+		return nil, warnings, err
+	}
+
+	// Setup analyzer
+	analyzer, err := analysis.FromNetlist(ckt)
+	if err != nil {
+		return nil, warnings, err
+	}
+	applyFlags(analyzer)
+
+	err = analyzer.Setup(circuit)
+	if err != nil {
+		return nil, warnings, fmt.Errorf("analysis setup failed: %v", err)
+	}
+
+	finish := startProgress(analyzer)
+	err = analyzer.Execute()
+	finish()
+	if w, ok := analyzer.(interface{ Warnings() []string }); ok {
+		warnings = append(warnings, w.Warnings()...)
+	}
+	if err != nil {
+		return nil, warnings, fmt.Errorf("analysis execution failed: %v", err)
+	}
+
+	return analyzer, warnings, nil
+}
+
+// runAnalyses - proc for each analysis of netlist selected by -analysis or -analyses, with parameters
+// overridden by flags. Results and plot files of more than one analysis get analysis name suffix.
+// eg. out_tran.csv, operating point is not plotted
+func runAnalyses(ckt *netlist.NetlistData, opts *runOptions, proc func(*netlist.NetlistData, *runOptions)) {
+	netlists, err := opts.split(ckt)
+	if err != nil {
+		fatalf("Error: %v", err)
+	}
+	for _, n := range netlists {
+		err = opts.apply(n)
+		if err != nil {
+			fatalf("Error: %s: %v", n.Analysis, err)
+		}
+	}
+	if len(netlists) == 1 {
+		proc(netlists[0], opts)
+		return
+	}
+
+	plot := *plotFile
+	defer func() { *plotFile = plot }()
+	for i, n := range netlists {
+		if !opts.quiet {
+			fmt.Printf("\n===== Analysis %d of %d: %s =====\n", i+1, len(netlists), n.Analysis)
+		}
+		o := *opts
+		o.out = analysisFileName(opts.out, n.Analysis)
+		*plotFile = analysisFileName(plot, n.Analysis)
+		if n.Analysis == netlist.AnalysisOP {
+			*plotFile = "" // Nothing to plot
+		}
+		proc(n, &o)
+	}
+}
+
+// analysisFileName - File name with analysis name before extension. Empty stays empty
+func analysisFileName(name string, analysis netlist.AnalysisType) string {
+	if name == "" {
+		return ""
+	}
+	ext := filepath.Ext(name)
+	return strings.TrimSuffix(name, ext) + "_" + analysis.String() + ext
+}
+
+// selectResults - Results of analyzer, selected by .print/.plot when given
+func selectResults(analyzer analysis.Analysis, ckt *netlist.NetlistData) (map[string][]float64, error) {
+	results := analyzer.GetResults()
+	if len(ckt.Outputs) == 0 {
+		return results, nil
+	}
+
+	results, err := analysis.SelectResults(results, ckt.Outputs)
+	if err != nil {
+		return nil, fmt.Errorf("selecting outputs: %v", err)
+	}
+	return results, nil
+}
+
+// printStats - Solver statistics of analyzer
+func printStats(analyzer analysis.Analysis) {
+	s, ok := analyzer.(interface{ Stats() analysis.Stats })
+	if !ok {
+		return
+	}
+	fmt.Printf("\nStatistics:\n%s", s.Stats())
+}
+
+// applyFlags - Command line settings of analyzer which are not part of netlist
+func applyFlags(analyzer analysis.Analysis) {
+	if tran, ok := analyzer.(*analysis.Transient); ok {
+		tran.SetRawOutput(*rawOutput)
+		tran.SetBestEffort(*bestEffort)
+	}
+}
+
+// saveNodeSet - .nodeset of operating point of analyzer to -savenodeset file, or into netlist file by embed.
+// Returns name of written file
+func saveNodeSet(netlistFile string, analyzer analysis.Analysis) (string, error) {
+	voltages := analysis.NodeSet(analyzer)
+	if voltages == nil {
+		return "", fmt.Errorf("analysis has no operating point")
+	}
+
+	if *saveNodeSetFile != "embed" {
+		f, err := os.Create(*saveNodeSetFile)
+		if err != nil {
+			return "", err
+		}
+		err = netlist.WriteNodeSet(f, voltages)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		return *saveNodeSetFile, err
+	}
+
+	content, err := os.ReadFile(netlistFile)
+	if err != nil {
+		return "", err
+	}
+	return netlistFile, os.WriteFile(netlistFile, []byte(netlist.EmbedNodeSet(string(content), voltages)), 0644)
+}
+
+// printMargins - Gain and phase margins of stability analysis
+func printMargins(analyzer analysis.Analysis) {
+	stb, ok := analyzer.(*analysis.StabilityAnalysis)
+	if !ok {
+		return
+	}
+	fmt.Printf("\nStability margins:\n%s", stb.Margins())
+}
+
+// printSolutions - Distinct operating points found by -multistart random starts of operating point analysis
+func printSolutions(analyzer analysis.Analysis) error {
+	op, ok := analyzer.(*analysis.OperatingPoint)
+	if !ok || *multiStart <= 0 {
+		return nil
+	}
+	solutions, err := op.FindSolutions(nil, *multiStart, 1)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nOperating points (%d found by %d starts):\n", len(solutions), *multiStart+1)
+	for i, s := range solutions {
+		state := "stable"
+		if !s.Stable {
+			state = "unstable"
+		}
+		fmt.Printf("  Solution %d: %s, %d starts\n", i+1, state, s.Starts)
+		names := make([]string, 0, len(s.Voltages))
+		for name := range s.Voltages {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			fmt.Printf("    V(%s) = %s\n", name, util.FormatValueFactor(s.Voltages[name], "V"))
+		}
+	}
+	return nil
+}
+
+// printMeasures - .measure values of netlist analysis. Measures of other analyses are skipped
+func printMeasures(analyzer analysis.Analysis, ckt *netlist.NetlistData) error {
+	kind := map[netlist.AnalysisType]string{netlist.AnalysisTRAN: "tran", netlist.AnalysisDC: "dc"}[ckt.Analysis]
+	printed := false
+	for _, m := range ckt.Measures {
+		if m.Analysis != kind {
+			continue
+		}
+		value, err := analysis.Measure(analyzer.GetResults(), m)
+		if err != nil {
+			return err
+		}
+		if !printed {
+			fmt.Println("\nMeasurements:")
+			printed = true
+		}
+		fmt.Printf("  %s = %.6g\n", m.Name, value)
+	}
+	return nil
+}
+
+// printEfficiency - Input power, output power and efficiency of transient by -efficiency.
+// Names are comma separated. eg. vin1,vin2:rload:1m:2m
+func printEfficiency(analyzer analysis.Analysis) error {
+	if *efficiency == "" {
+		return nil
+	}
+	tr, ok := analyzer.(*analysis.Transient)
+	if !ok {
+		return fmt.Errorf("efficiency requires .tran analysis")
+	}
+	parts := strings.Split(*efficiency, ":")
+	if len(parts) < 2 || len(parts) > 4 {
+		return fmt.Errorf("invalid efficiency %s, need inputs:outputs[:from[:to]]", *efficiency)
+	}
+	window := []float64{math.NaN(), math.NaN()}
+	for i, part := range parts[2:] {
+		value, err := netlist.ParseValue(part)
+		if err != nil {
+			return fmt.Errorf("invalid efficiency window: %v", err)
+		}
+		window[i] = value
+	}
+
+	pe, err := tr.Efficiency(strings.Split(parts[0], ","), strings.Split(parts[1], ","), window[0], window[1])
+	if err != nil {
+		return err
+	}
+	fmt.Println("\nEfficiency:")
+	fmt.Printf("  Input power:  %s\n", util.FormatValueFactor(pe.InputPower, "W"))
+	fmt.Printf("  Output power: %s\n", util.FormatValueFactor(pe.OutputPower, "W"))
+	fmt.Printf("  Efficiency:   %.2f %%\n", 100*pe.Efficiency)
+	return nil
+}
+
+// redirectSolverLog - Destination of solver warnings by -solverlog. Returned func closes log file
+func redirectSolverLog() func() {
+	switch *solverLog {
+	case "":
+		return func() {}
+	case "none":
+		matrix.Logger.SetOutput(io.Discard)
+		return func() {}
+	}
+
+	f, err := os.Create(*solverLog)
+	if err != nil {
+		fatalf("Error creating solver log: %v", err)
+	}
+	matrix.Logger.SetOutput(f)
+	return func() {
+		matrix.Logger.SetOutput(os.Stderr)
+		f.Close()
+	}
+}
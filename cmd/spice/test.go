@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/edp1096/toy-spice/pkg/analysis"
+	"github.com/edp1096/toy-spice/pkg/netlist"
+	"github.com/edp1096/toy-spice/pkg/verify"
+)
+
+// goldenTolerance - Result matches golden value when |got-want| <= abstol + reltol*max(|got|, |want|)
+type goldenTolerance struct {
+	reltol float64
+	abstol float64
+}
+
+// readCSV - Results written by writeCSV
+func readCSV(fileName string) (map[string][]float64, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s: no header", fileName)
+	}
+
+	header := records[0]
+	results := make(map[string][]float64, len(header))
+	for i, record := range records[1:] {
+		for j, name := range header {
+			value, err := strconv.ParseFloat(record[j], 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s line %d: %v", fileName, i+2, err)
+			}
+			results[name] = append(results[name], value)
+		}
+	}
+	return results, nil
+}
+
+// compareGolden - First mismatch of results against golden columns, empty when all match
+func compareGolden(results, golden map[string][]float64, tol goldenTolerance) string {
+	for _, name := range analysis.ResultNames(golden) {
+		want := golden[name]
+		got, ok := results[name]
+		if !ok {
+			return fmt.Sprintf("%s missing", name)
+		}
+		if len(got) != len(want) {
+			return fmt.Sprintf("%s has %d points, golden %d", name, len(got), len(want))
+		}
+		for i := range want {
+			diff := math.Abs(got[i] - want[i])
+			if diff > tol.abstol+tol.reltol*math.Max(math.Abs(got[i]), math.Abs(want[i])) || math.IsNaN(got[i]) {
+				return fmt.Sprintf("%s[%d] = %g, golden %g", name, i, got[i], want[i])
+			}
+		}
+	}
+	return ""
+}
+
+// testNetlist - Run netlist and compare with golden file, or write golden file when update
+func testNetlist(fileName, goldenFile string, tol goldenTolerance, update bool) (string, string) {
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return "ERROR", err.Error()
+	}
+	ckt, err := netlist.ParseDir(string(content), filepath.Dir(fileName))
+	if err != nil {
+		return "ERROR", fmt.Sprintf("parsing netlist: %v", err)
+	}
+	results, _, err := simulate(ckt)
+	if err != nil {
+		return "ERROR", err.Error()
+	}
+
+	if update {
+		f, err := os.Create(goldenFile)
+		if err != nil {
+			return "ERROR", err.Error()
+		}
+		err = writeCSV(f, results)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return "ERROR", fmt.Sprintf("writing golden file: %v", err)
+		}
+		return "UPDATED", goldenFile
+	}
+
+	golden, err := readCSV(goldenFile)
+	if os.IsNotExist(err) {
+		return "SKIP", "no golden file"
+	}
+	if err != nil {
+		return "ERROR", fmt.Sprintf("reading golden file: %v", err)
+	}
+
+	if mismatch := compareGolden(results, golden, tol); mismatch != "" {
+		return "FAIL", mismatch
+	}
+	return "PASS", ""
+}
+
+// runTests - spice test [flags] <dir>. Golden file of name.cir is name.csv in golden directory
+func runTests(args []string) {
+	fs := flag.NewFlagSet("test", flag.ExitOnError)
+	goldenDir := fs.String("golden", "", "directory of golden CSV files (default netlist directory)")
+	reltol := fs.Float64("reltol", 1e-3, "relative tolerance")
+	abstol := fs.Float64("abstol", 1e-9, "absolute tolerance")
+	update := fs.Bool("update", false, "write golden files from current results")
+	fs.StringVar(solverName, "solver", *solverName, "linear solver backend")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fatal("Usage: spice test [-golden dir] [-reltol 1e-3] [-abstol 1e-9] [-update] <netlist_dir>")
+	}
+
+	dir := fs.Arg(0)
+	if *goldenDir == "" {
+		*goldenDir = dir
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.cir"))
+	if err != nil {
+		fatal(err)
+	}
+	if len(files) == 0 {
+		fatalf("No netlists in %s", dir)
+	}
+
+	tol := goldenTolerance{reltol: *reltol, abstol: *abstol}
+	counts := make(map[string]int)
+	var report []string
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		status, detail := testNetlist(file, filepath.Join(*goldenDir, name+".csv"), tol, *update)
+		counts[status]++
+
+		line := fmt.Sprintf("%-8s %s", status, filepath.Base(file))
+		if detail != "" {
+			line += ": " + detail
+		}
+		report = append(report, line)
+	}
+
+	fmt.Println("\nTest Results:")
+	fmt.Println("=============")
+	for _, line := range report {
+		fmt.Println(line)
+	}
+	fmt.Printf("\n%d passed, %d failed, %d errors, %d skipped, %d updated\n",
+		counts["PASS"], counts["FAIL"], counts["ERROR"], counts["SKIP"], counts["UPDATED"])
+
+	if counts["FAIL"] > 0 || counts["ERROR"] > 0 {
+		exit(1)
+	}
+}
+
+// runVerify - spice verify. Canonical circuits of pkg/verify against analytic solutions
+func runVerify() {
+	results := verify.RunAll(verify.Cases())
+
+	fmt.Println("\nVerification Results:")
+	fmt.Println("=====================")
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Printf("%-8s %s: %v\n", "FAIL", r.Name, r.Err)
+			failed++
+		} else {
+			fmt.Printf("%-8s %s\n", "PASS", r.Name)
+		}
+		for _, warning := range r.Warnings {
+			fmt.Printf("%-8s Warning: %s\n", "", warning)
+		}
+	}
+	fmt.Printf("\n%d passed, %d failed\n", len(results)-failed, failed)
+
+	if failed > 0 {
+		exit(1)
+	}
+}
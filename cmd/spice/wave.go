@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/edp1096/toy-spice/pkg/analysis"
+)
+
+// digitizer - Logic level of waveform. Fixed threshold, or midpoint of each waveform when not given
+type digitizer struct {
+	threshold float64
+	fixed     bool
+}
+
+func (d digitizer) levelsOf(values []float64) []bool {
+	threshold := d.threshold
+	if !d.fixed && len(values) > 0 {
+		threshold = (slices.Min(values) + slices.Max(values)) / 2
+	}
+	levels := make([]bool, len(values))
+	for i, v := range values {
+		levels[i] = v >= threshold
+	}
+	return levels
+}
+
+// waveNames - Transient results except TIME in canonical order
+func waveNames(results map[string][]float64) ([]string, error) {
+	times, ok := results["TIME"]
+	if !ok || len(times) == 0 {
+		return nil, fmt.Errorf("waveform output requires transient results")
+	}
+	var names []string
+	for _, name := range analysis.ResultNames(results) {
+		if name != "TIME" && len(results[name]) == len(times) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// vcdIdentifier - Short identifier code of VCD variable from printable ASCII
+func vcdIdentifier(index int) string {
+	var id []byte
+	for {
+		id = append(id, byte('!'+index%94))
+		index /= 94
+		if index == 0 {
+			return string(id)
+		}
+		index--
+	}
+}
+
+// VCD timescale. Time of each change is integer count of fs
+const vcdTimescale = 1e-15
+
+// writeVCD - Value change dump of transient results. Digitized to 1 bit wires by threshold when digital,
+// otherwise real variables
+func writeVCD(w io.Writer, results map[string][]float64, module string, digital bool, d digitizer) error {
+	names, err := waveNames(results)
+	if err != nil {
+		return err
+	}
+
+	scope := strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return '_'
+		}
+		return r
+	}, module)
+	if scope == "" {
+		scope = "circuit"
+	}
+
+	fmt.Fprintf(w, "$version toy-spice $end\n$timescale 1 fs $end\n$scope module %s $end\n", scope)
+	levels := make([][]bool, len(names))
+	for i, name := range names {
+		if digital {
+			levels[i] = d.levelsOf(results[name])
+			fmt.Fprintf(w, "$var wire 1 %s %s $end\n", vcdIdentifier(i), name)
+		} else {
+			fmt.Fprintf(w, "$var real 64 %s %s $end\n", vcdIdentifier(i), name)
+		}
+	}
+	fmt.Fprint(w, "$upscope $end\n$enddefinitions $end\n")
+
+	value := func(i, k int) string {
+		if digital {
+			if levels[i][k] {
+				return "1" + vcdIdentifier(i)
+			}
+			return "0" + vcdIdentifier(i)
+		}
+		return "r" + strconv.FormatFloat(results[names[i]][k], 'g', -1, 64) + " " + vcdIdentifier(i)
+	}
+
+	times := results["TIME"]
+	lastTick := int64(-1)
+	for k, t := range times {
+		var changes []string
+		for i, name := range names {
+			changed := k == 0
+			if k > 0 && digital {
+				changed = levels[i][k] != levels[i][k-1]
+			} else if k > 0 {
+				changed = results[name][k] != results[name][k-1]
+			}
+			if changed {
+				changes = append(changes, value(i, k))
+			}
+		}
+		if len(changes) == 0 {
+			continue
+		}
+
+		// Timepoints closer than timescale share tick
+		tick := max(int64(math.Round(t/vcdTimescale)), lastTick)
+		if tick != lastTick {
+			fmt.Fprintf(w, "#%d\n", tick)
+			lastTick = tick
+		}
+		if k == 0 {
+			fmt.Fprintf(w, "$dumpvars\n%s\n$end\n", strings.Join(changes, "\n"))
+			continue
+		}
+		fmt.Fprintln(w, strings.Join(changes, "\n"))
+	}
+	_, err = fmt.Fprintf(w, "#%d\n", max(int64(math.Round(times[len(times)-1]/vcdTimescale)), lastTick))
+	return err
+}
+
+// writeWaveJSON - WaveDrom WaveJSON of digitized transient results. Each timepoint is one period of wave,
+// so results on TSTEP grid give uniform time axis
+func writeWaveJSON(w io.Writer, results map[string][]float64, d digitizer) error {
+	names, err := waveNames(results)
+	if err != nil {
+		return err
+	}
+
+	type signal struct {
+		Name string `json:"name"`
+		Wave string `json:"wave"`
+	}
+	doc := struct {
+		Signal []signal `json:"signal"`
+	}{}
+
+	for _, name := range names {
+		var wave strings.Builder
+		levels := d.levelsOf(results[name])
+		for k, level := range levels {
+			switch {
+			case k > 0 && level == levels[k-1]:
+				wave.WriteByte('.')
+			case level:
+				wave.WriteByte('1')
+			default:
+				wave.WriteByte('0')
+			}
+		}
+		doc.Signal = append(doc.Signal, signal{Name: name, Wave: wave.String()})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
@@ -2,4 +2,7 @@ module github.com/edp1096/toy-spice
 
 go 1.24.0
 
-require github.com/edp1096/sparse v0.0.0-20250223074749-e82e4651f4d6
+require (
+	github.com/edp1096/sparse v0.0.0-20250223074749-e82e4651f4d6
+	golang.org/x/image v0.25.0
+)
@@ -3,6 +3,8 @@ package analysis
 import (
 	"fmt"
 	"math"
+	"strings"
+	"time"
 
 	"github.com/edp1096/toy-spice/pkg/circuit"
 	"github.com/edp1096/toy-spice/pkg/device"
@@ -14,8 +16,9 @@ type ACAnalysis struct {
 	startFreq   float64
 	stopFreq    float64
 	numPoints   int
-	pointsType  string // "DEC", "OCT", "LIN"
+	pointsType  string // "DEC", "OCT", "LIN", "LIST"
 	frequencies []float64
+	bias        map[string]float64 // Node voltages given by user instead of OP
 }
 
 func NewAC(fStart, fStop float64, nPoints int, pType string) *ACAnalysis {
@@ -29,18 +32,43 @@ func NewAC(fStart, fStop float64, nPoints int, pType string) *ACAnalysis {
 	}
 }
 
+// NewACList - AC analysis at given frequencies. eg. .ac list 50 60 400
+func NewACList(freqs []float64) *ACAnalysis {
+	ac := NewAC(0, 0, len(freqs), "LIST")
+	ac.frequencies = append([]float64(nil), freqs...)
+	if len(freqs) > 0 {
+		ac.startFreq, ac.stopFreq = freqs[0], freqs[len(freqs)-1]
+	}
+	return ac
+}
+
 func (ac *ACAnalysis) Setup(ckt *circuit.Circuit) error {
 	var err error
 
 	ac.Circuit = ckt
 
-	err = ac.op.Setup(ckt)
+	err = checkAC(ckt.GetDevices())
 	if err != nil {
-		return fmt.Errorf("operating point setup error: %v", err)
+		return err
 	}
-	err = ac.op.Execute()
-	if err != nil {
-		return fmt.Errorf("operating point analysis error: %v", err)
+
+	// Operating point is only needed for small signal parameters of nonlinear devices
+	switch {
+	case ac.bias != nil:
+		err = ac.loadBias()
+		if err != nil {
+			return fmt.Errorf("loading bias point: %v", err)
+		}
+	case isLinear(ckt):
+	default:
+		err = ac.op.Setup(ckt)
+		if err != nil {
+			return fmt.Errorf("operating point setup error: %v", err)
+		}
+		err = ac.op.Execute()
+		if err != nil {
+			return fmt.Errorf("operating point analysis error: %v", err)
+		}
 	}
 
 	ac.generateFrequencyPoints()
@@ -48,16 +76,90 @@ func (ac *ACAnalysis) Setup(ckt *circuit.Circuit) error {
 	return nil
 }
 
+// Stats - Solver effort including operating point
+func (ac *ACAnalysis) Stats() Stats {
+	return ac.stats.merge(ac.op.Stats())
+}
+
+// SetBias - Node voltages of bias point. Setup linearizes devices there instead of running OP.
+// Nodes not given are at 0V.
+func (ac *ACAnalysis) SetBias(voltages map[string]float64) {
+	ac.bias = voltages
+}
+
+// SetBiasPoint - Operating point starts from stored bias point instead of initial estimate
+func (ac *ACAnalysis) SetBiasPoint(bp *circuit.BiasPoint) {
+	ac.op.SetBiasPoint(bp)
+}
+
+func (ac *ACAnalysis) operatingPoint() *OperatingPoint { return ac.op }
+
+// loadBias - Evaluate small signal parameters of nonlinear devices at bias voltages
+func (ac *ACAnalysis) loadBias() error {
+	ckt := ac.Circuit
+	mat := ckt.GetMatrix()
+
+	solution := make([]float64, mat.Size+1)
+	nodeMap := ckt.GetNodeMap()
+	for name, voltage := range ac.bias {
+		nodeIdx, ok := nodeMap[name]
+		if !ok {
+			return fmt.Errorf("unknown bias node: %s", name)
+		}
+		if nodeIdx > 0 {
+			solution[nodeIdx] = voltage
+		}
+	}
+
+	err := ckt.UpdateNonlinearVoltages(solution)
+	if err != nil {
+		return fmt.Errorf("updating nonlinear voltages: %v", err)
+	}
+
+	// Stamp once in DC mode without solving, which stores conductances of the bias point
+	ckt.Status = &device.CircuitStatus{
+		Mode: device.OperatingPointAnalysis,
+		Temp: ckt.Temp,
+		Gmin: ac.convergence.gmin,
+	}
+	mat.Clear()
+	return ckt.Stamp(ckt.Status)
+}
+
+// checkAC - Every device stamps its small signal model, device without StampAC would drop out of AC matrix
+func checkAC(devices []device.Device) error {
+	for _, dev := range devices {
+		if _, ok := dev.(device.ACElement); !ok {
+			return fmt.Errorf("device %s (%s) does not support AC analysis", dev.GetName(), dev.GetType())
+		}
+	}
+	return nil
+}
+
+// isLinear - Circuit without devices which depend on bias point
+func isLinear(ckt *circuit.Circuit) bool {
+	for _, dev := range ckt.GetDevices() {
+		if _, ok := dev.(device.NonLinear); ok {
+			return false
+		}
+		if _, ok := dev.(device.NonLinearStorage); ok {
+			return false
+		}
+	}
+	return true
+}
+
 func (ac *ACAnalysis) Execute() error {
 	if ac.Circuit == nil {
 		return fmt.Errorf("circuit not set")
 	}
+	defer ac.stats.addPhase("ac sweep", time.Now())
 
 	for _, freq := range ac.frequencies {
 		ac.Circuit.Status = &device.CircuitStatus{
 			Frequency: freq,
 			Mode:      device.ACAnalysis,
-			Temp:      300.15, // 27 = 300.15K
+			Temp:      ac.Circuit.Temp,
 		}
 
 		mat := ac.Circuit.GetMatrix()
@@ -67,60 +169,144 @@ func (ac *ACAnalysis) Execute() error {
 			return fmt.Errorf("stamping error at f=%g: %v", freq, err)
 		}
 
-		err = mat.Solve()
+		err = ac.solve(mat)
 		if err != nil {
-			return fmt.Errorf("matrix solve error at f=%g: %v", freq, err)
+			return fmt.Errorf("matrix solve error at f=%g: %v", freq, ac.Circuit.DiagnoseSingular(err))
 		}
 
 		solution := make(map[string]complex128)
+		voltages := make([]complex128, mat.Size+1)
+		for i := 1; i <= mat.Size; i++ {
+			real, imag := mat.GetComplexSolution(i)
+			voltages[i] = complex(real, imag)
+		}
 
 		// Node voltage
 		for name, nodeIdx := range ac.Circuit.GetNodeMap() {
 			if nodeIdx > 0 {
-				real, imag := mat.GetComplexSolution(nodeIdx)
-				solution[fmt.Sprintf("V(%s)", name)] = complex(real, imag)
+				solution[fmt.Sprintf("V(%s)", name)] = voltages[nodeIdx]
 			}
 		}
 
 		// Branch current
-		for _, dev := range ac.Circuit.GetDevices() {
-			if v, ok := dev.(*device.VoltageSource); ok {
-				bIdx := v.BranchIndex()
-				real, imag := mat.GetComplexSolution(bIdx)
-				solution[fmt.Sprintf("I(%s)", dev.GetName())] = complex(real, imag)
-			}
+		for name, current := range ac.Circuit.GetBranchCurrentsAC(voltages) {
+			solution[name] = current
+		}
+
+		// Device current
+		for name, current := range ac.Circuit.GetDeviceCurrentsAC(voltages) {
+			solution[name] = current
 		}
 
 		ac.StoreACResult(freq, solution)
+		ac.reportProgress(float64(ac.stats.Points), float64(len(ac.frequencies)))
 	}
 
+	deriveACResults(ac.results, ac.results["FREQ"])
+
 	return nil
 }
 
-func (ac *ACAnalysis) generateFrequencyPoints() {
-	ac.frequencies = make([]float64, ac.numPoints)
+// deriveACResults - Add dB magnitude, unwrapped phase and group delay of every _MAG/_PHASE pair.
+// Keys are name_DB (20log10|x|), name_PHASE_UNWRAPPED (degree) and name_GROUP_DELAY (-dφ/dω, second).
+func deriveACResults(results map[string][]float64, freqs []float64) {
+	var names []string
+	for key := range results {
+		if name, ok := strings.CutSuffix(key, "_MAG"); ok {
+			names = append(names, name)
+		}
+	}
 
-	switch ac.pointsType {
-	case "DEC": // Decade
-		logStart := math.Log10(ac.startFreq)
-		logStop := math.Log10(ac.stopFreq)
-		step := (logStop - logStart) / float64(ac.numPoints-1)
-		for i := range ac.numPoints {
-			ac.frequencies[i] = math.Pow(10, logStart+float64(i)*step)
+	for _, name := range names {
+		mag := results[name+"_MAG"]
+		phase, ok := results[name+"_PHASE"]
+		if !ok || len(phase) != len(mag) {
+			continue
 		}
 
-	case "OCT": // Octave
-		logStart := math.Log2(ac.startFreq)
-		logStop := math.Log2(ac.stopFreq)
-		step := (logStop - logStart) / float64(ac.numPoints-1)
-		for i := range ac.numPoints {
-			ac.frequencies[i] = math.Pow(2, logStart+float64(i)*step)
+		db := make([]float64, len(mag))
+		for i, m := range mag {
+			db[i] = 20.0 * math.Log10(m)
+		}
+
+		unwrapped := unwrapPhase(phase)
+
+		results[name+"_DB"] = db
+		results[name+"_PHASE_UNWRAPPED"] = unwrapped
+		results[name+"_GROUP_DELAY"] = groupDelay(unwrapped, freqs)
+	}
+}
+
+// unwrapPhase - Remove 360 degree jumps between adjacent points
+func unwrapPhase(phase []float64) []float64 {
+	unwrapped := make([]float64, len(phase))
+	offset := 0.0
+	for i, p := range phase {
+		if i > 0 {
+			diff := p + offset - unwrapped[i-1]
+			offset -= 360.0 * math.Round(diff/360.0)
+		}
+		unwrapped[i] = p + offset
+	}
+	return unwrapped
+}
+
+// groupDelay - -dφ/dω by central difference, one sided at sweep ends
+func groupDelay(phaseDeg, freqs []float64) []float64 {
+	n := len(phaseDeg)
+	delay := make([]float64, n)
+	if n < 2 || len(freqs) != n {
+		return delay
+	}
+
+	for i := range delay {
+		lo, hi := max(i-1, 0), min(i+1, n-1)
+		dw := 2.0 * math.Pi * (freqs[hi] - freqs[lo])
+		if dw == 0 {
+			continue
 		}
+		dphi := (phaseDeg[hi] - phaseDeg[lo]) * math.Pi / 180.0
+		delay[i] = -dphi / dw
+	}
+	return delay
+}
+
+func (ac *ACAnalysis) generateFrequencyPoints() {
+	if ac.pointsType == "LIST" {
+		return
+	}
 
-	case "LIN": // Linear
+	// Single point sweep is at start frequency
+	if ac.startFreq == ac.stopFreq || (ac.pointsType == "LIN" && ac.numPoints <= 1) {
+		ac.frequencies = []float64{ac.startFreq}
+		return
+	}
+
+	switch ac.pointsType {
+	case "DEC": // numPoints per decade from start up to stop
+		ac.frequencies = logPoints(ac.startFreq, ac.stopFreq, math.Pow(10, 1/float64(ac.numPoints)))
+
+	case "OCT": // numPoints per octave from start up to stop
+		ac.frequencies = logPoints(ac.startFreq, ac.stopFreq, math.Pow(2, 1/float64(ac.numPoints)))
+
+	case "LIN": // numPoints in total
+		ac.frequencies = make([]float64, ac.numPoints)
 		step := (ac.stopFreq - ac.startFreq) / float64(ac.numPoints-1)
 		for i := range ac.numPoints {
 			ac.frequencies[i] = ac.startFreq + float64(i)*step
 		}
 	}
 }
+
+// logPoints - Frequencies start*ratio^i not above stop, like SPICE. Stop is included when on the grid
+func logPoints(start, stop, ratio float64) []float64 {
+	count := 1
+	if start > 0 && stop > start {
+		count = int(math.Floor(math.Log(stop/start)/math.Log(ratio)+1e-9)) + 1
+	}
+	frequencies := make([]float64, count)
+	for i := range count {
+		frequencies[i] = start * math.Pow(ratio, float64(i))
+	}
+	return frequencies
+}
@@ -1,10 +1,12 @@
 package analysis
 
 import (
+	"fmt"
 	"math"
 	"math/cmplx"
 
 	"github.com/edp1096/toy-spice/pkg/circuit"
+	"github.com/edp1096/toy-spice/pkg/device"
 	"github.com/edp1096/toy-spice/pkg/util"
 )
 
@@ -23,22 +25,33 @@ type Analysis interface {
 
 type BaseAnalysis struct {
 	Circuit     *circuit.Circuit
-	results     map[string][]float64 // key: variable name, value: result by time
+	results     map[string][]float64    // key: variable name, value: result by time
+	complexAC   map[string][]complex128 // key: variable name, value: phasor by frequency
 	convergence struct {
 		maxIter int
 		abstol  float64
 		reltol  float64
 		gmin    float64
+
+		maxVoltage float64 // Node voltage above this is divergence
+		maxCurrent float64 // Branch current above this is divergence
 	}
+	stats    Stats
+	progress ProgressFunc
 }
 
 func NewBaseAnalysis() *BaseAnalysis {
-	ba := &BaseAnalysis{results: make(map[string][]float64)}
+	ba := &BaseAnalysis{
+		results:   make(map[string][]float64),
+		complexAC: make(map[string][]complex128),
+	}
 
 	ba.convergence.maxIter = 100
 	ba.convergence.abstol = 1e-12
 	ba.convergence.reltol = 1e-6
 	ba.convergence.gmin = 1e-12
+	ba.convergence.maxVoltage = 1e9
+	ba.convergence.maxCurrent = 1e9
 
 	return ba
 }
@@ -58,18 +71,73 @@ func (a *BaseAnalysis) CheckConvergence(oldSol, newSol []float64) bool {
 	return true
 }
 
-func (a *BaseAnalysis) StoreTimeResult(time float64, solution map[string]float64) {
-	// Ignore same time
-	if len(a.results["TIME"]) > 0 {
-		lastTime := a.results["TIME"][len(a.results["TIME"])-1]
-		if time == lastTime {
-			return
+// residualConverged - KCL error of every node at solution within reltol of its current terms plus abstol.
+// Successive solutions agreeing alone passes on slow drift
+func (a *BaseAnalysis) residualConverged(solution []float64, status *device.CircuitStatus) (bool, error) {
+	residual, scale, err := a.Circuit.Residual(solution, status)
+	if err != nil {
+		return false, fmt.Errorf("residual: %v", err)
+	}
+	for i := 1; i < len(residual); i++ {
+		if math.Abs(residual[i]) > a.convergence.reltol*scale[i]+a.convergence.abstol {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// SetLimits - Largest node voltage and branch current accepted in Newton iteration
+func (a *BaseAnalysis) SetLimits(maxVoltage, maxCurrent float64) {
+	a.convergence.maxVoltage = maxVoltage
+	a.convergence.maxCurrent = maxCurrent
+}
+
+// checkDivergence - Error naming worst unknown when solution is not finite or over limits
+func (a *BaseAnalysis) checkDivergence(solution []float64) error {
+	numNodes := a.Circuit.GetNumNodes()
+
+	worst, worstRatio := 0, 1.0
+	for i := 1; i < len(solution); i++ {
+		limit := a.convergence.maxVoltage
+		if i > numNodes {
+			limit = a.convergence.maxCurrent
+		}
+
+		ratio := math.Abs(solution[i]) / limit
+		if math.IsNaN(solution[i]) {
+			ratio = math.Inf(1)
 		}
-		// Compare rounded string. 1.999999e-05 == 2.000000e-05
-		if util.FormatValueFactor(time, "s") == util.FormatValueFactor(lastTime, "s") {
-			return
+		if ratio > worstRatio {
+			worst, worstRatio = i, ratio
 		}
 	}
+	if worst == 0 {
+		return nil
+	}
+
+	name := a.Circuit.UnknownName(worst)
+	if math.IsNaN(solution[worst]) || math.IsInf(solution[worst], 0) {
+		return fmt.Errorf("solution diverged: %s is %g", name, solution[worst])
+	}
+	limit := a.convergence.maxVoltage
+	if worst > numNodes {
+		limit = a.convergence.maxCurrent
+	}
+	return fmt.Errorf("solution diverged: %s=%g exceeds limit %g", name, solution[worst], limit)
+}
+
+// sameTime - Timepoints equal by value or rounded string. 1.999999e-05 == 2.000000e-05.
+// Default format, not of SetNumberFormat
+func sameTime(a, b float64) bool {
+	var f util.NumberFormat
+	return a == b || f.Format(a, "s") == f.Format(b, "s")
+}
+
+func (a *BaseAnalysis) StoreTimeResult(time float64, solution map[string]float64) {
+	// Ignore same time
+	if len(a.results["TIME"]) > 0 && sameTime(time, a.results["TIME"][len(a.results["TIME"])-1]) {
+		return
+	}
 
 	if _, exists := a.results["TIME"]; !exists {
 		a.results["TIME"] = make([]float64, 0)
@@ -85,13 +153,17 @@ func (a *BaseAnalysis) StoreTimeResult(time float64, solution map[string]float64
 }
 
 func (a *BaseAnalysis) StoreACResult(freq float64, solution map[string]complex128) {
+	a.stats.Points++
 	// Frequency
 	if _, exists := a.results["FREQ"]; !exists {
 		a.results["FREQ"] = make([]float64, 0)
 	}
 	a.results["FREQ"] = append(a.results["FREQ"], freq)
+	a.complexAC["FREQ"] = append(a.complexAC["FREQ"], complex(freq, 0))
 
 	for name, value := range solution {
+		a.complexAC[name] = append(a.complexAC[name], value)
+
 		// Magnitude
 		magName := name + "_MAG"
 		if _, exists := a.results[magName]; !exists {
@@ -113,3 +185,8 @@ func (a *BaseAnalysis) StoreACResult(freq float64, solution map[string]complex12
 func (a *BaseAnalysis) GetResults() map[string][]float64 {
 	return a.results
 }
+
+// GetComplexResults - AC phasors by variable name. FREQ holds frequencies as real parts
+func (a *BaseAnalysis) GetComplexResults() map[string][]complex128 {
+	return a.complexAC
+}
@@ -0,0 +1,69 @@
+package analysis
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/edp1096/toy-spice/pkg/netlist"
+)
+
+// rcLadder - Transient of RC ladder with n sections of 1k and 1n driven by pulse
+func rcLadder(n int) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "* RC ladder %d sections\n.tran 10u 1m\n", n)
+	sb.WriteString("vin n0 0 PULSE(0 1 0 10u 10u 500u 1m)\n")
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&sb, "r%d n%d n%d 1k\n", i, i-1, i)
+		fmt.Fprintf(&sb, "c%d n%d 0 1n\n", i, i)
+	}
+	return sb.String()
+}
+
+// diodeLadder - Operating point of n diode-resistor sections
+func diodeLadder(n int) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "* Diode ladder %d sections\n.op\n", n)
+	sb.WriteString(".model dmod D(is=1e-14 n=1)\n")
+	sb.WriteString("vin n0 0 DC 5\n")
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&sb, "r%d n%d n%d 1k\n", i, i-1, i)
+		fmt.Fprintf(&sb, "d%d n%d 0 dmod\n", i, i)
+	}
+	return sb.String()
+}
+
+// acFilter - AC sweep of n stage RLC low pass filter
+func acFilter(n int) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "* RLC low pass %d stages\n.ac dec 100 1k 100meg\n", n)
+	sb.WriteString("vin n0 0 AC 1\n")
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&sb, "r%d n%d m%d 10\n", i, i-1, i)
+		fmt.Fprintf(&sb, "l%d m%d n%d 10u\n", i, i, i)
+		fmt.Fprintf(&sb, "c%d n%d 0 1n\n", i, i)
+	}
+	return sb.String()
+}
+
+// benchNetlist - Circuit setup and analysis of netlist by Run. Parsing is not measured
+func benchNetlist(b *testing.B, content string) {
+	ckt, err := netlist.Parse(content)
+	if err != nil {
+		b.Fatalf("parsing netlist: %v", err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		_, err = Run(ckt)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTransientRC1k(b *testing.B) { benchNetlist(b, rcLadder(1000)) }
+
+func BenchmarkOPDiodeLadder(b *testing.B) { benchNetlist(b, diodeLadder(100)) }
+
+func BenchmarkACFilter(b *testing.B) { benchNetlist(b, acFilter(50)) }
@@ -2,6 +2,8 @@ package analysis
 
 import (
 	"fmt"
+	"math"
+	"time"
 
 	"github.com/edp1096/toy-spice/pkg/circuit"
 	"github.com/edp1096/toy-spice/pkg/device"
@@ -17,36 +19,37 @@ type DCSweep struct {
 	origVals    []float64   // Original values of the sources
 }
 
+// NewDCSweep - Sweep of sources from start to stop by increment. Stop below start sweeps downward,
+// sign of increment is ignored. Parameters are validated by Setup
 func NewDCSweep(sources []string, starts, stops []float64, numSteps []float64) *DCSweep {
-	if len(sources) != len(starts) || len(sources) != len(stops) || len(sources) != len(numSteps) {
-		panic("inconsistent parameter lengths")
-	}
-
-	dc := &DCSweep{
+	return &DCSweep{
 		BaseAnalysis: *NewBaseAnalysis(),
 		sourceNames:  sources,
 		startVals:    starts,
 		stopVals:     stops,
 		increments:   numSteps,
-		sweepVals:    make([][]float64, len(sources)),
 		origVals:     make([]float64, len(sources)),
 	}
+}
+
+func (dc *DCSweep) Setup(ckt *circuit.Circuit) error {
+	dc.Circuit = ckt
+
+	if len(dc.sourceNames) != len(dc.startVals) || len(dc.sourceNames) != len(dc.stopVals) || len(dc.sourceNames) != len(dc.increments) {
+		return fmt.Errorf("inconsistent dc sweep parameters: %d sources, %d starts, %d stops, %d increments",
+			len(dc.sourceNames), len(dc.startVals), len(dc.stopVals), len(dc.increments))
+	}
 
 	// Generate sweep values for each source
-	for i := range sources {
-		sweep := make([]float64, 0)
-		for v := dc.startVals[i]; v <= dc.stopVals[i]; v += dc.increments[i] {
-			sweep = append(sweep, v)
+	dc.sweepVals = make([][]float64, len(dc.sourceNames))
+	for i, name := range dc.sourceNames {
+		sweep, err := sweepValues(dc.startVals[i], dc.stopVals[i], dc.increments[i])
+		if err != nil {
+			return fmt.Errorf("sweep of %s: %v", name, err)
 		}
 		dc.sweepVals[i] = sweep
 	}
 
-	return dc
-}
-
-func (dc *DCSweep) Setup(ckt *circuit.Circuit) error {
-	dc.Circuit = ckt
-
 	// Store original source values
 	for i, name := range dc.sourceNames {
 		found := false
@@ -71,6 +74,7 @@ func (dc *DCSweep) Execute() error {
 	if dc.Circuit == nil {
 		return fmt.Errorf("circuit not set")
 	}
+	defer dc.stats.addPhase("dc sweep", time.Now())
 
 	// Single source sweep
 	if len(dc.sourceNames) == 1 {
@@ -112,7 +116,7 @@ func (dc *DCSweep) singleSweep() error {
 		// Run operating point analysis
 		status := &device.CircuitStatus{
 			Mode: device.OperatingPointAnalysis,
-			Temp: 300.15,
+			Temp: dc.Circuit.Temp,
 			Gmin: dc.convergence.gmin,
 		}
 
@@ -124,7 +128,7 @@ func (dc *DCSweep) singleSweep() error {
 			return fmt.Errorf("stamping error at %s=%g: %v", sourceName, val, err)
 		}
 
-		err = dc.doNRiter(0, dc.convergence.maxIter)
+		err = dc.solvePoint()
 		if err != nil {
 			return fmt.Errorf("convergence error at %s=%g: %v", sourceName, val, err)
 		}
@@ -132,6 +136,7 @@ func (dc *DCSweep) singleSweep() error {
 		// Store results
 		solution := dc.Circuit.GetSolution()
 		dc.StoreResult(val, solution)
+		dc.reportProgress(float64(dc.stats.Points), float64(len(dc.sweepVals[0])))
 	}
 
 	source.SetValue(dc.origVals[0])
@@ -139,20 +144,67 @@ func (dc *DCSweep) singleSweep() error {
 	return nil
 }
 
+// Upper limit of points of one swept source, against increment typos. eg. 1p instead of 1m
+const maxSweepPoints = 10000000
+
+// sweepValues - start, start+inc, ... to stop. Increment is negative when stop is below start. Values are
+// computed from start without accumulated rounding, last value within 1e-6 increment of stop is stop
+func sweepValues(start, stop, inc float64) ([]float64, error) {
+	if inc == 0 || math.IsNaN(inc) || math.IsInf(inc, 0) {
+		return nil, fmt.Errorf("invalid increment %g", inc)
+	}
+	if (stop-start)*inc < 0 {
+		return nil, fmt.Errorf("increment %g does not step from %g to %g", inc, start, stop)
+	}
+	step := inc
+	points := math.Floor((stop-start)/step + 1e-6)
+	if points > maxSweepPoints {
+		return nil, fmt.Errorf("increment %g gives more than %d points", inc, maxSweepPoints)
+	}
+	n := int(points)
+	values := make([]float64, n+1)
+	for k := range values {
+		values[k] = start + float64(k)*step
+	}
+	if math.Abs(values[n]-stop) <= 1e-6*math.Abs(step) {
+		values[n] = stop
+	}
+	return values, nil
+}
+
+// solvePoint - Newton iteration at sweep point, Gmin stepping when it fails or diverges
+func (dc *DCSweep) solvePoint() error {
+	err := dc.doNRiter(0, dc.convergence.maxIter)
+	if err == nil {
+		return nil
+	}
+
+	for gmin := 1e-3; gmin > dc.convergence.gmin; gmin /= 10 {
+		if dc.doNRiter(gmin, dc.convergence.maxIter) != nil {
+			return err
+		}
+	}
+	return dc.doNRiter(0, dc.convergence.maxIter)
+}
+
 func (dc *DCSweep) doNRiter(gmin float64, maxIter int) error {
 	var err error
 
 	ckt := dc.Circuit
 	mat := ckt.GetMatrix()
 	var oldSolution []float64
+	ckt.InvalidateLinearStamps() // Swept source changed
 
 	cktStatus := &device.CircuitStatus{
-		Mode: device.OperatingPointAnalysis,
-		Temp: 300.15,
-		Gmin: gmin,
+		Mode:   device.OperatingPointAnalysis,
+		Temp:   ckt.Temp,
+		Gmin:   gmin,
+		Bypass: !ckt.NoBypass,
 	}
+	ckt.Status = cktStatus // Probes of results
 
 	for iter := range maxIter {
+		dc.stats.Iterations++
 		mat.Clear()
 		if iter > 0 {
 			err := ckt.UpdateNonlinearVoltages(oldSolution)
@@ -161,20 +213,31 @@ func (dc *DCSweep) doNRiter(gmin float64, maxIter int) error {
 			}
 		}
 
-		err = ckt.Stamp(cktStatus)
+		err = ckt.StampIteration(cktStatus)
 		if err != nil {
 			return fmt.Errorf("stamping error: %v", err)
 		}
 
 		mat.LoadGmin(gmin)
-		err := mat.Solve()
+		err := dc.solve(mat)
 		if err != nil {
-			return fmt.Errorf("matrix solve error: %v", err)
+			return fmt.Errorf("matrix solve error: %v", ckt.DiagnoseSingular(err))
 		}
 
 		solution := mat.Solution()
+		err = dc.checkDivergence(solution)
+		if err != nil {
+			return err
+		}
+
 		if iter > 0 && dc.CheckConvergence(oldSolution, solution) {
-			return nil
+			converged, err := dc.residualConverged(solution, cktStatus)
+			if err != nil {
+				return err
+			}
+			if converged {
+				return nil
+			}
 		}
 
 		if oldSolution == nil {
@@ -187,11 +250,13 @@ func (dc *DCSweep) doNRiter(gmin float64, maxIter int) error {
 }
 
 func (dc *DCSweep) StoreResult(sweepVal float64, solution map[string]float64) {
+	dc.stats.Points++
 	// Store sweep value
 	if _, exists := dc.results["SWEEP1"]; !exists {
 		dc.results["SWEEP1"] = make([]float64, 0)
 	}
 	dc.results["SWEEP1"] = append(dc.results["SWEEP1"], sweepVal)
+	dc.storeSweepValue(0, sweepVal)
 
 	// Store node voltages and branch currents
 	for name, value := range solution {
@@ -237,7 +302,7 @@ func (dc *DCSweep) nestedSweep() error {
 			// Run operating point analysis
 			status := &device.CircuitStatus{
 				Mode: device.OperatingPointAnalysis,
-				Temp: 300.15,
+				Temp: dc.Circuit.Temp,
 				Gmin: dc.convergence.gmin,
 			}
 
@@ -250,7 +315,7 @@ func (dc *DCSweep) nestedSweep() error {
 					source1Name, val1, source2Name, val2, err)
 			}
 
-			err = dc.doNRiter(0, dc.convergence.maxIter)
+			err = dc.solvePoint()
 			if err != nil {
 				return fmt.Errorf("convergence error at %s=%g, %s=%g: %v",
 					source1Name, val1, source2Name, val2, err)
@@ -259,6 +324,7 @@ func (dc *DCSweep) nestedSweep() error {
 			// Store results with both sweep values
 			solution := dc.Circuit.GetSolution()
 			dc.StoreNestedResult(val1, val2, solution)
+			dc.reportProgress(float64(dc.stats.Points), float64(len(dc.sweepVals[0])*len(dc.sweepVals[1])))
 		}
 	}
 
@@ -270,6 +336,7 @@ func (dc *DCSweep) nestedSweep() error {
 }
 
 func (dc *DCSweep) StoreNestedResult(val1, val2 float64, solution map[string]float64) {
+	dc.stats.Points++
 	// Store sweep values
 	if _, exists := dc.results["SWEEP1"]; !exists {
 		dc.results["SWEEP1"] = make([]float64, 0)
@@ -277,6 +344,8 @@ func (dc *DCSweep) StoreNestedResult(val1, val2 float64, solution map[string]flo
 	}
 	dc.results["SWEEP1"] = append(dc.results["SWEEP1"], val1)
 	dc.results["SWEEP2"] = append(dc.results["SWEEP2"], val2)
+	dc.storeSweepValue(0, val1)
+	dc.storeSweepValue(1, val2)
 
 	// Store all node voltages and branch currents
 	for name, value := range solution {
@@ -286,3 +355,9 @@ func (dc *DCSweep) StoreNestedResult(val1, val2 float64, solution map[string]flo
 		dc.results[name] = append(dc.results[name], value)
 	}
 }
+
+// storeSweepValue - Value of i-th swept source by its name. eg. SWEEP(Vin), along with SWEEP1 and SWEEP2
+func (dc *DCSweep) storeSweepValue(i int, value float64) {
+	name := SweepName(dc.sourceNames[i])
+	dc.results[name] = append(dc.results[name], value)
+}
@@ -0,0 +1,73 @@
+package analysis
+
+import "math"
+
+// decimator - Min/max envelope of timepoints in buckets of step from start.
+// Bucket of several timepoints is stored as two points at its first and last time. Each variable takes
+// its minimum and maximum of bucket in order of occurrence, so peaks are kept with time error below step.
+type decimator struct {
+	start, step float64
+
+	bucket     int
+	count      int // Timepoints in present bucket
+	firstTime  float64
+	lastTime   float64
+	first      map[string]float64 // Single timepoint of bucket
+	lo, hi     map[string]float64
+	loAt, hiAt map[string]float64
+}
+
+func newDecimator(start, step float64) *decimator {
+	return &decimator{start: start, step: step}
+}
+
+// push - Timepoint in order of time. Finished buckets are stored
+func (d *decimator) push(time float64, solution map[string]float64, store func(float64, map[string]float64)) {
+	bucket := int(math.Floor((time - d.start) / d.step))
+	if d.count > 0 && bucket != d.bucket {
+		d.flush(store)
+	}
+	if d.count == 0 {
+		d.bucket, d.firstTime, d.first = bucket, time, solution
+		d.lo, d.hi = make(map[string]float64, len(solution)), make(map[string]float64, len(solution))
+		d.loAt, d.hiAt = make(map[string]float64, len(solution)), make(map[string]float64, len(solution))
+		for name, value := range solution {
+			d.lo[name], d.hi[name] = value, value
+			d.loAt[name], d.hiAt[name] = time, time
+		}
+	}
+
+	for name, value := range solution {
+		if value < d.lo[name] {
+			d.lo[name], d.loAt[name] = value, time
+		}
+		if value > d.hi[name] {
+			d.hi[name], d.hiAt[name] = value, time
+		}
+	}
+	d.lastTime = time
+	d.count++
+}
+
+// flush - Store present bucket
+func (d *decimator) flush(store func(float64, map[string]float64)) {
+	switch {
+	case d.count == 0:
+		return
+	case d.count == 1:
+		store(d.firstTime, d.first)
+	default:
+		early := make(map[string]float64, len(d.lo))
+		late := make(map[string]float64, len(d.lo))
+		for name := range d.lo {
+			if d.loAt[name] <= d.hiAt[name] {
+				early[name], late[name] = d.lo[name], d.hi[name]
+			} else {
+				early[name], late[name] = d.hi[name], d.lo[name]
+			}
+		}
+		store(d.firstTime, early)
+		store(d.lastTime, late)
+	}
+	d.count = 0
+}
@@ -0,0 +1,199 @@
+package analysis
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/edp1096/toy-spice/pkg/circuit"
+	"github.com/edp1096/toy-spice/pkg/device"
+)
+
+// DistoAnalysis - Small signal distortion of SPICE3 .disto over frequency sweep of f1.
+// Fundamental and harmonics at 2f1, 3f1 (HD2, HD3), and with second tone f2 = f2overf1*f1 intermodulation
+// products at f1+f2, f1-f2, 2f1-f2 (SIM2, DIM2, DIM3). AC sources drive both tones at their AC magnitude.
+// Products are solved at their frequencies with nonlinear currents of device Taylor terms around OP,
+// resistive nonlinearities of devices implementing device.Distortion only.
+type DistoAnalysis struct {
+	ACAnalysis
+	f2OverF1  float64 // 0 is harmonics only
+	nonlinear []device.Distortion
+}
+
+// Distortion products by result suffix. eg. V(2)_HD2_MAG, V(2)_HD2_PHASE
+var distoProducts = []string{"_HD2", "_HD3", "_SIM2", "_DIM2", "_DIM3"}
+
+func NewDisto(fStart, fStop float64, nPoints int, pType string, f2OverF1 float64) *DistoAnalysis {
+	return &DistoAnalysis{ACAnalysis: *NewAC(fStart, fStop, nPoints, pType), f2OverF1: f2OverF1}
+}
+
+// NewDistoList - Harmonic distortion at given frequencies
+func NewDistoList(freqs []float64) *DistoAnalysis {
+	return &DistoAnalysis{ACAnalysis: *NewACList(freqs)}
+}
+
+func (d *DistoAnalysis) Setup(ckt *circuit.Circuit) error {
+	if d.f2OverF1 < 0 || d.f2OverF1 >= 1 {
+		return fmt.Errorf("f2overf1 %g must be between 0 and 1", d.f2OverF1)
+	}
+
+	d.nonlinear = nil
+	for _, dev := range ckt.GetDevices() {
+		if nl, ok := dev.(device.Distortion); ok {
+			d.nonlinear = append(d.nonlinear, nl)
+		}
+	}
+
+	return d.ACAnalysis.Setup(ckt)
+}
+
+func (d *DistoAnalysis) Execute() error {
+	if d.Circuit == nil {
+		return fmt.Errorf("circuit not set")
+	}
+	defer d.stats.addPhase("distortion", time.Now())
+
+	for _, f1 := range d.frequencies {
+		va, err := d.solveAt(f1, nil)
+		if err != nil {
+			return err
+		}
+
+		// Harmonics. v^2 has V1^2/2 at 2f1, v^3 has V1^3/4 at 3f1
+		v2, err := d.solveAt(2*f1, scaled(d.quadratic(va, va), 0.5))
+		if err != nil {
+			return err
+		}
+		v3, err := d.solveAt(3*f1, added(d.quadratic(va, v2), scaled(d.cubic(va, va, va), 0.25)))
+		if err != nil {
+			return err
+		}
+		products := map[string][]complex128{"": va, "_HD2": v2, "_HD3": v3}
+
+		// Intermodulation of f1 and f2
+		if d.f2OverF1 > 0 {
+			f2 := d.f2OverF1 * f1
+			vb, err := d.solveAt(f2, nil)
+			if err != nil {
+				return err
+			}
+			vbConj := conjugated(vb)
+
+			sum, err := d.solveAt(f1+f2, d.quadratic(va, vb))
+			if err != nil {
+				return err
+			}
+			diff, err := d.solveAt(f1-f2, d.quadratic(va, vbConj))
+			if err != nil {
+				return err
+			}
+			dim3, err := d.solveAt(2*f1-f2, added(d.quadratic(va, diff), d.quadratic(v2, vbConj), scaled(d.cubic(va, va, vbConj), 0.75)))
+			if err != nil {
+				return err
+			}
+			products["_SIM2"], products["_DIM2"], products["_DIM3"] = sum, diff, dim3
+		}
+
+		solution := make(map[string]complex128)
+		for name, nodeIdx := range d.Circuit.GetNodeMap() {
+			if nodeIdx <= 0 {
+				continue
+			}
+			for suffix, voltages := range products {
+				solution[fmt.Sprintf("V(%s)%s", name, suffix)] = voltages[nodeIdx]
+			}
+		}
+		d.StoreACResult(f1, solution)
+		d.reportProgress(float64(d.stats.Points), float64(len(d.frequencies)))
+	}
+
+	return nil
+}
+
+// solveAt - Phasors of circuit unknowns at freq. Driven by AC sources when currents is nil,
+// otherwise by nonlinear currents leaving nodes only
+func (d *DistoAnalysis) solveAt(freq float64, currents []complex128) ([]complex128, error) {
+	ckt := d.Circuit
+	ckt.Status = &device.CircuitStatus{
+		Frequency: freq,
+		Mode:      device.ACAnalysis,
+		Temp:      ckt.Temp,
+	}
+
+	mat := ckt.GetMatrix()
+	mat.Clear()
+	err := ckt.Stamp(ckt.Status)
+	if err != nil {
+		return nil, fmt.Errorf("stamping error at f=%g: %v", freq, err)
+	}
+	if currents != nil {
+		mat.ClearRHS()
+		for i, current := range currents {
+			if i > 0 && current != 0 {
+				mat.AddComplexRHS(i, -real(current), -imag(current))
+			}
+		}
+	}
+
+	err = d.solve(mat)
+	if err != nil {
+		return nil, fmt.Errorf("matrix solve error at f=%g: %v", freq, ckt.DiagnoseSingular(err))
+	}
+
+	voltages := make([]complex128, mat.Size+1)
+	for i := 1; i <= mat.Size; i++ {
+		re, im := mat.GetComplexSolution(i)
+		voltages[i] = complex(re, im)
+	}
+	return voltages, nil
+}
+
+// distortionStatus - Devices evaluate Taylor terms at operating point of DC mode
+func (d *DistoAnalysis) distortionStatus() *device.CircuitStatus {
+	return &device.CircuitStatus{Mode: device.OperatingPointAnalysis, Temp: d.Circuit.Temp, Gmin: d.convergence.gmin}
+}
+
+// quadratic - Second order nonlinear currents of all devices
+func (d *DistoAnalysis) quadratic(x, y []complex128) []complex128 {
+	currents := make([]complex128, len(x))
+	status := d.distortionStatus()
+	for _, nl := range d.nonlinear {
+		nl.Distortion2(currents, x, y, status)
+	}
+	return currents
+}
+
+// cubic - Third order nonlinear currents of all devices
+func (d *DistoAnalysis) cubic(x, y, z []complex128) []complex128 {
+	currents := make([]complex128, len(x))
+	status := d.distortionStatus()
+	for _, nl := range d.nonlinear {
+		nl.Distortion3(currents, x, y, z, status)
+	}
+	return currents
+}
+
+func scaled(v []complex128, factor float64) []complex128 {
+	out := make([]complex128, len(v))
+	for i, value := range v {
+		out[i] = value * complex(factor, 0)
+	}
+	return out
+}
+
+func added(vectors ...[]complex128) []complex128 {
+	out := make([]complex128, len(vectors[0]))
+	for _, v := range vectors {
+		for i, value := range v {
+			out[i] += value
+		}
+	}
+	return out
+}
+
+func conjugated(v []complex128) []complex128 {
+	out := make([]complex128, len(v))
+	for i, value := range v {
+		out[i] = complex(real(value), -imag(value))
+	}
+	return out
+}
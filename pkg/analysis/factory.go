@@ -0,0 +1,98 @@
+package analysis
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/edp1096/toy-spice/pkg/circuit"
+	"github.com/edp1096/toy-spice/pkg/netlist"
+)
+
+// FromNetlist - Analysis of parsed netlist with its parameters, .ic, .nodeset and .options applied.
+// Setup and Execute are left to caller
+func FromNetlist(ckt *netlist.NetlistData) (Analysis, error) {
+	var analyzer Analysis
+	switch ckt.Analysis {
+	case netlist.AnalysisOP:
+		analyzer = NewOP()
+	case netlist.AnalysisTRAN:
+		param := ckt.TranParam
+		tran := NewTransient(param.TStart, param.TStop, param.TStep, param.TMax, param.UIC)
+		tran.SetInitialConditions(ckt.InitialConditions)
+		tran.SetDecimation(int(ckt.Options["maxpoints"]), ckt.Options["savestep"])
+		if len(ckt.Saves) > 0 && !ckt.SaveAll {
+			// Outputs of .print and .measure are saved too
+			saves := append(slices.Clone(ckt.Saves), ckt.Outputs...)
+			for _, m := range ckt.Measures {
+				if m.Analysis == "tran" {
+					saves = append(saves, m.OutputVariables()...)
+				}
+			}
+			err := tran.SetSaves(saves)
+			if err != nil {
+				return nil, fmt.Errorf(".save: %v", err)
+			}
+		}
+		analyzer = tran
+	case netlist.AnalysisAC:
+		param := ckt.ACParam
+		if param.Sweep == "LIST" {
+			analyzer = NewACList(param.Frequencies)
+		} else {
+			analyzer = NewAC(param.FStart, param.FStop, param.Points, param.Sweep)
+		}
+	case netlist.AnalysisSP:
+		param := ckt.ACParam
+		if param.Sweep == "LIST" {
+			analyzer = NewSPList(param.Frequencies)
+		} else {
+			analyzer = NewSP(param.FStart, param.FStop, param.Points, param.Sweep)
+		}
+	case netlist.AnalysisDISTO:
+		param := ckt.ACParam
+		if param.Sweep == "LIST" {
+			analyzer = NewDistoList(param.Frequencies)
+		} else {
+			analyzer = NewDisto(param.FStart, param.FStop, param.Points, param.Sweep, param.F2OverF1)
+		}
+	case netlist.AnalysisSTB:
+		param := ckt.ACParam
+		if param.Sweep == "LIST" {
+			analyzer = NewSTBList(param.Frequencies, param.Probe)
+		} else {
+			analyzer = NewSTB(param.FStart, param.FStop, param.Points, param.Sweep, param.Probe)
+		}
+	case netlist.AnalysisHB:
+		analyzer = NewHB(ckt.HBParam.F0, ckt.HBParam.Harmonics)
+	case netlist.AnalysisDC:
+		param := ckt.DCParam
+		if param.Source2 != "" {
+			// nested sweep
+			analyzer = NewDCSweep(
+				[]string{param.Source1, param.Source2},
+				[]float64{param.Start1, param.Start2},
+				[]float64{param.Stop1, param.Stop2},
+				[]float64{param.Increment1, param.Increment2},
+			)
+		} else {
+			// single sweep
+			analyzer = NewDCSweep(
+				[]string{param.Source1},
+				[]float64{param.Start1},
+				[]float64{param.Stop1},
+				[]float64{param.Increment1},
+			)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported analysis type")
+	}
+
+	// Starting guess of operating point of analysis
+	if len(ckt.NodeSets) > 0 {
+		if b, ok := analyzer.(interface{ SetBiasPoint(*circuit.BiasPoint) }); ok {
+			b.SetBiasPoint(&circuit.BiasPoint{Voltages: ckt.NodeSets})
+		}
+	}
+
+	return analyzer, nil
+}
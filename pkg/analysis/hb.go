@@ -0,0 +1,356 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"time"
+
+	"github.com/edp1096/toy-spice/pkg/circuit"
+	"github.com/edp1096/toy-spice/pkg/device"
+	"github.com/edp1096/toy-spice/pkg/matrix"
+)
+
+// HarmonicBalance - Periodic steady state at fundamental and its harmonics, alternative to long transient
+// of circuits driven by periodic sources. Unknowns are harmonics 0..N of every MNA unknown, collocated at
+// 2N+1 samples of period. Linear devices are stamped by their AC admittance at each harmonic,
+// nonlinear devices by their DC linearization at each sample, so Newton reuses device models.
+// Charges of nonlinear devices are not included. Results are peak phasors of harmonics by FREQ.
+type HarmonicBalance struct {
+	BaseAnalysis
+	op          *OperatingPoint
+	fundamental float64
+	harmonics   int
+
+	linear    []device.Device // AC admittance at harmonics
+	sources   []device.Device // Independent sources, RHS sampled over period
+	nonlinear []device.Device // DC linearization at samples
+}
+
+func NewHB(fundamental float64, harmonics int) *HarmonicBalance {
+	return &HarmonicBalance{
+		BaseAnalysis: *NewBaseAnalysis(),
+		op:           NewOP(),
+		fundamental:  fundamental,
+		harmonics:    harmonics,
+	}
+}
+
+func (hb *HarmonicBalance) Setup(ckt *circuit.Circuit) error {
+	if hb.fundamental <= 0 {
+		return fmt.Errorf("harmonic balance requires fundamental frequency > 0")
+	}
+	if hb.harmonics < 1 {
+		return fmt.Errorf("harmonic balance requires at least 1 harmonic")
+	}
+	hb.Circuit = ckt
+
+	hb.linear, hb.sources, hb.nonlinear = nil, nil, nil
+	for _, dev := range ckt.GetDevices() {
+		if _, ok := dev.(device.NonLinear); ok {
+			hb.nonlinear = append(hb.nonlinear, dev)
+			continue
+		}
+		if _, ok := dev.(device.NonLinearStorage); ok {
+			return fmt.Errorf("harmonic balance does not support nonlinear storage device %s", dev.GetName())
+		}
+		hb.linear = append(hb.linear, dev)
+		switch dev.(type) {
+		case *device.VoltageSource, *device.CurrentSource:
+			hb.sources = append(hb.sources, dev)
+		}
+	}
+	err := checkAC(hb.linear)
+	if err != nil {
+		return err
+	}
+
+	// Operating point is initial guess of every sample
+	err = hb.op.Setup(ckt)
+	if err != nil {
+		return fmt.Errorf("operating point setup error: %v", err)
+	}
+	return nil
+}
+
+// Stats - Solver effort including operating point
+func (hb *HarmonicBalance) Stats() Stats {
+	return hb.stats.merge(hb.op.Stats())
+}
+
+// SetBiasPoint - Operating point starts from stored bias point instead of initial estimate
+func (hb *HarmonicBalance) SetBiasPoint(bp *circuit.BiasPoint) {
+	hb.op.SetBiasPoint(bp)
+}
+
+func (hb *HarmonicBalance) operatingPoint() *OperatingPoint { return hb.op }
+
+func (hb *HarmonicBalance) Execute() error {
+	if hb.Circuit == nil {
+		return fmt.Errorf("circuit not set")
+	}
+
+	err := hb.op.Execute()
+	if err != nil {
+		return fmt.Errorf("operating point analysis error: %v", err)
+	}
+	defer hb.stats.addPhase("harmonic balance", time.Now())
+
+	size := hb.Circuit.GetMatrix().Size
+	samples := 2*hb.harmonics + 1
+
+	linear, err := hb.linearBlocks(size, samples)
+	if err != nil {
+		return err
+	}
+	sources, err := hb.sampleSources(size, samples)
+	if err != nil {
+		return err
+	}
+
+	x := make([][]float64, samples)
+	for m := range x {
+		x[m] = make([]float64, size+1)
+		copy(x[m], hb.Circuit.GetMatrix().Solution())
+	}
+
+	// Newton with full sources, ramped from DC when it fails
+	err = hb.newton(x, linear, sources, 1)
+	if err != nil {
+		for m := range x {
+			copy(x[m], hb.Circuit.GetMatrix().Solution())
+		}
+		for _, scale := range []float64{0.1, 0.25, 0.5, 0.75, 1} {
+			err = hb.newton(x, linear, sources, scale)
+			if err != nil {
+				return fmt.Errorf("source ramping failed at %.0f%%: %v", scale*100, err)
+			}
+		}
+	}
+
+	hb.storeHarmonics(x)
+	return nil
+}
+
+// linearBlocks - Circulant operator of linear devices on samples. Block of sample distance d is
+// (Y(0) + sum 2*Re{Y(h*w0)*exp(j*2pi*h*d/K)})/K, row major without ground row and column
+func (hb *HarmonicBalance) linearBlocks(size, samples int) ([][]float64, error) {
+	blocks := make([][]float64, samples)
+	for d := range blocks {
+		blocks[d] = make([]float64, size*size)
+	}
+
+	for h := 0; h <= hb.harmonics; h++ {
+		status := &device.CircuitStatus{
+			Frequency: float64(h) * hb.fundamental,
+			Mode:      device.ACAnalysis,
+			Temp:      hb.Circuit.Temp,
+		}
+		rec := newStampRecorder(size)
+		for _, dev := range hb.linear {
+			err := dev.Stamp(rec, status)
+			if err != nil {
+				return nil, fmt.Errorf("stamping device %s at f=%g: %v", dev.GetName(), status.Frequency, err)
+			}
+		}
+
+		weight := 2.0
+		if h == 0 {
+			weight = 1
+		}
+		for d := range samples {
+			rotation := cmplx.Rect(weight/float64(samples), 2*math.Pi*float64(h*d)/float64(samples))
+			for i := 1; i <= size; i++ {
+				for j := 1; j <= size; j++ {
+					blocks[d][(i-1)*size+j-1] += real(rec.element(i, j) * rotation)
+				}
+			}
+		}
+	}
+	return blocks, nil
+}
+
+// sampleSources - RHS of independent sources at samples of period
+func (hb *HarmonicBalance) sampleSources(size, samples int) ([][]float64, error) {
+	rhs := make([][]float64, samples)
+	for m := range rhs {
+		status := &device.CircuitStatus{
+			Time: float64(m) / (float64(samples) * hb.fundamental),
+			Mode: device.TransientAnalysis,
+			Temp: hb.Circuit.Temp,
+		}
+		rec := newStampRecorder(size)
+		for _, dev := range hb.sources {
+			err := dev.Stamp(rec, status)
+			if err != nil {
+				return nil, fmt.Errorf("stamping source %s at t=%g: %v", dev.GetName(), status.Time, err)
+			}
+		}
+		rhs[m] = make([]float64, size+1)
+		for i := 1; i <= size; i++ {
+			rhs[m][i] = real(rec.rhs[i])
+		}
+	}
+	return rhs, nil
+}
+
+// newton - Solve samples x in place with AC part of sources scaled by scale around their mean
+func (hb *HarmonicBalance) newton(x [][]float64, linear, sources [][]float64, scale float64) error {
+	samples, size := len(x), len(x[0])-1
+	mat := matrix.NewMatrixDense(size*samples, false)
+	status := &device.CircuitStatus{
+		Mode: device.OperatingPointAnalysis,
+		Temp: hb.Circuit.Temp,
+		Gmin: hb.convergence.gmin,
+	}
+
+	mean := make([]float64, size+1)
+	for _, rhs := range sources {
+		for i := range rhs {
+			mean[i] += rhs[i] / float64(samples)
+		}
+	}
+
+	oldSolution := flatten(x)
+	for range hb.convergence.maxIter {
+		hb.stats.Iterations++
+		mat.Clear()
+
+		for m := range samples {
+			row := m * size
+
+			// Nonlinear devices linearized at sample
+			rec := newStampRecorder(size)
+			for _, dev := range hb.nonlinear {
+				err := dev.(device.NonLinear).UpdateVoltages(x[m])
+				if err != nil {
+					return fmt.Errorf("updating voltages of %s: %v", dev.GetName(), err)
+				}
+				err = dev.Stamp(rec, status)
+				if err != nil {
+					return fmt.Errorf("stamping device %s: %v", dev.GetName(), err)
+				}
+			}
+			for i := 1; i <= size; i++ {
+				for j := 1; j <= size; j++ {
+					if g := real(rec.element(i, j)); g != 0 {
+						mat.AddElement(row+i, row+j, g)
+					}
+				}
+				source := mean[i] + scale*(sources[m][i]-mean[i])
+				mat.AddRHS(row+i, real(rec.rhs[i])+source)
+			}
+
+			// Linear devices couple all samples
+			for k := range samples {
+				block := linear[(m-k+samples)%samples]
+				col := k * size
+				for i := 1; i <= size; i++ {
+					for j := 1; j <= size; j++ {
+						if y := block[(i-1)*size+j-1]; y != 0 {
+							mat.AddElement(row+i, col+j, y)
+						}
+					}
+				}
+			}
+		}
+		mat.LoadGmin(hb.convergence.gmin)
+
+		err := hb.solve(mat)
+		if err != nil {
+			return fmt.Errorf("matrix solve error: %v", err)
+		}
+		solution := mat.Solution()
+		err = hb.checkDivergence(solution)
+		if err != nil {
+			return err
+		}
+		for m := range samples {
+			copy(x[m][1:], solution[m*size+1:(m+1)*size+1])
+		}
+
+		if hb.CheckConvergence(oldSolution, solution) {
+			return nil
+		}
+		oldSolution = append(oldSolution[:0], solution...)
+	}
+
+	return fmt.Errorf("failed to converge in %d iterations", hb.convergence.maxIter)
+}
+
+// storeHarmonics - Peak phasors of harmonics by DFT of samples. DC is mean value
+func (hb *HarmonicBalance) storeHarmonics(x [][]float64) {
+	samples, size := len(x), len(x[0])-1
+	for h := 0; h <= hb.harmonics; h++ {
+		voltages := make([]complex128, size+1)
+		for m := range samples {
+			rotation := cmplx.Rect(1, -2*math.Pi*float64(h*m)/float64(samples))
+			for i := 1; i <= size; i++ {
+				voltages[i] += complex(x[m][i], 0) * rotation
+			}
+		}
+		weight := 2 / float64(samples)
+		if h == 0 {
+			weight = 1 / float64(samples)
+		}
+
+		solution := make(map[string]complex128)
+		for name, nodeIdx := range hb.Circuit.GetNodeMap() {
+			if nodeIdx > 0 {
+				solution[fmt.Sprintf("V(%s)", name)] = voltages[nodeIdx] * complex(weight, 0)
+			}
+		}
+		for name, current := range hb.Circuit.GetBranchCurrentsAC(voltages) {
+			solution[name] = current * complex(weight, 0)
+		}
+		hb.StoreACResult(float64(h)*hb.fundamental, solution)
+	}
+}
+
+// flatten - Samples as one vector of Newton system, 1-based like matrix solution
+func flatten(x [][]float64) []float64 {
+	out := []float64{0}
+	for _, sample := range x {
+		out = append(out, sample[1:]...)
+	}
+	return out
+}
+
+// stampRecorder - Dense copy of device stamps, for systems assembled by analysis instead of circuit matrix
+type stampRecorder struct {
+	size int
+	a    []complex128 // Row major, 1-based with ground row and column
+	rhs  []complex128
+}
+
+func newStampRecorder(size int) *stampRecorder {
+	return &stampRecorder{
+		size: size,
+		a:    make([]complex128, (size+1)*(size+1)),
+		rhs:  make([]complex128, size+1),
+	}
+}
+
+func (r *stampRecorder) element(i, j int) complex128 {
+	return r.a[i*(r.size+1)+j]
+}
+
+func (r *stampRecorder) AddElement(i, j int, value float64) {
+	r.AddComplexElement(i, j, value, 0)
+}
+
+func (r *stampRecorder) AddComplexElement(i, j int, re, im float64) {
+	if i > 0 && j > 0 && i <= r.size && j <= r.size {
+		r.a[i*(r.size+1)+j] += complex(re, im)
+	}
+}
+
+func (r *stampRecorder) AddRHS(i int, value float64) {
+	r.AddComplexRHS(i, value, 0)
+}
+
+func (r *stampRecorder) AddComplexRHS(i int, re, im float64) {
+	if i > 0 && i <= r.size {
+		r.rhs[i] += complex(re, im)
+	}
+}
@@ -0,0 +1,144 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"strings"
+
+	"github.com/edp1096/toy-spice/pkg/device"
+)
+
+// Impedance - Impedance over AC sweep frequencies
+type Impedance struct {
+	Freq  []float64
+	Z     []complex128
+	Mag   []float64 // Ohm
+	Phase []float64 // Degree
+}
+
+func newImpedance(freqs []float64, z []complex128) *Impedance {
+	imp := &Impedance{Freq: freqs, Z: z, Mag: make([]float64, len(z)), Phase: make([]float64, len(z))}
+	for i, value := range z {
+		imp.Mag[i] = cmplx.Abs(value)
+		imp.Phase[i] = cmplx.Phase(value) * 180.0 / math.Pi
+	}
+	return imp
+}
+
+// InputImpedance - Impedance seen by source, voltage across source over current it delivers.
+// Uses results of Execute.
+func (ac *ACAnalysis) InputImpedance(source string) (*Impedance, error) {
+	dev, err := ac.findDevice(source)
+	if err != nil {
+		return nil, err
+	}
+
+	results := ac.GetComplexResults()
+	if len(results["FREQ"]) == 0 {
+		return nil, fmt.Errorf("no AC results, run analysis first")
+	}
+	freqs := ac.results["FREQ"]
+	names := dev.GetNodeNames()
+
+	z := make([]complex128, len(freqs))
+	for i := range freqs {
+		v := complexNodeResult(results, names[0], i) - complexNodeResult(results, names[1], i)
+
+		var current complex128
+		switch src := dev.(type) {
+		case *device.VoltageSource:
+			// Branch current flows n+ to n- through source. Delivered current is opposite
+			current = -results[fmt.Sprintf("I(%s)", src.GetName())][i]
+		case *device.CurrentSource:
+			// AC current is injected into n+
+			current = src.ACPhasor()
+		default:
+			return nil, fmt.Errorf("%s is not an independent source", source)
+		}
+		if current == 0 {
+			return nil, fmt.Errorf("source %s has no AC excitation", source)
+		}
+		z[i] = v / current
+	}
+
+	return newImpedance(freqs, z), nil
+}
+
+// OutputImpedance - Thevenin impedance seen by load at its terminals, load excluded.
+// Independent sources are zeroed and 1A test current is injected into load n+ at each frequency.
+func (ac *ACAnalysis) OutputImpedance(load string) (*Impedance, error) {
+	dev, err := ac.findDevice(load)
+	if err != nil {
+		return nil, err
+	}
+	if _, isBranch := ac.Circuit.GetBranchMap()[dev.GetName()]; isBranch {
+		return nil, fmt.Errorf("load %s has branch current, only two terminal elements without branch are supported", load)
+	}
+	nodes := dev.GetNodes()
+	if len(nodes) != 2 {
+		return nil, fmt.Errorf("load %s must have two terminals", load)
+	}
+
+	z := make([]complex128, len(ac.frequencies))
+	for i, freq := range ac.frequencies {
+		ac.Circuit.Status = &device.CircuitStatus{
+			Frequency: freq,
+			Mode:      device.ACAnalysis,
+			Temp:      ac.Circuit.Temp,
+		}
+
+		mat := ac.Circuit.GetMatrix()
+		mat.Clear()
+		for _, other := range ac.Circuit.GetDevices() {
+			if other == dev {
+				continue
+			}
+			err := other.Stamp(mat, ac.Circuit.Status)
+			if err != nil {
+				return nil, fmt.Errorf("stamping device %s: %v", other.GetName(), err)
+			}
+		}
+
+		// Zero independent sources, then test current into n+ and out of n-
+		mat.ClearRHS()
+		if nodes[0] != 0 {
+			mat.AddComplexRHS(nodes[0], 1, 0)
+		}
+		if nodes[1] != 0 {
+			mat.AddComplexRHS(nodes[1], -1, 0)
+		}
+
+		err := ac.solve(mat)
+		if err != nil {
+			return nil, fmt.Errorf("matrix solve error at f=%g: %v", freq, ac.Circuit.DiagnoseSingular(err))
+		}
+
+		v1r, v1i := mat.GetComplexSolution(nodes[0])
+		v2r, v2i := mat.GetComplexSolution(nodes[1])
+		z[i] = complex(v1r-v2r, v1i-v2i)
+	}
+
+	return newImpedance(ac.frequencies, z), nil
+}
+
+func (ac *ACAnalysis) findDevice(name string) (device.Device, error) {
+	if ac.Circuit == nil {
+		return nil, fmt.Errorf("circuit not set")
+	}
+	for _, dev := range ac.Circuit.GetDevices() {
+		if strings.EqualFold(dev.GetName(), name) {
+			return dev, nil
+		}
+	}
+	return nil, fmt.Errorf("device not found: %s", name)
+}
+
+// complexNodeResult - Phasor of node at frequency index. Ground is zero
+func complexNodeResult(results map[string][]complex128, node string, i int) complex128 {
+	values, ok := results[fmt.Sprintf("V(%s)", node)]
+	if !ok || i >= len(values) {
+		return 0
+	}
+	return values[i]
+}
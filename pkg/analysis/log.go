@@ -0,0 +1,9 @@
+package analysis
+
+import (
+	"log"
+	"os"
+)
+
+// Logger - Convergence progress of analyses, eg. Gmin and source stepping. SetOutput(io.Discard) suppresses it
+var Logger = log.New(os.Stderr, "", 0)
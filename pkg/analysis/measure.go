@@ -0,0 +1,251 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/edp1096/toy-spice/pkg/netlist"
+	"github.com/edp1096/toy-spice/pkg/post"
+)
+
+// Measure - Value of .measure over TRAN or DC results. Expression is evaluated at every point of TIME or
+// SWEEP1, then FIND interpolates it at AT, DERIV interpolates its derivative at AT, INTEG integrates it
+// over FROM to TO, AVG, RMS and PP are mean, root mean square and peak to peak over window.
+// eg. average power by AVG v(1)*i(R1)
+func Measure(results map[string][]float64, m netlist.Measure) (float64, error) {
+	sweep := "TIME"
+	if m.Analysis == "dc" {
+		sweep = "SWEEP1"
+	}
+	x, ok := results[sweep]
+	if !ok {
+		return 0, fmt.Errorf("measure %s requires %s results", m.Name, m.Analysis)
+	}
+	y, err := EvaluateExpression(results, m.Expr)
+	if err != nil {
+		return 0, fmt.Errorf("measure %s: %v", m.Name, err)
+	}
+	if len(x) > 1 && x[0] > x[len(x)-1] {
+		// Downward DC sweep
+		x, y = slices.Clone(x), slices.Clone(y)
+		slices.Reverse(x)
+		slices.Reverse(y)
+	}
+	err = post.CheckSweep(x, y)
+	if err != nil {
+		return 0, fmt.Errorf("measure %s %v", m.Name, err)
+	}
+
+	from, to := m.From, m.To
+	if math.IsNaN(from) {
+		from = x[0]
+	}
+	if math.IsNaN(to) {
+		to = x[len(x)-1]
+	}
+
+	var value float64
+	switch m.Kind {
+	case "FIND":
+		value, err = post.Interpolate(x, y, m.At)
+	case "DERIV":
+		value, err = post.Interpolate(x, post.Derivative(x, y), m.At)
+	case "INTEG":
+		value, err = post.Integral(x, y, from, to)
+	case "AVG":
+		value, err = post.Average(x, y, from, to)
+	case "RMS":
+		value, err = post.RMS(x, y, from, to)
+	case "PP":
+		value, err = post.PeakToPeak(x, y, from, to)
+	default:
+		return 0, fmt.Errorf("unsupported measure operation %s", m.Kind)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("measure %s: %v", m.Name, err)
+	}
+	return value, nil
+}
+
+// EvaluateExpression - Values of expression at every point of results. Operands are numbers with unit
+// suffixes, output variables of Probe and result names. eg. TIME. Operators are + - * / and parentheses
+func EvaluateExpression(results map[string][]float64, expr string) ([]float64, error) {
+	n := 0
+	for _, values := range results {
+		n = max(n, len(values))
+	}
+	e := &exprParser{results: results, input: expr, size: n}
+	values, err := e.sum()
+	if err != nil {
+		return nil, err
+	}
+	if e.skipSpace(); e.pos < len(e.input) {
+		return nil, fmt.Errorf("unexpected %q in expression %s", e.input[e.pos:], expr)
+	}
+	return values, nil
+}
+
+// exprParser - Recursive descent evaluation of expression, every operand is vector of size points
+type exprParser struct {
+	results map[string][]float64
+	input   string
+	pos     int
+	size    int
+}
+
+var exprNumber = regexp.MustCompile(`^\d*\.?\d+(?:[eE][-+]?\d+)?(?:meg|[TGMKkmunpf])?`)
+
+func (e *exprParser) skipSpace() {
+	for e.pos < len(e.input) && e.input[e.pos] == ' ' {
+		e.pos++
+	}
+}
+
+// next - Next operator character after spaces, consumed when it is one of ops
+func (e *exprParser) next(ops string) (byte, bool) {
+	e.skipSpace()
+	if e.pos < len(e.input) && strings.IndexByte(ops, e.input[e.pos]) >= 0 {
+		e.pos++
+		return e.input[e.pos-1], true
+	}
+	return 0, false
+}
+
+func (e *exprParser) sum() ([]float64, error) {
+	values, err := e.product()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := e.next("+-")
+		if !ok {
+			return values, nil
+		}
+		rhs, err := e.product()
+		if err != nil {
+			return nil, err
+		}
+		for i := range values {
+			if op == '+' {
+				values[i] += rhs[i]
+			} else {
+				values[i] -= rhs[i]
+			}
+		}
+	}
+}
+
+func (e *exprParser) product() ([]float64, error) {
+	values, err := e.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := e.next("*/")
+		if !ok {
+			return values, nil
+		}
+		rhs, err := e.unary()
+		if err != nil {
+			return nil, err
+		}
+		for i := range values {
+			if op == '*' {
+				values[i] *= rhs[i]
+			} else {
+				values[i] /= rhs[i]
+			}
+		}
+	}
+}
+
+func (e *exprParser) unary() ([]float64, error) {
+	op, ok := e.next("+-")
+	if !ok {
+		return e.operand()
+	}
+	values, err := e.unary()
+	if err != nil {
+		return nil, err
+	}
+	if op == '-' {
+		for i := range values {
+			values[i] = -values[i]
+		}
+	}
+	return values, nil
+}
+
+func (e *exprParser) operand() ([]float64, error) {
+	if _, ok := e.next("("); ok {
+		values, err := e.sum()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := e.next(")"); !ok {
+			return nil, fmt.Errorf("missing ) in expression %s", e.input)
+		}
+		return values, nil
+	}
+
+	rest := e.input[e.pos:]
+	if number := exprNumber.FindString(rest); number != "" {
+		value, err := netlist.ParseValue(number)
+		if err != nil {
+			return nil, err
+		}
+		e.pos += len(number)
+		return e.constant(value), nil
+	}
+
+	end := strings.IndexFunc(rest, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+	if end < 0 {
+		end = len(rest)
+	}
+	if end == 0 {
+		return nil, fmt.Errorf("missing operand at %q in expression %s", rest, e.input)
+	}
+	name := rest[:end]
+
+	// Output variable. eg. V(1,2), I(R1)
+	if end < len(rest) && rest[end] == '(' {
+		closing := strings.IndexByte(rest, ')')
+		if closing < 0 {
+			return nil, fmt.Errorf("missing ) in expression %s", e.input)
+		}
+		values, err := Probe(e.results, rest[:closing+1])
+		if err != nil {
+			return nil, err
+		}
+		e.pos += closing + 1
+		return e.vector(values), nil
+	}
+
+	values, ok := lookupResult(e.results, name)
+	if !ok {
+		return nil, fmt.Errorf("unknown result %s in expression %s", name, e.input)
+	}
+	e.pos += end
+	return e.vector(values), nil
+}
+
+func (e *exprParser) constant(value float64) []float64 {
+	values := make([]float64, e.size)
+	for i := range values {
+		values[i] = value
+	}
+	return values
+}
+
+// vector - Copy of result padded to size, ground is zero
+func (e *exprParser) vector(values []float64) []float64 {
+	out := make([]float64, e.size)
+	copy(out, values)
+	return out
+}
@@ -0,0 +1,89 @@
+package analysis
+
+import "strings"
+
+// ResultInfo - Unit and role of result variable
+type ResultInfo struct {
+	Unit  string // eg. V, A, s, Hz, deg, dB. Empty when dimensionless, eg. magnitude of S-parameter
+	Axis  bool   // Independent variable of analysis. TIME, FREQ, SWEEP1, SWEEP2 and SWEEP(source)
+	Label string // Axis label. eg. "Time (s)", "V(2) phase (deg)"
+}
+
+// Units of AC result suffixes. Magnitude and real parts keep unit of variable
+var acSuffixUnits = []struct{ suffix, unit, label string }{
+	{"_PHASE_UNWRAPPED", "deg", "phase"},
+	{"_GROUP_DELAY", "s", "group delay"},
+	{"_PHASE", "deg", "phase"},
+	{"_DB", "dB", "magnitude"},
+	{"_MAG", "", "magnitude"},
+}
+
+// DescribeResult - Unit and role of result by name. DC sweep variables are voltages of swept sources
+func DescribeResult(name string) ResultInfo {
+	switch name {
+	case "TIME":
+		return ResultInfo{Unit: "s", Axis: true, Label: "Time (s)"}
+	case "FREQ":
+		return ResultInfo{Unit: "Hz", Axis: true, Label: "Frequency (Hz)"}
+	case "SWEEP1", "SWEEP2":
+		return ResultInfo{Unit: "V", Axis: true, Label: "Sweep (V)"}
+	}
+	if source, ok := strings.CutPrefix(name, "SWEEP("); ok {
+		return ResultInfo{Unit: "V", Axis: true, Label: strings.TrimSuffix(source, ")") + " (V)"}
+	}
+
+	for _, s := range acSuffixUnits {
+		base, ok := strings.CutSuffix(name, s.suffix)
+		if !ok {
+			continue
+		}
+		unit := s.unit
+		if s.suffix == "_MAG" {
+			unit = variableUnit(base)
+		}
+		return ResultInfo{Unit: unit, Label: withUnit(base+" "+s.label, unit)}
+	}
+
+	unit := variableUnit(name)
+	return ResultInfo{Unit: unit, Label: withUnit(name, unit)}
+}
+
+// variableUnit - Unit of output variable. eg. V(1), I(R1), B(L1), H(L1), PLOSS(core)
+func variableUnit(name string) string {
+	kind, _, _ := strings.Cut(strings.ToUpper(name), "(")
+	switch kind {
+	case "V", "VM":
+		return "V"
+	case "I", "IM":
+		return "A"
+	case "VP", "IP":
+		return "deg"
+	case "VDB", "IDB":
+		return "dB"
+	case "VG", "IG":
+		return "s"
+	case "B":
+		return "T"
+	case "H":
+		return "A/m"
+	case "PLOSS":
+		return "W"
+	}
+	return ""
+}
+
+func withUnit(label, unit string) string {
+	if unit == "" {
+		return label
+	}
+	return label + " (" + unit + ")"
+}
+
+// ResultInfo - Unit and role of results of analysis by name
+func (a *BaseAnalysis) ResultInfo() map[string]ResultInfo {
+	info := make(map[string]ResultInfo, len(a.results))
+	for name := range a.results {
+		info[name] = DescribeResult(name)
+	}
+	return info
+}
@@ -0,0 +1,214 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/edp1096/toy-spice/pkg/device"
+)
+
+// DCSolution - Distinct operating point found by FindSolutions
+type DCSolution struct {
+	Voltages map[string]float64 // Node voltages by node name
+	Starts   int                // Starts converged to solution
+	Stable   bool               // No real unstable mode with capacitance at every node. eg. false at metastable point of latch
+	solution []float64
+}
+
+// FindSolutions - Operating points of bistable circuits. eg. latch, Schmitt trigger.
+// Newton is started from usual operating point, from each nodeset and from random node voltages in range of
+// voltage sources. Solutions equal within 1e-3 relative and 1uV, 1uA absolute are merged, first is usual one.
+// Starts without convergence are skipped. Results and circuit stay at first solution
+func (op *OperatingPoint) FindSolutions(nodeSets []map[string]float64, randomStarts int, seed int64) ([]DCSolution, error) {
+	err := op.Execute()
+	if err != nil {
+		return nil, err
+	}
+	defer op.stats.addPhase("multiple operating points", time.Now())
+
+	ckt := op.Circuit
+	mat := ckt.GetMatrix()
+	first := append([]float64(nil), mat.Solution()...)
+	var solutions []DCSolution
+	add := func(solution []float64) error {
+		for i := range solutions {
+			if sameSolution(solutions[i].solution, solution) {
+				solutions[i].Starts++
+				return nil
+			}
+		}
+		stable, err := op.stableSolution(solution)
+		if err != nil {
+			return err
+		}
+		s := DCSolution{Voltages: make(map[string]float64), Starts: 1, Stable: stable, solution: solution}
+		for name, idx := range ckt.GetNodeMap() {
+			if idx > 0 {
+				s.Voltages[name] = solution[idx]
+			}
+		}
+		solutions = append(solutions, s)
+		return nil
+	}
+	err = add(first)
+	if err != nil {
+		return nil, err
+	}
+
+	var starts [][]float64
+	for _, voltages := range nodeSets {
+		start := make([]float64, mat.Size+1)
+		for name, voltage := range voltages {
+			idx, ok := ckt.GetNodeMap()[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown node in nodeset: %s", name)
+			}
+			start[idx] = voltage
+		}
+		starts = append(starts, start)
+	}
+
+	// Random node voltages between lowest and highest voltage source. Branch currents start at zero
+	low, high := 0.0, 0.0
+	for _, dev := range ckt.GetDevices() {
+		if v, ok := dev.(*device.VoltageSource); ok {
+			low, high = math.Min(low, v.GetValue()), math.Max(high, v.GetValue())
+		}
+	}
+	rng := rand.New(rand.NewSource(seed))
+	for range randomStarts {
+		start := make([]float64, mat.Size+1)
+		for _, idx := range ckt.GetNodeMap() {
+			if idx > 0 {
+				start[idx] = low + rng.Float64()*(high-low)
+			}
+		}
+		starts = append(starts, start)
+	}
+
+	for _, start := range starts {
+		if op.doNRiter(0, op.convergence.maxIter, start) != nil {
+			continue
+		}
+		err = add(append([]float64(nil), mat.Solution()...))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Back to first solution
+	copy(mat.Solution(), first)
+	err = ckt.UpdateNonlinearVoltages(first)
+	if err != nil {
+		return nil, fmt.Errorf("updating nonlinear voltages: %v", err)
+	}
+	op.storeResults(first)
+	return solutions, nil
+}
+
+func sameSolution(a, b []float64) bool {
+	for i := 1; i < len(a); i++ {
+		if math.Abs(a[i]-b[i]) > 1e-3*math.Max(math.Abs(a[i]), math.Abs(b[i]))+1e-6 {
+			return false
+		}
+	}
+	return true
+}
+
+// stableSolution - Solution has no real unstable mode when every node has capacitance to ground C.
+// Real unstable modes are roots λ > 0 of det(J + λC), counted by sign changes of determinant over λ from 0
+// to far above conductances of Jacobian J. Oscillating instability, complex pair of roots, is not detected
+func (op *OperatingPoint) stableSolution(solution []float64) (bool, error) {
+	ckt := op.Circuit
+	size := ckt.GetMatrix().Size
+	err := ckt.UpdateNonlinearVoltages(solution)
+	if err != nil {
+		return false, fmt.Errorf("updating nonlinear voltages: %v", err)
+	}
+
+	status := &device.CircuitStatus{
+		Mode: device.OperatingPointAnalysis,
+		Temp: op.temp,
+		Gmin: op.convergence.gmin,
+	}
+	rec := newStampRecorder(size)
+	for _, dev := range ckt.GetDevices() {
+		err := dev.Stamp(rec, status)
+		if err != nil {
+			return false, fmt.Errorf("stamping device %s: %v", dev.GetName(), err)
+		}
+	}
+
+	jacobian := make([]float64, size*size)
+	scale := 0.0
+	for i := 1; i <= size; i++ {
+		for j := 1; j <= size; j++ {
+			jacobian[(i-1)*size+j-1] = real(rec.element(i, j))
+		}
+		jacobian[(i-1)*size+i-1] += op.convergence.gmin
+		scale = math.Max(scale, math.Abs(jacobian[(i-1)*size+i-1]))
+	}
+
+	var nodes []int
+	for _, idx := range ckt.GetNodeMap() {
+		if idx > 0 {
+			nodes = append(nodes, idx)
+		}
+	}
+
+	a := make([]float64, len(jacobian))
+	previous := 0
+	for k := -1; k <= 36; k++ {
+		lambda := 0.0
+		if k >= 0 {
+			lambda = scale * math.Pow(10, float64(k)/2-12)
+		}
+		copy(a, jacobian)
+		for _, idx := range nodes {
+			a[(idx-1)*size+idx-1] += lambda
+		}
+		sign := determinantSign(a, size)
+		if sign == 0 {
+			continue
+		}
+		if previous != 0 && sign != previous {
+			return false, nil
+		}
+		previous = sign
+	}
+	return true, nil
+}
+
+// determinantSign - Sign of determinant of row major n x n matrix by LU with partial pivoting, a is overwritten
+func determinantSign(a []float64, n int) int {
+	sign := 1
+	for k := range n {
+		pivot := k
+		for i := k + 1; i < n; i++ {
+			if math.Abs(a[i*n+k]) > math.Abs(a[pivot*n+k]) {
+				pivot = i
+			}
+		}
+		if a[pivot*n+k] == 0 {
+			return 0
+		}
+		if pivot != k {
+			for j := range n {
+				a[k*n+j], a[pivot*n+j] = a[pivot*n+j], a[k*n+j]
+			}
+			sign = -sign
+		}
+		if a[k*n+k] < 0 {
+			sign = -sign
+		}
+		for i := k + 1; i < n; i++ {
+			factor := a[i*n+k] / a[k*n+k]
+			for j := k + 1; j < n; j++ {
+				a[i*n+j] -= factor * a[k*n+j]
+			}
+		}
+	}
+	return sign
+}
@@ -0,0 +1,80 @@
+package analysis
+
+import (
+	"slices"
+	"strings"
+)
+
+// Sweep variables of results in canonical order
+var sweepNames = []string{"FREQ", "TIME", "SWEEP1", "SWEEP2"}
+
+// SweepName - Result name of DC sweep source, eg. SWEEP(Vin)
+func SweepName(source string) string {
+	return "SWEEP(" + source + ")"
+}
+
+// SweptSources - Names of swept sources in DC results. eg. Vin of SWEEP(Vin)
+func SweptSources(results map[string][]float64) []string {
+	var sources []string
+	for name := range results {
+		if source, ok := strings.CutPrefix(name, "SWEEP("); ok && strings.HasSuffix(source, ")") {
+			sources = append(sources, strings.TrimSuffix(source, ")"))
+		}
+	}
+	slices.Sort(sources)
+	return sources
+}
+
+// ResultNames - Names of results in canonical order: sweep variables, swept sources, node voltages, currents, then others
+// like S-parameters and magnetic results. Names in each group are sorted by variable, so AC results
+// of a variable stay together. eg. FREQ, V(1)_MAG, V(1)_PHASE, V(10)_MAG, ...
+func ResultNames(results map[string][]float64) []string {
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	SortResultNames(names)
+	return names
+}
+
+// SortResultNames - Sort names in canonical order of ResultNames
+func SortResultNames(names []string) {
+	slices.SortFunc(names, func(a, b string) int {
+		if rankA, rankB := resultRank(a), resultRank(b); rankA != rankB {
+			return rankA - rankB
+		}
+		if c := strings.Compare(resultBase(a), resultBase(b)); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+}
+
+// ResultNames - Names of results in canonical order
+func (a *BaseAnalysis) ResultNames() []string {
+	return ResultNames(a.results)
+}
+
+func resultRank(name string) int {
+	if i := slices.Index(sweepNames, name); i >= 0 {
+		return i - len(sweepNames)
+	}
+	switch {
+	case strings.HasPrefix(name, "SWEEP("):
+		return 0
+	case strings.HasPrefix(name, "V("):
+		return 1
+	case strings.HasPrefix(name, "I("):
+		return 2
+	}
+	return 3
+}
+
+// resultBase - Variable of result name without AC suffix. eg. V(1)_MAG -> V(1), S21_DB -> S21
+func resultBase(name string) string {
+	if i := strings.LastIndex(name, ")"); i >= 0 {
+		return name[:i+1]
+	}
+	base, _, _ := strings.Cut(name, "_")
+	return base
+}
@@ -3,22 +3,65 @@ package analysis
 import (
 	"fmt"
 	"math"
+	"strings"
+	"time"
 
+	"github.com/edp1096/toy-spice/internal/consts"
 	"github.com/edp1096/toy-spice/pkg/circuit"
 	"github.com/edp1096/toy-spice/pkg/device"
 	"github.com/edp1096/toy-spice/pkg/matrix"
 )
 
-type OperatingPoint struct{ BaseAnalysis }
+type OperatingPoint struct {
+	BaseAnalysis
+	initJunction bool               // Next NR iteration is first one of DC analysis
+	bias         *circuit.BiasPoint // Stored operating point as starting guess
+	temp         float64            // Temperature (K) of solve, circuit temperature except while temperature stepping
+}
+
+// Nominal temperature of model parameters
+const tnom = circuit.NominalTemp
 
 func NewOP() *OperatingPoint {
 	return &OperatingPoint{
 		BaseAnalysis: *NewBaseAnalysis(),
+		temp:         tnom,
 	}
 }
 
+// SetBiasPoint - Start Newton from stored operating point. eg. of previous run of same circuit.
+// Falls back to usual initial estimate and stepping when it does not converge.
+func (op *OperatingPoint) SetBiasPoint(bp *circuit.BiasPoint) {
+	op.bias = bp
+}
+
+func (op *OperatingPoint) operatingPoint() *OperatingPoint { return op }
+
+// NodeSet - Node voltages of operating point solved by analysis, by node name as .nodeset.
+// nil when analysis has no operating point or it was not solved. eg. UIC transient, AC of linear circuit
+func NodeSet(a Analysis) map[string]float64 {
+	biased, ok := a.(interface{ operatingPoint() *OperatingPoint })
+	if !ok {
+		return nil
+	}
+
+	var voltages map[string]float64
+	for name, values := range biased.operatingPoint().GetResults() {
+		node, ok := strings.CutPrefix(name, "V(")
+		if !ok || len(values) != 1 {
+			continue
+		}
+		if voltages == nil {
+			voltages = make(map[string]float64)
+		}
+		voltages[strings.TrimSuffix(node, ")")] = values[0]
+	}
+	return voltages
+}
+
 func (op *OperatingPoint) Setup(ckt *circuit.Circuit) error {
 	op.Circuit = ckt
+	op.temp = ckt.Temp
 	return nil
 }
 
@@ -27,6 +70,7 @@ func (op *OperatingPoint) doNRiter(gmin float64, maxIter int, initialSolution []
 	ckt := op.Circuit
 	mat := ckt.GetMatrix()
 	var oldSolution []float64
+	ckt.InvalidateLinearStamps() // Source values may be stepped between solves
 
 	if initialSolution != nil {
 		oldSolution = make([]float64, len(initialSolution))
@@ -36,13 +80,15 @@ func (op *OperatingPoint) doNRiter(gmin float64, maxIter int, initialSolution []
 	}
 
 	ckt.Status = &device.CircuitStatus{
-		Time: 0,
-		Mode: device.OperatingPointAnalysis,
-		Temp: 300.15, // 27 = 300.15K
-		Gmin: gmin,
+		Time:   0,
+		Mode:   device.OperatingPointAnalysis,
+		Temp:   op.temp,
+		Gmin:   gmin,
+		Bypass: !ckt.NoBypass,
 	}
 
 	for iter := range maxIter {
+		op.stats.Iterations++
 		mat.Clear()
 
 		err = ckt.UpdateNonlinearVoltages(oldSolution)
@@ -50,19 +96,26 @@ func (op *OperatingPoint) doNRiter(gmin float64, maxIter int, initialSolution []
 			return fmt.Errorf("updating nonlinear voltages: %v", err)
 		}
 
-		err = ckt.Stamp(ckt.Status)
+		ckt.Status.InitJunction = op.initJunction
+		err = ckt.StampIteration(ckt.Status)
 		if err != nil {
 			return fmt.Errorf("stamping error: %v", err)
 		}
+		op.initJunction = false
+		ckt.Status.InitJunction = false
 
 		mat.LoadGmin(gmin)
 
-		err = mat.Solve()
+		err = op.solve(mat)
 		if err != nil {
-			return fmt.Errorf("matrix solve error: %v", err)
+			return fmt.Errorf("matrix solve error: %v", ckt.DiagnoseSingular(err))
 		}
 
 		solution := mat.Solution()
+		err = op.checkDivergence(solution)
+		if err != nil {
+			return err
+		}
 
 		if iter > 0 {
 			allConverged := true
@@ -77,7 +130,13 @@ func (op *OperatingPoint) doNRiter(gmin float64, maxIter int, initialSolution []
 			}
 
 			if allConverged {
-				return nil
+				converged, err := op.residualConverged(solution, ckt.Status)
+				if err != nil {
+					return err
+				}
+				if converged {
+					return nil
+				}
 			}
 		}
 
@@ -87,27 +146,37 @@ func (op *OperatingPoint) doNRiter(gmin float64, maxIter int, initialSolution []
 	return fmt.Errorf("failed to converge in %d iterations", maxIter)
 }
 
+// calculateInitialEstimate - Solution of linear devices only, nil when it fails
 func (op *OperatingPoint) calculateInitialEstimate() []float64 {
+	solution, err := op.solveLinearDevices()
+	if err != nil {
+		Logger.Println("failed to calculate initial estimate:", err)
+		return nil
+	}
+	return solution
+}
+
+func (op *OperatingPoint) solveLinearDevices() ([]float64, error) {
 	ckt := op.Circuit
 	size := ckt.GetMatrix().Size
 
-	initialMatrix := matrix.NewMatrix(size, false)
+	initialMatrix, err := matrix.NewMatrixWithSolver(ckt.GetMatrix().SolverName(), size, false)
+	if err != nil {
+		return nil, err
+	}
 
 	for _, dev := range ckt.GetDevices() {
 		if _, isNonlinear := dev.(device.NonLinear); !isNonlinear {
-			fmt.Println("linear device:", dev.GetName())
 			dev.Stamp(initialMatrix, ckt.Status)
 		}
 	}
 
-	err := initialMatrix.Solve()
+	err = op.solve(initialMatrix)
 	if err != nil {
-		fmt.Println("failed to calculate initial estimate:", err)
-		return nil
+		return nil, err
 	}
 
-	result := initialMatrix.Solution()
-	return result
+	return initialMatrix.Solution(), nil
 }
 
 func (op *OperatingPoint) performSourceStepping() error {
@@ -145,7 +214,7 @@ func (op *OperatingPoint) performSourceStepping() error {
 
 	// Increase 10% -> 100%
 	for factor := 0.1; factor <= 1.0; factor += 0.1 {
-		fmt.Printf("Source stepping: %.0f%%\n", factor*100)
+		Logger.Printf("Source stepping: %.0f%%", factor*100)
 
 		for name, origValue := range originalSources {
 			for _, dev := range ckt.GetDevices() {
@@ -168,20 +237,110 @@ func (op *OperatingPoint) performSourceStepping() error {
 	return nil
 }
 
+// performGminStepping - Newton with shunt conductance at every node, from large by decades down to none,
+// each step from previous solution. Starts from last Newton iterate unless it diverged
+func (op *OperatingPoint) performGminStepping(initialSolution []float64) error {
+	mat := op.Circuit.GetMatrix()
+	numGminSteps := 10
+	startGmin := float64(mat.Size) * 0.001
+	gmin := startGmin * math.Pow(10, float64(numGminSteps))
+
+	// 현재 솔루션을 가져와서 Gmin stepping에 사용
+	currentSolution := mat.Solution()
+	if op.checkDivergence(currentSolution) != nil {
+		// Diverged solution is no starting point
+		currentSolution = initialSolution
+	}
+
+	for i := 0; i <= numGminSteps; i++ {
+		err := op.doNRiter(gmin, op.convergence.maxIter, currentSolution)
+		if err != nil {
+			break
+		}
+		currentSolution = mat.Solution() // 다음 반복에 사용할 솔루션 업데이트
+		gmin /= 10
+	}
+
+	return op.doNRiter(0, op.convergence.maxIter, currentSolution)
+}
+
+// performTemperatureStepping - Solve at nominal temperature, by Gmin stepping when Newton fails, then step
+// temperature to circuit temperature, each step starting from previous solution. Step is halved on failure
+func (op *OperatingPoint) performTemperatureStepping() error {
+	target := op.temp
+	defer func() { op.temp = target }()
+	mat := op.Circuit.GetMatrix()
+
+	op.temp = tnom
+	initialSolution := op.calculateInitialEstimate()
+	op.initJunction = true
+	err := op.doNRiter(0, op.convergence.maxIter, initialSolution)
+	if err != nil {
+		err = op.performGminStepping(initialSolution)
+	}
+	if err != nil {
+		return fmt.Errorf("temperature stepping failed at nominal temperature: %v", err)
+	}
+	solution := append([]float64(nil), mat.Solution()...)
+
+	step := (target - tnom) / 10
+	for op.temp != target {
+		next := op.temp + step
+		if math.Abs(step) >= math.Abs(target-op.temp)*(1-1e-9) {
+			next = target
+		}
+		previous := op.temp
+		op.temp = next
+		Logger.Printf("Temperature stepping: %.2f C", next-consts.KELVIN)
+
+		err = op.doNRiter(0, op.convergence.maxIter, solution)
+		if err != nil {
+			op.temp = previous
+			step /= 2
+			if math.Abs(step) < 1e-3 {
+				return fmt.Errorf("temperature stepping failed at %.2f C: %v", next-consts.KELVIN, err)
+			}
+			continue
+		}
+		copy(solution, mat.Solution())
+	}
+	return nil
+}
+
 func (op *OperatingPoint) Execute() error {
+	defer op.stats.addPhase("operating point", time.Now())
 	ckt := op.Circuit
 	mat := ckt.GetMatrix()
 
+	if op.bias != nil {
+		solution, err := ckt.BiasSolution(op.bias)
+		if err != nil {
+			return fmt.Errorf("bias point: %v", err)
+		}
+		err = ckt.UpdateNonlinearVoltages(solution)
+		if err != nil {
+			return fmt.Errorf("updating nonlinear voltages: %v", err)
+		}
+
+		err = op.doNRiter(0, op.convergence.maxIter, solution)
+		if err == nil {
+			op.storeResults(mat.Solution())
+			return nil
+		}
+		Logger.Println("Newton-Raphson from bias point failed, solving from initial estimate...", err)
+	}
+
 	// 선형 소자만으로 초기 추정값 계산
 	initialSolution := op.calculateInitialEstimate()
 	if initialSolution != nil {
 		err := ckt.UpdateNonlinearVoltages(initialSolution)
 		if err != nil {
-			fmt.Println("Warning: Error updating nonlinear voltages:", err)
+			Logger.Println("Warning: Error updating nonlinear voltages:", err)
 		}
 	}
 
 	// 초기 해를 doNRiter에 전달하여 Newton-Raphson 수행
+	op.initJunction = true
 	err := op.doNRiter(0, op.convergence.maxIter, initialSolution)
 	if err == nil {
 		solution := mat.Solution()
@@ -189,31 +348,26 @@ func (op *OperatingPoint) Execute() error {
 		return nil
 	}
 
-	fmt.Println("Newton-Raphson failed, trying Gmin stepping...", err)
-	numGminSteps := 10
-	startGmin := float64(mat.Size) * 0.001
-	gmin := startGmin * math.Pow(10, float64(numGminSteps))
-
-	// 현재 솔루션을 가져와서 Gmin stepping에 사용
-	currentSolution := mat.Solution()
-
-	for i := 0; i <= numGminSteps; i++ {
-		err := op.doNRiter(gmin, op.convergence.maxIter, currentSolution)
-		if err != nil {
-			break
-		}
-		currentSolution = mat.Solution() // 다음 반복에 사용할 솔루션 업데이트
-		gmin /= 10
-	}
-
-	err = op.doNRiter(0, op.convergence.maxIter, currentSolution)
+	Logger.Println("Newton-Raphson failed, trying Gmin stepping...", err)
+	err = op.performGminStepping(initialSolution)
 	if err == nil {
 		solution := mat.Solution()
 		op.storeResults(solution)
 		return nil
 	}
 
-	fmt.Println("Gmin stepping failed, performing source stepping...", err)
+	stepping := "Gmin"
+	if op.temp != tnom {
+		Logger.Println("Gmin stepping failed, performing temperature stepping...", err)
+		err = op.performTemperatureStepping()
+		if err == nil {
+			op.storeResults(mat.Solution())
+			return nil
+		}
+		stepping = "Temperature"
+	}
+
+	Logger.Printf("%s stepping failed, performing source stepping... %v", stepping, err)
 	err = op.performSourceStepping()
 	if err != nil {
 		return fmt.Errorf("source stepping failed: %v", err)
@@ -233,6 +387,7 @@ func (op *OperatingPoint) Execute() error {
 }
 
 func (op *OperatingPoint) storeResults(solution []float64) {
+	op.stats.Points++
 	// Node voltage
 	for nodeName, nodeIdx := range op.Circuit.GetNodeMap() {
 		if nodeIdx > 0 {
@@ -241,8 +396,11 @@ func (op *OperatingPoint) storeResults(solution []float64) {
 		}
 	}
 	// Branch current
-	for devName, branchIdx := range op.Circuit.GetBranchMap() {
-		key := fmt.Sprintf("I(%s)", devName)
-		op.results[key] = []float64{solution[branchIdx]}
+	for key, current := range op.Circuit.GetBranchCurrents(solution) {
+		op.results[key] = []float64{current}
+	}
+	// Device current
+	for key, current := range op.Circuit.GetDeviceCurrents(solution) {
+		op.results[key] = []float64{current}
 	}
 }
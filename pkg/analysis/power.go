@@ -0,0 +1,88 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/edp1096/toy-spice/pkg/device"
+	"github.com/edp1096/toy-spice/pkg/post"
+)
+
+// PowerEfficiency - Average powers of power converter over window of transient
+type PowerEfficiency struct {
+	InputPower  float64 // Power delivered by input sources (W)
+	OutputPower float64 // Power absorbed by output loads (W)
+	Efficiency  float64 // OutputPower / InputPower
+}
+
+// Efficiency - Average input and output power and efficiency over window from to to of steady state.
+// NaN window ends are start and end of transient. Power of two terminal device is V(n+,n-)*I(device),
+// absorbed by loads and delivered by sources, so inputs are usually voltage sources and outputs resistors
+func (tr *Transient) Efficiency(inputs, outputs []string, from, to float64) (PowerEfficiency, error) {
+	var pe PowerEfficiency
+	if len(inputs) == 0 || len(outputs) == 0 {
+		return pe, fmt.Errorf("efficiency requires input sources and output loads")
+	}
+	times, ok := tr.results["TIME"]
+	if !ok {
+		return pe, fmt.Errorf("efficiency requires transient results")
+	}
+	if math.IsNaN(from) {
+		from = max(times[0], tr.startTime)
+	}
+	if math.IsNaN(to) {
+		to = times[len(times)-1]
+	}
+	if to <= from {
+		return pe, fmt.Errorf("efficiency requires window end after start")
+	}
+
+	for _, name := range inputs {
+		power, err := tr.averagePower(name, from, to)
+		if err != nil {
+			return pe, err
+		}
+		pe.InputPower -= power
+	}
+	for _, name := range outputs {
+		power, err := tr.averagePower(name, from, to)
+		if err != nil {
+			return pe, err
+		}
+		pe.OutputPower += power
+	}
+	if pe.InputPower <= 0 {
+		return pe, fmt.Errorf("input power %g W is not positive", pe.InputPower)
+	}
+	pe.Efficiency = pe.OutputPower / pe.InputPower
+	return pe, nil
+}
+
+// averagePower - Average power absorbed by two terminal device over window
+func (tr *Transient) averagePower(name string, from, to float64) (float64, error) {
+	var dev device.Device
+	for _, d := range tr.Circuit.GetDevices() {
+		if strings.EqualFold(d.GetName(), name) {
+			dev = d
+			break
+		}
+	}
+	if dev == nil {
+		return 0, fmt.Errorf("device %s not found", name)
+	}
+	nodes := dev.GetNodeNames()
+	if len(nodes) != 2 {
+		return 0, fmt.Errorf("power of %s requires two terminal device", name)
+	}
+
+	power, err := EvaluateExpression(tr.results, fmt.Sprintf("V(%s,%s)*I(%s)", nodes[0], nodes[1], dev.GetName()))
+	if err != nil {
+		return 0, fmt.Errorf("power of %s: %v", name, err)
+	}
+	average, err := post.Average(tr.results["TIME"], power, from, to)
+	if err != nil {
+		return 0, fmt.Errorf("power of %s: %v", name, err)
+	}
+	return average, nil
+}
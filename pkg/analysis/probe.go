@@ -0,0 +1,271 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"slices"
+	"strings"
+
+	"github.com/edp1096/toy-spice/pkg/netlist"
+)
+
+// Probe - Values of output variable from OP, TRAN or DC results.
+// V(n1,n2) is computed as difference of node voltages V(n1)-V(n2).
+func Probe(results map[string][]float64, name string) ([]float64, error) {
+	kind, args, err := parseProbeName(name)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(magneticKinds, kind) {
+		values, ok := lookupResult(results, kind+"("+args[0]+")")
+		if !ok {
+			return nil, fmt.Errorf("no magnetic result for %s", name)
+		}
+		return values, nil
+	}
+	if len(kind) > 1 {
+		return nil, fmt.Errorf("%s is only available in AC analysis", name)
+	}
+
+	if kind == "I" {
+		values, ok := lookupResult(results, "I("+args[0]+")")
+		if !ok {
+			return nil, fmt.Errorf("no current result for %s", name)
+		}
+		return values, nil
+	}
+
+	v1, ok1 := nodeResult(results, args[0])
+	if len(args) == 1 {
+		if !ok1 {
+			return nil, fmt.Errorf("no voltage result for %s", name)
+		}
+		return v1, nil
+	}
+	v2, ok2 := nodeResult(results, args[1])
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("no voltage result for %s", name)
+	}
+
+	size := max(len(v1), len(v2))
+	values := make([]float64, size)
+	for i := range values {
+		values[i] = valueAt(v1, i) - valueAt(v2, i)
+	}
+	return values, nil
+}
+
+// ProbeAC - Complex values of output variable from AC results.
+// V(n1,n2) is computed as difference of node phasors V(n1)-V(n2).
+// VDB, VP, VG, VM (and I variants) give the same phasor as V (or I).
+func ProbeAC(results map[string][]float64, name string) ([]complex128, error) {
+	kind, args, err := parseProbeName(name)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(magneticKinds, kind) {
+		return nil, fmt.Errorf("%s is only available in transient analysis", name)
+	}
+
+	if kind[0] == 'I' {
+		values, ok := phasorResult(results, "I("+args[0]+")")
+		if !ok {
+			return nil, fmt.Errorf("no current result for %s", name)
+		}
+		return values, nil
+	}
+
+	v1, ok1 := nodePhasor(results, args[0])
+	if len(args) == 1 {
+		if !ok1 {
+			return nil, fmt.Errorf("no voltage result for %s", name)
+		}
+		return v1, nil
+	}
+	v2, ok2 := nodePhasor(results, args[1])
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("no voltage result for %s", name)
+	}
+
+	size := max(len(v1), len(v2))
+	values := make([]complex128, size)
+	for i := range values {
+		var a, b complex128
+		if i < len(v1) {
+			a = v1[i]
+		}
+		if i < len(v2) {
+			b = v2[i]
+		}
+		values[i] = a - b
+	}
+	return values, nil
+}
+
+// SelectResults - Results of requested output variables only.
+// Sweep variables (TIME, FREQ, SWEEP1, SWEEP2, SWEEP(source)), currents of swept sources and loop gain of
+// stability analysis are always kept.
+func SelectResults(results map[string][]float64, names []string) (map[string][]float64, error) {
+	selected := make(map[string][]float64)
+	for _, key := range []string{"TIME", "FREQ", "SWEEP1", "SWEEP2"} {
+		if values, ok := results[key]; ok {
+			selected[key] = values
+		}
+	}
+	for key, values := range results {
+		if strings.HasPrefix(key, loopGainName+"_") {
+			selected[key] = values
+		}
+	}
+
+	// Swept sources of DC sweep and their currents, for I-V curves
+	for _, source := range SweptSources(results) {
+		selected[SweepName(source)] = results[SweepName(source)]
+		if values, ok := lookupResult(results, "I("+source+")"); ok {
+			selected["I("+source+")"] = values
+		}
+	}
+
+	_, isAC := results["FREQ"]
+	for _, name := range names {
+		key := normalizeProbeName(name)
+		if isAC {
+			values, err := ProbeAC(results, name)
+			if err != nil {
+				return nil, err
+			}
+			mag := make([]float64, len(values))
+			phase := make([]float64, len(values))
+			for i, value := range values {
+				mag[i] = cmplx.Abs(value)
+				phase[i] = cmplx.Phase(value) * 180.0 / math.Pi
+			}
+			selected[key+"_MAG"] = mag
+			selected[key+"_PHASE"] = phase
+
+			// Distortion products of node
+			for _, suffix := range distoProducts {
+				if mag, ok := results[key+suffix+"_MAG"]; ok {
+					selected[key+suffix+"_MAG"] = mag
+					selected[key+suffix+"_PHASE"] = results[key+suffix+"_PHASE"]
+				}
+			}
+			continue
+		}
+
+		values, err := Probe(results, name)
+		if err != nil {
+			return nil, err
+		}
+		selected[key] = values
+	}
+
+	if freqs, isAC := selected["FREQ"]; isAC {
+		deriveACResults(selected, freqs)
+	}
+
+	return selected, nil
+}
+
+// parseProbeName - Split "V(n1,n2)", "VDB(n)" or "I(V1)" into kind and arguments
+func parseProbeName(name string) (string, []string, error) {
+	name = strings.TrimSpace(name)
+	open := strings.Index(name, "(")
+	if open < 1 || !strings.HasSuffix(name, ")") {
+		return "", nil, fmt.Errorf("invalid output variable: %s", name)
+	}
+
+	kind := strings.ToUpper(name[:open])
+	if !slices.Contains(probeKinds, kind) && !slices.Contains(magneticKinds, kind) {
+		return "", nil, fmt.Errorf("invalid output variable: %s", name)
+	}
+
+	var args []string
+	for _, arg := range strings.Split(name[open+1:len(name)-1], ",") {
+		if arg = strings.TrimSpace(arg); arg != "" {
+			args = append(args, arg)
+		}
+	}
+
+	switch {
+	case slices.Contains(magneticKinds, kind) && len(args) == 1:
+	case kind[0] == 'V' && (len(args) == 1 || len(args) == 2):
+	case kind[0] == 'I' && len(args) == 1:
+	default:
+		return "", nil, fmt.Errorf("invalid output variable: %s", name)
+	}
+
+	return kind, args, nil
+}
+
+// Output variable kinds. M, P, DB and G suffixes are magnitude, phase, dB and group delay of AC result
+var probeKinds = []string{"V", "VM", "VP", "VDB", "VG", "I", "IM", "IP", "IDB", "IG"}
+
+// Transient magnetic outputs. B and H of winding, PLOSS of core
+var magneticKinds = []string{"B", "H", "PLOSS"}
+
+// normalizeProbeName - Result key of output variable. AC suffixed kinds map to base V or I key
+func normalizeProbeName(name string) string {
+	kind, args, err := parseProbeName(name)
+	if err != nil {
+		return name
+	}
+	if slices.Contains(magneticKinds, kind) {
+		return kind + "(" + args[0] + ")"
+	}
+	return kind[:1] + "(" + strings.Join(args, ",") + ")"
+}
+
+// lookupResult - Result by exact key, then case insensitive
+func lookupResult(results map[string][]float64, key string) ([]float64, bool) {
+	if values, ok := results[key]; ok {
+		return values, true
+	}
+	for name, values := range results {
+		if strings.EqualFold(name, key) {
+			return values, true
+		}
+	}
+	return nil, false
+}
+
+// nodeResult - Node voltage result. Ground is nil slice, read as zero
+func nodeResult(results map[string][]float64, node string) ([]float64, bool) {
+	if netlist.IsGround(node) {
+		return nil, true
+	}
+	return lookupResult(results, "V("+node+")")
+}
+
+func nodePhasor(results map[string][]float64, node string) ([]complex128, bool) {
+	if netlist.IsGround(node) {
+		return nil, true
+	}
+	return phasorResult(results, "V("+node+")")
+}
+
+// phasorResult - Rebuild complex values from _MAG and _PHASE results
+func phasorResult(results map[string][]float64, key string) ([]complex128, bool) {
+	mag, ok := lookupResult(results, key+"_MAG")
+	if !ok {
+		return nil, false
+	}
+	phase, ok := lookupResult(results, key+"_PHASE")
+	if !ok {
+		return nil, false
+	}
+
+	values := make([]complex128, len(mag))
+	for i := range mag {
+		values[i] = cmplx.Rect(mag[i], valueAt(phase, i)*math.Pi/180.0)
+	}
+	return values, true
+}
+
+func valueAt(values []float64, i int) float64 {
+	if i < len(values) {
+		return values[i]
+	}
+	return 0
+}
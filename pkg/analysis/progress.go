@@ -0,0 +1,16 @@
+package analysis
+
+// ProgressFunc - Progress of analysis. done of total is simulated time of transient, or points of sweep.
+// Called on analysis goroutine at every accepted point, so it must return quickly
+type ProgressFunc func(done, total float64)
+
+// SetProgress - Report progress of transient and sweep analyses to fn. nil disables it
+func (a *BaseAnalysis) SetProgress(fn ProgressFunc) {
+	a.progress = fn
+}
+
+func (a *BaseAnalysis) reportProgress(done, total float64) {
+	if a.progress != nil {
+		a.progress(done, total)
+	}
+}
@@ -0,0 +1,161 @@
+package analysis
+
+import (
+	"encoding/json"
+	"math"
+	"math/cmplx"
+	"strconv"
+	"strings"
+)
+
+// ResultSchema - Schema of Result JSON. Changed only by incompatible change of fields
+const ResultSchema = "toy-spice/result/1"
+
+// Result - Results of analysis by type and parameters, marshaled as canonical JSON:
+//
+//	{
+//	  "schema": "toy-spice/result/1",
+//	  "analysis": "ac",
+//	  "parameters": {"fstart": 1, "fstop": 1e6, "points": 10, "sweep": "DEC"},
+//	  "vectors": [
+//	    {"name": "FREQ", "unit": "Hz", "axis": true, "values": [...]},
+//	    {"name": "V(2)", "unit": "V", "complex": true, "real": [...], "imag": [...]}
+//	  ]
+//	}
+//
+// Vectors are in order of ResultNames. AC magnitude and phase pairs are written as one complex vector.
+// NaN and Inf are not valid JSON numbers, they are written as null
+type Result struct {
+	Analysis   string               // op, tran, ac, dc, sp, disto, hb, stb
+	Parameters map[string]any       // Analysis parameters. eg. tstep, tstop of tran
+	Values     map[string][]float64 // Results by name as GetResults
+	Warnings   []string             // Warnings of RunNetlist, not part of JSON
+}
+
+// NewResult - Result of executed analysis. Results nil are all results of analysis,
+// otherwise selection of them. eg. by SelectResults
+func NewResult(a Analysis, results map[string][]float64) *Result {
+	if results == nil {
+		results = a.GetResults()
+	}
+	r := &Result{Values: results, Parameters: make(map[string]any)}
+
+	switch a := a.(type) {
+	case *OperatingPoint:
+		r.Analysis = "op"
+	case *Transient:
+		r.Analysis = "tran"
+		r.Parameters["tstart"] = a.startTime
+		r.Parameters["tstop"] = a.stopTime
+		r.Parameters["tstep"] = a.printStep
+		r.Parameters["tmax"] = a.maxStep
+		r.Parameters["uic"] = a.useUIC
+	case *SPAnalysis:
+		r.Analysis = "sp"
+		a.ACAnalysis.sweepParameters(r.Parameters)
+		var ports []string
+		for _, port := range a.ports {
+			ports = append(ports, port.Name)
+		}
+		r.Parameters["ports"] = ports
+	case *ACAnalysis:
+		r.Analysis = "ac"
+		a.sweepParameters(r.Parameters)
+	case *StabilityAnalysis:
+		r.Analysis = "stb"
+		a.ACAnalysis.sweepParameters(r.Parameters)
+		r.Parameters["probe"] = a.probe.GetName()
+	case *DistoAnalysis:
+		r.Analysis = "disto"
+		a.ACAnalysis.sweepParameters(r.Parameters)
+		r.Parameters["f2overf1"] = a.f2OverF1
+	case *HarmonicBalance:
+		r.Analysis = "hb"
+		r.Parameters["f0"] = a.fundamental
+		r.Parameters["harmonics"] = a.harmonics
+	case *DCSweep:
+		r.Analysis = "dc"
+		for i, source := range a.sourceNames {
+			n := strconv.Itoa(i + 1)
+			r.Parameters["source"+n] = source
+			r.Parameters["start"+n] = a.startVals[i]
+			r.Parameters["stop"+n] = a.stopVals[i]
+			r.Parameters["increment"+n] = a.increments[i]
+		}
+	}
+	return r
+}
+
+func (ac *ACAnalysis) sweepParameters(params map[string]any) {
+	params["sweep"] = ac.pointsType
+	if ac.pointsType == "LIST" {
+		params["frequencies"] = jsonValues(ac.frequencies)
+		return
+	}
+	params["fstart"] = ac.startFreq
+	params["fstop"] = ac.stopFreq
+	params["points"] = ac.numPoints
+}
+
+// resultVector - Vector of Result JSON. Complex vectors have real and imag instead of values
+type resultVector struct {
+	Name    string     `json:"name"`
+	Unit    string     `json:"unit,omitempty"`
+	Axis    bool       `json:"axis,omitempty"`
+	Complex bool       `json:"complex,omitempty"`
+	Values  jsonValues `json:"values,omitempty"`
+	Real    jsonValues `json:"real,omitempty"`
+	Imag    jsonValues `json:"imag,omitempty"`
+}
+
+func (r *Result) MarshalJSON() ([]byte, error) {
+	vectors := []resultVector{}
+	for _, name := range ResultNames(r.Values) {
+		if base, ok := strings.CutSuffix(name, "_PHASE"); ok {
+			if _, paired := r.Values[base+"_MAG"]; paired {
+				continue
+			}
+		}
+
+		base, ok := strings.CutSuffix(name, "_MAG")
+		phase, paired := r.Values[base+"_PHASE"]
+		if ok && paired && len(phase) == len(r.Values[name]) {
+			vector := resultVector{Name: base, Unit: variableUnit(base), Complex: true}
+			for i, mag := range r.Values[name] {
+				value := cmplx.Rect(mag, phase[i]*math.Pi/180.0)
+				vector.Real = append(vector.Real, real(value))
+				vector.Imag = append(vector.Imag, imag(value))
+			}
+			vectors = append(vectors, vector)
+			continue
+		}
+
+		info := DescribeResult(name)
+		vectors = append(vectors, resultVector{Name: name, Unit: info.Unit, Axis: info.Axis, Values: r.Values[name]})
+	}
+
+	return json.Marshal(struct {
+		Schema     string         `json:"schema"`
+		Analysis   string         `json:"analysis"`
+		Parameters map[string]any `json:"parameters"`
+		Vectors    []resultVector `json:"vectors"`
+	}{ResultSchema, r.Analysis, r.Parameters, vectors})
+}
+
+// jsonValues - Values marshaled with NaN and Inf as null
+type jsonValues []float64
+
+func (v jsonValues) MarshalJSON() ([]byte, error) {
+	out := []byte{'['}
+	for i, value := range v {
+		if i > 0 {
+			out = append(out, ',')
+		}
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			out = append(out, "null"...)
+			continue
+		}
+		out = strconv.AppendFloat(out, value, 'g', -1, 64)
+	}
+	return append(out, ']'), nil
+}
@@ -0,0 +1,76 @@
+package analysis
+
+import (
+	"fmt"
+
+	"github.com/edp1096/toy-spice/pkg/circuit"
+	"github.com/edp1096/toy-spice/pkg/netlist"
+)
+
+// RunNetlist - Parse netlist, build circuit, run its analyses and return results of each analysis in order of
+// netlist, selected by .print/.plot when given. analyses selects analyses by name, eg. op, ac, all when empty.
+// .include and .lib files are resolved from current directory. Warnings of parse, netlist check, device setup
+// and analysis are in Result.Warnings
+func RunNetlist(input string, analyses ...string) ([]*Result, error) {
+	ckt, err := netlist.Parse(input)
+	if err != nil {
+		return nil, fmt.Errorf("parsing netlist: %v", err)
+	}
+	netlists, err := ckt.Split(analyses...)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*Result
+	for _, n := range netlists {
+		r, err := Run(n)
+		if err != nil {
+			return nil, fmt.Errorf("%s analysis: %v", n.Analysis, err)
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// Run - Check parsed netlist, build circuit and run its Analysis with default circuit options
+func Run(ckt *netlist.NetlistData) (*Result, error) {
+	warnings := append([]string{}, ckt.Warnings...)
+	lintWarnings, err := netlist.Lint(ckt)
+	warnings = append(warnings, lintWarnings...)
+	if err != nil {
+		return nil, fmt.Errorf("checking netlist: %v", err)
+	}
+
+	c, err := circuit.FromNetlist(ckt, nil)
+	if err != nil {
+		return nil, err
+	}
+	warnings = append(warnings, c.Warnings()...)
+
+	analyzer, err := FromNetlist(ckt)
+	if err != nil {
+		return nil, err
+	}
+	err = analyzer.Setup(c)
+	if err != nil {
+		return nil, fmt.Errorf("analysis setup failed: %v", err)
+	}
+	err = analyzer.Execute()
+	if tran, ok := analyzer.(*Transient); ok {
+		warnings = append(warnings, tran.Warnings()...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("analysis execution failed: %v", err)
+	}
+
+	results := analyzer.GetResults()
+	if len(ckt.Outputs) > 0 {
+		results, err = SelectResults(results, ckt.Outputs)
+		if err != nil {
+			return nil, fmt.Errorf("selecting outputs: %v", err)
+		}
+	}
+	r := NewResult(analyzer, results)
+	r.Warnings = warnings
+	return r, nil
+}
@@ -0,0 +1,78 @@
+package analysis
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/edp1096/toy-spice/pkg/netlist"
+)
+
+// SetSaves - Keep only results of output variables in memory and streaming output, everything else is
+// discarded at each timepoint. V(n1,n2) keeps both node voltages. Empty keeps all
+func (tr *Transient) SetSaves(names []string) error {
+	keys, err := saveKeys(names)
+	if err != nil {
+		return err
+	}
+	tr.saves = keys
+	return nil
+}
+
+// saveKeys - Result keys of output variables. eg. VDB(2) -> V(2), V(1,0) -> V(1)
+func saveKeys(names []string) ([]string, error) {
+	var keys []string
+	add := func(key string) {
+		if !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	for _, name := range names {
+		kind, args, err := parseProbeName(name)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case slices.Contains(magneticKinds, kind):
+			add(kind + "(" + args[0] + ")")
+		case kind[0] == 'I':
+			add("I(" + args[0] + ")")
+		default:
+			for _, node := range args {
+				if !netlist.IsGround(node) {
+					add("V(" + node + ")")
+				}
+			}
+		}
+	}
+	return keys, nil
+}
+
+// saveFilter - Exact keys of solution kept by .save, resolved case insensitive from keys of first solution
+type saveFilter map[string]bool
+
+func newSaveFilter(keys []string, solution map[string]float64) (saveFilter, error) {
+	filter := make(saveFilter)
+	for _, key := range keys {
+		found := false
+		for name := range solution {
+			if strings.EqualFold(name, key) {
+				filter[name], found = true, true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown output variable of .save: %s", key)
+		}
+	}
+	return filter, nil
+}
+
+func (f saveFilter) apply(solution map[string]float64) map[string]float64 {
+	saved := make(map[string]float64, len(f))
+	for name := range f {
+		if value, ok := solution[name]; ok {
+			saved[name] = value
+		}
+	}
+	return saved
+}
@@ -0,0 +1,171 @@
+package analysis
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/edp1096/toy-spice/pkg/circuit"
+	"github.com/edp1096/toy-spice/pkg/device"
+)
+
+// SPAnalysis - S-parameters of P ports over AC sweep.
+// Each port is excited in turn by 1V behind its Z0 while other ports are terminated by their Z0.
+type SPAnalysis struct {
+	ACAnalysis
+	ports []*device.Port
+}
+
+func NewSP(fStart, fStop float64, nPoints int, pType string) *SPAnalysis {
+	return &SPAnalysis{ACAnalysis: *NewAC(fStart, fStop, nPoints, pType)}
+}
+
+// NewSPList - S-parameters at given frequencies
+func NewSPList(freqs []float64) *SPAnalysis {
+	return &SPAnalysis{ACAnalysis: *NewACList(freqs)}
+}
+
+func (sp *SPAnalysis) Setup(ckt *circuit.Circuit) error {
+	sp.ports = nil
+	for _, dev := range ckt.GetDevices() {
+		if port, ok := dev.(*device.Port); ok {
+			sp.ports = append(sp.ports, port)
+		}
+	}
+	if len(sp.ports) == 0 {
+		return fmt.Errorf("S-parameter analysis requires at least one port")
+	}
+	sort.Slice(sp.ports, func(i, j int) bool { return sp.ports[i].Number < sp.ports[j].Number })
+	for i, port := range sp.ports {
+		if port.Number != i+1 {
+			return fmt.Errorf("ports must be numbered 1 to %d: %s is port %d", len(sp.ports), port.GetName(), port.Number)
+		}
+	}
+
+	return sp.ACAnalysis.Setup(ckt)
+}
+
+func (sp *SPAnalysis) Execute() error {
+	if sp.Circuit == nil {
+		return fmt.Errorf("circuit not set")
+	}
+	defer sp.excite(-1)
+	defer sp.stats.addPhase("s-parameters", time.Now())
+
+	n := len(sp.ports)
+	for _, freq := range sp.frequencies {
+		solution := make(map[string]complex128)
+
+		for j := range n {
+			sp.excite(j)
+
+			sp.Circuit.Status = &device.CircuitStatus{
+				Frequency: freq,
+				Mode:      device.ACAnalysis,
+				Temp:      sp.Circuit.Temp,
+			}
+
+			mat := sp.Circuit.GetMatrix()
+			mat.Clear()
+			err := sp.Circuit.Stamp(sp.Circuit.Status)
+			if err != nil {
+				return fmt.Errorf("stamping error at f=%g: %v", freq, err)
+			}
+			err = sp.solve(mat)
+			if err != nil {
+				return fmt.Errorf("matrix solve error at f=%g: %v", freq, sp.Circuit.DiagnoseSingular(err))
+			}
+
+			voltages := make([]complex128, mat.Size+1)
+			for _, nodeIdx := range sp.Circuit.GetNodeMap() {
+				if nodeIdx > 0 {
+					real, imag := mat.GetComplexSolution(nodeIdx)
+					voltages[nodeIdx] = complex(real, imag)
+				}
+			}
+
+			// Incident wave a_j = 1/(2*sqrt(Z0j)).
+			// Sjj = 2*Vj - 1, Sij = 2*Vi*sqrt(Z0j/Z0i)
+			for i, port := range sp.ports {
+				v := port.PortVoltageAC(voltages)
+				s := 2 * v * complex(math.Sqrt(sp.ports[j].Z0/port.Z0), 0)
+				if i == j {
+					s = 2*v - 1
+				}
+				solution[sParamName(i+1, j+1)] = s
+			}
+		}
+
+		sp.StoreACResult(freq, solution)
+		sp.reportProgress(float64(sp.stats.Points), float64(len(sp.frequencies)))
+	}
+
+	deriveACResults(sp.results, sp.results["FREQ"])
+
+	return nil
+}
+
+// excite - Drive port of index, -1 leaves all ports terminated
+func (sp *SPAnalysis) excite(index int) {
+	for i, port := range sp.ports {
+		port.SetExcited(i == index)
+	}
+}
+
+// sParamName - Result name of S-parameter. eg. S21 is from port 1 to port 2
+func sParamName(i, j int) string {
+	if i > 9 || j > 9 {
+		return fmt.Sprintf("S%d_%d", i, j)
+	}
+	return fmt.Sprintf("S%d%d", i, j)
+}
+
+// WriteTouchstone - Touchstone 1.0 file (.sNp) of S-parameters in real/imaginary format.
+// Ports must share reference impedance.
+func (sp *SPAnalysis) WriteTouchstone(w io.Writer) error {
+	n := len(sp.ports)
+	if n == 0 {
+		return fmt.Errorf("no ports")
+	}
+	z0 := sp.ports[0].Z0
+	for _, port := range sp.ports[1:] {
+		if port.Z0 != z0 {
+			return fmt.Errorf("touchstone requires equal port impedances: %s is %g, %s is %g", sp.ports[0].GetName(), z0, port.GetName(), port.Z0)
+		}
+	}
+
+	freqs := sp.results["FREQ"]
+	params := sp.GetComplexResults()
+
+	fmt.Fprintf(w, "! %d-port S-parameters of %s\n", n, sp.Circuit.Name())
+	fmt.Fprintf(w, "# HZ S RI R %g\n", z0)
+
+	for k, freq := range freqs {
+		fmt.Fprintf(w, "%.9e", freq)
+
+		// 2-port order is S11 S21 S12 S22, others are row by row with 4 entries per line
+		if n == 2 {
+			for _, name := range []string{"S11", "S21", "S12", "S22"} {
+				s := params[name][k]
+				fmt.Fprintf(w, " %.9e %.9e", real(s), imag(s))
+			}
+			fmt.Fprintln(w)
+			continue
+		}
+
+		for i := 1; i <= n; i++ {
+			for j := 1; j <= n; j++ {
+				if j > 1 && (j-1)%4 == 0 {
+					fmt.Fprint(w, "\n")
+				}
+				s := params[sParamName(i, j)][k]
+				fmt.Fprintf(w, " %.9e %.9e", real(s), imag(s))
+			}
+			fmt.Fprintln(w)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package analysis
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/edp1096/toy-spice/pkg/matrix"
+)
+
+// Stats - Solver effort of analysis for tuning of tolerances and timestep options
+type Stats struct {
+	Iterations     int // Newton iterations, including failed attempts
+	Points         int // Accepted timepoints, sweep points or frequencies
+	RejectedSteps  int // Transient timesteps rejected by convergence failure or truncation error
+	Factorizations int // LU factorizations of circuit matrix
+	Phases         []PhaseTime
+}
+
+// PhaseTime - Wall-clock time of analysis phase. eg. operating point, transient
+type PhaseTime struct {
+	Name    string
+	Elapsed time.Duration
+}
+
+// IterationsPerPoint - Average Newton iterations by accepted point
+func (s Stats) IterationsPerPoint() float64 {
+	if s.Points == 0 {
+		return 0
+	}
+	return float64(s.Iterations) / float64(s.Points)
+}
+
+// Total - Wall-clock time of all phases
+func (s Stats) Total() time.Duration {
+	var total time.Duration
+	for _, phase := range s.Phases {
+		total += phase.Elapsed
+	}
+	return total
+}
+
+func (s Stats) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%-24s%d\n", "Newton iterations:", s.Iterations)
+	fmt.Fprintf(&sb, "%-24s%d\n", "Accepted points:", s.Points)
+	fmt.Fprintf(&sb, "%-24s%d\n", "Rejected timesteps:", s.RejectedSteps)
+	fmt.Fprintf(&sb, "%-24s%.2f\n", "Iterations/point:", s.IterationsPerPoint())
+	fmt.Fprintf(&sb, "%-24s%d\n", "Factorizations:", s.Factorizations)
+	for _, phase := range s.Phases {
+		fmt.Fprintf(&sb, "%-24s%v\n", "Time "+phase.Name+":", phase.Elapsed)
+	}
+	fmt.Fprintf(&sb, "%-24s%v\n", "Time total:", s.Total())
+	return sb.String()
+}
+
+// addPhase - Accumulate time of phase started at start. Repeated phases are summed
+func (s *Stats) addPhase(name string, start time.Time) {
+	s.addElapsed(name, time.Since(start))
+}
+
+func (s *Stats) addElapsed(name string, elapsed time.Duration) {
+	for i := range s.Phases {
+		if s.Phases[i].Name == name {
+			s.Phases[i].Elapsed += elapsed
+			return
+		}
+	}
+	s.Phases = append(s.Phases, PhaseTime{Name: name, Elapsed: elapsed})
+}
+
+// merge - Stats of nested analysis, eg. operating point of transient. Points are not counted
+func (s Stats) merge(nested Stats) Stats {
+	merged := nested
+	merged.Phases = nil
+	merged.Points = s.Points
+	merged.Iterations += s.Iterations
+	merged.RejectedSteps += s.RejectedSteps
+	merged.Factorizations += s.Factorizations
+	for _, phase := range append(append([]PhaseTime{}, nested.Phases...), s.Phases...) {
+		merged.addElapsed(phase.Name, phase.Elapsed)
+	}
+	return merged
+}
+
+// Stats - Solver effort of setup and execution
+func (a *BaseAnalysis) Stats() Stats {
+	return a.stats
+}
+
+// solve - Factor and solve circuit matrix, counted in stats
+func (a *BaseAnalysis) solve(mat *matrix.CircuitMatrix) error {
+	a.stats.Factorizations++
+	return mat.Solve()
+}
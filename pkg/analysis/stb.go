@@ -0,0 +1,188 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/edp1096/toy-spice/pkg/circuit"
+	"github.com/edp1096/toy-spice/pkg/device"
+)
+
+// Result name of loop gain. eg. LOOPGAIN_DB, LOOPGAIN_PHASE_UNWRAPPED
+const loopGainName = "LOOPGAIN"
+
+// StabilityAnalysis - Loop gain of feedback loop broken by Y probe over AC sweep, by Tian's method.
+// Each frequency is solved twice, with 1V series injection and with 1A shunt injection at probe,
+// which gives true loop gain of bilateral loop without opening it. Results are LOOPGAIN only.
+// Loop gain is positive at DC for negative feedback.
+type StabilityAnalysis struct {
+	ACAnalysis
+	probeName string // Empty is only probe of circuit
+	probe     *device.LoopProbe
+}
+
+// StabilityMargins - Gain and phase margins of loop gain. NaN when loop gain has no crossing in sweep
+type StabilityMargins struct {
+	UnityGainFreq  float64 // Frequency of |T| = 1 (Hz)
+	PhaseMargin    float64 // 180 + phase of T at unity gain (deg)
+	PhaseCrossFreq float64 // Frequency of phase -180 (Hz)
+	GainMargin     float64 // -|T| in dB at phase crossover (dB)
+}
+
+func NewSTB(fStart, fStop float64, nPoints int, pType string, probe string) *StabilityAnalysis {
+	return &StabilityAnalysis{ACAnalysis: *NewAC(fStart, fStop, nPoints, pType), probeName: probe}
+}
+
+// NewSTBList - Loop gain at given frequencies
+func NewSTBList(freqs []float64, probe string) *StabilityAnalysis {
+	return &StabilityAnalysis{ACAnalysis: *NewACList(freqs), probeName: probe}
+}
+
+func (s *StabilityAnalysis) Setup(ckt *circuit.Circuit) error {
+	s.probe = nil
+	var names []string
+	for _, dev := range ckt.GetDevices() {
+		probe, ok := dev.(*device.LoopProbe)
+		if !ok {
+			continue
+		}
+		names = append(names, probe.GetName())
+		if s.probeName == "" || strings.EqualFold(probe.GetName(), s.probeName) {
+			s.probe = probe
+		}
+	}
+	switch {
+	case len(names) == 0:
+		return fmt.Errorf("stability analysis requires loop probe (Y element)")
+	case s.probeName == "" && len(names) > 1:
+		return fmt.Errorf("stability analysis requires probe name, circuit has probes %s", strings.Join(names, ", "))
+	case s.probe == nil:
+		return fmt.Errorf("loop probe %s not found", s.probeName)
+	}
+
+	return s.ACAnalysis.Setup(ckt)
+}
+
+func (s *StabilityAnalysis) Execute() error {
+	if s.Circuit == nil {
+		return fmt.Errorf("circuit not set")
+	}
+	defer s.probe.SetInjection(0, 0)
+	defer s.stats.addPhase("stability", time.Now())
+
+	n1, n2 := s.probe.GetNodes()[0], s.probe.GetNodes()[1]
+	for _, freq := range s.frequencies {
+		// Voltage injection, Tv = -V(n+)/V(n-)
+		s.probe.SetInjection(1, 0)
+		voltages, err := s.solveAt(freq)
+		if err != nil {
+			return err
+		}
+		tv := -voltageAt(voltages, n1) / voltageAt(voltages, n2)
+
+		// Current injection. Current into n+ side is -I(probe), into n- side is I(probe)+1
+		s.probe.SetInjection(0, 1)
+		voltages, err = s.solveAt(freq)
+		if err != nil {
+			return err
+		}
+		i := voltages[s.probe.BranchIndex()]
+
+		// Middlebrook T = (Tv*Ti - 1)/(Tv + Ti + 2) with Ti = -I/(I+1), free of pole at I = -1
+		loopGain := -(tv*i + i + 1) / (tv*(i+1) + i + 2)
+
+		s.StoreACResult(freq, map[string]complex128{loopGainName: loopGain})
+		s.reportProgress(float64(s.stats.Points), float64(len(s.frequencies)))
+	}
+
+	deriveACResults(s.results, s.results["FREQ"])
+
+	return nil
+}
+
+// solveAt - Phasors of circuit unknowns at freq driven by injection of probe
+func (s *StabilityAnalysis) solveAt(freq float64) ([]complex128, error) {
+	ckt := s.Circuit
+	ckt.Status = &device.CircuitStatus{
+		Frequency: freq,
+		Mode:      device.ACAnalysis,
+		Temp:      ckt.Temp,
+	}
+
+	mat := ckt.GetMatrix()
+	mat.Clear()
+	err := ckt.Stamp(ckt.Status)
+	if err != nil {
+		return nil, fmt.Errorf("stamping error at f=%g: %v", freq, err)
+	}
+	err = s.solve(mat)
+	if err != nil {
+		return nil, fmt.Errorf("matrix solve error at f=%g: %v", freq, ckt.DiagnoseSingular(err))
+	}
+
+	voltages := make([]complex128, mat.Size+1)
+	for i := 1; i <= mat.Size; i++ {
+		re, im := mat.GetComplexSolution(i)
+		voltages[i] = complex(re, im)
+	}
+	return voltages, nil
+}
+
+func voltageAt(voltages []complex128, node int) complex128 {
+	if node <= 0 {
+		return 0
+	}
+	return voltages[node]
+}
+
+// Margins - Gain and phase margins of loop gain, interpolated at first crossings on log frequency
+func (s *StabilityAnalysis) Margins() StabilityMargins {
+	m := StabilityMargins{UnityGainFreq: math.NaN(), PhaseMargin: math.NaN(), PhaseCrossFreq: math.NaN(), GainMargin: math.NaN()}
+	freqs := s.results["FREQ"]
+	db := s.results[loopGainName+"_DB"]
+	phase := s.results[loopGainName+"_PHASE_UNWRAPPED"]
+	if len(freqs) < 2 || len(db) != len(freqs) || len(phase) != len(freqs) {
+		return m
+	}
+
+	// Unwrapped phase starts in (-180, 180]
+	offset := -360 * math.Round(phase[0]/360)
+	for k := 1; k < len(freqs); k++ {
+		if math.IsNaN(m.UnityGainFreq) && db[k-1] >= 0 && db[k] < 0 {
+			t := db[k-1] / (db[k-1] - db[k])
+			m.UnityGainFreq = logInterpolate(freqs[k-1], freqs[k], t)
+			m.PhaseMargin = 180 + phase[k-1] + offset + t*(phase[k]-phase[k-1])
+		}
+		p1, p2 := phase[k-1]+offset, phase[k]+offset
+		if math.IsNaN(m.PhaseCrossFreq) && p1 > -180 && p2 <= -180 {
+			t := (p1 + 180) / (p1 - p2)
+			m.PhaseCrossFreq = logInterpolate(freqs[k-1], freqs[k], t)
+			m.GainMargin = -(db[k-1] + t*(db[k]-db[k-1]))
+		}
+	}
+	return m
+}
+
+func logInterpolate(f1, f2, t float64) float64 {
+	if f1 <= 0 || f2 <= 0 {
+		return f1 + t*(f2-f1)
+	}
+	return math.Exp(math.Log(f1) + t*(math.Log(f2)-math.Log(f1)))
+}
+
+func (m StabilityMargins) String() string {
+	var sb strings.Builder
+	if math.IsNaN(m.UnityGainFreq) {
+		sb.WriteString("  Phase margin:  no unity gain crossing\n")
+	} else {
+		fmt.Fprintf(&sb, "  Phase margin:  %.2f deg at %.4g Hz\n", m.PhaseMargin, m.UnityGainFreq)
+	}
+	if math.IsNaN(m.PhaseCrossFreq) {
+		sb.WriteString("  Gain margin:   no phase crossover\n")
+	} else {
+		fmt.Fprintf(&sb, "  Gain margin:   %.2f dB at %.4g Hz\n", m.GainMargin, m.PhaseCrossFreq)
+	}
+	return sb.String()
+}
@@ -3,6 +3,7 @@ package analysis
 import (
 	"fmt"
 	"math"
+	"time"
 
 	"github.com/edp1096/toy-spice/pkg/circuit"
 	"github.com/edp1096/toy-spice/pkg/device"
@@ -18,15 +19,34 @@ type Transient struct {
 	maxStep   float64
 	minStep   float64
 	useUIC    bool
+	ic        map[string]float64 // .ic node voltages used with UIC
+	printStep float64            // TSTEP of .tran, grid of results
+	rawOutput bool               // Keep adaptive timepoints instead of TSTEP grid
+	output    TimeWriter         // Streaming output, written on result goroutine
+	saveStep  float64            // Min/max envelope of stored results by interval, 0 keeps all timepoints
+	maxPoints int                // Limit of stored timepoints by envelope, 0 is unlimited
+	saves     []string           // Result keys kept by .save, empty keeps all
+
+	bestEffort bool     // Accept unconverged timepoint with warning when recovery fails
+	warnings   []string // Timepoints accepted without convergence
 
 	// Local Truncation Error
 	order     int     // ODE (1=BE, 2=TR)
 	trtol     float64 // truncation error tolerance (SPICE3F5 default: 7)
 	firstTime bool
 	prevStep  float64
+
+	// Accepted solutions of last timepoints, oldest first, for predictor of Newton initial guess
+	history      [][]float64
+	historyTimes []float64
+	predictorOK  bool // Prediction of last timepoint was better than previous solution
 }
 
+// Points of predictor polynomial, 2 is forward Euler. Quadratic overshoots more at turn-on of junctions
+const predictorPoints = 2
+
 func NewTransient(tStart, tStop, tStep, tMax float64, uic bool) *Transient {
+	printStep := tStep
 	if tStep > tStop/300 {
 		tStep = tStop / 300
 	}
@@ -45,6 +65,7 @@ func NewTransient(tStart, tStop, tStep, tMax float64, uic bool) *Transient {
 		maxStep:      tMax,
 		minStep:      minStep,
 		useUIC:       uic,
+		printStep:    printStep,
 		time:         0,
 		order:        1,   // BE
 		trtol:        7.0, // SPICE3F5 default
@@ -54,6 +75,60 @@ func NewTransient(tStart, tStop, tStep, tMax float64, uic bool) *Transient {
 	return analysisSettings
 }
 
+// SetInitialConditions - Node voltages of .ic by node name
+func (tr *Transient) SetInitialConditions(ic map[string]float64) {
+	tr.ic = ic
+}
+
+// SetBestEffort - Accept last Newton iterate with warning when timepoint does not converge at minimum step,
+// instead of aborting. Diverged solutions are never accepted.
+func (tr *Transient) SetBestEffort(accept bool) {
+	tr.bestEffort = accept
+}
+
+// Warnings - Timepoints accepted by best effort
+func (tr *Transient) Warnings() []string {
+	return tr.warnings
+}
+
+// SetOutput - Write timepoints to w while solver runs. TSTEP grid unless raw output
+func (tr *Transient) SetOutput(w TimeWriter) {
+	tr.output = w
+}
+
+// SetDecimation - Stored results are min/max envelope of timepoints in intervals of saveStep, or of interval
+// keeping at most maxPoints timepoints. Larger interval applies when both are given, 0 disables either.
+// Streaming output is not decimated.
+func (tr *Transient) SetDecimation(maxPoints int, saveStep float64) {
+	tr.maxPoints, tr.saveStep = maxPoints, saveStep
+}
+
+// decimationStep - Envelope interval of stored results, 0 without decimation. Envelope keeps 2 points by interval
+func (tr *Transient) decimationStep() float64 {
+	step := tr.saveStep
+	if tr.maxPoints > 0 {
+		step = math.Max(step, 2*(tr.stopTime-tr.startTime)/float64(tr.maxPoints))
+	}
+	return math.Max(step, 0)
+}
+
+// Stats - Solver effort including operating point
+func (tr *Transient) Stats() Stats {
+	return tr.stats.merge(tr.op.Stats())
+}
+
+// SetBiasPoint - Operating point starts from stored bias point instead of initial estimate
+func (tr *Transient) SetBiasPoint(bp *circuit.BiasPoint) {
+	tr.op.SetBiasPoint(bp)
+}
+
+func (tr *Transient) operatingPoint() *OperatingPoint { return tr.op }
+
+// SetRawOutput - Results at adaptive timepoints of solver instead of TSTEP grid
+func (tr *Transient) SetRawOutput(raw bool) {
+	tr.rawOutput = raw
+}
+
 func (tr *Transient) Setup(ckt *circuit.Circuit) error {
 	var err error
 
@@ -68,6 +143,13 @@ func (tr *Transient) Setup(ckt *circuit.Circuit) error {
 		if err != nil {
 			return fmt.Errorf("operating point analysis error: %v", err)
 		}
+		tr.Circuit.InitDCState()
+	} else {
+		// No operating point. Start from IC= of devices and .ic node voltages
+		err = tr.Circuit.InitUICState(tr.ic)
+		if err != nil {
+			return fmt.Errorf("initial condition error: %v", err)
+		}
 	}
 
 	tr.Circuit.SetTimeStep(tr.timeStep)
@@ -88,12 +170,55 @@ func (tr *Transient) Execute() error {
 		if err != nil {
 			return fmt.Errorf("operating point analysis error: %v", err)
 		}
+		tr.Circuit.InitDCState()
+	}
+
+	var filter saveFilter
+	if len(tr.saves) > 0 {
+		var err error
+		filter, err = newSaveFilter(tr.saves, tr.Circuit.GetSolution())
+		if err != nil {
+			return err
+		}
+	}
+
+	// Results are stored and written on pipeline goroutine
+	pipe := tr.startPipeline(filter)
+	start := time.Now()
+	err := tr.run(pipe)
+	writeErr := pipe.close()
+	tr.stats.addPhase("transient", start)
+	if err != nil {
+		return err
+	}
+	if writeErr != nil {
+		return fmt.Errorf("writing results: %v", writeErr)
+	}
+	return nil
+}
+
+// run - Timestep loop from initial point to tstop
+func (tr *Transient) run(pipe *resultPipeline) error {
+	// Initial point
+	if tr.startTime <= 0 {
+		tr.Circuit.Status = &device.CircuitStatus{
+			TimeStep: tr.minStep,
+			Mode:     device.TransientAnalysis,
+			Method:   device.BE,
+			Temp:     tr.Circuit.Temp,
+			Gmin:     tr.convergence.gmin,
+		}
+		pipe.store(0, tr.Circuit.GetSolution())
 	}
+	tr.history, tr.historyTimes = nil, nil
+	tr.pushHistory(tr.time)
 
-	tr.timeStep = tr.minStep
+	tr.timeStep = tr.initialTimeStep()
+	tr.minStep = math.Min(tr.minStep, tr.timeStep)
 	methodState := device.BE
 
 	for tr.time < tr.stopTime {
+		tr.timeStep = math.Min(tr.timeStep, tr.deviceStepLimit())
 		nextTime := tr.time + tr.timeStep
 		if nextTime > tr.stopTime {
 			nextTime = tr.stopTime
@@ -101,35 +226,40 @@ func (tr *Transient) Execute() error {
 		}
 
 		status := &device.CircuitStatus{
-			Time:     tr.time,
+			Time:     nextTime,
 			TimeStep: tr.timeStep,
 			Mode:     device.TransientAnalysis,
 			Method:   methodState,
-			Temp:     300.15,
+			Temp:     tr.Circuit.Temp,
 			Gmin:     tr.convergence.gmin,
+			Trtol:    tr.trtol,
 		}
 		tr.Circuit.Status = status
 
 		err := tr.doNRiter(0, tr.convergence.maxIter)
 		if err != nil {
 			if tr.timeStep > tr.minStep {
+				tr.stats.RejectedSteps++
 				tr.timeStep /= 2
 				continue
 			}
-			return fmt.Errorf("failed to converge at t=%g", tr.time)
+			err = tr.recoverTimepoint(err)
+			if err != nil {
+				return fmt.Errorf("failed to converge at t=%g: %v", tr.time, err)
+			}
 		}
 
-		lte := tr.calculateTruncError()
-		if lte > tr.trtol {
-			if tr.timeStep > tr.minStep {
-				tr.timeStep /= 2
-				continue
-			}
+		// Timepoint is rejected when truncation error of any device requires much smaller step
+		truncStep := tr.truncateTimeStep(status)
+		if truncStep < 0.9*tr.timeStep && tr.timeStep > tr.minStep {
+			tr.stats.RejectedSteps++
+			tr.timeStep = math.Max(truncStep, tr.minStep)
+			continue
 		}
 
 		// BE -> TR
 		if methodState == device.BE && tr.time > 0 {
-			if lte < tr.trtol/10 {
+			if truncStep > 2*tr.timeStep {
 				methodState = device.TR
 			}
 		}
@@ -137,17 +267,17 @@ func (tr *Transient) Execute() error {
 		tr.Circuit.LoadState()
 		tr.Circuit.Update()
 		tr.time = nextTime
+		tr.stats.Points++
+		tr.pushHistory(tr.time)
 
 		if tr.time >= tr.startTime {
-			tr.StoreTimeResult(tr.time, tr.Circuit.GetSolution())
+			pipe.store(tr.time, tr.Circuit.GetSolution())
 		}
+		tr.reportProgress(tr.time, tr.stopTime)
 
-		if tr.time < tr.stopTime && tr.timeStep < tr.maxStep {
-			if lte < tr.trtol/100 {
-				tr.timeStep = math.Min(tr.timeStep*2, tr.maxStep)
-			} else {
-				tr.timeStep = math.Min(tr.timeStep*1.1, tr.maxStep)
-			}
+		// Next step by truncation error, growing at most twice
+		if tr.time < tr.stopTime {
+			tr.timeStep = math.Min(math.Min(truncStep, tr.timeStep*2), tr.maxStep)
 		}
 	}
 
@@ -160,36 +290,51 @@ func (tr *Transient) doNRiter(gmin float64, maxIter int) error {
 	ckt := tr.Circuit
 	mat := ckt.GetMatrix()
 	var oldSolution []float64
+	ckt.InvalidateLinearStamps() // History terms of storage devices changed
 	cktStatus := &device.CircuitStatus{
-		Time:     tr.time,
+		Time:     tr.time + tr.timeStep, // Sources at end of step
 		TimeStep: tr.timeStep,
 		Mode:     device.TransientAnalysis,
 		Method:   tr.order,
-		Temp:     300.15,
+		Temp:     ckt.Temp,
 		Gmin:     gmin,
+		Bypass:   !ckt.NoBypass,
+	}
+
+	// Newton starts from solution extrapolated from last timepoints instead of last solution
+	prediction := tr.predict(cktStatus.Time)
+	predicted := prediction != nil
+	if predicted {
+		oldSolution = prediction
 	}
 
 	for iter := range maxIter {
+		tr.stats.Iterations++
 		mat.Clear()
-		if iter > 0 {
+		if oldSolution != nil {
 			err = ckt.UpdateNonlinearVoltages(oldSolution)
 			if err != nil {
 				return fmt.Errorf("updating nonlinear voltages: %v", err)
 			}
 		}
 
-		err = ckt.Stamp(cktStatus)
+		err = ckt.StampIteration(cktStatus)
 		if err != nil {
 			return fmt.Errorf("stamping error: %v", err)
 		}
 		mat.LoadGmin(gmin)
-		err = mat.Solve()
+		err = tr.solve(mat)
 		if err != nil {
-			return fmt.Errorf("matrix solve error: %v", err)
+			return fmt.Errorf("matrix solve error: %v", ckt.DiagnoseSingular(err))
 		}
 
 		solution := mat.Solution()
-		if iter > 0 {
+		err = tr.checkDivergence(solution)
+		if err != nil {
+			return err
+		}
+
+		if iter > 0 || predicted {
 			allConverged := true
 			for i := 1; i < len(solution); i++ {
 				diff := math.Abs(solution[i] - oldSolution[i])
@@ -202,7 +347,13 @@ func (tr *Transient) doNRiter(gmin float64, maxIter int) error {
 				}
 			}
 			if allConverged {
-				return nil
+				converged, err := tr.residualConverged(solution, cktStatus)
+				if err != nil {
+					return err
+				}
+				if converged {
+					return nil
+				}
 			}
 		}
 
@@ -215,36 +366,242 @@ func (tr *Transient) doNRiter(gmin float64, maxIter int) error {
 	return fmt.Errorf("failed to converge in %d iterations", maxIter)
 }
 
+// pushHistory - Keep solution of accepted timepoint for predictor. Predictor is suspended while its
+// error at timepoint exceeds half of step change in any unknown. eg. diode turning on,
+// where extrapolated junction voltage overshoots into exponential
+func (tr *Transient) pushHistory(t float64) {
+	solution := append([]float64(nil), tr.Circuit.GetMatrix().Solution()...)
+	tr.predictorOK = true
+	if prediction := tr.extrapolate(t); prediction != nil {
+		previous := tr.history[len(tr.history)-1]
+		for i := 1; i < len(solution); i++ {
+			tol := math.Abs(solution[i]-previous[i])/2 + tr.convergence.reltol*math.Abs(solution[i]) + tr.convergence.abstol
+			if math.Abs(prediction[i]-solution[i]) > tol {
+				tr.predictorOK = false
+				break
+			}
+		}
+	}
+
+	if len(tr.history) == predictorPoints {
+		tr.history, tr.historyTimes = tr.history[1:], tr.historyTimes[1:]
+	}
+	tr.history = append(tr.history, solution)
+	tr.historyTimes = append(tr.historyTimes, t)
+}
+
+// predict - Initial guess of Newton at t, nil when predictor is suspended
+func (tr *Transient) predict(t float64) []float64 {
+	if !tr.predictorOK {
+		return nil
+	}
+	return tr.extrapolate(t)
+}
+
+// extrapolate - Solution at t by Lagrange polynomial through stored timepoints, forward Euler with 2 points
+// and quadratic with 3. nil before 2 timepoints are accepted
+func (tr *Transient) extrapolate(t float64) []float64 {
+	n := len(tr.history)
+	if n < 2 {
+		return nil
+	}
+
+	prediction := make([]float64, len(tr.history[n-1]))
+	for k := range n {
+		weight := 1.0
+		for j := range n {
+			if j != k {
+				weight *= (t - tr.historyTimes[j]) / (tr.historyTimes[k] - tr.historyTimes[j])
+			}
+		}
+		for i := 1; i < len(prediction); i++ {
+			prediction[i] += weight * tr.history[k][i]
+		}
+	}
+	return prediction
+}
+
+// initialTimeStep - First timestep, tenth of first source breakpoint or smallest RC, L/R time constant.
+// Time constants are estimated from resistors at terminals of each capacitor and inductor.
+func (tr *Transient) initialTimeStep() float64 {
+	dt := math.Min(tr.stopTime/300, tr.maxStep)
+	if tr.printStep > 0 {
+		dt = math.Min(dt, tr.printStep)
+	}
+
+	// Smallest and largest resistance at each node
+	rMin, rMax := make(map[int]float64), make(map[int]float64)
+	for _, dev := range tr.Circuit.GetDevices() {
+		r, ok := dev.(*device.Resistor)
+		if !ok || r.GetValue() <= 0 {
+			continue
+		}
+		for _, node := range r.GetNodes() {
+			if value, ok := rMin[node]; !ok || r.GetValue() < value {
+				rMin[node] = r.GetValue()
+			}
+			rMax[node] = math.Max(rMax[node], r.GetValue())
+		}
+	}
+
+	scale := math.Inf(1)
+	for _, dev := range tr.Circuit.GetDevices() {
+		switch d := dev.(type) {
+		case *device.Capacitor:
+			for _, node := range d.GetNodes() {
+				if r, ok := rMin[node]; ok && node != 0 {
+					scale = math.Min(scale, d.GetValue()*r)
+				}
+			}
+		case *device.Inductor:
+			for _, node := range d.GetNodes() {
+				if r := rMax[node]; r > 0 && node != 0 {
+					scale = math.Min(scale, d.GetValue()/r)
+				}
+			}
+		case device.BreakpointSource:
+			if bp := d.FirstBreakpoint(); bp > 0 {
+				scale = math.Min(scale, bp)
+			}
+		}
+	}
+
+	if scale > 0 && !math.IsInf(scale, 1) {
+		dt = math.Min(dt, scale/10)
+	}
+	return math.Max(dt, tr.stopTime*1e-9)
+}
+
+// deviceStepLimit - Smallest MaxTimeStep of devices, +Inf when none
+func (tr *Transient) deviceStepLimit() float64 {
+	limit := math.Inf(1)
+	for _, dev := range tr.Circuit.GetDevices() {
+		if sl, ok := dev.(device.StepLimiter); ok {
+			if dt := sl.MaxTimeStep(); dt > 0 {
+				limit = math.Min(limit, dt)
+			}
+		}
+	}
+	return limit
+}
+
+// recoverTimepoint - Retry timepoint failed at minimum step. Gmin stepping relaxes shunts from 1e-3 to zero,
+// each level starting from previous one, then Newton is continued with more iterations.
+func (tr *Transient) recoverTimepoint(cause error) error {
+	err := tr.gminStepping()
+	if err == nil {
+		return nil
+	}
+
+	err = tr.doNRiter(0, tr.convergence.maxIter*10)
+	if err == nil {
+		return nil
+	}
+
+	if tr.bestEffort && tr.checkDivergence(tr.Circuit.GetMatrix().Solution()) == nil {
+		tr.warnings = append(tr.warnings, fmt.Sprintf("timepoint t=%g accepted without convergence: %v", tr.time+tr.timeStep, cause))
+		return nil
+	}
+	return cause
+}
+
+func (tr *Transient) gminStepping() error {
+	for gmin := 1e-3; gmin > tr.convergence.gmin; gmin /= 10 {
+		err := tr.doNRiter(gmin, tr.convergence.maxIter)
+		if err != nil {
+			return fmt.Errorf("gmin stepping failed at gmin=%g: %v", gmin, err)
+		}
+	}
+	return tr.doNRiter(0, tr.convergence.maxIter)
+}
+
 func (tr *Transient) checkAcceptability() (bool, error) {
 	if tr.firstTime {
 		tr.firstTime = false
 		tr.order = 2 // TR
 
-		tol := tr.calculateTruncError()
-		if tol > tr.trtol {
+		if tr.truncateTimeStep(tr.Circuit.Status) < tr.timeStep {
 			tr.order = 1 // BE
 			return true, nil
 		}
 		return true, nil
 	}
 
-	tol := tr.calculateTruncError()
-	if tol >= 1.0 {
-		return false, nil
+	return tr.truncateTimeStep(tr.Circuit.Status) >= 0.9*tr.timeStep, nil
+}
+
+// truncateTimeStep - Smallest next timestep suggested by truncation error of devices at solution of new timepoint
+func (tr *Transient) truncateTimeStep(status *device.CircuitStatus) float64 {
+	solution := tr.Circuit.GetMatrix().Solution()
+	step := math.Inf(1)
+	for _, td := range tr.Circuit.TimeDependentDevices() {
+		step = math.Min(step, td.TruncateTimeStep(solution, status))
 	}
+	return step
+}
 
-	return true, nil
+// GetResults - Results interpolated on TSTEP grid from tstart to tstop, or adaptive timepoints with raw output.
+// Envelope of decimation is returned as stored, interpolation would lose its min/max peaks
+func (tr *Transient) GetResults() map[string][]float64 {
+	if tr.rawOutput || tr.printStep <= 0 || tr.decimationStep() > 0 {
+		return tr.results
+	}
+	return interpolateTimeResults(tr.results, tr.startTime, tr.stopTime, tr.printStep)
 }
 
-func (tr *Transient) calculateTruncError() float64 {
-	maxLTE := 0.0
-	for _, dev := range tr.Circuit.GetDevices() {
-		if td, ok := dev.(device.TimeDependent); ok {
-			lte := td.CalculateLTE(tr.Circuit.GetSolution(), tr.Circuit.Status)
-			if lte > maxLTE {
-				maxLTE = lte
+// interpolateTimeResults - Linear interpolation of results on uniform time grid.
+// Grid points out of simulated range take nearest result.
+func interpolateTimeResults(results map[string][]float64, tStart, tStop, step float64) map[string][]float64 {
+	times := results["TIME"]
+	if len(times) == 0 {
+		return results
+	}
+
+	// Small tolerance so tstop on grid is not lost by rounding
+	n := int(math.Floor((tStop-tStart)/step + 1e-9))
+	grid := make([]float64, 0, n+2)
+	for k := 0; k <= n; k++ {
+		grid = append(grid, tStart+float64(k)*step)
+	}
+	if tStop-grid[len(grid)-1] > 1e-9*step {
+		grid = append(grid, tStop)
+	}
+
+	interpolated := make(map[string][]float64, len(results))
+	interpolated["TIME"] = grid
+	for name, values := range results {
+		if name == "TIME" || len(values) != len(times) {
+			continue
+		}
+		interpolated[name] = make([]float64, len(grid))
+	}
+
+	j := 0
+	for k, t := range grid {
+		for j < len(times)-2 && times[j+1] < t {
+			j++
+		}
+
+		var i1, i2 int
+		frac := 0.0
+		switch {
+		case len(times) == 1 || t <= times[0]:
+			i1, i2 = 0, 0
+		case t >= times[len(times)-1]:
+			i1, i2 = len(times)-1, len(times)-1
+		default:
+			i1, i2 = j, j+1
+			frac = (t - times[i1]) / (times[i2] - times[i1])
+		}
+
+		for name, values := range interpolated {
+			if name == "TIME" {
+				continue
 			}
+			raw := results[name]
+			values[k] = raw[i1] + frac*(raw[i2]-raw[i1])
 		}
 	}
-	return maxLTE
+
+	return interpolated
 }
@@ -0,0 +1,205 @@
+package analysis
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"strconv"
+)
+
+// TimeWriter - Streaming output of transient timepoints, eg. to file while solver runs
+type TimeWriter interface {
+	WriteTime(time float64, values map[string]float64) error
+	Flush() error
+}
+
+// csvTimeWriter - CSV rows of TIME and results in order of ResultNames. Columns are fixed by first timepoint
+type csvTimeWriter struct {
+	w     *csv.Writer
+	names []string
+}
+
+// NewCSVTimeWriter - TimeWriter of CSV with TIME column first, same layout as results CSV of cmd/spice
+func NewCSVTimeWriter(w io.Writer) TimeWriter {
+	return &csvTimeWriter{w: csv.NewWriter(w)}
+}
+
+func (cw *csvTimeWriter) WriteTime(time float64, values map[string]float64) error {
+	if cw.names == nil {
+		for name := range values {
+			cw.names = append(cw.names, name)
+		}
+		SortResultNames(cw.names)
+		err := cw.w.Write(append([]string{"TIME"}, cw.names...))
+		if err != nil {
+			return err
+		}
+	}
+
+	record := make([]string, len(cw.names)+1)
+	record[0] = strconv.FormatFloat(time, 'g', -1, 64)
+	for i, name := range cw.names {
+		record[i+1] = strconv.FormatFloat(values[name], 'g', -1, 64)
+	}
+	return cw.w.Write(record)
+}
+
+func (cw *csvTimeWriter) Flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+type timePoint struct {
+	time     float64
+	solution map[string]float64
+}
+
+// resultPipeline - Stores timepoints and writes output on own goroutine, so disk bound output does not hold solver.
+// Solutions passed to store must not be modified afterwards. Filter of .save is applied before both, nil keeps all.
+type resultPipeline struct {
+	points chan timePoint
+	done   chan error
+}
+
+func (tr *Transient) startPipeline(filter saveFilter) *resultPipeline {
+	p := &resultPipeline{
+		points: make(chan timePoint, 256),
+		done:   make(chan error, 1),
+	}
+
+	var grid *timeGrid
+	if !tr.rawOutput && tr.printStep > 0 {
+		grid = newTimeGrid(tr.startTime, tr.stopTime, tr.printStep)
+	}
+
+	var envelope *decimator
+	if step := tr.decimationStep(); step > 0 {
+		envelope = newDecimator(tr.startTime, step)
+	}
+
+	go func() {
+		var err error
+		var lastTime float64
+		written := false
+		for point := range p.points {
+			if filter != nil {
+				point.solution = filter.apply(point.solution)
+			}
+			duplicate := written && sameTime(point.time, lastTime)
+			if envelope == nil {
+				tr.StoreTimeResult(point.time, point.solution)
+			} else if !duplicate {
+				envelope.push(point.time, point.solution, tr.StoreTimeResult)
+			}
+			if duplicate {
+				continue
+			}
+			lastTime, written = point.time, true
+			if tr.output == nil || err != nil {
+				continue
+			}
+
+			if grid == nil {
+				err = tr.output.WriteTime(point.time, point.solution)
+			} else {
+				err = grid.push(point.time, point.solution, tr.output.WriteTime)
+			}
+		}
+
+		if envelope != nil {
+			envelope.flush(tr.StoreTimeResult)
+		}
+		if tr.output != nil && err == nil {
+			if grid != nil {
+				err = grid.finish(tr.output.WriteTime)
+			}
+			if err == nil {
+				err = tr.output.Flush()
+			}
+		}
+		p.done <- err
+	}()
+
+	return p
+}
+
+func (p *resultPipeline) store(time float64, solution map[string]float64) {
+	p.points <- timePoint{time: time, solution: solution}
+}
+
+// close - Wait until all timepoints are stored and written
+func (p *resultPipeline) close() error {
+	close(p.points)
+	return <-p.done
+}
+
+// timeGrid - Streaming linear interpolation on TSTEP grid, same points as interpolateTimeResults
+type timeGrid struct {
+	tStart, tStop, step float64
+	count               int // Grid points including off grid tstop
+	next                int // Index of next grid point to emit
+	prevTime            float64
+	prev                map[string]float64
+}
+
+func newTimeGrid(tStart, tStop, step float64) *timeGrid {
+	n := int(math.Floor((tStop-tStart)/step + 1e-9))
+	g := &timeGrid{tStart: tStart, tStop: tStop, step: step, count: n + 1}
+	if tStop-(tStart+float64(n)*step) > 1e-9*step {
+		g.count++
+	}
+	return g
+}
+
+func (g *timeGrid) at(k int) float64 {
+	t := g.tStart + float64(k)*g.step
+	if t > g.tStop {
+		return g.tStop
+	}
+	return t
+}
+
+// push - Emit grid points up to time. Points before first timepoint take its values
+func (g *timeGrid) push(time float64, solution map[string]float64, emit func(float64, map[string]float64) error) error {
+	for ; g.next < g.count && g.at(g.next) <= time; g.next++ {
+		t := g.at(g.next)
+		if g.prev == nil || time <= g.prevTime {
+			err := emit(t, solution)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		frac := (t - g.prevTime) / (time - g.prevTime)
+		values := make(map[string]float64, len(solution))
+		for name, value := range solution {
+			prev, ok := g.prev[name]
+			if !ok {
+				prev = value
+			}
+			values[name] = prev + frac*(value-prev)
+		}
+		err := emit(t, values)
+		if err != nil {
+			return err
+		}
+	}
+
+	g.prevTime, g.prev = time, solution
+	return nil
+}
+
+// finish - Grid points after last timepoint take its values
+func (g *timeGrid) finish(emit func(float64, map[string]float64) error) error {
+	if g.prev == nil {
+		return nil
+	}
+	for ; g.next < g.count; g.next++ {
+		err := emit(g.at(g.next), g.prev)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package circuit
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/edp1096/toy-spice/pkg/device"
+	"github.com/edp1096/toy-spice/pkg/netlist"
+)
+
+// AlterDevice - Change value of R, L, C, K or DC/AC source after setup. eg. AlterDevice("R1", 2000).
+// Devices are recreated from elements, so analyzer must be set up again before next Execute.
+func (c *Circuit) AlterDevice(name string, value float64) error {
+	index := c.findElement(name)
+	if index < 0 {
+		return fmt.Errorf("device not found: %s", name)
+	}
+
+	elem := c.elements[index]
+	switch elem.Type {
+	case "R", "L", "C", "K":
+	case "V", "I":
+		if elem.Params["type"] != "dc" && elem.Params["type"] != "ac" {
+			return fmt.Errorf("source %s: only DC value or AC magnitude can be altered", elem.Name)
+		}
+	default:
+		return fmt.Errorf("device %s has no value, alter its model parameters", elem.Name)
+	}
+
+	elements := slices.Clone(c.elements)
+	elements[index].Value = value
+	return c.rebuildDevices(elements, c.Models)
+}
+
+// AlterModelParam - Change model parameter after setup, eg. AlterModelParam("D1N4148", "is", 1e-12).
+// All devices of model are recreated. Models of netlist are not modified.
+func (c *Circuit) AlterModelParam(modelName, param string, value float64) error {
+	key := modelName
+	if _, ok := c.Models[key]; !ok {
+		key = netlist.ModelKey(modelName)
+	}
+	if _, ok := c.Models[key]; !ok {
+		return fmt.Errorf("model not found: %s", modelName)
+	}
+
+	models := maps.Clone(c.Models)
+	model := models[key]
+	model.Params = maps.Clone(model.Params)
+	if model.Params == nil {
+		model.Params = make(map[string]float64)
+	}
+	model.Params[strings.ToLower(param)] = value
+	models[key] = model
+
+	return c.rebuildDevices(c.elements, models)
+}
+
+// rebuildDevices - Recreate devices of altered elements and models. Old devices are kept on error
+func (c *Circuit) rebuildDevices(elements []netlist.Element, models map[string]device.ModelParam) error {
+	oldDevices, oldNonlinear, oldStorage, oldThermal := c.devices, c.nonlinearDevices, c.storageDevices, c.thermalDevices
+	oldTimeDependent := c.timeDependent
+	oldElements, oldModels := c.elements, c.Models
+
+	c.devices, c.nonlinearDevices, c.storageDevices, c.thermalDevices = nil, nil, nil, nil
+	c.timeDependent = nil
+	c.Models = models
+	c.Matrix.Clear()
+	c.InvalidateLinearStamps()
+	err := c.SetupDevices(elements)
+	if err != nil {
+		c.devices, c.nonlinearDevices, c.storageDevices, c.thermalDevices = oldDevices, oldNonlinear, oldStorage, oldThermal
+		c.timeDependent = oldTimeDependent
+		c.elements, c.Models = oldElements, oldModels
+		return fmt.Errorf("rebuilding devices: %v", err)
+	}
+
+	return nil
+}
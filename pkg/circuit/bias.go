@@ -0,0 +1,155 @@
+package circuit
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/edp1096/toy-spice/pkg/device"
+)
+
+// BiasPoint - Snapshot of operating point by name, so it can be reused after circuit is rebuilt.
+// Voltages are by node name, Currents by branch device name, n+ to n- through device.
+type BiasPoint struct {
+	Voltages map[string]float64
+	Currents map[string]float64
+}
+
+// SaveOperatingPoint - Snapshot of current solution. Run after operating point analysis
+func (c *Circuit) SaveOperatingPoint() *BiasPoint {
+	solution := c.Matrix.Solution()
+	bp := &BiasPoint{Voltages: make(map[string]float64), Currents: make(map[string]float64)}
+
+	for name, idx := range c.nodeMap {
+		if idx > 0 && idx < len(solution) {
+			bp.Voltages[name] = solution[idx]
+		}
+	}
+	for _, dev := range c.devices {
+		if b, ok := dev.(device.BranchDevice); ok && b.BranchIndex() > 0 && b.BranchIndex() < len(solution) {
+			bp.Currents[dev.GetName()] = b.BranchSign() * solution[b.BranchIndex()]
+		}
+	}
+
+	return bp
+}
+
+// BiasSolution - Solution vector of bias point. Unknowns missing in bias point are zero
+func (c *Circuit) BiasSolution(bp *BiasPoint) ([]float64, error) {
+	solution := make([]float64, c.Matrix.Size+1)
+	for name, voltage := range bp.Voltages {
+		idx, ok := c.nodeMap[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown node in bias point: %s", name)
+		}
+		solution[idx] = voltage
+	}
+
+	branches := make(map[string]device.BranchDevice)
+	for _, dev := range c.devices {
+		if b, ok := dev.(device.BranchDevice); ok {
+			branches[strings.ToLower(dev.GetName())] = b
+		}
+	}
+	for name, current := range bp.Currents {
+		b, ok := branches[strings.ToLower(name)]
+		if !ok || b.BranchIndex() <= 0 || b.BranchIndex() >= len(solution) {
+			return nil, fmt.Errorf("unknown branch in bias point: %s", name)
+		}
+		solution[b.BranchIndex()] = b.BranchSign() * current
+	}
+
+	return solution, nil
+}
+
+// LoadOperatingPoint - Put bias point into solution and nonlinear devices without solving
+func (c *Circuit) LoadOperatingPoint(bp *BiasPoint) error {
+	solution, err := c.BiasSolution(bp)
+	if err != nil {
+		return err
+	}
+
+	copy(c.Matrix.Solution(), solution)
+	return c.UpdateNonlinearVoltages(solution)
+}
+
+// Write - Bias point as text lines. eg. V(out) 2.5, I(V1) -1e-3
+func (bp *BiasPoint) Write(w io.Writer) error {
+	var lines []string
+	for name, voltage := range bp.Voltages {
+		lines = append(lines, fmt.Sprintf("V(%s) %s", name, strconv.FormatFloat(voltage, 'g', -1, 64)))
+	}
+	for name, current := range bp.Currents {
+		lines = append(lines, fmt.Sprintf("I(%s) %s", name, strconv.FormatFloat(current, 'g', -1, 64)))
+	}
+	sort.Strings(lines)
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "* Operating point")
+	for _, line := range lines {
+		fmt.Fprintln(bw, line)
+	}
+	return bw.Flush()
+}
+
+// ReadBiasPoint - Bias point written by Write. Lines starting with * are comments
+func ReadBiasPoint(r io.Reader) (*BiasPoint, error) {
+	bp := &BiasPoint{Voltages: make(map[string]float64), Currents: make(map[string]float64)}
+
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "*") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 || len(fields[0]) < 4 || fields[0][1] != '(' || !strings.HasSuffix(fields[0], ")") {
+			return nil, fmt.Errorf("line %d: expected V(node) or I(device) and value: %s", lineNum, line)
+		}
+		value, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+
+		name := fields[0][2 : len(fields[0])-1]
+		switch fields[0][0] {
+		case 'V', 'v':
+			bp.Voltages[name] = value
+		case 'I', 'i':
+			bp.Currents[name] = value
+		default:
+			return nil, fmt.Errorf("line %d: expected V(node) or I(device): %s", lineNum, fields[0])
+		}
+	}
+
+	return bp, scanner.Err()
+}
+
+// SaveFile - Write bias point to file
+func (bp *BiasPoint) SaveFile(fileName string) error {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	err = bp.Write(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// LoadBiasPointFile - Read bias point from file written by SaveFile
+func LoadBiasPointFile(fileName string) (*BiasPoint, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadBiasPoint(f)
+}
@@ -1,8 +1,12 @@
 package circuit
 
 import (
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
 
+	"github.com/edp1096/toy-spice/internal/consts"
 	"github.com/edp1096/toy-spice/pkg/device"
 	"github.com/edp1096/toy-spice/pkg/matrix"
 	"github.com/edp1096/toy-spice/pkg/netlist"
@@ -13,6 +17,7 @@ type Circuit struct {
 	nodeMap          map[string]int
 	branchMap        map[string]int
 	devices          []device.Device
+	elements         []netlist.Element // Elements of devices, kept for alter
 	numNodes         int
 	Matrix           *matrix.CircuitMatrix
 	Status           *device.CircuitStatus
@@ -21,7 +26,18 @@ type Circuit struct {
 	isComplex        bool
 	prevSolution     map[string]float64
 	nonlinearDevices []device.NonLinear
+	storageDevices   []device.NonLinearStorage
+	timeDependent    []device.TimeDependent // Reactive and nonlinear devices with transient state
+	thermalDevices   []device.ThermalDevice // Devices with thermal node or self-heating
+	linearStamps     stampCache
 	Models           map[string]device.ModelParam
+	warnings         []string // Unusual model parameters found in setup
+	Solver           string   // Linear solver backend by name. eg. "dense" for tiny circuits, default sparse when empty
+	NoBypass         bool     // Evaluate nonlinear device models every Newton iteration
+	StrictStamps     bool     // Out of bounds matrix stamps fail solve instead of logged warning
+	ScaleMatrix      bool     // Equilibrate rows and columns of matrix before factorization
+	FloatingShunt    float64  // Resistance to ground at nodes without DC path in DC analyses, 0 disables
+	Temp             float64  // Circuit temperature (K) of every analysis, 27 degC by default
 }
 
 func New(name string) *Circuit {
@@ -38,17 +54,26 @@ func NewWithComplex(name string, isComplex bool) *Circuit {
 		prevSolution: make(map[string]float64),
 		isComplex:    isComplex,
 		Models:       make(map[string]device.ModelParam),
+		Temp:         NominalTemp,
 	}
 }
 
+// NominalTemp - Default circuit temperature and nominal temperature of model parameters, 27 = 300.15K
+const NominalTemp = 300.15
+
+// SetTemperature - Circuit temperature in degC
+func (c *Circuit) SetTemperature(celsius float64) {
+	c.Temp = celsius + consts.KELVIN
+}
+
 func (c *Circuit) SetModels(models map[string]device.ModelParam) {
 	c.Models = models
 }
 
 func (c *Circuit) AssignNodeBranchMaps(elements []netlist.Element) error {
 	for _, elem := range elements {
-		for _, nodeName := range elem.Nodes {
-			if nodeName == "0" || nodeName == "gnd" {
+		for _, nodeName := range netlist.ElementNodes(elem) {
+			if netlist.IsGround(nodeName) {
 				continue
 			}
 			if _, exists := c.nodeMap[nodeName]; !exists {
@@ -60,7 +85,7 @@ func (c *Circuit) AssignNodeBranchMaps(elements []netlist.Element) error {
 
 	branchStart := len(c.nodeMap) + 1
 	for _, elem := range elements {
-		if elem.Type == "V" || elem.Type == "L" {
+		if netlist.HasBranch(elem) {
 			c.branchMap[elem.Name] = branchStart
 			branchStart++
 		}
@@ -70,14 +95,23 @@ func (c *Circuit) AssignNodeBranchMaps(elements []netlist.Element) error {
 	return nil
 }
 
-func (c *Circuit) CreateMatrix() {
+func (c *Circuit) CreateMatrix() error {
+	var err error
+
 	matrixSize := len(c.nodeMap) + len(c.branchMap)
-	c.Matrix = matrix.NewMatrix(matrixSize, c.isComplex)
+	c.Matrix, err = matrix.NewMatrixWithSolver(c.Solver, matrixSize, c.isComplex)
+	if err != nil {
+		return err
+	}
+	c.Matrix.SetStrict(c.StrictStamps)
+	c.Matrix.SetScaling(c.ScaleMatrix)
+	return nil
 }
 
 func (c *Circuit) SetupDevices(elements []netlist.Element) error {
 	var err error
 	deviceMap := make(map[string]device.Device)
+	c.elements = elements
 
 	// Create all devices except mutual inductance device
 	for _, elem := range elements {
@@ -93,7 +127,7 @@ func (c *Circuit) SetupDevices(elements []netlist.Element) error {
 		// Node index
 		nodeIndices := make([]int, len(elem.Nodes))
 		for i, nodeName := range elem.Nodes {
-			if nodeName == "0" || nodeName == "gnd" {
+			if netlist.IsGround(nodeName) {
 				nodeIndices[i] = 0
 				continue
 			}
@@ -101,28 +135,65 @@ func (c *Circuit) SetupDevices(elements []netlist.Element) error {
 		}
 		dev.SetNodes(nodeIndices)
 
-		// Branch index for voltage source
-		if v, ok := dev.(*device.VoltageSource); ok {
-			v.SetBranchIndex(c.branchMap[elem.Name])
+		// Branch index for voltage source and inductors
+		if b, ok := dev.(device.BranchDevice); ok {
+			b.SetBranchIndex(c.branchMap[elem.Name])
 		}
 
-		// Branch index for inductor
-		if l, ok := dev.(*device.Inductor); ok {
-			l.SetBranchIndex(c.branchMap[elem.Name])
-		}
-		if magInd, ok := dev.(*device.MagneticInductor); ok {
-			magInd.SetBranchIndex(c.branchMap[elem.Name])
+		// Thermal node of power device
+		if td, ok := dev.(device.ThermalDevice); ok {
+			if nodeName := netlist.ThermalNode(elem); nodeName != "" && !netlist.IsGround(nodeName) {
+				td.SetThermalNode(c.nodeMap[nodeName])
+			}
+			if td.HasThermalState() {
+				c.thermalDevices = append(c.thermalDevices, td)
+			}
 		}
 
 		if nl, ok := dev.(device.NonLinear); ok {
 			c.nonlinearDevices = append(c.nonlinearDevices, nl)
+		} else if ns, ok := dev.(device.NonLinearStorage); ok {
+			c.storageDevices = append(c.storageDevices, ns)
 		}
 
 		deviceMap[elem.Name] = dev
 		c.devices = append(c.devices, dev)
 	}
 
-	// Create mutual inductance devices
+	// Current controlled devices sense branch of voltage source or inductor
+	for _, dev := range c.devices {
+		cc, ok := dev.(device.CurrentControlled)
+		if !ok || cc.ControlName() == "" {
+			continue
+		}
+		ctrl, ok := deviceMap[cc.ControlName()]
+		if !ok {
+			return fmt.Errorf("controlling device %s not found for %s", cc.ControlName(), dev.GetName())
+		}
+		branch, ok := ctrl.(device.BranchDevice)
+		if !ok {
+			return fmt.Errorf("controlling device %s of %s has no branch current", cc.ControlName(), dev.GetName())
+		}
+		cc.SetControl(branch)
+	}
+
+	// Windings of same core model share one magnetic core
+	cores := make(map[string]*device.MagneticCore)
+	for _, dev := range c.devices {
+		magInd, ok := dev.(*device.MagneticInductor)
+		if !ok {
+			continue
+		}
+		key := strings.ToLower(magInd.CoreName())
+		if core, exists := cores[key]; exists {
+			magInd.AttachCore(core)
+			continue
+		}
+		cores[key] = magInd.GetCore()
+	}
+
+	// Create mutual inductance devices. Each inductor pair may be coupled only once
+	coupledBy := make(map[[2]string]string)
 	for _, elem := range elements {
 		if elem.Type != "K" {
 			continue
@@ -133,7 +204,23 @@ func (c *Circuit) SetupDevices(elements []netlist.Element) error {
 		}
 
 		mutual := dev.(*device.Mutual)
-		for i, name := range mutual.GetInductorNames() {
+		names := mutual.GetInductorNames()
+		for i := range names {
+			for j := i + 1; j < len(names); j++ {
+				pair := [2]string{names[i], names[j]}
+				if pair[0] == pair[1] {
+					return fmt.Errorf("inductor %s coupled with itself in %s", names[i], elem.Name)
+				}
+				if pair[0] > pair[1] {
+					pair[0], pair[1] = pair[1], pair[0]
+				}
+				if other, exists := coupledBy[pair]; exists {
+					return fmt.Errorf("inductors %s and %s are coupled by both %s and %s", names[i], names[j], other, elem.Name)
+				}
+				coupledBy[pair] = elem.Name
+			}
+		}
+		for i, name := range names {
 			ind, ok := deviceMap[name]
 			if !ok {
 				return fmt.Errorf("inductor %s not found for mutual coupling %s", name, mutual.GetName())
@@ -147,21 +234,67 @@ func (c *Circuit) SetupDevices(elements []netlist.Element) error {
 				return fmt.Errorf("setting inductor %s in mutual coupling %s: %v", name, mutual.GetName(), err)
 			}
 		}
+		mutual.CoupleCores()
 
 		c.devices = append(c.devices, dev)
 	}
 
+	for _, dev := range c.devices {
+		if td, ok := dev.(device.TimeDependent); ok {
+			c.timeDependent = append(c.timeDependent, td)
+		}
+	}
+
+	err = c.validateParameters()
+	if err != nil {
+		return err
+	}
+	c.insertShunts(elements)
+
 	// Initial stamp
 	cktStatus := &device.CircuitStatus{Time: 0}
 	err = c.Stamp(cktStatus)
 	if err != nil {
 		return fmt.Errorf("initial stamping failed: %v", err)
 	}
-	c.Matrix.SetupElements()
 
 	return nil
 }
 
+// validateParameters - Parameters out of range of devices. Invalid parameters are error, unusual ones are warnings
+func (c *Circuit) validateParameters() error {
+	var invalid []string
+	seen := make(map[string]bool)
+	c.warnings = c.warnings[:0]
+	for _, dev := range c.devices {
+		v, ok := dev.(device.ParameterValidator)
+		if !ok {
+			continue
+		}
+		for _, issue := range v.ValidateParameters() {
+			msg := issue.String()
+			if seen[msg] {
+				continue
+			}
+			seen[msg] = true
+			if issue.Fatal {
+				invalid = append(invalid, msg)
+			} else {
+				c.warnings = append(c.warnings, msg)
+			}
+		}
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("invalid model parameters: %s", strings.Join(invalid, "; "))
+	}
+	return nil
+}
+
+// Warnings - Unusual model parameters found by SetupDevices
+func (c *Circuit) Warnings() []string {
+	return c.warnings
+}
+
 func (c *Circuit) Stamp(status *device.CircuitStatus) error {
 	var err error
 
@@ -182,21 +315,62 @@ func (c *Circuit) SetTimeStep(dt float64) {
 	}
 
 	// Set timestep for all time dependent devices
+	for _, td := range c.timeDependent {
+		td.SetTimeStep(dt, c.Status)
+	}
+}
+
+// TimeDependentDevices - Devices with transient state, which are stepped and checked for truncation error
+func (c *Circuit) TimeDependentDevices() []device.TimeDependent {
+	return c.timeDependent
+}
+
+// InitDCState - Initial state of inductors etc. from operating point solution
+func (c *Circuit) InitDCState() {
+	solution := c.Matrix.Solution()
 	for _, dev := range c.devices {
-		if td, ok := dev.(device.TimeDependent); ok {
-			td.SetTimeStep(dt, c.Status)
+		if di, ok := dev.(device.DCInitializer); ok {
+			di.InitDCState(solution, c.Status)
 		}
 	}
+	c.updateThermal(solution, 0)
+}
+
+// InitUICState - Initial state for transient without operating point.
+// voltages are .ic node voltages by node name, other nodes start at zero. IC= of devices is added to them
+func (c *Circuit) InitUICState(voltages map[string]float64) error {
+	solution := make([]float64, c.Matrix.Size+1)
+	for name, voltage := range voltages {
+		if netlist.IsGround(name) {
+			continue
+		}
+		nodeIdx, ok := c.nodeMap[name]
+		if !ok {
+			return fmt.Errorf("unknown node in initial condition: %s", name)
+		}
+		if nodeIdx > 0 {
+			solution[nodeIdx] = voltage
+		}
+	}
+
+	for _, dev := range c.devices {
+		if ui, ok := dev.(device.UICInitializer); ok {
+			ui.InitUICState(solution, c.Status)
+		}
+	}
+	c.updateThermal(solution, 0)
+
+	// Initial point is reported from initial voltages, and Newton of first timestep starts there
+	copy(c.Matrix.Solution(), solution)
+	return c.UpdateNonlinearVoltages(solution)
 }
 
 func (c *Circuit) LoadState() {
 	voltages := c.Matrix.Solution()
 
 	// Load state of all time dependent devices
-	for _, dev := range c.devices {
-		if td, ok := dev.(device.TimeDependent); ok {
-			td.LoadState(voltages, c.Status)
-		}
+	for _, td := range c.timeDependent {
+		td.LoadState(voltages, c.Status)
 	}
 }
 
@@ -204,11 +378,10 @@ func (c *Circuit) Update() {
 	solution := c.Matrix.Solution()
 
 	// Update state of all time dependent devices
-	for _, dev := range c.devices {
-		if td, ok := dev.(device.TimeDependent); ok {
-			td.UpdateState(solution, c.Status)
-		}
+	for _, td := range c.timeDependent {
+		td.UpdateState(solution, c.Status)
 	}
+	c.updateThermal(solution, c.Status.TimeStep)
 
 	// Save solution to previous solution
 	for nodeName, nodeIdx := range c.nodeMap {
@@ -217,9 +390,15 @@ func (c *Circuit) Update() {
 	}
 
 	// Branch current is saved too
-	for devName, branchIdx := range c.branchMap {
-		key := fmt.Sprintf("I(%s)", devName)
-		c.prevSolution[key] = -solution[branchIdx]
+	for key, current := range c.GetBranchCurrents(solution) {
+		c.prevSolution[key] = current
+	}
+}
+
+// updateThermal - Dissipation of power devices and temperature of their thermal nodes at accepted solution
+func (c *Circuit) updateThermal(solution []float64, dt float64) {
+	for _, td := range c.thermalDevices {
+		td.UpdateThermal(solution, dt, c.Status)
 	}
 }
 
@@ -235,6 +414,54 @@ func (c *Circuit) GetBranchMap() map[string]int {
 	return c.branchMap
 }
 
+// UnknownName - Name of solution entry. V(node) for node, I(device) for branch
+func (c *Circuit) UnknownName(idx int) string {
+	for name, nodeIdx := range c.nodeMap {
+		if nodeIdx == idx {
+			return fmt.Sprintf("V(%s)", name)
+		}
+	}
+	for name, branchIdx := range c.branchMap {
+		if branchIdx == idx {
+			return fmt.Sprintf("I(%s)", name)
+		}
+	}
+	return fmt.Sprintf("#%d", idx)
+}
+
+// DiagnoseSingular - Singular matrix error with node or branch name and likely cause.
+// Other errors are returned as is.
+func (c *Circuit) DiagnoseSingular(err error) error {
+	var singular *matrix.SingularError
+	if !errors.As(err, &singular) {
+		return err
+	}
+
+	idx := singular.Row
+	if idx <= 0 {
+		idx = singular.Col
+	}
+	if idx <= 0 {
+		return err
+	}
+
+	name := c.UnknownName(idx)
+	if idx > c.numNodes {
+		return fmt.Errorf("singular matrix at branch %s: loop of voltage sources or inductors, or shorted source (%v)", name, err)
+	}
+
+	var connected []string
+	for _, dev := range c.devices {
+		if slices.Contains(dev.GetNodes(), idx) {
+			connected = append(connected, dev.GetName())
+		}
+	}
+	if len(connected) == 1 {
+		return fmt.Errorf("singular matrix at node %s: floating node connected only to %s (%v)", name, connected[0], err)
+	}
+	return fmt.Errorf("singular matrix at node %s: no DC path to ground through %s (%v)", name, strings.Join(connected, ", "), err)
+}
+
 func (c *Circuit) GetDevices() []device.Device {
 	return c.devices
 }
@@ -248,30 +475,89 @@ func (c *Circuit) GetSolution() map[string]float64 {
 		solution[fmt.Sprintf("V(%s)", name)] = matrixSolution[idx]
 	}
 
-	// Branch current of voltage source
-	for name, idx := range c.branchMap {
-		solution[fmt.Sprintf("I(%s)", name)] = -matrixSolution[idx]
+	// Branch current of voltage sources and inductors
+	for name, current := range c.GetBranchCurrents(matrixSolution) {
+		solution[name] = current
 	}
 
-	// V = IR -> I = V/R
-	for _, dev := range c.devices {
-		if dev.GetType() == "R" {
-			nodes := dev.GetNodes()
-			v1, v2 := 0.0, 0.0
-			if nodes[0] > 0 {
-				v1 = matrixSolution[nodes[0]]
+	// Device currents. I = V/R, ...
+	for name, current := range c.GetDeviceCurrents(matrixSolution) {
+		solution[name] = current
+	}
+
+	// Core state in transient. B and H seen by windings, loss power of cores
+	if c.Status != nil && c.Status.Mode == device.TransientAnalysis {
+		for _, dev := range c.devices {
+			magInd, ok := dev.(*device.MagneticInductor)
+			if !ok || magInd.GetCore() == nil {
+				continue
 			}
-			if nodes[1] > 0 {
-				v2 = matrixSolution[nodes[1]]
+			core := magInd.GetCore()
+			solution[fmt.Sprintf("B(%s)", dev.GetName())] = core.FluxDensity()
+			solution[fmt.Sprintf("H(%s)", dev.GetName())] = core.FieldStrength()
+			if core.GetInductors()[0] == magInd {
+				solution[fmt.Sprintf("PLOSS(%s)", core.Name())] = core.LossPower()
 			}
-			current := (v1 - v2) / dev.GetValue()
-			solution[fmt.Sprintf("I(%s)", dev.GetName())] = current
 		}
 	}
 
 	return solution
 }
 
+// GetBranchCurrents - I(name) of every device owning branch current, n+ to n- through device
+func (c *Circuit) GetBranchCurrents(solution []float64) map[string]float64 {
+	currents := make(map[string]float64)
+	for _, dev := range c.devices {
+		if b, ok := dev.(device.BranchDevice); ok && b.BranchIndex() > 0 && b.BranchIndex() < len(solution) {
+			currents[fmt.Sprintf("I(%s)", dev.GetName())] = b.BranchSign() * solution[b.BranchIndex()]
+		}
+	}
+	return currents
+}
+
+// GetBranchCurrentsAC - Complex I(name) of every device owning branch current
+func (c *Circuit) GetBranchCurrentsAC(solution []complex128) map[string]complex128 {
+	currents := make(map[string]complex128)
+	for _, dev := range c.devices {
+		if b, ok := dev.(device.BranchDevice); ok && b.BranchIndex() > 0 && b.BranchIndex() < len(solution) {
+			currents[fmt.Sprintf("I(%s)", dev.GetName())] = complex(b.BranchSign(), 0) * solution[b.BranchIndex()]
+		}
+	}
+	return currents
+}
+
+// GetDeviceCurrents - I(name) of devices which have no branch current
+func (c *Circuit) GetDeviceCurrents(voltages []float64) map[string]float64 {
+	currents := make(map[string]float64)
+
+	for _, dev := range c.devices {
+		if _, isBranch := c.branchMap[dev.GetName()]; isBranch {
+			continue
+		}
+		if probe, ok := dev.(device.CurrentProbe); ok {
+			currents[fmt.Sprintf("I(%s)", dev.GetName())] = probe.ProbeCurrent(voltages, c.Status)
+		}
+	}
+
+	return currents
+}
+
+// GetDeviceCurrentsAC - Complex I(name) of devices which have no branch current
+func (c *Circuit) GetDeviceCurrentsAC(voltages []complex128) map[string]complex128 {
+	currents := make(map[string]complex128)
+
+	for _, dev := range c.devices {
+		if _, isBranch := c.branchMap[dev.GetName()]; isBranch {
+			continue
+		}
+		if probe, ok := dev.(device.ACCurrentProbe); ok {
+			currents[fmt.Sprintf("I(%s)", dev.GetName())] = probe.ProbeCurrentAC(voltages, c.Status)
+		}
+	}
+
+	return currents
+}
+
 func (c *Circuit) Destroy() {
 	if c.Matrix != nil {
 		c.Matrix.Destroy()
@@ -308,6 +594,12 @@ func (c *Circuit) UpdateNonlinearVoltages(solution []float64) error {
 			return fmt.Errorf("updating voltages: %v", err)
 		}
 	}
+	for _, dev := range c.storageDevices {
+		err = dev.UpdateVoltages(solution)
+		if err != nil {
+			return fmt.Errorf("updating voltages: %v", err)
+		}
+	}
 
 	return nil
 }
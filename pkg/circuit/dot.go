@@ -0,0 +1,86 @@
+package circuit
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/edp1096/toy-spice/pkg/device"
+	"github.com/edp1096/toy-spice/pkg/netlist"
+)
+
+// ExportDOT - Graphviz graph of circuit topology for debugging connectivity. Circuit nodes are ellipses and
+// devices are boxes, edges are labeled by terminal number. Nodes with one connection are red,
+// devices with all terminals on one node are orange and mutual couplings are dashed edges to inductors.
+// eg. dot -Tsvg circuit.dot -o circuit.svg
+func (c *Circuit) ExportDOT(w io.Writer) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "graph %s {\n", strconv.Quote(c.name))
+	sb.WriteString("  node [fontname=\"Helvetica\"];\n")
+
+	connections := make(map[string]int)
+	var nodes []string
+	for _, elem := range c.elements {
+		for _, node := range netlist.ElementNodes(elem) {
+			if connections[nodeName(node)] == 0 {
+				nodes = append(nodes, nodeName(node))
+			}
+			connections[nodeName(node)]++
+		}
+	}
+
+	for _, node := range nodes {
+		attrs := "shape=ellipse"
+		switch {
+		case node == "0":
+			attrs = "shape=ellipse, style=filled, fillcolor=lightgray"
+		case connections[node] == 1:
+			attrs = "shape=ellipse, style=filled, fillcolor=red"
+		}
+		fmt.Fprintf(&sb, "  %s [label=%s, %s];\n", strconv.Quote("n:"+node), strconv.Quote(node), attrs)
+	}
+
+	for _, elem := range c.elements {
+		elemNodes := netlist.ElementNodes(elem)
+		attrs := "shape=box"
+		if len(elemNodes) > 1 && shorted(elemNodes) {
+			attrs = "shape=box, style=filled, fillcolor=orange"
+		}
+		fmt.Fprintf(&sb, "  %s [label=%s, %s];\n", strconv.Quote("d:"+elem.Name), strconv.Quote(elem.Name), attrs)
+		for i, node := range elemNodes {
+			fmt.Fprintf(&sb, "  %s -- %s [label=\"%d\"];\n", strconv.Quote("d:"+elem.Name), strconv.Quote("n:"+nodeName(node)), i+1)
+		}
+	}
+
+	for _, dev := range c.devices {
+		mutual, ok := dev.(*device.Mutual)
+		if !ok {
+			continue
+		}
+		for _, name := range mutual.GetInductorNames() {
+			fmt.Fprintf(&sb, "  %s -- %s [style=dashed];\n", strconv.Quote("d:"+mutual.GetName()), strconv.Quote("d:"+name))
+		}
+	}
+
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// nodeName - Node name of graph, ground names are one node 0
+func nodeName(node string) string {
+	if netlist.IsGround(node) {
+		return "0"
+	}
+	return node
+}
+
+func shorted(nodes []string) bool {
+	for _, node := range nodes[1:] {
+		if nodeName(node) != nodeName(nodes[0]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,110 @@
+package circuit
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/edp1096/toy-spice/pkg/netlist"
+)
+
+// AddElement - Add device to circuit after setup. New nodes are numbered after existing ones.
+// Matrix is resized and analyzer must be set up again before next Execute.
+func (c *Circuit) AddElement(elem netlist.Element) error {
+	if c.findElement(elem.Name) >= 0 {
+		return fmt.Errorf("device already exists: %s", elem.Name)
+	}
+
+	elements := append(slices.Clone(c.elements), elem)
+	return c.rebuildTopology(elements)
+}
+
+// RemoveElement - Remove device from circuit after setup. Nodes left without devices are removed
+func (c *Circuit) RemoveElement(name string) error {
+	index := c.findElement(name)
+	if index < 0 {
+		return fmt.Errorf("device not found: %s", name)
+	}
+
+	// Inductor must not be left in mutual coupling
+	removed := c.elements[index]
+	for _, elem := range c.elements {
+		if elem.Type != "K" {
+			continue
+		}
+		for key, value := range elem.Params {
+			if strings.HasPrefix(key, "ind") && strings.EqualFold(value, removed.Name) {
+				return fmt.Errorf("inductor %s is coupled by %s, remove it first", removed.Name, elem.Name)
+			}
+		}
+	}
+
+	elements := slices.Delete(slices.Clone(c.elements), index, index+1)
+	return c.rebuildTopology(elements)
+}
+
+func (c *Circuit) findElement(name string) int {
+	return slices.IndexFunc(c.elements, func(elem netlist.Element) bool {
+		return strings.EqualFold(elem.Name, name)
+	})
+}
+
+// rebuildTopology - Renumber nodes and branches of edited elements, then recreate matrix and devices.
+// Remaining nodes keep their order, so indices shift only past removed nodes.
+func (c *Circuit) rebuildTopology(elements []netlist.Element) error {
+	used := make(map[string]bool)
+	var added []string
+	for _, elem := range elements {
+		for _, nodeName := range netlist.ElementNodes(elem) {
+			if netlist.IsGround(nodeName) || used[nodeName] {
+				continue
+			}
+			used[nodeName] = true
+			if _, exists := c.nodeMap[nodeName]; !exists {
+				added = append(added, nodeName)
+			}
+		}
+	}
+
+	var nodes []string
+	for nodeName := range c.nodeMap {
+		if used[nodeName] {
+			nodes = append(nodes, nodeName)
+		}
+	}
+	slices.SortFunc(nodes, func(a, b string) int { return c.nodeMap[a] - c.nodeMap[b] })
+	nodes = append(nodes, added...)
+
+	nodeMap := make(map[string]int, len(nodes))
+	for i, nodeName := range nodes {
+		nodeMap[nodeName] = i + 1
+	}
+	branchMap := make(map[string]int)
+	branchStart := len(nodeMap) + 1
+	for _, elem := range elements {
+		if netlist.HasBranch(elem) {
+			branchMap[elem.Name] = branchStart
+			branchStart++
+		}
+	}
+
+	oldNodeMap, oldBranchMap, oldNumNodes, oldMatrix := c.nodeMap, c.branchMap, c.numNodes, c.Matrix
+	c.nodeMap, c.branchMap, c.numNodes = nodeMap, branchMap, len(nodeMap)
+	err := c.CreateMatrix()
+	if err == nil {
+		err = c.rebuildDevices(elements, c.Models)
+	}
+	if err != nil {
+		if c.Matrix != oldMatrix && c.Matrix != nil {
+			c.Matrix.Destroy()
+		}
+		c.nodeMap, c.branchMap, c.numNodes, c.Matrix = oldNodeMap, oldBranchMap, oldNumNodes, oldMatrix
+		return err
+	}
+
+	if oldMatrix != nil {
+		oldMatrix.Destroy()
+	}
+	clear(c.prevSolution)
+	return nil
+}
@@ -0,0 +1,39 @@
+package circuit
+
+import (
+	"fmt"
+
+	"github.com/edp1096/toy-spice/pkg/netlist"
+)
+
+// FromNetlist - Circuit of parsed netlist with devices set up. Matrix is complex for frequency domain analyses.
+// configure, when not nil, sets options before matrix is created. eg. Solver, ScaleMatrix
+func FromNetlist(ckt *netlist.NetlistData, configure func(*Circuit)) (*Circuit, error) {
+	isComplex := ckt.Analysis == netlist.AnalysisAC || ckt.Analysis == netlist.AnalysisSP || ckt.Analysis == netlist.AnalysisDISTO || ckt.Analysis == netlist.AnalysisHB || ckt.Analysis == netlist.AnalysisSTB
+	c := NewWithComplex(ckt.Title, isComplex)
+
+	err := c.AssignNodeBranchMaps(ckt.Elements)
+	if err != nil {
+		return nil, fmt.Errorf("creating circuit mappings: %v", err)
+	}
+	c.FloatingShunt = ckt.Options["dcshunt"]
+	if temp, ok := ckt.Options["temp"]; ok {
+		c.SetTemperature(temp)
+	}
+	if configure != nil {
+		configure(c)
+	}
+	err = c.CreateMatrix()
+	if err != nil {
+		return nil, fmt.Errorf("creating matrix: %v", err)
+	}
+
+	// Model parameters - Must run before SetupDevices
+	c.Models = ckt.Models
+
+	err = c.SetupDevices(ckt.Elements)
+	if err != nil {
+		return c, fmt.Errorf("setting up devices: %v", err)
+	}
+	return c, nil
+}
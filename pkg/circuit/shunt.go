@@ -0,0 +1,49 @@
+package circuit
+
+import (
+	"fmt"
+
+	"github.com/edp1096/toy-spice/pkg/device"
+	"github.com/edp1096/toy-spice/pkg/matrix"
+	"github.com/edp1096/toy-spice/pkg/netlist"
+)
+
+// nodeShunt - Conductance from node without DC path to ground, inserted by FloatingShunt.
+// Stamped only in DC analyses where the node would make matrix singular, capacitors carry it otherwise
+type nodeShunt struct {
+	device.BaseDevice
+	g float64
+}
+
+func (s *nodeShunt) GetType() string { return "R" }
+
+func (s *nodeShunt) Stamp(matrix matrix.DeviceMatrix, status *device.CircuitStatus) error {
+	switch status.Mode {
+	case device.OperatingPointAnalysis, device.DCSweep:
+		matrix.AddElement(s.Nodes[0], s.Nodes[0], s.g)
+	}
+	return nil
+}
+
+func (s *nodeShunt) StampAC(matrix matrix.DeviceMatrix, status *device.CircuitStatus) error {
+	return nil
+}
+
+// insertShunts - Shunt of FloatingShunt resistance at every node without DC path, with warning
+func (c *Circuit) insertShunts(elements []netlist.Element) {
+	if c.FloatingShunt <= 0 {
+		return
+	}
+	for _, name := range netlist.FloatingNodes(elements) {
+		idx, ok := c.nodeMap[name]
+		if !ok || idx <= 0 {
+			continue
+		}
+		shunt := &nodeShunt{
+			BaseDevice: device.BaseDevice{Name: "shunt(" + name + ")", Nodes: []int{idx}, Value: c.FloatingShunt, NodeNames: []string{name}},
+			g:          1 / c.FloatingShunt,
+		}
+		c.devices = append(c.devices, shunt)
+		c.warnings = append(c.warnings, fmt.Sprintf("node %s has no DC path to ground, shunted by %g ohm in DC analyses", name, c.FloatingShunt))
+	}
+}
@@ -0,0 +1,179 @@
+package circuit
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/edp1096/toy-spice/pkg/device"
+)
+
+type stampEntry struct {
+	i, j       int // j is 0 for RHS
+	real, imag float64
+}
+
+// stampCache - Summed stamps of device.LinearStamp devices. They depend on time, timestep and gmin only,
+// so they are recorded once per Newton solve and replayed on every iteration.
+type stampCache struct {
+	valid   bool
+	status  device.CircuitStatus
+	entries []stampEntry
+	index   map[[2]int]int
+}
+
+func (sc *stampCache) invalidate() {
+	sc.valid = false
+}
+
+func (sc *stampCache) reset(status device.CircuitStatus) {
+	sc.valid = true
+	sc.status = status
+	sc.entries = sc.entries[:0]
+	if sc.index == nil {
+		sc.index = make(map[[2]int]int)
+	}
+	clear(sc.index)
+}
+
+func (sc *stampCache) add(i, j int, real, imag float64) {
+	key := [2]int{i, j}
+	idx, ok := sc.index[key]
+	if !ok {
+		idx = len(sc.entries)
+		sc.index[key] = idx
+		sc.entries = append(sc.entries, stampEntry{i: i, j: j})
+	}
+	sc.entries[idx].real += real
+	sc.entries[idx].imag += imag
+}
+
+// Recording of device stamps. RHS entries are keyed by column 0
+func (sc *stampCache) AddElement(i, j int, value float64) { sc.add(i, j, value, 0) }
+func (sc *stampCache) AddRHS(i int, value float64)        { sc.add(i, 0, value, 0) }
+func (sc *stampCache) AddComplexElement(i, j int, real, imag float64) {
+	sc.add(i, j, real, imag)
+}
+func (sc *stampCache) AddComplexRHS(i int, real, imag float64) { sc.add(i, 0, real, imag) }
+
+func (sc *stampCache) load(c *Circuit) {
+	for _, e := range sc.entries {
+		switch {
+		case e.j == 0 && e.imag == 0:
+			c.Matrix.AddRHS(e.i, e.real)
+		case e.j == 0:
+			c.Matrix.AddComplexRHS(e.i, e.real, e.imag)
+		case e.imag == 0:
+			c.Matrix.AddElement(e.i, e.j, e.real)
+		default:
+			c.Matrix.AddComplexElement(e.i, e.j, e.real, e.imag)
+		}
+	}
+}
+
+// isLinear - Stamp does not depend on Newton iterate. Devices opt in by device.LinearStamp
+func isLinear(dev device.Device) bool {
+	_, ok := dev.(device.LinearStamp)
+	return ok
+}
+
+// InvalidateLinearStamps - Linear stamps are recorded again by next StampIteration.
+// Call at start of each Newton solve and after changing device values, eg. source stepping.
+func (c *Circuit) InvalidateLinearStamps() {
+	c.linearStamps.invalidate()
+}
+
+// StampIteration - Stamp of Newton iteration. Linear devices are loaded from cache,
+// nonlinear devices are stamped at current iterate.
+func (c *Circuit) StampIteration(status *device.CircuitStatus) error {
+	// Flags of Newton iteration do not change linear stamps
+	key := *status
+	key.InitJunction, key.Bypass = false, false
+
+	if !c.linearStamps.valid || c.linearStamps.status != key {
+		c.linearStamps.reset(key)
+		for _, dev := range c.devices {
+			if !isLinear(dev) {
+				continue
+			}
+			err := dev.Stamp(&c.linearStamps, status)
+			if err != nil {
+				c.linearStamps.invalidate()
+				return fmt.Errorf("stamping device %s: %v", dev.GetName(), err)
+			}
+		}
+	}
+	c.linearStamps.load(c)
+
+	for _, dev := range c.devices {
+		if isLinear(dev) {
+			continue
+		}
+		err := dev.Stamp(c.Matrix, status)
+		if err != nil {
+			return fmt.Errorf("stamping device %s: %v", dev.GetName(), err)
+		}
+	}
+
+	return nil
+}
+
+// kclResidual - Accumulates A·x − b of stamps at x instead of matrix. Scale is sum of magnitudes of terms
+type kclResidual struct {
+	x, residual, scale []float64
+}
+
+func (r *kclResidual) AddElement(i, j int, value float64) {
+	if i > 0 && i < len(r.residual) && j > 0 && j < len(r.x) {
+		term := value * r.x[j]
+		r.residual[i] += term
+		r.scale[i] += math.Abs(term)
+	}
+}
+
+func (r *kclResidual) AddRHS(i int, value float64) {
+	if i > 0 && i < len(r.residual) {
+		r.residual[i] -= value
+		r.scale[i] += math.Abs(value)
+	}
+}
+
+func (r *kclResidual) AddComplexElement(i, j int, real, imag float64) { r.AddElement(i, j, real) }
+func (r *kclResidual) AddComplexRHS(i int, real, imag float64)        { r.AddRHS(i, real) }
+
+// Residual - KCL error of nodes at Newton solution, A(x)·x − b(x) with nonlinear devices linearized at x
+// and gmin of status on diagonal. Scale is sum of magnitudes of current terms of each node, for relative tolerance.
+// Linear devices are replayed from stamps of last StampIteration. Nonlinear devices are left evaluated at x.
+func (c *Circuit) Residual(solution []float64, status *device.CircuitStatus) (residual, scale []float64, err error) {
+	r := &kclResidual{
+		x:        solution,
+		residual: make([]float64, c.numNodes+1),
+		scale:    make([]float64, c.numNodes+1),
+	}
+
+	for _, e := range c.linearStamps.entries {
+		if e.j == 0 {
+			r.AddRHS(e.i, e.real)
+		} else {
+			r.AddElement(e.i, e.j, e.real)
+		}
+	}
+
+	err = c.UpdateNonlinearVoltages(solution)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, dev := range c.devices {
+		if isLinear(dev) {
+			continue
+		}
+		err := dev.Stamp(r, status)
+		if err != nil {
+			return nil, nil, fmt.Errorf("stamping device %s: %v", dev.GetName(), err)
+		}
+	}
+
+	for i := 1; i <= c.numNodes && i < len(solution); i++ {
+		r.AddElement(i, i, status.Gmin)
+	}
+	return r.residual, r.scale, nil
+}
@@ -0,0 +1,78 @@
+package circuit
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Summary - Size of circuit, eg. to check giant decks before analysis
+type Summary struct {
+	Devices    map[string]int // Device count by element type. eg. R, C, D
+	Nodes      int            // Nodes without ground
+	Branches   int            // Branch current unknowns
+	MatrixSize int            // Unknowns of MNA system
+	Nonlinear  int            // Nonlinear devices including nonlinear storage
+	Models     map[string]int // Device count by lowercase model name
+	Instances  []Instance     // Devices in netlist order
+}
+
+// Instance - Device of circuit by its netlist element
+type Instance struct {
+	Name  string
+	Type  string   // Element type. eg. R, Q
+	Nodes []string // Node names
+	Model string   // Model name, empty without model
+}
+
+// Summary - Device counts, unknowns and model usage of circuit after setup
+func (c *Circuit) Summary() Summary {
+	s := Summary{
+		Devices:    make(map[string]int),
+		Nodes:      c.numNodes,
+		Branches:   len(c.branchMap),
+		MatrixSize: len(c.nodeMap) + len(c.branchMap),
+		Nonlinear:  len(c.nonlinearDevices) + len(c.storageDevices),
+		Models:     make(map[string]int),
+	}
+	for _, elem := range c.elements {
+		model := elem.Params["model"]
+		s.Devices[elem.Type]++
+		if model != "" {
+			s.Models[strings.ToLower(model)]++
+		}
+		s.Instances = append(s.Instances, Instance{Name: elem.Name, Type: elem.Type, Nodes: elem.Nodes, Model: model})
+	}
+	return s
+}
+
+func (s Summary) String() string {
+	var sb strings.Builder
+	total := 0
+	for _, count := range s.Devices {
+		total += count
+	}
+	fmt.Fprintf(&sb, "Devices:     %d (%d nonlinear)\n", total, s.Nonlinear)
+	for _, name := range sortedKeys(s.Devices) {
+		fmt.Fprintf(&sb, "  %-10s %d\n", name, s.Devices[name])
+	}
+	fmt.Fprintf(&sb, "Nodes:       %d\n", s.Nodes)
+	fmt.Fprintf(&sb, "Branches:    %d\n", s.Branches)
+	fmt.Fprintf(&sb, "Matrix size: %d\n", s.MatrixSize)
+	if len(s.Models) > 0 {
+		fmt.Fprintf(&sb, "Models:      %d\n", len(s.Models))
+		for _, name := range sortedKeys(s.Models) {
+			fmt.Fprintf(&sb, "  %-10s %d\n", name, s.Models[name])
+		}
+	}
+	return sb.String()
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
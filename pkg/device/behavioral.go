@@ -0,0 +1,350 @@
+package device
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/edp1096/toy-spice/pkg/matrix"
+)
+
+// behavioral - Voltage source output of behavioral block. Nodes are out+, out-, in+, in- and optional clock.
+// Branch equation is v(out+) - v(out-) - Ro*i = output.
+type behavioral struct {
+	BaseDevice
+	branchIdx int
+	ro        float64
+}
+
+func (b *behavioral) inputVoltage(voltages []float64) float64 {
+	return nodeVoltage(voltages, b.Nodes[2]) - nodeVoltage(voltages, b.Nodes[3])
+}
+
+// stampOutput - Branch of output source. Output is g*v(in) + offset
+func (b *behavioral) stampOutput(matrix matrix.DeviceMatrix, g, offset float64) {
+	n1, n2, nc1, nc2 := b.Nodes[0], b.Nodes[1], b.Nodes[2], b.Nodes[3]
+	bIdx := b.branchIdx
+
+	if n1 != 0 {
+		matrix.AddElement(bIdx, n1, 1)
+		matrix.AddElement(n1, bIdx, 1)
+	}
+	if n2 != 0 {
+		matrix.AddElement(bIdx, n2, -1)
+		matrix.AddElement(n2, bIdx, -1)
+	}
+	if b.ro > 0 {
+		matrix.AddElement(bIdx, bIdx, -b.ro)
+	}
+	if g != 0 {
+		if nc1 != 0 {
+			matrix.AddElement(bIdx, nc1, -g)
+		}
+		if nc2 != 0 {
+			matrix.AddElement(bIdx, nc2, g)
+		}
+	}
+	matrix.AddRHS(bIdx, offset)
+}
+
+// stampOutputAC - Small signal of output with gain g of input
+func (b *behavioral) stampOutputAC(matrix matrix.DeviceMatrix, g float64) {
+	n1, n2, nc1, nc2 := b.Nodes[0], b.Nodes[1], b.Nodes[2], b.Nodes[3]
+	bIdx := b.branchIdx
+
+	if n1 != 0 {
+		matrix.AddComplexElement(bIdx, n1, 1, 0)
+		matrix.AddComplexElement(n1, bIdx, 1, 0)
+	}
+	if n2 != 0 {
+		matrix.AddComplexElement(bIdx, n2, -1, 0)
+		matrix.AddComplexElement(n2, bIdx, -1, 0)
+	}
+	if b.ro > 0 {
+		matrix.AddComplexElement(bIdx, bIdx, -b.ro, 0)
+	}
+	if g != 0 {
+		if nc1 != 0 {
+			matrix.AddComplexElement(bIdx, nc1, -g, 0)
+		}
+		if nc2 != 0 {
+			matrix.AddComplexElement(bIdx, nc2, g, 0)
+		}
+	}
+}
+
+func (b *behavioral) BranchIndex() int { return b.branchIdx }
+
+func (b *behavioral) SetBranchIndex(idx int) { b.branchIdx = idx }
+
+// BranchSign - Branch variable flows out+ to out- through output source
+func (b *behavioral) BranchSign() float64 { return 1 }
+
+func (b *behavioral) SetTimeStep(dt float64, status *CircuitStatus) { status.TimeStep = dt }
+
+func (b *behavioral) LoadState(voltages []float64, status *CircuitStatus) {}
+
+// TruncateTimeStep - Output follows node voltages without state
+func (b *behavioral) TruncateTimeStep(voltages []float64, status *CircuitStatus) float64 {
+	return math.Inf(1)
+}
+
+// Comparator - Output switches between Vol and Voh when v(in+) - v(in-) crosses Vth.
+// Threshold moves by Vhys/2 away from present output state. Unclocked output is smooth tanh of Width,
+// clocked output is latched at rising crossing of Vclk by clock node and held until next edge.
+type Comparator struct {
+	behavioral
+	Voh, Vol, Vth, Vhys, Width, Vclk float64
+
+	high     bool    // Accepted output state
+	vd       float64 // Input at Newton iterate
+	prevClk  float64
+	clockSet bool
+}
+
+var _ TimeDependent = (*Comparator)(nil)
+var _ BranchDevice = (*Comparator)(nil)
+var _ NonLinear = (*Comparator)(nil)
+
+func NewComparator(name string, nodeNames []string) *Comparator {
+	return &Comparator{
+		behavioral: behavioral{BaseDevice: BaseDevice{
+			Name:      name,
+			Nodes:     make([]int, len(nodeNames)),
+			NodeNames: nodeNames,
+		}},
+		Voh:   1,
+		Width: 1e-3,
+		Vclk:  0.5,
+	}
+}
+
+func (c *Comparator) GetType() string { return "A" }
+
+// SetModelParameters - COMP model
+func (c *Comparator) SetModelParameters(params map[string]float64) {
+	set := map[string]*float64{"voh": &c.Voh, "vol": &c.Vol, "vth": &c.Vth, "vhys": &c.Vhys, "width": &c.Width, "vclk": &c.Vclk, "ro": &c.ro}
+	for name, field := range set {
+		if v, ok := params[name]; ok {
+			*field = v
+		}
+	}
+}
+
+func (c *Comparator) clocked() bool { return len(c.Nodes) == 5 }
+
+// threshold - Switching point from present state
+func (c *Comparator) threshold() float64 {
+	if c.high {
+		return c.Vth - c.Vhys/2
+	}
+	return c.Vth + c.Vhys/2
+}
+
+// output - Output and its derivative by input
+func (c *Comparator) output(vd float64) (float64, float64) {
+	if c.clocked() {
+		if c.high {
+			return c.Voh, 0
+		}
+		return c.Vol, 0
+	}
+	swing := (c.Voh - c.Vol) / 2
+	if c.Width <= 0 {
+		if vd > c.threshold() {
+			return c.Voh, 0
+		}
+		return c.Vol, 0
+	}
+	t := math.Tanh((vd - c.threshold()) / c.Width)
+	return c.Vol + swing*(1+t), swing * (1 - t*t) / c.Width
+}
+
+func (c *Comparator) validate() error {
+	if len(c.Nodes) != 4 && len(c.Nodes) != 5 {
+		return fmt.Errorf("comparator %s: requires 4 nodes or 5 nodes with clock", c.Name)
+	}
+	return nil
+}
+
+func (c *Comparator) Stamp(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
+	if status.Mode == ACAnalysis {
+		return c.StampAC(matrix, status)
+	}
+	err := c.validate()
+	if err != nil {
+		return err
+	}
+	err = c.LoadConductance(matrix)
+	if err != nil {
+		return err
+	}
+	return c.LoadCurrent(matrix)
+}
+
+func (c *Comparator) StampAC(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
+	err := c.validate()
+	if err != nil {
+		return err
+	}
+	_, g := c.output(c.vd)
+	c.stampOutputAC(matrix, g)
+	return nil
+}
+
+// LoadConductance - Output linearized at input of Newton iterate
+func (c *Comparator) LoadConductance(matrix matrix.DeviceMatrix) error {
+	_, g := c.output(c.vd)
+	c.stampOutput(matrix, g, 0)
+	return nil
+}
+
+// LoadCurrent - Constant part of linearized output
+func (c *Comparator) LoadCurrent(matrix matrix.DeviceMatrix) error {
+	out, g := c.output(c.vd)
+	matrix.AddRHS(c.branchIdx, out-g*c.vd)
+	return nil
+}
+
+func (c *Comparator) UpdateVoltages(voltages []float64) error {
+	err := c.validate()
+	if err != nil {
+		return err
+	}
+	c.vd = c.inputVoltage(voltages)
+	return nil
+}
+
+// UpdateState - Accept output state at end of timestep
+func (c *Comparator) UpdateState(voltages []float64, status *CircuitStatus) {
+	vd := c.inputVoltage(voltages)
+	if !c.clocked() {
+		c.high = vd > c.threshold()
+		c.vd = vd
+		return
+	}
+
+	clk := nodeVoltage(voltages, c.Nodes[4])
+	if c.clockSet && c.prevClk < c.Vclk && clk >= c.Vclk {
+		c.high = vd > c.threshold()
+	}
+	c.prevClk, c.clockSet = clk, true
+}
+
+// InitDCState - Output state from operating point input
+func (c *Comparator) InitDCState(solution []float64, status *CircuitStatus) {
+	c.vd = c.inputVoltage(solution)
+	c.high = c.vd > c.threshold()
+	if c.clocked() {
+		c.prevClk, c.clockSet = nodeVoltage(solution, c.Nodes[4]), true
+	}
+}
+
+func (c *Comparator) InitUICState(voltages []float64, status *CircuitStatus) {
+	c.InitDCState(voltages, status)
+}
+
+// VCO - Output Vo + Va*wave(phase), frequency F0 + Kv*v(in+, in-) not below Fmin.
+// Phase is integrated from control voltage of accepted timepoints. Shape 0 is sine, 1 is square.
+type VCO struct {
+	behavioral
+	F0, Kv, Fmin, Vo, Va, Shape float64
+
+	phase float64 // Phase at last accepted timepoint, cycles
+	freq  float64 // Frequency at last accepted timepoint
+}
+
+var _ TimeDependent = (*VCO)(nil)
+var _ BranchDevice = (*VCO)(nil)
+var _ StepLimiter = (*VCO)(nil)
+
+// Timesteps per period of VCO output
+const vcoStepsPerPeriod = 50
+
+func NewVCO(name string, nodeNames []string) *VCO {
+	return &VCO{
+		behavioral: behavioral{BaseDevice: BaseDevice{
+			Name:      name,
+			Nodes:     make([]int, len(nodeNames)),
+			NodeNames: nodeNames,
+		}},
+		F0: 1e3,
+		Va: 1,
+	}
+}
+
+func (v *VCO) GetType() string { return "A" }
+
+// SetModelParameters - VCO model
+func (v *VCO) SetModelParameters(params map[string]float64) {
+	set := map[string]*float64{"f0": &v.F0, "kv": &v.Kv, "fmin": &v.Fmin, "vo": &v.Vo, "va": &v.Va, "shape": &v.Shape, "ro": &v.ro}
+	for name, field := range set {
+		if value, ok := params[name]; ok {
+			*field = value
+		}
+	}
+	v.freq = v.frequency(0)
+}
+
+// frequency - Output frequency at control voltage
+func (v *VCO) frequency(vc float64) float64 {
+	return math.Max(v.F0+v.Kv*vc, v.Fmin)
+}
+
+// wave - Output at phase in cycles
+func (v *VCO) wave(phase float64) float64 {
+	s := math.Sin(2 * math.Pi * phase)
+	if v.Shape == 1 {
+		if s >= 0 {
+			return v.Vo + v.Va
+		}
+		return v.Vo - v.Va
+	}
+	return v.Vo + v.Va*s
+}
+
+func (v *VCO) Stamp(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
+	if status.Mode == ACAnalysis {
+		return v.StampAC(matrix, status)
+	}
+	if len(v.Nodes) != 4 {
+		return fmt.Errorf("VCO %s: requires 4 nodes", v.Name)
+	}
+
+	phase := v.phase
+	if status.Mode == TransientAnalysis {
+		phase += v.freq * status.TimeStep
+	}
+	v.stampOutput(matrix, 0, v.wave(phase))
+	return nil
+}
+
+func (v *VCO) StampAC(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
+	if len(v.Nodes) != 4 {
+		return fmt.Errorf("VCO %s: requires 4 nodes", v.Name)
+	}
+	v.stampOutputAC(matrix, 0)
+	return nil
+}
+
+// UpdateState - Advance phase by frequency of previous timepoint
+func (v *VCO) UpdateState(voltages []float64, status *CircuitStatus) {
+	v.phase = math.Mod(v.phase+v.freq*status.TimeStep, 1)
+	v.freq = v.frequency(v.inputVoltage(voltages))
+}
+
+// MaxTimeStep - Resolve output waveform
+func (v *VCO) MaxTimeStep() float64 {
+	if v.freq <= 0 {
+		return 0
+	}
+	return 1 / (vcoStepsPerPeriod * v.freq)
+}
+
+func (v *VCO) InitDCState(solution []float64, status *CircuitStatus) {
+	v.phase = 0
+	v.freq = v.frequency(v.inputVoltage(solution))
+}
+
+func (v *VCO) InitUICState(voltages []float64, status *CircuitStatus) {
+	v.InitDCState(voltages, status)
+}
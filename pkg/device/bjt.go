@@ -3,6 +3,7 @@ package device
 import (
 	"fmt"
 	"math"
+	"math/cmplx"
 
 	"github.com/edp1096/toy-spice/internal/consts"
 	"github.com/edp1096/toy-spice/pkg/matrix"
@@ -38,15 +39,35 @@ type Bjt struct {
 	// Diffusion capacitance
 	Tf float64 // transit time (s), BE diffusion capacitance = Tf * gm
 
+	// Excess phase and quasi-saturation
+	Ptf float64 // Excess phase at 1/(2π Tf) (degree)
+	Rco float64 // Epitaxial region resistance (Ω)
+	Vo  float64 // Carrier velocity saturation voltage of epitaxial region (V)
+
+	// Temperature parameters
+	Xtb float64 // Forward and reverse beta temperature exponent
+	Xti float64 // Saturation current temperature exponent
+	Eg  float64 // Energy gap (eV)
+
+	// Instance parameters
+	Area  float64 // Area factor
+	Off   bool    // Initially off for DC analysis
+	Temp  float64 // Instance temperature (K). 0 = circuit temperature
+	Dtemp float64 // Instance temperature offset from circuit temperature (K)
+
+	thermalPort
+
 	// Internal voltages (V)
-	vbe float64 // Base-Emitter voltage
-	vbc float64 // Base-Collector voltage
-	vce float64 // Base-Collector voltage
+	vbe  float64 // Base-Emitter voltage
+	vbc  float64 // Base-Collector voltage
+	vce  float64 // Base-Collector voltage
+	vbci float64 // Base-Collector voltage of intrinsic transistor, after epitaxial drop
 
 	// DC current (A)
-	ic float64 // Collector current
-	ib float64 // Base current
-	ie float64 // Emitter current
+	ic  float64 // Collector current
+	ib  float64 // Base current
+	ie  float64 // Emitter current
+	icf float64 // Forward transport part of collector current, delayed by excess phase
 
 	// Conductance (S)
 	gm   float64 // transconductance, dI_C/dV_BE
@@ -63,8 +84,21 @@ type Bjt struct {
 	// Previous charge (C)
 	prevQbe float64
 	prevQbc float64
+
+	// Junction charges of accepted timepoints for truncation error
+	qbeHistory stateHistory
+	qbcHistory stateHistory
+
+	// Excess phase history. Delayed forward current at last two accepted timepoints and last timestep
+	excess    [2]float64
+	excessDt  float64
+	excessSet bool
 }
 
+var _ TimeDependent = (*Bjt)(nil)
+var _ DCInitializer = (*Bjt)(nil)
+var _ ParameterValidator = (*Bjt)(nil)
+
 func NewBJT(name string, nodeNames []string) *Bjt {
 	if len(nodeNames) != 3 {
 		panic(fmt.Sprintf("Bjt %s: requires exactly 3 nodes (collector, base, emitter)", name))
@@ -105,13 +139,47 @@ func (b *Bjt) setDefaultParameters() {
 	b.Mjc = 0.33    // BC grading coefficient
 
 	b.Tf = 300e-12 // 300 ps
+
+	b.Vo = 10.0
+	b.Xti = 3.0
+	b.Eg = 1.11
+
+	b.Area = 1.0
+}
+
+func (b *Bjt) SetInstanceParameters(params map[string]float64) {
+	if area, ok := params["area"]; ok && area > 0 {
+		b.Area = area
+	}
+	if off, ok := params["off"]; ok {
+		b.Off = off != 0
+	}
+	if temp, ok := params["temp"]; ok {
+		b.Temp = temp + consts.KELVIN // degC -> K
+	}
+	if dtemp, ok := params["dtemp"]; ok {
+		b.Dtemp = dtemp
+	}
+	b.setSelfHeating(params)
+}
+
+// Instance temperature if set, otherwise circuit temperature and dtemp. Thermal node or self-heating adds its rise
+func (b *Bjt) temperature(status *CircuitStatus) float64 {
+	if b.Temp > 0 {
+		return b.Temp + b.rise
+	}
+	return status.Temp + b.Dtemp + b.rise
+}
+
+func (b *Bjt) UpdateThermal(voltages []float64, dt float64, status *CircuitStatus) {
+	b.accept(voltages, b.ic*b.vce+b.ib*b.vbe, dt)
 }
 
 func (b *Bjt) calculateInitialOperatingPoint(temp float64) {
 	vt := b.thermalVoltage(temp)
 
 	targetIc := 1e-3
-	b.vbe = b.Nf * vt * math.Log(targetIc/b.Ies)
+	b.vbe = b.Nf * vt * math.Log(targetIc/(b.Area*b.Ies))
 	b.vce = math.Max(2.0, b.vbe+1.0)
 
 	b.vbc = b.vbe - b.vce
@@ -126,14 +194,63 @@ func (b *Bjt) thermalVoltage(temp float64) float64 {
 	return consts.BOLTZMANN * temp / consts.CHARGE
 }
 
-func (b *Bjt) temperatureAdjustedIs(temp float64) float64 {
-	tnom := 300.15
-	ratio := temp / tnom
-	eg := 1.11
-	egFactor := (eg * consts.CHARGE / consts.BOLTZMANN) * (1/tnom - 1/temp)
-	xti := 3.0
+// Nominal temperature of model parameters
+const bjtTnom = 300.15
 
-	return b.Ies * math.Pow(ratio, xti) * math.Exp(egFactor)
+// temperatureAdjustedIs - Emitter and collector saturation currents at temp by XTI and EG
+func (b *Bjt) temperatureAdjustedIs(temp float64) (float64, float64) {
+	if temp <= 0 || temp == bjtTnom {
+		return b.Area * b.Ies, b.Area * b.Ics
+	}
+	ratio := temp / bjtTnom
+	egFactor := (b.Eg * consts.CHARGE / consts.BOLTZMANN) * (1/bjtTnom - 1/temp)
+	factor := math.Pow(ratio, b.Xti) * math.Exp(egFactor)
+
+	return b.Area * b.Ies * factor, b.Area * b.Ics * factor
+}
+
+// temperatureAdjustedAlpha - Forward and reverse alpha at temp. Beta scales by (T/Tnom)^XTB
+func (b *Bjt) temperatureAdjustedAlpha(temp float64) (float64, float64) {
+	if b.Xtb == 0 || temp <= 0 || temp == bjtTnom {
+		return b.AlphaF, b.AlphaR
+	}
+	factor := math.Pow(temp/bjtTnom, b.Xtb)
+	alpha := func(a float64) float64 {
+		beta := a / (1 - a) * factor
+		return beta / (1 + beta)
+	}
+	return alpha(b.AlphaF), alpha(b.AlphaR)
+}
+
+// ValidateParameters - Model and instance parameters
+func (b *Bjt) ValidateParameters() []ParameterIssue {
+	c := paramCheck{device: b.Name}
+	c.positive("is", b.Ies)
+	c.positive("ics", b.Ics)
+	c.check("alphaf", b.AlphaF, b.AlphaF > 0 && b.AlphaF < 1, "0 < alphaf < 1, bf > 0", true)
+	c.fraction("alphar", b.AlphaR)
+	c.positive("nf", b.Nf)
+	c.positive("nr", b.Nr)
+	c.positive("vje", b.Vje)
+	c.positive("vjc", b.Vjc)
+	c.fraction("mje", b.Mje)
+	c.fraction("mjc", b.Mjc)
+	c.positive("area", b.Area)
+	c.nonNegative("ikf", b.Ikf)
+	c.nonNegative("ikr", b.Ikr)
+	c.nonNegative("vaf", b.Vaf)
+	c.nonNegative("var", b.Var)
+	c.nonNegative("tf", b.Tf)
+	c.nonNegative("rco", b.Rco)
+	if b.Rco > 0 {
+		c.positive("vo", b.Vo)
+	}
+	if b.AlphaF > 0 && b.AlphaF < 1 {
+		c.usual("bf", b.AlphaF/(1-b.AlphaF), 1, 1e4)
+	}
+	c.usual("nf", b.Nf, 0.5, 4)
+	c.usual("nr", b.Nr, 0.5, 4)
+	return c.issues
 }
 
 func (b *Bjt) SetModelParameters(params map[string]float64) {
@@ -144,6 +261,24 @@ func (b *Bjt) SetModelParameters(params map[string]float64) {
 		}
 	}
 
+	// Gummel-Poon model card names
+	if val, ok := params["is"]; ok {
+		b.Ies = val
+		b.Ics = val
+	}
+	if val, ok := params["bf"]; ok {
+		b.AlphaF = val / (1 + val)
+	}
+	if val, ok := params["br"]; ok {
+		b.AlphaR = val / (1 + val)
+	}
+	if val, ok := params["nf"]; ok {
+		b.Nf = val
+	}
+	if val, ok := params["nr"]; ok {
+		b.Nr = val
+	}
+
 	if val, ok := params["ies"]; ok {
 		b.Ies = val
 	}
@@ -190,80 +325,170 @@ func (b *Bjt) SetModelParameters(params map[string]float64) {
 	if val, ok := params["tf"]; ok {
 		b.Tf = val
 	}
+	// Excess phase, quasi-saturation and temperature
+	set := map[string]*float64{"ptf": &b.Ptf, "rco": &b.Rco, "vo": &b.Vo, "xtb": &b.Xtb, "xti": &b.Xti, "eg": &b.Eg}
+	for name, field := range set {
+		if val, ok := params[name]; ok {
+			*field = val
+		}
+	}
 }
 
 // Diffusion capacitance
 func (b *Bjt) calculateCapacitances() {
 	// BE junction: depletion capacitance
 	if b.vbe < b.Vje {
-		b.Cbe = b.Cje / math.Pow(1-b.vbe/b.Vje, b.Mje)
+		b.Cbe = b.Area * b.Cje / math.Pow(1-b.vbe/b.Vje, b.Mje)
 	} else {
-		b.Cbe = b.Cje * (1 + b.Mje*(b.vbe-b.Vje)/b.Vje)
+		b.Cbe = b.Area * b.Cje * (1 + b.Mje*(b.vbe-b.Vje)/b.Vje)
 	}
 
 	b.Cbe += b.Tf * b.gm // Append diffusion capacitance: Tf * gm
 
 	// BC junction: depletion capacitance
 	if b.vbc < b.Vjc {
-		b.Cbc = b.Cjc / math.Pow(1-b.vbc/b.Vjc, b.Mjc)
+		b.Cbc = b.Area * b.Cjc / math.Pow(1-b.vbc/b.Vjc, b.Mjc)
 	} else {
-		b.Cbc = b.Cjc * (1 + b.Mjc*(b.vbc-b.Vjc)/b.Vjc)
+		b.Cbc = b.Area * b.Cjc * (1 + b.Mjc*(b.vbc-b.Vjc)/b.Vjc)
 	}
 }
 
-func (b *Bjt) calculateCurrents(temp float64) {
+// transport - Emitter current, collector current and its forward part at junction voltages vbe, vbc
+func (b *Bjt) transport(vbe, vbc, temp float64) (float64, float64, float64) {
 	vt := b.thermalVoltage(temp)
-	expVbe := math.Exp(b.vbe / (b.Nf * vt))
-	expVbc := math.Exp(b.vbc / (b.Nr * vt))
+	ies, ics := b.temperatureAdjustedIs(temp)
+	alphaF, _ := b.temperatureAdjustedAlpha(temp)
+	expVbe := math.Exp(vbe / (b.Nf * vt))
+	expVbc := math.Exp(vbc / (b.Nr * vt))
 
 	sign := 1.0
 	if b.Type == "PNP" {
 		sign = -1.0
 	}
 
-	iF0 := sign * b.Ies * (expVbe - 1)
-	iR0 := sign * b.Ics * (expVbc - 1)
+	iF0 := sign * ies * (expVbe - 1)
+	iR0 := sign * ics * (expVbc - 1)
 
 	iF := iF0
 	if b.Vaf > 0 {
-		iF = iF0 * (1 - b.vbc/b.Vaf)
+		iF = iF0 * (1 - vbc/b.Vaf)
 	}
 	iR := iR0
 	if b.Var > 0 {
-		iR = iR0 * (1 + b.vbe/b.Var)
+		iR = iR0 * (1 + vbe/b.Var)
 	}
 
 	qb := 1.0
 	if b.Vaf > 0 {
-		qb = 1.0 / (1 - b.vbc/b.Vaf)
+		qb = 1.0 / (1 - vbc/b.Vaf)
 	}
 
 	if b.Ikf > 0 {
-		iF = iF / (1 + math.Abs(iF)/(b.Ikf*qb))
+		iF = iF / (1 + math.Abs(iF)/(b.Area*b.Ikf*qb))
 	}
 	if b.Ikr > 0 {
-		iR = iR / (1 + math.Abs(iR)/(b.Ikr*qb))
+		iR = iR / (1 + math.Abs(iR)/(b.Area*b.Ikr*qb))
 	}
 
 	IE := sign * (iF - iR)
-	IC := sign * ((b.AlphaF*iF - iR) / qb)
+	ICF := sign * alphaF * iF / qb
+	IC := sign * ((alphaF*iF - iR) / qb)
+	return IE, IC, ICF
+}
+
+// intrinsicVbc - Base-collector voltage of intrinsic transistor in quasi-saturation.
+// Collector current crosses epitaxial region of Kull model, i = vrc / (RCO*(1 + |vrc|/VO)),
+// so intrinsic collector is vrc below external collector. Solved by bisection on 0 <= vrc <= vce.
+func (b *Bjt) intrinsicVbc(vbe, vbc, temp float64) float64 {
+	vce := vbe - vbc
+	if b.Rco <= 0 || vce <= 0 {
+		return vbc
+	}
+	if _, ic, _ := b.transport(vbe, vbc, temp); ic <= 0 {
+		return vbc
+	}
+
+	epi := func(vrc float64) float64 {
+		if b.Vo > 0 {
+			return vrc / (b.Rco * (1 + math.Abs(vrc)/b.Vo))
+		}
+		return vrc / b.Rco
+	}
+	lo, hi := 0.0, vce
+	for range 60 {
+		vrc := (lo + hi) / 2
+		if _, ic, _ := b.transport(vbe, vbc+vrc, temp); epi(vrc) > ic {
+			hi = vrc
+		} else {
+			lo = vrc
+		}
+	}
+	return vbc + (lo+hi)/2
+}
+
+func (b *Bjt) calculateCurrents(temp float64) {
+	b.vbci = b.intrinsicVbc(b.vbe, b.vbc, temp)
+	IE, IC, ICF := b.transport(b.vbe, b.vbci, temp)
 	IB := IE - IC
 
 	b.ie = IE
 	b.ic = IC
 	b.ib = IB
+	b.icf = ICF
+}
+
+// excessPhaseDelay - Delay of forward current, PTF in radian times TF
+func (b *Bjt) excessPhaseDelay() float64 {
+	return b.Ptf * math.Pi / 180 * b.Tf
+}
+
+// applyExcessPhase - Forward collector current through second order Bessel delay of SPICE, integrated
+// from accepted history over timestep dt. Returns delayed forward current and its gain on present value.
+func (b *Bjt) applyExcessPhase(dt float64) (float64, float64) {
+	td := b.excessPhaseDelay()
+	if !b.excessSet {
+		b.excess = [2]float64{b.icf, b.icf}
+		b.excessSet = true
+	}
+
+	arg1 := dt / td
+	arg2 := 3 * arg1
+	arg1 = arg2 * arg1
+	denom := 1 + arg1 + arg2
+	gain := arg1 / denom
+
+	ratio := 1.0
+	if b.excessDt > 0 {
+		ratio = dt / b.excessDt
+	}
+	delayed := (b.excess[0]*(1+ratio+arg2)-b.excess[1]*ratio)/denom + b.icf*gain
+	return delayed, gain
+}
+
+// excessPhase - Replace forward collector current by delayed one in transient
+func (b *Bjt) excessPhase(status *CircuitStatus) {
+	if b.excessPhaseDelay() <= 0 || status.Mode != TransientAnalysis || status.TimeStep <= 0 {
+		return
+	}
+	delayed, gain := b.applyExcessPhase(status.TimeStep)
+	b.ic += delayed - b.icf
+	b.ib = b.ie - b.ic
+	b.gm *= gain
 }
 
 func (b *Bjt) calculateConductances(temp float64) {
 	vt := b.thermalVoltage(temp)
+	ies, _ := b.temperatureAdjustedIs(temp)
+	alphaF, _ := b.temperatureAdjustedAlpha(temp)
 	expVbe := math.Exp(b.vbe / (b.Nf * vt))
-	dIes_dVbe := b.Ies * expVbe / (b.Nf * vt)
+	dIes_dVbe := ies * expVbe / (b.Nf * vt)
 
+	vbc := b.intrinsicVbc(b.vbe, b.vbc, temp)
 	qb := 1.0
 	if b.Vaf > 0 {
-		qb = 1.0 / (1 - b.vbc/b.Vaf)
+		qb = 1.0 / (1 - vbc/b.Vaf)
 	}
-	b.gm = b.AlphaF * dIes_dVbe / qb
+	b.gm = alphaF * dIes_dVbe / qb
 
 	if vt != 0 {
 		b.gpi = math.Abs(b.ib) / vt
@@ -272,11 +497,23 @@ func (b *Bjt) calculateConductances(temp float64) {
 	}
 
 	if b.Vaf != 0 {
-		b.gout = b.AlphaF * b.Ies * (expVbe - 1) * (1 / b.Vaf) * math.Pow(1+b.vce/b.Vaf, -2)
+		b.gout = alphaF * ies * (expVbe - 1) * (1 / b.Vaf) * math.Pow(1+b.vce/b.Vaf, -2)
 	} else {
 		b.gout = 1e-12
 	}
 
+	// Epitaxial drop depends on collector current, so quasi-saturation conductances are numerical
+	if b.Rco > 0 {
+		delta := 1e-6
+		collector := func(vbe, vbc float64) float64 {
+			_, ic, _ := b.transport(vbe, b.intrinsicVbc(vbe, vbc, temp), temp)
+			return ic
+		}
+		ic := collector(b.vbe, b.vbc)
+		b.gm = (collector(b.vbe+delta, b.vbc+delta) - ic) / delta // Constant vce
+		b.gout = math.Max((ic-collector(b.vbe, b.vbc-delta))/delta, 1e-12)
+	}
+
 	fmt.Println("b.vbe, b.Nf, vt, expVbe, dIes_dVbe, gm, gpi, gout", b.vbe, b.Nf, vt, expVbe, dIes_dVbe, b.gm, b.gpi, b.gout)
 }
 
@@ -313,6 +550,10 @@ func (b *Bjt) UpdateVoltages(voltages []float64) error {
 }
 
 func (b *Bjt) Stamp(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
+	if status.Mode == ACAnalysis {
+		return b.StampAC(matrix, status)
+	}
+
 	nc := b.Nodes[0]
 	nb := b.Nodes[1]
 	ne := b.Nodes[2]
@@ -320,19 +561,26 @@ func (b *Bjt) Stamp(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
 	// fmt.Printf("BJT %s type: %s\n", b.Name, b.Type)
 	// fmt.Printf("Before calculation: VBE=%.3f, VCE=%.3f\n", b.vbe, b.vce)
 
-	if b.vbe == 0 && b.vce == 0 {
+	temp := b.temperature(status)
+
+	if b.Off && status.InitJunction {
+		b.vbe = 0
+		b.vbc = 0
+		b.vce = 0
+	} else if b.vbe == 0 && b.vce == 0 {
 		// // b.vbe = 0.7
 		// // b.vce = 5.0
 		// b.vbe = 0.685
 		// b.vce = 2.7
 		// b.vbc = b.vbe - b.vce
 
-		b.calculateInitialOperatingPoint(status.Temp)
+		b.calculateInitialOperatingPoint(temp)
 	}
 
-	b.calculateCurrents(status.Temp)
-	b.calculateConductances(status.Temp)
+	b.calculateCurrents(temp)
+	b.calculateConductances(temp)
 	b.calculateCapacitances()
+	b.excessPhase(status)
 
 	// fmt.Printf("After calculation: VBE=%.3f, VCE=%.3f\n", b.vbe, b.vce)
 
@@ -341,6 +589,8 @@ func (b *Bjt) Stamp(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
 	// b.gm += gmin
 	// b.gout += gmin
 
+	b.stampPower(matrix, status)
+
 	// Collector
 	if nc != 0 {
 		matrix.AddElement(nc, nc, b.gout)
@@ -378,11 +628,12 @@ func (b *Bjt) StampAC(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
 	nb := b.Nodes[1]
 	ne := b.Nodes[2]
 
-	b.calculateConductances(status.Temp)
+	b.calculateConductances(b.temperature(status))
 	b.calculateCapacitances()
 
 	omega := 2 * math.Pi * status.Frequency
 	gmin := status.Gmin
+	gm := b.acTransconductance(omega)
 
 	if nb != 0 {
 		matrix.AddComplexElement(nb, nb, b.gpi+gmin, omega*b.Cbe)
@@ -393,10 +644,10 @@ func (b *Bjt) StampAC(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
 	if nc != 0 {
 		matrix.AddComplexElement(nc, nc, b.gout+gmin, 0)
 		if nb != 0 {
-			matrix.AddComplexElement(nc, nb, -b.gout-b.gm, 0)
+			matrix.AddComplexElement(nc, nb, -b.gout-real(gm), -imag(gm))
 		}
 		if ne != 0 {
-			matrix.AddComplexElement(nc, ne, b.gm, 0)
+			matrix.AddComplexElement(nc, ne, real(gm), imag(gm))
 		}
 	}
 	if ne != 0 {
@@ -438,14 +689,81 @@ func (b *Bjt) LoadCurrent(matrix matrix.DeviceMatrix) error {
 	return nil
 }
 
+// Collector current at last evaluated operating point
+func (b *Bjt) ProbeCurrent(voltages []float64, status *CircuitStatus) float64 {
+	return b.ic
+}
+
+// Small-signal collector current. Same as collector row of AC stamp
+func (b *Bjt) ProbeCurrentAC(voltages []complex128, status *CircuitStatus) complex128 {
+	vc := nodeVoltageAC(voltages, b.Nodes[0])
+	vb := nodeVoltageAC(voltages, b.Nodes[1])
+	ve := nodeVoltageAC(voltages, b.Nodes[2])
+
+	gmin := status.Gmin
+	gm := b.acTransconductance(2 * math.Pi * status.Frequency)
+	return complex(b.gout+gmin, 0)*vc + (complex(-b.gout, 0)-gm)*vb + gm*ve
+}
+
+// acTransconductance - Transconductance lagging by excess phase delay
+func (b *Bjt) acTransconductance(omega float64) complex128 {
+	td := b.excessPhaseDelay()
+	if td <= 0 {
+		return complex(b.gm, 0)
+	}
+	return cmplx.Rect(b.gm, -omega*td)
+}
+
+func (b *Bjt) SetTimeStep(dt float64, status *CircuitStatus) { status.TimeStep = dt }
+
+func (b *Bjt) LoadState(voltages []float64, status *CircuitStatus) {}
+
 func (b *Bjt) UpdateState(voltages []float64, status *CircuitStatus) {
 	b.UpdateVoltages(voltages)
 	b.prevQbe = b.qbe
 	b.prevQbc = b.qbc
 
-	b.calculateCurrents(status.Temp)
-	b.calculateConductances(status.Temp)
+	temp := b.temperature(status)
+	b.calculateCurrents(temp)
+	b.calculateConductances(temp)
 	b.calculateCapacitances()
 	b.qbe = b.Cbe * b.vbe
 	b.qbc = b.Cbc * b.vbc
+	b.qbeHistory.accept(b.qbe, status.TimeStep)
+	b.qbcHistory.accept(b.qbc, status.TimeStep)
+
+	// Delayed forward current of accepted timepoint becomes history of excess phase
+	if b.excessPhaseDelay() > 0 && status.TimeStep > 0 {
+		delayed, _ := b.applyExcessPhase(status.TimeStep)
+		b.excess = [2]float64{delayed, b.excess[0]}
+		b.excessDt = status.TimeStep
+	}
+}
+
+// TruncateTimeStep - Junction charges Cbe*vbe and Cbc*vbc at new junction voltages, capacitances of last iterate
+func (b *Bjt) TruncateTimeStep(voltages []float64, status *CircuitStatus) float64 {
+	vc := nodeVoltage(voltages, b.Nodes[0])
+	vb := nodeVoltage(voltages, b.Nodes[1])
+	ve := nodeVoltage(voltages, b.Nodes[2])
+	vbe, vbc := vb-ve, vb-vc
+	if b.Type == "PNP" {
+		vbe, vbc = -vbe, -vbc
+	}
+	return math.Min(b.qbeHistory.truncate(b.Cbe*vbe, status), b.qbcHistory.truncate(b.Cbc*vbc, status))
+}
+
+// InitDCState - Excess phase and junction charges start from operating point
+func (b *Bjt) InitDCState(solution []float64, status *CircuitStatus) {
+	temp := b.temperature(status)
+	b.UpdateVoltages(solution)
+	b.calculateCurrents(temp)
+	b.calculateConductances(temp)
+	b.calculateCapacitances()
+	b.qbe, b.qbc = b.Cbe*b.vbe, b.Cbc*b.vbc
+	b.prevQbe, b.prevQbc = b.qbe, b.qbc
+	b.qbeHistory.reset(b.qbe)
+	b.qbcHistory.reset(b.qbc)
+	b.excess = [2]float64{b.icf, b.icf}
+	b.excessDt = 0
+	b.excessSet = true
 }
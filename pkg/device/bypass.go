@@ -0,0 +1,36 @@
+package device
+
+import "math"
+
+// Tolerances of bypass. Terminal voltage change below vntol + reltol*|v| keeps last model evaluation
+const (
+	bypassReltol = 1e-3
+	bypassVntol  = 1e-6
+)
+
+// bypassState - Terminal voltages and temperature of last full model evaluation of nonlinear device
+type bypassState struct {
+	valid    bool
+	temp     float64
+	voltages [3]float64
+}
+
+// canBypass - Last evaluation is reusable. Bypass is on, not first DC iteration and voltages moved less than tolerance
+func (s *bypassState) canBypass(status *CircuitStatus, temp float64, voltages ...float64) bool {
+	if !status.Bypass || status.InitJunction || !s.valid || temp != s.temp {
+		return false
+	}
+	for i, v := range voltages {
+		if math.Abs(v-s.voltages[i]) > bypassReltol*math.Max(math.Abs(v), math.Abs(s.voltages[i]))+bypassVntol {
+			return false
+		}
+	}
+	return true
+}
+
+// evaluated - Remember voltages of full model evaluation
+func (s *bypassState) evaluated(temp float64, voltages ...float64) {
+	s.valid = true
+	s.temp = temp
+	copy(s.voltages[:], voltages)
+}
@@ -3,6 +3,7 @@ package device
 import (
 	"math"
 
+	"github.com/edp1096/toy-spice/internal/consts"
 	"github.com/edp1096/toy-spice/pkg/matrix"
 )
 
@@ -18,9 +19,25 @@ type Capacitor struct {
 	Tc1  float64
 	Tc2  float64
 	Tnom float64
+	Temp float64 // Instance temperature. Circuit temperature if zero
+
+	ic    float64 // Initial voltage of IC=
+	hasIC bool
+
+	history stateHistory // Charge of accepted timepoints for truncation error
+
+	// Semiconductor capacitor model
+	Cj     float64 // Junction bottom capacitance per area
+	Cjsw   float64 // Junction sidewall capacitance per length
+	Defw   float64 // Default width
+	Narrow float64 // Narrowing due to side etching
+	L      float64 // Length
+	W      float64 // Width
 }
 
 var _ TimeDependent = (*Capacitor)(nil)
+var _ UICInitializer = (*Capacitor)(nil)
+var _ LinearStamp = (*Capacitor)(nil)
 
 func NewCapacitor(name string, nodeNames []string, value float64) *Capacitor {
 	return &Capacitor{
@@ -33,36 +50,74 @@ func NewCapacitor(name string, nodeNames []string, value float64) *Capacitor {
 		Tc1:  0.0,
 		Tc2:  0.0,
 		Tnom: 300.15,
+		Defw: 10e-6,
 	}
 }
 
 func (c *Capacitor) GetType() string { return "C" }
+func (c *Capacitor) LinearStamp()    {}
+
+func (c *Capacitor) SetModelParameters(params map[string]float64) {
+	paramsSet := map[string]*float64{
+		"cj":     &c.Cj,     // Cj (Junction bottom capacitance)
+		"cjsw":   &c.Cjsw,   // Cjsw (Junction sidewall capacitance)
+		"defw":   &c.Defw,   // Defw (Default width)
+		"narrow": &c.Narrow, // Narrow (Narrowing due to side etching)
+		"tc1":    &c.Tc1,    // Tc1 (First order temp. coefficient)
+		"tc2":    &c.Tc2,    // Tc2 (Second order temp. coefficient)
+	}
+
+	for key, param := range paramsSet {
+		if value, ok := params[key]; ok {
+			*param = value
+		}
+	}
+	if tnom, ok := params["tnom"]; ok {
+		c.Tnom = tnom + consts.KELVIN // degC -> K
+	}
+}
+
+// SetInstanceParameters - Temperature coefficients, temperature and geometry. Value is C = Cj*(L-Narrow)*(W-Narrow) + 2*Cjsw*(L+W-2*Narrow) unless given on instance
+func (c *Capacitor) SetInstanceParameters(params map[string]float64) {
+	if tc1, ok := params["tc1"]; ok {
+		c.Tc1 = tc1
+	}
+	if tc2, ok := params["tc2"]; ok {
+		c.Tc2 = tc2
+	}
+	if tnom, ok := params["tnom"]; ok {
+		c.Tnom = tnom + consts.KELVIN // degC -> K
+	}
+	if temp, ok := params["temp"]; ok {
+		c.Temp = temp + consts.KELVIN // degC -> K
+	}
+	if l, ok := params["l"]; ok {
+		c.L = l
+	}
+	if w, ok := params["w"]; ok {
+		c.W = w
+	}
+
+	if c.Value == 0 && c.L > 0 {
+		width := c.W
+		if width <= 0 {
+			width = c.Defw
+		}
+		length := c.L - c.Narrow
+		width -= c.Narrow
+		c.Value = c.Cj*length*width + 2*c.Cjsw*(length+width)
+	}
+}
 
 func (c *Capacitor) SetTimeStep(dt float64, status *CircuitStatus) { status.TimeStep = dt }
 
 func (c *Capacitor) Stamp(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
 	n1, n2 := c.Nodes[0], c.Nodes[1]
-	adjustedC := c.temperatureAdjustedValue(status.Temp)
+	adjustedC := c.temperatureAdjustedValue(c.temperature(status))
 
 	switch status.Mode {
 	case ACAnalysis:
-		omega := 2 * math.Pi * status.Frequency
-		capConductanceReal := 0.0
-		// capConductanceImag := omega * c.Value // C * jω
-		capConductanceImag := omega * adjustedC // C * jω
-
-		if n1 != 0 {
-			matrix.AddComplexElement(n1, n1, capConductanceReal, capConductanceImag)
-			if n2 != 0 {
-				matrix.AddComplexElement(n1, n2, -capConductanceReal, -capConductanceImag)
-			}
-		}
-		if n2 != 0 {
-			matrix.AddComplexElement(n2, n2, capConductanceReal, capConductanceImag)
-			if n1 != 0 {
-				matrix.AddComplexElement(n2, n1, -capConductanceReal, -capConductanceImag)
-			}
-		}
+		return c.StampAC(matrix, status)
 
 	case OperatingPointAnalysis:
 		gmin := status.Gmin
@@ -87,7 +142,7 @@ func (c *Capacitor) Stamp(matrix matrix.DeviceMatrix, status *CircuitStatus) err
 		// geq := 2.0 * adjustedC / dt
 		// ceq := geq*c.Voltage0/2.0 + c.current1
 		geq := adjustedC / dt
-		ceq := c.charge1 / dt
+		ceq := c.charge0 / dt // Charge of last accepted timepoint
 
 		if n1 != 0 {
 			matrix.AddElement(n1, n1, geq)
@@ -108,6 +163,31 @@ func (c *Capacitor) Stamp(matrix matrix.DeviceMatrix, status *CircuitStatus) err
 	return nil
 }
 
+func (c *Capacitor) StampAC(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
+	n1, n2 := c.Nodes[0], c.Nodes[1]
+	adjustedC := c.temperatureAdjustedValue(c.temperature(status))
+
+	omega := 2 * math.Pi * status.Frequency
+	capConductanceReal := 0.0
+	// capConductanceImag := omega * c.Value // C * jω
+	capConductanceImag := omega * adjustedC // C * jω
+
+	if n1 != 0 {
+		matrix.AddComplexElement(n1, n1, capConductanceReal, capConductanceImag)
+		if n2 != 0 {
+			matrix.AddComplexElement(n1, n2, -capConductanceReal, -capConductanceImag)
+		}
+	}
+	if n2 != 0 {
+		matrix.AddComplexElement(n2, n2, capConductanceReal, capConductanceImag)
+		if n1 != 0 {
+			matrix.AddComplexElement(n2, n1, -capConductanceReal, -capConductanceImag)
+		}
+	}
+
+	return nil
+}
+
 func (c *Capacitor) LoadState(voltages []float64, status *CircuitStatus) {
 	v1 := 0.0
 	if c.Nodes[0] != 0 {
@@ -165,16 +245,74 @@ func (c *Capacitor) UpdateState(voltages []float64, status *CircuitStatus) {
 
 	c.charge1 = c.charge0
 	c.charge0 = c.Value * vd
+	c.history.accept(c.charge0, status.TimeStep)
 
 	c.Voltage1 = c.Voltage0
 	c.Voltage0 = vd
 }
 
-func (c *Capacitor) CalculateLTE(voltages map[string]float64, status *CircuitStatus) float64 {
-	qNew := c.Value * c.Voltage0
-	qOld := c.Value * c.Voltage1
+// SetInitialCondition - IC= voltage used at transient start with UIC
+func (c *Capacitor) SetInitialCondition(voltage float64) {
+	c.ic = voltage
+	c.hasIC = true
+}
+
+// InitDCState - Start transient from DC operating point voltage
+func (c *Capacitor) InitDCState(solution []float64, status *CircuitStatus) {
+	c.initVoltage(nodeVoltage(solution, c.Nodes[0]) - nodeVoltage(solution, c.Nodes[1]))
+}
 
-	return math.Abs(qNew-qOld) / (2.0 * status.TimeStep)
+// InitUICState - Start transient from IC=, otherwise from .ic node voltages.
+// IC= of grounded capacitor is voltage of its node in initial solution
+func (c *Capacitor) InitUICState(voltages []float64, status *CircuitStatus) {
+	if c.hasIC {
+		c.initVoltage(c.ic)
+		switch {
+		case c.Nodes[1] == 0 && c.Nodes[0] > 0:
+			voltages[c.Nodes[0]] = c.ic
+		case c.Nodes[0] == 0 && c.Nodes[1] > 0:
+			voltages[c.Nodes[1]] = -c.ic
+		}
+		return
+	}
+	c.initVoltage(nodeVoltage(voltages, c.Nodes[0]) - nodeVoltage(voltages, c.Nodes[1]))
+}
+
+func (c *Capacitor) initVoltage(vd float64) {
+	c.Voltage0 = vd
+	c.Voltage1 = vd
+	c.charge0 = c.Value * vd
+	c.charge1 = c.charge0
+	c.current0 = 0
+	c.current1 = 0
+	c.history.reset(c.charge0)
+}
+
+func (c *Capacitor) TruncateTimeStep(voltages []float64, status *CircuitStatus) float64 {
+	vd := nodeVoltage(voltages, c.Nodes[0]) - nodeVoltage(voltages, c.Nodes[1])
+	return c.history.truncate(c.Value*vd, status)
+}
+
+// Current from n1 to n2. Zero at DC
+func (c *Capacitor) ProbeCurrent(voltages []float64, status *CircuitStatus) float64 {
+	if status.Mode != TransientAnalysis || status.TimeStep <= 0 {
+		return 0
+	}
+	return c.temperatureAdjustedValue(c.temperature(status)) * (c.Voltage0 - c.Voltage1) / status.TimeStep
+}
+
+func (c *Capacitor) ProbeCurrentAC(voltages []complex128, status *CircuitStatus) complex128 {
+	vd := nodeVoltageAC(voltages, c.Nodes[0]) - nodeVoltageAC(voltages, c.Nodes[1])
+	omega := 2 * math.Pi * status.Frequency
+	return complex(0, omega*c.temperatureAdjustedValue(c.temperature(status))) * vd
+}
+
+// Instance temperature if set, otherwise circuit temperature
+func (c *Capacitor) temperature(status *CircuitStatus) float64 {
+	if c.Temp > 0 {
+		return c.Temp
+	}
+	return status.Temp
 }
 
 func (c *Capacitor) temperatureAdjustedValue(temp float64) float64 {
@@ -0,0 +1,254 @@
+package device
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/edp1096/toy-spice/pkg/matrix"
+)
+
+// controlled - Voltage controlled source. Nodes are n+, n-, nc+, nc-.
+// Without transfer function output is Value times control voltage.
+type controlled struct {
+	BaseDevice
+	transfer Transfer
+	dt       float64 // Timestep of last transient stamp
+	output   float64 // Output of transfer function at last accepted timepoint
+}
+
+func (c *controlled) controlVoltage(voltages []float64) float64 {
+	return nodeVoltage(voltages, c.Nodes[2]) - nodeVoltage(voltages, c.Nodes[3])
+}
+
+// gain - Output per control voltage and constant part of output
+func (c *controlled) gain(status *CircuitStatus) (float64, float64, error) {
+	if len(c.Nodes) != 4 {
+		return 0, 0, fmt.Errorf("controlled source %s: requires 4 nodes", c.Name)
+	}
+	if c.transfer == nil {
+		return c.Value, 0, nil
+	}
+	if status.Mode == TransientAnalysis && status.TimeStep > 0 {
+		c.dt = status.TimeStep
+		g, offset := c.transfer.Companion(status.Time, status.TimeStep)
+		return g, offset, nil
+	}
+	return c.transfer.DCGain(), 0, nil
+}
+
+// acGain - Complex gain at AC frequency
+func (c *controlled) acGain(status *CircuitStatus) complex128 {
+	if c.transfer == nil {
+		return complex(c.Value, 0)
+	}
+	return c.transfer.Response(status.Frequency)
+}
+
+func (c *controlled) SetTimeStep(dt float64, status *CircuitStatus) { status.TimeStep = dt }
+
+func (c *controlled) LoadState(voltages []float64, status *CircuitStatus) {}
+
+func (c *controlled) UpdateState(voltages []float64, status *CircuitStatus) {
+	if c.transfer != nil && c.dt > 0 {
+		u := c.controlVoltage(voltages)
+		g, offset := c.transfer.Companion(status.Time, c.dt)
+		c.output = g*u + offset
+		c.transfer.Accept(status.Time, c.dt, u)
+	}
+}
+
+func (c *controlled) InitDCState(solution []float64, status *CircuitStatus) {
+	if c.transfer != nil {
+		u := c.controlVoltage(solution)
+		c.output = c.transfer.DCGain() * u
+		c.transfer.Reset(u)
+	}
+}
+
+func (c *controlled) InitUICState(voltages []float64, status *CircuitStatus) {
+	c.InitDCState(voltages, status)
+}
+
+// TruncateTimeStep - Output follows node voltages without state
+func (c *controlled) TruncateTimeStep(voltages []float64, status *CircuitStatus) float64 {
+	return math.Inf(1)
+}
+
+// VCVS - E source. v(n+) - v(n-) = H * (v(nc+) - v(nc-))
+type VCVS struct {
+	controlled
+	branchIdx int
+}
+
+var _ TimeDependent = (*VCVS)(nil)
+var _ BranchDevice = (*VCVS)(nil)
+var _ LinearStamp = (*VCVS)(nil)
+
+// NewVCVS - Voltage controlled voltage source. transfer is nil for constant gain
+func NewVCVS(name string, nodeNames []string, gain float64, transfer Transfer) *VCVS {
+	return &VCVS{controlled: controlled{
+		BaseDevice: BaseDevice{
+			Name:      name,
+			Value:     gain,
+			Nodes:     make([]int, len(nodeNames)),
+			NodeNames: nodeNames,
+		},
+		transfer: transfer,
+	}}
+}
+
+func (e *VCVS) GetType() string { return "E" }
+func (e *VCVS) LinearStamp()    {}
+
+func (e *VCVS) Stamp(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
+	if status.Mode == ACAnalysis {
+		return e.StampAC(matrix, status)
+	}
+
+	g, offset, err := e.gain(status)
+	if err != nil {
+		return err
+	}
+	n1, n2, nc1, nc2 := e.Nodes[0], e.Nodes[1], e.Nodes[2], e.Nodes[3]
+	bIdx := e.branchIdx
+
+	// v1 - v2 - g*(vc1 - vc2) = offset
+	if n1 != 0 {
+		matrix.AddElement(bIdx, n1, 1)
+		matrix.AddElement(n1, bIdx, 1)
+	}
+	if n2 != 0 {
+		matrix.AddElement(bIdx, n2, -1)
+		matrix.AddElement(n2, bIdx, -1)
+	}
+	if nc1 != 0 {
+		matrix.AddElement(bIdx, nc1, -g)
+	}
+	if nc2 != 0 {
+		matrix.AddElement(bIdx, nc2, g)
+	}
+	matrix.AddRHS(bIdx, offset)
+	return nil
+}
+
+func (e *VCVS) StampAC(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
+	if len(e.Nodes) != 4 {
+		return fmt.Errorf("controlled source %s: requires 4 nodes", e.Name)
+	}
+	h := e.acGain(status)
+	n1, n2, nc1, nc2 := e.Nodes[0], e.Nodes[1], e.Nodes[2], e.Nodes[3]
+	bIdx := e.branchIdx
+
+	if n1 != 0 {
+		matrix.AddComplexElement(bIdx, n1, 1, 0)
+		matrix.AddComplexElement(n1, bIdx, 1, 0)
+	}
+	if n2 != 0 {
+		matrix.AddComplexElement(bIdx, n2, -1, 0)
+		matrix.AddComplexElement(n2, bIdx, -1, 0)
+	}
+	if nc1 != 0 {
+		matrix.AddComplexElement(bIdx, nc1, -real(h), -imag(h))
+	}
+	if nc2 != 0 {
+		matrix.AddComplexElement(bIdx, nc2, real(h), imag(h))
+	}
+	return nil
+}
+
+func (e *VCVS) BranchIndex() int { return e.branchIdx }
+
+func (e *VCVS) SetBranchIndex(idx int) { e.branchIdx = idx }
+
+// BranchSign - Branch variable flows n+ to n- through source
+func (e *VCVS) BranchSign() float64 { return 1 }
+
+// VCCS - G source. Current H * (v(nc+) - v(nc-)) flows n+ to n- through source
+type VCCS struct {
+	controlled
+}
+
+var _ TimeDependent = (*VCCS)(nil)
+var _ CurrentProbe = (*VCCS)(nil)
+var _ LinearStamp = (*VCCS)(nil)
+
+// NewVCCS - Voltage controlled current source. transfer is nil for constant transconductance
+func NewVCCS(name string, nodeNames []string, gain float64, transfer Transfer) *VCCS {
+	return &VCCS{controlled: controlled{
+		BaseDevice: BaseDevice{
+			Name:      name,
+			Value:     gain,
+			Nodes:     make([]int, len(nodeNames)),
+			NodeNames: nodeNames,
+		},
+		transfer: transfer,
+	}}
+}
+
+func (g *VCCS) GetType() string { return "G" }
+func (g *VCCS) LinearStamp()    {}
+
+func (g *VCCS) Stamp(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
+	if status.Mode == ACAnalysis {
+		return g.StampAC(matrix, status)
+	}
+
+	gm, offset, err := g.gain(status)
+	if err != nil {
+		return err
+	}
+	n1, n2, nc1, nc2 := g.Nodes[0], g.Nodes[1], g.Nodes[2], g.Nodes[3]
+
+	// Current gm*vc + offset leaves n+ and enters n-
+	for _, row := range []struct {
+		node int
+		sign float64
+	}{{n1, 1}, {n2, -1}} {
+		if row.node == 0 {
+			continue
+		}
+		if nc1 != 0 {
+			matrix.AddElement(row.node, nc1, row.sign*gm)
+		}
+		if nc2 != 0 {
+			matrix.AddElement(row.node, nc2, -row.sign*gm)
+		}
+		matrix.AddRHS(row.node, -row.sign*offset)
+	}
+	return nil
+}
+
+func (g *VCCS) StampAC(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
+	if len(g.Nodes) != 4 {
+		return fmt.Errorf("controlled source %s: requires 4 nodes", g.Name)
+	}
+	h := g.acGain(status)
+	n1, n2, nc1, nc2 := g.Nodes[0], g.Nodes[1], g.Nodes[2], g.Nodes[3]
+
+	for _, row := range []struct {
+		node int
+		sign float64
+	}{{n1, 1}, {n2, -1}} {
+		if row.node == 0 {
+			continue
+		}
+		if nc1 != 0 {
+			matrix.AddComplexElement(row.node, nc1, row.sign*real(h), row.sign*imag(h))
+		}
+		if nc2 != 0 {
+			matrix.AddComplexElement(row.node, nc2, -row.sign*real(h), -row.sign*imag(h))
+		}
+	}
+	return nil
+}
+
+// ProbeCurrent - Current n+ to n- through source
+func (g *VCCS) ProbeCurrent(voltages []float64, status *CircuitStatus) float64 {
+	if g.transfer == nil {
+		return g.Value * g.controlVoltage(voltages)
+	}
+	if status.Mode == TransientAnalysis {
+		return g.output // Timepoint is accepted before results are probed
+	}
+	return g.transfer.DCGain() * g.controlVoltage(voltages)
+}
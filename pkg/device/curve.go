@@ -0,0 +1,94 @@
+package device
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Curve - Nonlinear capacitance C(V) or inductance L(I).
+// Polynomial p0 + p1*x + p2*x^2 + ... or piecewise linear table, constant beyond table ends.
+type Curve struct {
+	poly   []float64
+	xs, ys []float64
+}
+
+func NewPolyCurve(coeffs []float64) (*Curve, error) {
+	if len(coeffs) == 0 {
+		return nil, fmt.Errorf("polynomial needs at least one coefficient")
+	}
+	return &Curve{poly: coeffs}, nil
+}
+
+// NewTableCurve - Table of (x, y) points. Points are sorted by x
+func NewTableCurve(xs, ys []float64) (*Curve, error) {
+	if len(xs) == 0 || len(xs) != len(ys) {
+		return nil, fmt.Errorf("table needs pairs of x and value")
+	}
+
+	idx := make([]int, len(xs))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.Slice(idx, func(a, b int) bool { return xs[idx[a]] < xs[idx[b]] })
+
+	c := &Curve{xs: make([]float64, len(xs)), ys: make([]float64, len(ys))}
+	for i, j := range idx {
+		c.xs[i], c.ys[i] = xs[j], ys[j]
+		if i > 0 && c.xs[i] == c.xs[i-1] {
+			return nil, fmt.Errorf("duplicate table point at %g", c.xs[i])
+		}
+	}
+	return c, nil
+}
+
+// Value - C(V) or L(I)
+func (c *Curve) Value(x float64) float64 {
+	if c.poly != nil {
+		value := 0.0
+		for i := len(c.poly) - 1; i >= 0; i-- {
+			value = value*x + c.poly[i]
+		}
+		return value
+	}
+
+	n := len(c.xs)
+	if x <= c.xs[0] {
+		return c.ys[0]
+	}
+	if x >= c.xs[n-1] {
+		return c.ys[n-1]
+	}
+	i := sort.SearchFloat64s(c.xs, x)
+	t := (x - c.xs[i-1]) / (c.xs[i] - c.xs[i-1])
+	return c.ys[i-1] + t*(c.ys[i]-c.ys[i-1])
+}
+
+// Integral - Charge Q(V) or flux linkage λ(I), integral of curve from 0 to x
+func (c *Curve) Integral(x float64) float64 {
+	if c.poly != nil {
+		value := 0.0
+		for i := len(c.poly) - 1; i >= 0; i-- {
+			value = value*x + c.poly[i]/float64(i+1)
+		}
+		return value * x
+	}
+	return c.tableIntegral(x) - c.tableIntegral(0)
+}
+
+// tableIntegral - Integral of table from first point to x
+func (c *Curve) tableIntegral(x float64) float64 {
+	n := len(c.xs)
+	if x <= c.xs[0] {
+		return (x - c.xs[0]) * c.ys[0]
+	}
+
+	total := 0.0
+	for i := 1; i < n; i++ {
+		if x <= c.xs[i] {
+			y := c.Value(x)
+			return total + 0.5*(c.ys[i-1]+y)*(x-c.xs[i-1])
+		}
+		total += 0.5 * (c.ys[i-1] + c.ys[i]) * (c.xs[i] - c.xs[i-1])
+	}
+	return total + (x-c.xs[n-1])*c.ys[n-1]
+}
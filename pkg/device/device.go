@@ -31,11 +31,55 @@ type ACElement interface {
 	StampAC(matrix matrix.DeviceMatrix, status *CircuitStatus) error
 }
 
+// CurrentProbe - Device current for I(name) in OP, DC sweep and transient results
+type CurrentProbe interface {
+	ProbeCurrent(voltages []float64, status *CircuitStatus) float64
+}
+
+// ACCurrentProbe - Device current phasor for I(name) in AC results
+type ACCurrentProbe interface {
+	ProbeCurrentAC(voltages []complex128, status *CircuitStatus) complex128
+}
+
 type TimeDependent interface {
 	SetTimeStep(dt float64, status *CircuitStatus)
 	UpdateState(voltages []float64, status *CircuitStatus)
 	LoadState(voltages []float64, status *CircuitStatus)
-	CalculateLTE(voltages map[string]float64, status *CircuitStatus) float64
+	// TruncateTimeStep - Largest next timestep keeping local truncation error of device state within tolerance,
+	// by solution of new timepoint before it is accepted. +Inf when device does not limit timestep (SPICE DEVtrunc)
+	TruncateTimeStep(voltages []float64, status *CircuitStatus) float64
+}
+
+// BreakpointSource - Sources with corner in waveform. First corner after t=0, 0 when none
+type BreakpointSource interface {
+	FirstBreakpoint() float64
+}
+
+// StepLimiter - Devices which need timestep of at most MaxTimeStep to resolve their waveform. 0 is no limit
+type StepLimiter interface {
+	MaxTimeStep() float64
+}
+
+// DCInitializer - Devices which take initial transient state from DC operating point
+type DCInitializer interface {
+	InitDCState(solution []float64, status *CircuitStatus)
+}
+
+// UICInitializer - Devices which take initial transient state from IC= or .ic node voltages when OP is skipped (UIC).
+// Devices write their IC= into initial solution, eg. node of grounded capacitor and branch of inductor
+type UICInitializer interface {
+	InitUICState(voltages []float64, status *CircuitStatus)
+}
+
+// NonLinearStorage - Nonlinear C(V) or L(I). Linear at DC, linearized at Newton iterate in transient
+type NonLinearStorage interface {
+	UpdateVoltages(voltages []float64) error
+}
+
+// LinearStamp - Devices of constant value whose stamp depends on time, timestep, gmin and accepted state only,
+// not on Newton iterate. Their stamps are cached over Newton iterations
+type LinearStamp interface {
+	LinearStamp()
 }
 
 type NonLinear interface {
@@ -44,6 +88,21 @@ type NonLinear interface {
 	UpdateVoltages(voltages []float64) error
 }
 
+// BranchDevice - Device owning branch current unknown of MNA, eg. voltage source and inductors.
+// I(name) is current from n+ to n- through device, BranchSign times branch unknown.
+type BranchDevice interface {
+	Device
+	BranchIndex() int
+	SetBranchIndex(idx int)
+	BranchSign() float64
+}
+
+// CurrentControlled - Devices sensing branch current of device named by ControlName, eg. W switch. Empty name senses none
+type CurrentControlled interface {
+	ControlName() string
+	SetControl(branch BranchDevice)
+}
+
 type InductorComponent interface {
 	Device
 	GetValue() float64
@@ -62,6 +121,8 @@ const (
 	SIN
 	PULSE
 	PWL
+	AM
+	RANDOM // TRNOISE, TRRANDOM
 )
 
 type AnalysisMode int
@@ -93,7 +154,11 @@ type CircuitStatus struct {
 	Temp      float64
 	Order     int
 	MaxOrder  int
+	Trtol     float64 // Truncation error overestimate factor of transient timestep control
 	Frequency float64 // AC frequency
+
+	InitJunction bool // First DC iteration. Junctions of OFF devices start at zero
+	Bypass       bool // Nonlinear devices reuse last model evaluation while terminal voltages stay within tolerance
 }
 
 func (d *BaseDevice) GetName() string {
@@ -116,6 +181,20 @@ func (d *BaseDevice) SetNodes(nodes []int) {
 	d.Nodes = nodes
 }
 
+func nodeVoltage(voltages []float64, node int) float64 {
+	if node <= 0 || node >= len(voltages) {
+		return 0
+	}
+	return voltages[node]
+}
+
+func nodeVoltageAC(voltages []complex128, node int) complex128 {
+	if node <= 0 || node >= len(voltages) {
+		return 0
+	}
+	return voltages[node]
+}
+
 func NewBaseDevice(name string, value float64, nodeNames []string, devType string) *BaseDevice {
 	return &BaseDevice{
 		Name:      name,
@@ -28,19 +28,38 @@ type Diode struct {
 	Tt  float64 // Transit time
 	Fc  float64 // Forward-bias depletion capacitance coefficient
 
+	// Instance parameters
+	Area  float64 // Area factor
+	Off   bool    // Initially off for DC analysis
+	Temp  float64 // Instance temperature (K). 0 = circuit temperature
+	Dtemp float64 // Instance temperature offset from circuit temperature (K)
+
+	thermalPort
+
 	// Internal states for Operating Point
 	vd     float64 // Voltage
 	id     float64 // Current
 	charge float64 // charge
 	gd     float64 // Conductance at Operating Point
 
+	// Last model evaluation for bypass
+	bypass   bypassState
+	bypassId float64
+	bypassGd float64
+
 	// Status for Transient analysis
-	prevVd     float64 // Previous voltage
-	prevId     float64 // Previous current
-	prevCharge float64 // Previous charge
-	capCurrent float64 // Capacitive current
+	prevVd     float64      // Previous voltage
+	prevId     float64      // Previous current
+	prevCharge float64      // Previous charge
+	capCurrent float64      // Capacitive current
+	history    stateHistory // Transit time charge of accepted timepoints for truncation error
 }
 
+var _ TimeDependent = (*Diode)(nil)
+var _ DCInitializer = (*Diode)(nil)
+var _ ParameterValidator = (*Diode)(nil)
+var _ Distortion = (*Diode)(nil)
+
 func NewDiode(name string, nodeNames []string) *Diode {
 	if len(nodeNames) != 2 {
 		panic(fmt.Sprintf("diode %s: requires exactly 2 nodes", name))
@@ -73,6 +92,37 @@ func (d *Diode) setDefaultParameters() {
 	d.Xti = 3.0 // Saturation current temp. exp
 	d.Tt = 0.0  // Transit time
 	d.Fc = 0.5  // Forward-bias depletion capacitance coefficient
+
+	d.Area = 1.0
+}
+
+func (d *Diode) SetInstanceParameters(params map[string]float64) {
+	if area, ok := params["area"]; ok && area > 0 {
+		d.Area = area
+	}
+	if off, ok := params["off"]; ok {
+		d.Off = off != 0
+	}
+	if temp, ok := params["temp"]; ok {
+		d.Temp = temp + consts.KELVIN // degC -> K
+	}
+	if dtemp, ok := params["dtemp"]; ok {
+		d.Dtemp = dtemp
+	}
+	d.setSelfHeating(params)
+}
+
+// Instance temperature if set, otherwise circuit temperature and dtemp. Thermal node or self-heating adds its rise
+func (d *Diode) temperature(status *CircuitStatus) float64 {
+	if d.Temp > 0 {
+		return d.Temp + d.rise
+	}
+	return status.Temp + d.Dtemp + d.rise
+}
+
+func (d *Diode) UpdateThermal(voltages []float64, dt float64, status *CircuitStatus) {
+	vd := nodeVoltage(voltages, d.Nodes[0]) - nodeVoltage(voltages, d.Nodes[1])
+	d.accept(voltages, vd*d.id, dt)
 }
 
 func (d *Diode) thermalVoltage(temp float64) float64 {
@@ -83,6 +133,24 @@ func (d *Diode) thermalVoltage(temp float64) float64 {
 	return consts.BOLTZMANN * temp / consts.CHARGE
 }
 
+// ValidateParameters - Model and instance parameters
+func (d *Diode) ValidateParameters() []ParameterIssue {
+	c := paramCheck{device: d.Name}
+	c.positive("is", d.Is)
+	c.positive("n", d.N)
+	c.positive("vj", d.Vj)
+	c.positive("area", d.Area)
+	c.nonNegative("rs", d.Rs)
+	c.nonNegative("cj0", d.Cj0)
+	c.nonNegative("tt", d.Tt)
+	c.nonNegative("bv", d.Bv)
+	c.fraction("m", d.M)
+	c.fraction("fc", d.Fc)
+	c.usual("n", d.N, 0.5, 4)
+	c.usual("eg", d.Eg, 0.1, 5)
+	return c.issues
+}
+
 func (d *Diode) SetModelParameters(params map[string]float64) {
 	paramsSet := map[string]*float64{
 		"is":  &d.Is,  // Is (Saturation Current)
@@ -109,11 +177,11 @@ func (d *Diode) temperatureAdjustedIs(temp float64) float64 {
 	const ktemp = consts.KELVIN + 27 // 27degC
 	vt := d.thermalVoltage(temp)
 
-	// is(T2) = is(T1) * (T2/T1)^(XTI/N) * exp(-(Eg/(2*k))*(1/T2 - 1/T1))
+	// is(T2) = is(T1) * (T2/T1)^(XTI/N) * exp((Eg/(N*Vt(T2)))*(T2/T1 - 1))
 	ratio := temp / ktemp
-	egfact := -d.Eg / (2 * vt) * (temp/ktemp - 1.0)
+	egfact := d.Eg / (d.N * vt) * (temp/ktemp - 1.0)
 
-	return d.Is * math.Pow(ratio, d.Xti/d.N) * math.Exp(egfact)
+	return d.Area * d.Is * math.Pow(ratio, d.Xti/d.N) * math.Exp(egfact)
 }
 
 func (d *Diode) calculateCurrent(vd, temp float64) float64 {
@@ -158,11 +226,11 @@ func (d *Diode) calculateJunctionCap(vd float64) float64 {
 		if arg < 0.1 {
 			arg = 0.1
 		}
-		return d.Cj0 / math.Pow(arg, d.M)
+		return d.Area * d.Cj0 / math.Pow(arg, d.M)
 	}
 
 	// Forward bias
-	return d.Cj0 * (1 + d.M*vd/d.Vj)
+	return d.Area * d.Cj0 * (1 + d.M*vd/d.Vj)
 }
 
 func (d *Diode) diffusionCapacitance(vd float64, temp float64, timeStep float64) float64 {
@@ -180,6 +248,15 @@ func (d *Diode) diffusionCapacitance(vd float64, temp float64, timeStep float64)
 	return d.Tt * didt
 }
 
+// canBypass - Voltage and predicted current change are within tolerance of last evaluation
+func (d *Diode) canBypass(status *CircuitStatus, temp float64) bool {
+	if !d.bypass.canBypass(status, temp, d.vd) {
+		return false
+	}
+	deltaId := d.bypassGd * (d.vd - d.bypass.voltages[0])
+	return math.Abs(deltaId) <= bypassReltol*math.Abs(d.bypassId)+1e-12
+}
+
 // Stamp for OP/Transient
 func (d *Diode) Stamp(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
 	if status.Mode == ACAnalysis {
@@ -190,8 +267,21 @@ func (d *Diode) Stamp(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
 		return fmt.Errorf("diode %s: requires exactly 2 nodes", d.Name)
 	}
 
-	d.id = d.calculateCurrent(d.vd, status.Temp)
-	d.gd = d.calculateConductance(d.vd, d.id, status.Temp)
+	if d.Off && status.InitJunction {
+		d.vd = 0
+	}
+
+	temp := d.temperature(status)
+	if d.canBypass(status, temp) {
+		// Linearization of last evaluation at new voltage
+		d.id = d.bypassId + d.bypassGd*(d.vd-d.bypass.voltages[0])
+		d.gd = d.bypassGd
+	} else {
+		d.id = d.calculateCurrent(d.vd, temp)
+		d.gd = d.calculateConductance(d.vd, d.id, temp)
+		d.bypass.evaluated(temp, d.vd)
+		d.bypassId, d.bypassGd = d.id, d.gd
+	}
 
 	if status.Mode == TransientAnalysis {
 		d.charge = d.Tt * d.id
@@ -205,6 +295,7 @@ func (d *Diode) Stamp(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
 		}
 	}
 
+	d.stampPower(matrix, status)
 	n1, n2 := d.Nodes[0], d.Nodes[1]
 
 	if n1 != 0 {
@@ -291,17 +382,76 @@ func (d *Diode) LoadCurrent(matrix matrix.DeviceMatrix) error {
 	return nil
 }
 
-func (d *Diode) SetTimeStep(dt float64) {}
+func (d *Diode) SetTimeStep(dt float64, status *CircuitStatus) { status.TimeStep = dt }
+
+func (d *Diode) LoadState(voltages []float64, status *CircuitStatus) {}
 
 func (d *Diode) UpdateState(voltages []float64, status *CircuitStatus) {
 	d.prevVd = d.vd
 	d.prevId = d.id - d.capCurrent // Store DC current only
 	d.prevCharge = d.charge
 	d.capCurrent = 0.0
+	d.history.accept(d.charge, status.TimeStep)
 }
 
-func (d *Diode) CalculateLTE(voltages map[string]float64, status *CircuitStatus) float64 {
-	return math.Abs(d.vd - d.prevVd)
+// TruncateTimeStep - Transit time charge Tt*id at new junction voltage
+func (d *Diode) TruncateTimeStep(voltages []float64, status *CircuitStatus) float64 {
+	vd := nodeVoltage(voltages, d.Nodes[0]) - nodeVoltage(voltages, d.Nodes[1])
+	return d.history.truncate(d.Tt*d.calculateCurrent(vd, d.temperature(status)), status)
+}
+
+// InitDCState - Transit time charge starts from current of operating point
+func (d *Diode) InitDCState(solution []float64, status *CircuitStatus) {
+	d.UpdateVoltages(solution)
+	d.id = d.calculateCurrent(d.vd, d.temperature(status))
+	d.prevVd = d.vd
+	d.prevId = d.id
+	d.prevCharge = d.Tt * d.id
+	d.capCurrent = 0
+	d.history.reset(d.prevCharge)
+}
+
+// Anode to cathode current at last evaluated operating point
+func (d *Diode) ProbeCurrent(voltages []float64, status *CircuitStatus) float64 {
+	return d.id
+}
+
+// distortionCoefficients - g2, g3 of junction current at operating point. Zero in strong reverse bias
+// and above exponent limit, where current is linear or constant
+func (d *Diode) distortionCoefficients(status *CircuitStatus) (float64, float64) {
+	temp := d.temperature(status)
+	nvt := d.N * d.thermalVoltage(temp)
+	arg := d.vd / nvt
+	if d.vd <= -3.0*nvt || arg > 40.0 {
+		return 0, 0
+	}
+
+	// id = Is*(exp(vd/nVt) - 1), derivatives are Is*exp(vd/nVt)/(nVt)^k
+	evd := d.temperatureAdjustedIs(temp) * math.Exp(arg)
+	return evd / (2 * nvt * nvt), evd / (6 * nvt * nvt * nvt)
+}
+
+func (d *Diode) Distortion2(currents, x, y []complex128, status *CircuitStatus) {
+	g2, _ := d.distortionCoefficients(status)
+	n1, n2 := d.Nodes[0], d.Nodes[1]
+	vx := nodeVoltageAC(x, n1) - nodeVoltageAC(x, n2)
+	vy := nodeVoltageAC(y, n1) - nodeVoltageAC(y, n2)
+	addTerminalCurrent(currents, n1, n2, complex(g2, 0)*vx*vy)
+}
+
+func (d *Diode) Distortion3(currents, x, y, z []complex128, status *CircuitStatus) {
+	_, g3 := d.distortionCoefficients(status)
+	n1, n2 := d.Nodes[0], d.Nodes[1]
+	vx := nodeVoltageAC(x, n1) - nodeVoltageAC(x, n2)
+	vy := nodeVoltageAC(y, n1) - nodeVoltageAC(y, n2)
+	vz := nodeVoltageAC(z, n1) - nodeVoltageAC(z, n2)
+	addTerminalCurrent(currents, n1, n2, complex(g3, 0)*vx*vy*vz)
+}
+
+func (d *Diode) ProbeCurrentAC(voltages []complex128, status *CircuitStatus) complex128 {
+	vd := nodeVoltageAC(voltages, d.Nodes[0]) - nodeVoltageAC(voltages, d.Nodes[1])
+	omega := 2 * math.Pi * status.Frequency
+	return complex(d.gd, omega*d.calculateJunctionCap(d.vd)) * vd
 }
 
 func (d *Diode) UpdateVoltages(voltages []float64) error {
@@ -0,0 +1,93 @@
+package device
+
+// Distortion - Nonlinear device in small signal distortion analysis. Terms of Taylor expansion of
+// device currents around operating point, i = g1*v + g2*v^2 + g3*v^3 for one controlling voltage.
+// Currents leaving terminal nodes into device are added to currents by node index, x, y, z are
+// node voltage phasors. Node 0 is ground and ignored.
+type Distortion interface {
+	// Distortion2 - Symmetric bilinear term, g2*x*y
+	Distortion2(currents, x, y []complex128, status *CircuitStatus)
+	// Distortion3 - Symmetric trilinear term, g3*x*y*z
+	Distortion3(currents, x, y, z []complex128, status *CircuitStatus)
+}
+
+// addTerminalCurrent - Current from node n1 through device to node n2
+func addTerminalCurrent(currents []complex128, n1, n2 int, current complex128) {
+	if n1 > 0 && n1 < len(currents) {
+		currents[n1] += current
+	}
+	if n2 > 0 && n2 < len(currents) {
+		currents[n2] -= current
+	}
+}
+
+// Step of finite differences of Taylor coefficients (V)
+const taylorStep = 1e-3
+
+// taylor3 - Second and third derivatives of f by three controlling voltages at v0, by central differences
+type taylor3 struct {
+	d2 [3][3]float64
+	d3 [3][3][3]float64
+}
+
+func newTaylor3(f func(v [3]float64) float64, v0 [3]float64) taylor3 {
+	const h = taylorStep
+
+	// Sum of f over sign combinations of steps along axes, each axis weighted by its sign
+	signed := func(axes ...int) float64 {
+		var sum float64
+		for mask := range 1 << len(axes) {
+			v := v0
+			weight := 1.0
+			for k, axis := range axes {
+				if mask&(1<<k) != 0 {
+					v[axis] -= h
+					weight = -weight
+				} else {
+					v[axis] += h
+				}
+			}
+			sum += weight * f(v)
+		}
+		return sum
+	}
+
+	var t taylor3
+	for i := range 3 {
+		for j := i; j < 3; j++ {
+			t.d2[i][j] = signed(i, j) / (4 * h * h)
+			t.d2[j][i] = t.d2[i][j]
+			for k := j; k < 3; k++ {
+				value := signed(i, j, k) / (8 * h * h * h)
+				for _, p := range [][3]int{{i, j, k}, {i, k, j}, {j, i, k}, {j, k, i}, {k, i, j}, {k, j, i}} {
+					t.d3[p[0]][p[1]][p[2]] = value
+				}
+			}
+		}
+	}
+	return t
+}
+
+// quadratic - g2*x*y term, half of second derivative form
+func (t taylor3) quadratic(x, y [3]complex128) complex128 {
+	var sum complex128
+	for i := range 3 {
+		for j := range 3 {
+			sum += complex(t.d2[i][j], 0) * x[i] * y[j]
+		}
+	}
+	return sum / 2
+}
+
+// cubic - g3*x*y*z term, sixth of third derivative form
+func (t taylor3) cubic(x, y, z [3]complex128) complex128 {
+	var sum complex128
+	for i := range 3 {
+		for j := range 3 {
+			for k := range 3 {
+				sum += complex(t.d3[i][j][k], 0) * x[i] * y[j] * z[k]
+			}
+		}
+	}
+	return sum / 6
+}
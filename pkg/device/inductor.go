@@ -16,9 +16,16 @@ type Inductor struct {
 	flux0     float64 // Current flux
 	flux1     float64 // Previous flux
 	branchIdx int     // Branch index
+
+	ic    float64 // Initial current of IC=
+	hasIC bool
+
+	history stateHistory // Flux of accepted timepoints for truncation error
 }
 
 var _ TimeDependent = (*Inductor)(nil)
+var _ UICInitializer = (*Inductor)(nil)
+var _ LinearStamp = (*Inductor)(nil)
 
 func NewInductor(name string, nodeNames []string, value float64) *Inductor {
 	return &Inductor{
@@ -32,6 +39,7 @@ func NewInductor(name string, nodeNames []string, value float64) *Inductor {
 }
 
 func (l *Inductor) GetType() string { return "L" }
+func (l *Inductor) LinearStamp()    {}
 
 func (l *Inductor) SetTimeStep(dt float64, status *CircuitStatus) { status.TimeStep = dt }
 
@@ -41,18 +49,17 @@ func (l *Inductor) Stamp(matrix matrix.DeviceMatrix, status *CircuitStatus) erro
 
 	switch status.Mode {
 	case ACAnalysis:
-		omega := 2 * math.Pi * status.Frequency
+		return l.StampAC(matrix, status)
+
+	case OperatingPointAnalysis:
+		// Short at DC. Branch equation v1 - v2 = 0
 		if n1 != 0 {
-			matrix.AddComplexElement(n1, n1, 0, omega*l.Value)
-			if n2 != 0 {
-				matrix.AddComplexElement(n1, n2, 0, -omega*l.Value)
-			}
+			matrix.AddElement(n1, bIdx, -1)
+			matrix.AddElement(bIdx, n1, -1)
 		}
 		if n2 != 0 {
-			matrix.AddComplexElement(n2, n2, 0, omega*l.Value)
-			if n1 != 0 {
-				matrix.AddComplexElement(n2, n1, 0, -omega*l.Value)
-			}
+			matrix.AddElement(n2, bIdx, 1)
+			matrix.AddElement(bIdx, n2, 1)
 		}
 
 	default:
@@ -78,6 +85,25 @@ func (l *Inductor) Stamp(matrix matrix.DeviceMatrix, status *CircuitStatus) erro
 	return nil
 }
 
+func (l *Inductor) StampAC(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
+	n1, n2 := l.Nodes[0], l.Nodes[1]
+	bIdx := l.branchIdx
+
+	// Branch equation v1 - v2 = jωL*i
+	omega := 2 * math.Pi * status.Frequency
+	if n1 != 0 {
+		matrix.AddComplexElement(n1, bIdx, -1, 0)
+		matrix.AddComplexElement(bIdx, n1, -1, 0)
+	}
+	if n2 != 0 {
+		matrix.AddComplexElement(n2, bIdx, 1, 0)
+		matrix.AddComplexElement(bIdx, n2, 1, 0)
+	}
+	matrix.AddComplexElement(bIdx, bIdx, 0, -omega*l.Value)
+
+	return nil
+}
+
 func (l *Inductor) LoadState(voltages []float64, status *CircuitStatus) {
 	v1 := 0.0
 	if l.Nodes[0] != 0 {
@@ -90,7 +116,8 @@ func (l *Inductor) LoadState(voltages []float64, status *CircuitStatus) {
 	vd := v1 - v2
 	dt := status.TimeStep
 
-	l.Current0 = l.Current1 + (vd*dt)/l.Value
+	// Branch current of solution. Integrating vd/L misses mutual coupling
+	l.Current0 = -voltages[l.branchIdx]
 	l.flux0 = l.flux1 + vd*dt
 }
 
@@ -108,16 +135,43 @@ func (l *Inductor) UpdateState(voltages []float64, status *CircuitStatus) {
 	l.Voltage0 = v1 - v2
 
 	l.Current1 = l.Current0
+	l.Current0 = -voltages[l.branchIdx] // Branch current from n1 to n2
+	l.history.accept(l.Value*l.Current0, status.TimeStep)
+}
 
-	equivR := l.Value / 1e-9
-	l.Current0 = l.Voltage0 / equivR
+// InitDCState - Start transient from DC operating point current
+func (l *Inductor) InitDCState(solution []float64, status *CircuitStatus) {
+	l.Current0 = -solution[l.branchIdx]
+	l.Current1 = l.Current0
+	l.Voltage0 = 0
+	l.Voltage1 = 0
+	l.flux0 = l.Value * l.Current0
+	l.flux1 = l.flux0
+	l.history.reset(l.flux0)
 }
 
-func (l *Inductor) CalculateLTE(voltages map[string]float64, status *CircuitStatus) float64 {
-	currentLTE := math.Abs(l.Current0-l.Current1) / (2.0 * status.TimeStep)
-	voltageLTE := math.Abs(l.Voltage0-l.Voltage1) / (2.0 * status.TimeStep)
+// SetInitialCondition - IC= current used at transient start with UIC
+func (l *Inductor) SetInitialCondition(current float64) {
+	l.ic = current
+	l.hasIC = true
+}
 
-	return math.Max(currentLTE, voltageLTE)
+// InitUICState - Start transient from IC= current, zero without IC=. IC= is branch current of initial solution
+func (l *Inductor) InitUICState(voltages []float64, status *CircuitStatus) {
+	if l.hasIC && l.branchIdx > 0 {
+		voltages[l.branchIdx] = l.ic * l.BranchSign()
+	}
+	l.Current0 = l.ic
+	l.Current1 = l.ic
+	l.Voltage0 = 0
+	l.Voltage1 = 0
+	l.flux0 = l.Value * l.ic
+	l.flux1 = l.flux0
+	l.history.reset(l.flux0)
+}
+
+func (l *Inductor) TruncateTimeStep(voltages []float64, status *CircuitStatus) float64 {
+	return l.history.truncate(-l.Value*voltages[l.branchIdx], status)
 }
 
 func (l *Inductor) GetCurrent() float64 {
@@ -145,3 +199,6 @@ func (l *Inductor) BranchIndex() int {
 func (l *Inductor) SetBranchIndex(idx int) {
 	l.branchIdx = idx
 }
+
+// BranchSign - Branch variable flows n2 to n1
+func (l *Inductor) BranchSign() float64 { return -1 }
@@ -14,6 +14,8 @@ type CurrentSource struct {
 	// SIN params
 	amplitude float64
 	freq      float64
+	sinDelay  float64
+	theta     float64 // Damping factor
 	phase     float64
 	// PULSE params
 	i1     float64
@@ -24,8 +26,13 @@ type CurrentSource struct {
 	pWidth float64
 	period float64
 	// PWL params
-	times  []float64
-	values []float64
+	times     []float64
+	values    []float64
+	pwlRepeat float64 // Repeat from this time after last point, negative for no repeat
+	pwlDelay  float64
+	// AM, TRNOISE and TRRANDOM params
+	am     *amWaveform
+	random *randomWaveform
 	// AC params
 	acMag   float64
 	acPhase float64
@@ -44,7 +51,8 @@ func NewDCCurrentSource(name string, nodeNames []string, value float64) *Current
 	}
 }
 
-func NewSinCurrentSource(name string, nodeNames []string, offset, amplitude, freq, phase float64) *CurrentSource {
+// NewSinCurrentSource - SIN(VO VA FREQ TD THETA PHASE)
+func NewSinCurrentSource(name string, nodeNames []string, offset, amplitude, freq, delay, theta, phase float64) *CurrentSource {
 	return &CurrentSource{
 		BaseDevice: BaseDevice{
 			Name:      name,
@@ -56,6 +64,8 @@ func NewSinCurrentSource(name string, nodeNames []string, offset, amplitude, fre
 		dcValue:   offset,
 		amplitude: amplitude,
 		freq:      freq,
+		sinDelay:  delay,
+		theta:     theta,
 		phase:     phase,
 	}
 }
@@ -87,9 +97,56 @@ func NewPWLCurrentSource(name string, nodeNames []string, times []float64, value
 			NodeNames: nodeNames,
 			Value:     values[0],
 		},
-		ctype:  PWL,
-		times:  times,
-		values: values,
+		ctype:     PWL,
+		times:     times,
+		values:    values,
+		pwlRepeat: -1,
+	}
+}
+
+// SetPWLRepeat - Repeat PWL from time repeat after last point (r=), and delay whole waveform (td=).
+// Negative repeat is no repeat.
+func (i *CurrentSource) SetPWLRepeat(repeat, delay float64) {
+	i.pwlRepeat = repeat
+	i.pwlDelay = delay
+}
+
+// NewAMCurrentSource - AM(VA VO MF FC TD)
+func NewAMCurrentSource(name string, nodeNames []string, amplitude, offset, modFreq, carrierFreq, delay float64) *CurrentSource {
+	return &CurrentSource{
+		BaseDevice: BaseDevice{
+			Name:      name,
+			Nodes:     make([]int, len(nodeNames)),
+			NodeNames: nodeNames,
+		},
+		ctype: AM,
+		am:    &amWaveform{amplitude, offset, modFreq, carrierFreq, delay},
+	}
+}
+
+// NewNoiseCurrentSource - TRNOISE(NA NT). Gaussian noise of rms na and bandwidth 1/(2*nt). Seed 0 is from name
+func NewNoiseCurrentSource(name string, nodeNames []string, rms, step float64, seed int64) *CurrentSource {
+	return &CurrentSource{
+		BaseDevice: BaseDevice{
+			Name:      name,
+			Nodes:     make([]int, len(nodeNames)),
+			NodeNames: nodeNames,
+		},
+		ctype:  RANDOM,
+		random: newNoiseWaveform(name, rms, step, seed),
+	}
+}
+
+// NewRandomCurrentSource - TRRANDOM(TYPE TS TD PARAM1 PARAM2). Seed 0 is from name
+func NewRandomCurrentSource(name string, nodeNames []string, kind int, step, delay, param1, param2 float64, seed int64) *CurrentSource {
+	return &CurrentSource{
+		BaseDevice: BaseDevice{
+			Name:      name,
+			Nodes:     make([]int, len(nodeNames)),
+			NodeNames: nodeNames,
+		},
+		ctype:  RANDOM,
+		random: newRandomWaveform(name, kind, step, delay, param1, param2, seed),
 	}
 }
 
@@ -114,17 +171,39 @@ func (i *CurrentSource) GetCurrent(t float64) float64 {
 		return i.dcValue
 	case SIN:
 		phaseRad := i.phase * math.Pi / 180.0
-		return i.dcValue + i.amplitude*math.Sin(2.0*math.Pi*i.freq*t+phaseRad)
+		if t < i.sinDelay {
+			return i.dcValue + i.amplitude*math.Sin(phaseRad)
+		}
+		t -= i.sinDelay
+		return i.dcValue + i.amplitude*math.Exp(-i.theta*t)*math.Sin(2.0*math.Pi*i.freq*t+phaseRad)
 	case PULSE:
 		return i.getPulseCurrent(t)
 	case PWL:
 		return i.getPWLCurrent(t)
+	case AM:
+		return i.am.value(t)
+	case RANDOM:
+		return i.random.value(t)
 	default:
 		return 0
 	}
 }
 
+// FirstBreakpoint - First corner of PULSE or PWL waveform after t=0, 0 for others
+func (i *CurrentSource) FirstBreakpoint() float64 {
+	switch i.ctype {
+	case PULSE:
+		return pulseFirstBreakpoint(i.delay, i.rise, i.pWidth, i.fall)
+	case PWL:
+		return pwlFirstBreakpoint(i.times, i.pwlDelay)
+	case SIN:
+		return i.sinDelay
+	}
+	return 0
+}
+
 func (i *CurrentSource) GetType() string { return "I" }
+func (i *CurrentSource) LinearStamp()    {}
 
 // Stamp for DC, transient analysis
 func (i *CurrentSource) Stamp(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
@@ -146,6 +225,12 @@ func (i *CurrentSource) Stamp(matrix matrix.DeviceMatrix, status *CircuitStatus)
 	return nil
 }
 
+// ACPhasor - AC excitation current injected into n1
+func (i *CurrentSource) ACPhasor() complex128 {
+	acPhaseRad := i.acPhase * math.Pi / 180.0
+	return complex(i.acMag*math.Cos(acPhaseRad), i.acMag*math.Sin(acPhaseRad))
+}
+
 // Stamp for AC analysis
 func (i *CurrentSource) StampAC(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
 	n1, n2 := i.Nodes[0], i.Nodes[1]
@@ -197,6 +282,7 @@ func (i *CurrentSource) getPulseCurrent(t float64) float64 {
 }
 
 func (i *CurrentSource) getPWLCurrent(t float64) float64 {
+	t = pwlTime(t, i.times, i.pwlRepeat, i.pwlDelay)
 	if t <= i.times[0] {
 		return i.values[0]
 	}
@@ -218,6 +304,16 @@ func (i *CurrentSource) getPWLCurrent(t float64) float64 {
 	return i.values[lastIdx] // Must not reach
 }
 
+// Current from n1 through source to n2
+func (i *CurrentSource) ProbeCurrent(voltages []float64, status *CircuitStatus) float64 {
+	return i.GetCurrent(status.Time)
+}
+
+func (i *CurrentSource) ProbeCurrentAC(voltages []complex128, status *CircuitStatus) complex128 {
+	acPhaseRad := i.acPhase * math.Pi / 180.0
+	return complex(i.acMag*math.Cos(acPhaseRad), i.acMag*math.Sin(acPhaseRad))
+}
+
 func (i *CurrentSource) SetValue(value float64) {
 	i.Value = value
 	i.dcValue = value
@@ -1 +1,18 @@
 package device
+
+import "math"
+
+// Largest exponent argument of junction current. Beyond it current continues linearly with slope at limit
+const maxJunctionExp = 40.0
+
+// junctionCurrent - Diode current isat*(exp(v/nvt) - 1) and its conductance without breakdown.
+// Exponential is extended linearly past maxJunctionExp, so large Newton steps do not overflow.
+func junctionCurrent(v, isat, nvt float64) (float64, float64) {
+	arg := v / nvt
+	if arg <= maxJunctionExp {
+		e := math.Exp(arg)
+		return isat * (e - 1), isat * e / nvt
+	}
+	e := math.Exp(maxJunctionExp)
+	return isat * (e*(1+arg-maxJunctionExp) - 1), isat * e / nvt
+}
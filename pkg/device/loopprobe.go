@@ -0,0 +1,86 @@
+package device
+
+import (
+	"github.com/edp1096/toy-spice/pkg/matrix"
+)
+
+// LoopProbe - Break point of feedback loop for stability analysis. 0V source from n+ to n-, so circuit is
+// unchanged in OP, DC and transient. n+ is toward output of driving stage, n- toward input of loop.
+// In AC the probe injects series voltage V(n-)-V(n+) and shunt current into n- for Tian's method.
+type LoopProbe struct {
+	BaseDevice
+	branchIdx int
+	voltage   float64 // Series injection voltage in AC
+	current   float64 // Shunt injection current into n- in AC
+}
+
+func NewLoopProbe(name string, nodeNames []string) *LoopProbe {
+	return &LoopProbe{
+		BaseDevice: BaseDevice{
+			Name:      name,
+			Nodes:     make([]int, len(nodeNames)),
+			NodeNames: nodeNames,
+		},
+	}
+}
+
+func (p *LoopProbe) GetType() string { return "Y" }
+
+// SetInjection - AC injection of stability analysis. 0, 0 leaves probe as short
+func (p *LoopProbe) SetInjection(voltage, current float64) {
+	p.voltage, p.current = voltage, current
+}
+
+func (p *LoopProbe) Stamp(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
+	if status.Mode == ACAnalysis {
+		return p.StampAC(matrix, status)
+	}
+
+	n1, n2 := p.Nodes[0], p.Nodes[1]
+	bIdx := p.branchIdx
+
+	// Short out of AC. v1 - v2 = 0
+	if n1 != 0 {
+		matrix.AddElement(bIdx, n1, 1)
+		matrix.AddElement(n1, bIdx, 1)
+	}
+	if n2 != 0 {
+		matrix.AddElement(bIdx, n2, -1)
+		matrix.AddElement(n2, bIdx, -1)
+	}
+	return nil
+}
+
+func (p *LoopProbe) StampAC(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
+	n1, n2 := p.Nodes[0], p.Nodes[1]
+	bIdx := p.branchIdx
+
+	// v1 - v2 = -Vinj
+	if n1 != 0 {
+		matrix.AddComplexElement(bIdx, n1, 1, 0)
+		matrix.AddComplexElement(n1, bIdx, 1, 0)
+	}
+	if n2 != 0 {
+		matrix.AddComplexElement(bIdx, n2, -1, 0)
+		matrix.AddComplexElement(n2, bIdx, -1, 0)
+	}
+
+	if p.voltage != 0 {
+		matrix.AddComplexRHS(bIdx, -p.voltage, 0)
+	}
+	if p.current != 0 && n2 != 0 {
+		matrix.AddComplexRHS(n2, p.current, 0)
+	}
+	return nil
+}
+
+func (p *LoopProbe) BranchIndex() int {
+	return p.branchIdx
+}
+
+func (p *LoopProbe) SetBranchIndex(idx int) {
+	p.branchIdx = idx
+}
+
+// BranchSign - Branch variable flows n+ to n- through probe
+func (p *LoopProbe) BranchSign() float64 { return 1 }
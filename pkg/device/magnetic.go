@@ -19,14 +19,26 @@ type MagneticComponent interface {
 	SetCore(params map[string]float64)
 }
 
+// MagneticCore - Core shared by windings. Field H is total MMF sum(N_i*I_i) over mean path length
 type MagneticCore struct {
 	JilesAthertonCore
 	inductors []*MagneticInductor // Inductors in the core
+	// Coupling of winding pairs from K cards. Unlisted pairs are fully coupled
+	coupling map[[2]*MagneticInductor]float64
+
+	name     string  // Core model name
+	B        float64 // Flux density mu0*(H+M) (T)
+	lossW    float64 // Hysteresis energy integral(H dB) * volume since transient start (J)
+	lossTime float64 // Time span of lossW (s)
 }
 
+var _ TimeDependent = (*MagneticInductor)(nil)
+var _ ParameterValidator = (*MagneticInductor)(nil)
+
 type MagneticInductor struct {
 	BaseDevice
 	core      *MagneticCore
+	coreName  string // Core model name. Windings of same model share one core
 	turns     int
 	current0  float64
 	current1  float64
@@ -35,6 +47,7 @@ type MagneticInductor struct {
 	voltage0  float64
 	voltage1  float64
 	branchIdx int
+	history   stateHistory // Flux linkage of accepted timepoints for truncation error
 }
 
 // Jiles-Atherton model parameters
@@ -64,6 +77,7 @@ func NewMagneticCore() *MagneticCore {
 	return &MagneticCore{
 		JilesAthertonCore: *NewJilesAthertonCore(),
 		inductors:         make([]*MagneticInductor, 0),
+		coupling:          make(map[[2]*MagneticInductor]float64),
 	}
 }
 
@@ -71,6 +85,84 @@ func (mc *MagneticCore) AddInductor(ind *MagneticInductor) {
 	mc.inductors = append(mc.inductors, ind)
 }
 
+func (mc *MagneticCore) GetInductors() []*MagneticInductor {
+	return mc.inductors
+}
+
+// SetCoupling - Coupling coefficient between two windings of core
+func (mc *MagneticCore) SetCoupling(a, b *MagneticInductor, k float64) {
+	mc.coupling[[2]*MagneticInductor{a, b}] = k
+	mc.coupling[[2]*MagneticInductor{b, a}] = k
+}
+
+// permeability - Incremental permeability mu0*(1+dM/dH) of last accepted core state
+func (mc *MagneticCore) permeability() float64 {
+	dMdH := math.Max(0, math.Min(1e3, mc.dMdH)) // dM/dH limit
+	return mu0 * (1.0 + dMdH)
+}
+
+// inductance - Self (a == b) or mutual incremental inductance k*mu*A*Na*Nb/len of windings
+func (mc *MagneticCore) inductance(a, b *MagneticInductor) float64 {
+	k := 1.0
+	if a != b {
+		if coupling, ok := mc.coupling[[2]*MagneticInductor{a, b}]; ok {
+			k = coupling
+		}
+	}
+	return k * mc.permeability() * float64(a.turns) * float64(b.turns) * mc.area / mc.len
+}
+
+// mmf - Total magnetomotive force sum(N_i*I_i) of windings in solution (ampere-turns)
+func (mc *MagneticCore) mmf(solution []float64) float64 {
+	total := 0.0
+	for _, ind := range mc.inductors {
+		if ind.branchIdx > 0 && ind.branchIdx < len(solution) {
+			total += float64(ind.turns) * -solution[ind.branchIdx]
+		}
+	}
+	return total
+}
+
+// update - Advance Jiles-Atherton state to field of accepted solution.
+// Energy H*dB per volume taken by core is accumulated for loss output.
+func (mc *MagneticCore) update(solution []float64, status *CircuitStatus) {
+	h := mc.mmf(solution) / mc.len
+	h = math.Max(-1e6, math.Min(1e6, h))
+
+	temp := 300.15
+	if status != nil {
+		temp = status.Temp
+	}
+
+	hOld, bOld := mc.H, mc.B
+	mc.Calculate(h, temp)
+	mc.B = mu0 * (mc.H + mc.M)
+
+	if status != nil && status.Mode == TransientAnalysis {
+		mc.lossW += 0.5 * (mc.H + hOld) * (mc.B - bOld) * mc.area * mc.len
+		mc.lossTime += status.TimeStep
+	}
+}
+
+func (mc *MagneticCore) Name() string { return mc.name }
+
+// FluxDensity - B (T)
+func (mc *MagneticCore) FluxDensity() float64 { return mc.B }
+
+// FieldStrength - H (A/m)
+func (mc *MagneticCore) FieldStrength() float64 { return mc.H }
+
+// LossEnergy - Energy taken by core since transient start (J). Over full B-H loops it is hysteresis loop area * volume
+func (mc *MagneticCore) LossEnergy() float64 { return mc.lossW }
+
+// LossPower - Average core loss power since transient start (W). Converges to hysteresis loss after some periods
+func (mc *MagneticCore) LossPower() float64 {
+	if mc.lossTime <= 0 {
+		return 0
+	}
+	return mc.lossW / mc.lossTime
+}
+
 func NewJilesAthertonCore() *JilesAthertonCore {
 	return &JilesAthertonCore{
 		Ms:    1.6e6, // Default values
@@ -149,8 +241,7 @@ func (m *MagneticInductor) GetValue() float64 {
 		return 0
 	}
 
-	_, dMdH := m.core.Calculate(float64(m.turns)*m.current0/m.core.len, 300.15)
-	return mu0 * float64(m.turns*m.turns) * m.core.area * (1 + dMdH) / m.core.len
+	return m.core.inductance(m, m)
 }
 
 func (m *MagneticInductor) GetCurrent() float64 {
@@ -190,10 +281,44 @@ func (m *MagneticInductor) SetCore(params map[string]float64) {
 	core.AddInductor(m)
 }
 
+// ValidateParameters - Jiles-Atherton parameters of core
+func (m *MagneticInductor) ValidateParameters() []ParameterIssue {
+	if m.core == nil {
+		return nil
+	}
+	c := paramCheck{device: m.Name}
+	core := &m.core.JilesAthertonCore
+	c.positive("ms", core.Ms)
+	c.positive("a", core.a)
+	c.positive("k", core.k)
+	c.positive("area", core.area)
+	c.positive("len", core.len)
+	c.nonNegative("alpha", core.alpha)
+	c.check("c", core.c, core.c >= 0 && core.c <= 1, "0 <= c <= 1", true)
+	return c.issues
+}
+
 func (m *MagneticInductor) GetCore() *MagneticCore {
 	return m.core
 }
 
+// AttachCore - Wind inductor on existing core instead of its own
+func (m *MagneticInductor) AttachCore(core *MagneticCore) {
+	m.core = core
+	core.AddInductor(m)
+}
+
+func (m *MagneticInductor) CoreName() string { return m.coreName }
+
+func (m *MagneticInductor) SetCoreName(name string) {
+	m.coreName = name
+	if m.core != nil {
+		m.core.name = name
+	}
+}
+
+func (m *MagneticInductor) SetTimeStep(dt float64, status *CircuitStatus) { status.TimeStep = dt }
+
 func (m *MagneticInductor) Stamp(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
 	if m.core == nil {
 		return fmt.Errorf("magnetic core not set for inductor %s", m.Name)
@@ -203,7 +328,11 @@ func (m *MagneticInductor) Stamp(matrix matrix.DeviceMatrix, status *CircuitStat
 	bIdx := m.branchIdx
 
 	switch status.Mode {
+	case ACAnalysis:
+		return m.StampAC(matrix, status)
+
 	case OperatingPointAnalysis:
+		// Short at DC. Branch equation v1 - v2 = 0
 		if n1 != 0 {
 			matrix.AddElement(n1, bIdx, -1)
 			matrix.AddElement(bIdx, n1, -1)
@@ -213,14 +342,6 @@ func (m *MagneticInductor) Stamp(matrix matrix.DeviceMatrix, status *CircuitStat
 			matrix.AddElement(bIdx, n2, 1)
 		}
 
-		var smallL float64 = 1e-3
-		matrix.AddElement(bIdx, bIdx, smallL)
-
-		m.current0 = 0
-		m.current1 = 0
-		m.flux0 = 0
-		m.flux1 = 0
-
 	case TransientAnalysis:
 		if n1 != 0 {
 			matrix.AddElement(n1, bIdx, -1)
@@ -236,38 +357,13 @@ func (m *MagneticInductor) Stamp(matrix matrix.DeviceMatrix, status *CircuitStat
 			dt = 1e-9
 		}
 
-		if status.Time < dt || math.Abs(m.current0) < 1e-9 {
-			mu0 := 4.0e-7 * math.Pi // Vacuum permeability
-			L0 := mu0 * float64(m.turns*m.turns) * m.core.area / m.core.len
-
-			// v = L*di/dt => L/dt*i_now - L/dt*i_prev = v
-			coeffs := util.GetIntegratorCoeffs(util.GearMethod, 1, dt)
-			diag := coeffs[0] * L0
-
-			matrix.AddElement(bIdx, bIdx, -diag)
-			matrix.AddRHS(bIdx, diag*m.current1)
-
-			return nil
-		}
-
-		h := float64(m.turns) * m.current0 / m.core.len
-		h = math.Max(-1e6, math.Min(1e6, h))
-
-		_, dMdH := m.core.Calculate(h, status.Temp) // dM/dH
-		dMdH = math.Max(-1e3, math.Min(1e3, dMdH))  // dM/dH limit
-
-		mu0 := 4.0e-7 * math.Pi
-		muEff := mu0 * (1.0 + dMdH)
-		Leff := muEff * float64(m.turns*m.turns) * m.core.area / m.core.len
-
-		Leff = math.Max(1e-12, Leff)
-
+		// v_i = sum_j L_ij*di_j/dt over windings of core. L_ij = k_ij*mu*A*N_i*N_j/len
 		coeffs := util.GetIntegratorCoeffs(util.GearMethod, 1, dt)
-		diag := coeffs[0] * Leff
-
-		matrix.AddElement(bIdx, bIdx, -diag)
-		rhs := diag * m.current1
-		matrix.AddRHS(bIdx, rhs)
+		for _, winding := range m.core.inductors {
+			diag := coeffs[0] * m.core.inductance(m, winding)
+			matrix.AddElement(bIdx, winding.branchIdx, -diag)
+			matrix.AddRHS(bIdx, diag*winding.current1)
+		}
 	}
 
 	return nil
@@ -281,31 +377,29 @@ func (m *MagneticInductor) StampAC(matrix matrix.DeviceMatrix, status *CircuitSt
 	n1, n2 := m.Nodes[0], m.Nodes[1]
 	omega := 2 * math.Pi * status.Frequency
 
-	h := float64(m.turns) * m.current0 / m.core.len
-	_, dMdH := m.core.Calculate(h, status.Temp)
-	Leff := mu0 * float64(m.turns) * float64(m.turns) *
-		m.core.area * (1 + dMdH) / m.core.len
-
-	// Complex admittance
-	yeqReal := 0.0
-	yeqImag := -1.0 / (omega * Leff)
-
+	// Branch equation v1 - v2 = sum_j jωL_ij*i_j at operating point permeability
+	bIdx := m.branchIdx
 	if n1 != 0 {
-		matrix.AddComplexElement(n1, n1, yeqReal, yeqImag)
-		if n2 != 0 {
-			matrix.AddComplexElement(n1, n2, -yeqReal, -yeqImag)
-		}
+		matrix.AddComplexElement(n1, bIdx, -1, 0)
+		matrix.AddComplexElement(bIdx, n1, -1, 0)
 	}
 	if n2 != 0 {
-		if n1 != 0 {
-			matrix.AddComplexElement(n2, n1, -yeqReal, -yeqImag)
-		}
-		matrix.AddComplexElement(n2, n2, yeqReal, yeqImag)
+		matrix.AddComplexElement(n2, bIdx, 1, 0)
+		matrix.AddComplexElement(bIdx, n2, 1, 0)
+	}
+	for _, winding := range m.core.inductors {
+		matrix.AddComplexElement(bIdx, winding.branchIdx, 0, -omega*m.core.inductance(m, winding))
 	}
 
 	return nil
 }
 
+func (m *MagneticInductor) LoadState(solution []float64, status *CircuitStatus) {
+	if m.branchIdx > 0 && m.branchIdx < len(solution) {
+		m.current0 = -solution[m.branchIdx]
+	}
+}
+
 func (m *MagneticInductor) UpdateState(solution []float64, status *CircuitStatus) {
 	m.voltage1 = m.voltage0
 	m.current1 = m.current0
@@ -330,6 +424,31 @@ func (m *MagneticInductor) UpdateState(solution []float64, status *CircuitStatus
 	if dt > 0 {
 		m.flux0 = m.flux1 + m.voltage0*dt
 	}
+	m.history.accept(m.flux0, dt)
+
+	// Core state follows MMF of all windings. Advanced once per timestep by first winding
+	if m.core != nil && m.core.inductors[0] == m {
+		m.core.update(solution, status)
+	}
+}
+
+// TruncateTimeStep - Flux linkage integrated from winding voltage, core state follows its windings
+func (m *MagneticInductor) TruncateTimeStep(voltages []float64, status *CircuitStatus) float64 {
+	vd := nodeVoltage(voltages, m.Nodes[0]) - nodeVoltage(voltages, m.Nodes[1])
+	return m.history.truncate(m.flux0+vd*status.TimeStep, status)
+}
+
+// InitDCState - Start transient from DC operating point current
+func (m *MagneticInductor) InitDCState(solution []float64, status *CircuitStatus) {
+	m.current0 = -solution[m.branchIdx]
+	m.current1 = m.current0
+	m.voltage0 = 0
+	m.voltage1 = 0
+	m.history.reset(m.flux0)
+
+	if m.core != nil && m.core.inductors[0] == m {
+		m.core.update(solution, status)
+	}
 }
 
 func (m *MagneticInductor) GetFlux() float64 {
@@ -348,6 +467,9 @@ func (m *MagneticInductor) SetBranchIndex(idx int) {
 	m.branchIdx = idx
 }
 
+// BranchSign - Branch variable flows n2 to n1
+func (m *MagneticInductor) BranchSign() float64 { return -1 }
+
 func (m *MagneticInductor) GetPreviousCurrent() float64 {
 	return m.current1
 }
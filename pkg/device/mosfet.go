@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math"
 
+	"github.com/edp1096/toy-spice/internal/consts"
 	"github.com/edp1096/toy-spice/pkg/matrix"
 )
 
@@ -77,6 +78,14 @@ type Mosfet struct {
 	KF   float64 // Flicker noise coefficient
 	AF   float64 // Flicker noise exponent
 
+	thermalPort
+
+	// Parameters at device temperature
+	adjustedTemp float64 // Temperature of adjusted parameters
+	tVTO, tKP    float64
+	tUO, tPHI    float64
+	tIS, tJS     float64
+
 	// Internal states
 	vgs float64 // Gate-Source voltage
 	vds float64 // Drain-Source voltage
@@ -92,6 +101,10 @@ type Mosfet struct {
 	cgd  float64 // Gate-Drain capacitance
 	cgb  float64 // Gate-Bulk capacitance
 
+	// Bulk junction diodes, bulk to source and bulk to drain
+	ibs, gbs float64
+	ibd, gbd float64
+
 	// Operation region
 	region int // 0: cutoff, 1: linear, 2: saturation
 
@@ -101,21 +114,22 @@ type Mosfet struct {
 	prevVbs float64
 	prevId  float64
 
-	// Charge storage
-	qgs float64 // Gate-Source charge
-	qgd float64 // Gate-Drain charge
-	qgb float64 // Gate-Bulk charge
-	qbs float64 // Bulk-Source charge
-	qbd float64 // Bulk-Drain charge
+	// Last model evaluation for bypass
+	bypass   bypassState
+	bypassId float64
 
-	// Previous charge storage
-	prevQgs float64
-	prevQgd float64
-	prevQgb float64
-	prevQbs float64
-	prevQbd float64
+	// Terminal charges of gate, drain and bulk at NMOS polarity. Source charge balances them
+	q     [3]float64
+	cq    [3][3]float64 // dq/d(vgs, vds, vbs)
+	prevQ [3]float64    // Charges at last accepted timepoint
 }
 
+var _ TimeDependent = (*Mosfet)(nil)
+var _ DCInitializer = (*Mosfet)(nil)
+var _ UICInitializer = (*Mosfet)(nil)
+var _ ParameterValidator = (*Mosfet)(nil)
+var _ Distortion = (*Mosfet)(nil)
+
 const (
 	CUTOFF     = 0 // Cutoff region
 	LINEAR     = 1 // Linear/Triode region
@@ -137,6 +151,7 @@ func NewMosfet(name string, nodeNames []string) *Mosfet {
 		Level: 1,      // Default level 1
 	}
 	m.setDefaultParameters()
+	m.temperatureAdjust(m.TNOM)
 	return m
 }
 
@@ -280,9 +295,8 @@ func (m *Mosfet) SetModelParameters(params map[string]float64) {
 		"kappa": &m.KAPPA,
 
 		// Temperature parameters
-		"tnom": &m.TNOM,
-		"kf":   &m.KF,
-		"af":   &m.AF,
+		"kf": &m.KF,
+		"af": &m.AF,
 	}
 
 	for key, param := range paramsSet {
@@ -290,16 +304,48 @@ func (m *Mosfet) SetModelParameters(params map[string]float64) {
 			*param = value
 		}
 	}
+	if tnom, ok := params["tnom"]; ok {
+		m.TNOM = tnom + consts.KELVIN // degC -> K
+	}
+	m.adjustedTemp = 0
+	m.temperatureAdjust(m.TNOM)
+}
+
+// ValidateParameters - Model and instance parameters
+func (m *Mosfet) ValidateParameters() []ParameterIssue {
+	c := paramCheck{device: m.Name}
+	c.positive("l", m.L)
+	c.positive("w", m.W)
+	c.positive("kp", m.KP)
+	c.positive("phi", m.PHI)
+	c.positive("tox", m.TOX)
+	c.positive("n", m.N)
+	c.positive("pb", m.PB)
+	c.positive("tnom", m.TNOM)
+	c.nonNegative("gamma", m.GAMMA)
+	c.nonNegative("lambda", m.LAMBDA)
+	c.nonNegative("is", m.IS)
+	c.nonNegative("js", m.JS)
+	c.nonNegative("cbd", m.CBD)
+	c.nonNegative("cbs", m.CBS)
+	c.nonNegative("cj", m.CJ)
+	c.nonNegative("cjsw", m.CJSW)
+	c.fraction("mj", m.MJ)
+	c.fraction("mjsw", m.MJSW)
+	c.fraction("fc", m.FC)
+	c.usual("level", float64(m.Level), 1, 3)
+	c.usual("lambda", m.LAMBDA, 0, 1)
+	return c.issues
 }
 
 // Calculate threshold voltage with body effect
 func (m *Mosfet) calculateVth(vbs float64) float64 {
-	vt0 := m.VTO
+	vt0 := m.tVTO
 
 	// Apply body effect
 	if m.GAMMA > 0 {
 		// GAMMA * (sqrt(PHI - VBS) - sqrt(PHI))
-		vth := vt0 + m.GAMMA*(math.Sqrt(math.Max(0, m.PHI-vbs))-math.Sqrt(m.PHI))
+		vth := vt0 + m.GAMMA*(math.Sqrt(math.Max(0, m.tPHI-vbs))-math.Sqrt(m.tPHI))
 
 		// For PMOS, negate the threshold voltage
 		if m.Type == "PMOS" {
@@ -360,7 +406,7 @@ func (m *Mosfet) calculateLevel1Current(vgs, vds, vbs, vth, temp float64) (float
 	vgst := vgs - vth
 
 	// Transconductance parameter
-	beta := m.KP * m.W / m.L
+	beta := m.tKP * m.W / m.L
 
 	// Check operation region
 	if vds < vgst {
@@ -387,7 +433,7 @@ func (m *Mosfet) calculateLevel2Current(vgs, vds, vbs, vth, temp float64) (float
 	eeff := vgst / (m.TOX * 100) // TOX(m) to cm
 
 	// Mobility correction
-	ueff := m.UO
+	ueff := m.tUO
 	if m.UCRIT > 0 && eeff > 0 {
 		ueff /= (1.0 + math.Pow(eeff/m.UCRIT, m.UEXP))
 	}
@@ -440,7 +486,7 @@ func (m *Mosfet) calculateLevel3Current(vgs, vds, vbs, vth, temp float64) (float
 	}
 
 	// Calculate beta (including channel width effect)
-	beta := m.KP * m.W / m.L
+	beta := m.tKP * m.W / m.L
 	if m.DELTA > 0 {
 		beta /= (1.0 + m.DELTA/m.W)
 	}
@@ -459,7 +505,7 @@ func (m *Mosfet) calculateLevel3Current(vgs, vds, vbs, vth, temp float64) (float
 }
 
 // Calculate conductances
-func (m *Mosfet) calculateConductances() {
+func (m *Mosfet) calculateConductances(temp float64) {
 	// Sign adjustment for PMOS
 	sign := 1.0
 	if m.Type == "PMOS" {
@@ -477,7 +523,7 @@ func (m *Mosfet) calculateConductances() {
 	vgst := vgs - vth
 
 	// Transconductance parameter
-	beta := m.KP * m.W / m.L
+	beta := m.tKP * m.W / m.L
 
 	// Minimum conductance for numerical stability
 	gmin := 1e-12
@@ -491,9 +537,9 @@ func (m *Mosfet) calculateConductances() {
 	}
 
 	// Body effect factor
-	if m.GAMMA > 0 && m.PHI > 0 {
+	if m.GAMMA > 0 && m.tPHI > 0 {
 		if vbs < 0 {
-			m.gmbs = m.gm * m.GAMMA / (2.0 * math.Sqrt(m.PHI-vbs))
+			m.gmbs = m.gm * m.GAMMA / (2.0 * math.Sqrt(m.tPHI-vbs))
 		} else {
 			m.gmbs = gmin
 		}
@@ -519,15 +565,15 @@ func (m *Mosfet) calculateConductances() {
 		id0 := m.id // Original current
 
 		// Change in current with small change in vgs
-		idg, _ := m.calculateCurrents(vgs+delta, vds, vbs, 300.15)
+		idg, _ := m.calculateCurrents(vgs+delta, vds, vbs, temp)
 		m.gm = math.Max((idg-id0)/delta, gmin)
 
 		// Change in current with small change in vds
-		idd, _ := m.calculateCurrents(vgs, vds+delta, vbs, 300.15)
+		idd, _ := m.calculateCurrents(vgs, vds+delta, vbs, temp)
 		m.gds = math.Max((idd-id0)/delta, gmin)
 
 		// Change in current with small change in vbs
-		idb, _ := m.calculateCurrents(vgs, vds, vbs+delta, 300.15)
+		idb, _ := m.calculateCurrents(vgs, vds, vbs+delta, temp)
 		m.gmbs = math.Max((idb-id0)/delta, gmin)
 	}
 
@@ -543,9 +589,7 @@ func (m *Mosfet) calculateCapacitances() {
 	cgd := 0.0
 	cgb := 0.0
 
-	// Gate oxide capacitance per unit area
-	cox := 3.9 * 8.85e-14 / m.TOX // εox / tox
-	cgate := cox * m.W * m.L      // Total gate capacitance
+	cgate := m.gateCapacitance()
 
 	// Overlap capacitances
 	cgso := m.CGSO * m.W
@@ -593,47 +637,143 @@ func (m *Mosfet) calculateCapacitances() {
 	m.cgb = cgb
 }
 
-// Calculate charges for transient analysis
-func (m *Mosfet) calculateCharges() {
-	switch m.region {
-	case CUTOFF:
-		m.qgs = 0.0
-		m.qgd = 0.0
-		m.qgb = m.cgb * (m.vgs - m.vbs)
+// Total gate oxide capacitance
+func (m *Mosfet) gateCapacitance() float64 {
+	cox := 3.9 * 8.85e-14 / m.TOX // εox / tox
+	return cox * m.W * m.L
+}
 
-	case LINEAR:
-		m.qgs = m.cgs * m.vgs
-		m.qgd = m.cgd * m.vgd
-		m.qgb = m.cgb * (m.vgs - m.vbs)
+// junctionCharge - Depletion charge of bulk junction with zero-bias capacitance cj0.
+// Above FC*PB capacitance continues linearly in voltage as in SPICE.
+func (m *Mosfet) junctionCharge(v, cj0 float64) float64 {
+	if cj0 == 0 {
+		return 0
+	}
+	pb, mj, fc := m.PB, m.MJ, m.FC
+	vfc := fc * pb
+	depletion := func(v float64) float64 {
+		if mj == 1 {
+			return -cj0 * pb * math.Log(1-v/pb)
+		}
+		return cj0 * pb * (1 - math.Pow(1-v/pb, 1-mj)) / (1 - mj)
+	}
+	if v < vfc {
+		return depletion(v)
+	}
+	f2 := math.Pow(1-fc, 1+mj)
+	f3 := 1 - fc*(1+mj)
+	return depletion(vfc) + cj0/f2*(f3*(v-vfc)+mj/(2*pb)*(v*v-vfc*vfc))
+}
 
-	case SATURATION:
-		m.qgs = m.cgs * m.vgs
-		m.qgd = m.cgd * m.vgd
-		m.qgb = m.cgb * (m.vgs - m.vbs)
+// terminalCharges - Charges of gate, drain and bulk at NMOS polarity voltages vgs, vds, vbs.
+// Inversion charge is partitioned to drain and source by Ward-Dutton, weighted by position along channel,
+// so it is 40/60 in saturation and 50/50 at vds=0. Bulk holds accumulation or depletion charge,
+// pinned at threshold. Gate balances channel and bulk, so charge is conserved by construction.
+func (m *Mosfet) terminalCharges(v [3]float64) [3]float64 {
+	vgs, vds, vbs := v[0], v[1], v[2]
+	vgd, vbd, vgb := vgs-vds, vbs-vds, vgs-vbs
+	cgate := m.gateCapacitance()
+
+	vth := m.calculateVth(vbs)
+	sqrtPhi := math.Sqrt(math.Max(m.tPHI-vbs, 0))
+	vfb := vth - m.tPHI - m.GAMMA*sqrtPhi
+
+	// Inversion charge by gate overdrive at source and drain end of channel
+	var qi, qd float64
+	a := math.Max(vgs-vth, 0)
+	b := math.Max(vgd-vth, 0)
+	if a+b > 0 {
+		qi = -2 * cgate * (a*a + a*b + b*b) / (3 * (a + b))
+		qd = -2 * cgate * (2*a*a*a + 4*a*a*b + 6*a*b*b + 3*b*b*b) / (15 * (a + b) * (a + b))
 	}
 
-	var cbs, cbd float64
+	// Bulk charge, depletion width stops growing at threshold
+	var qb float64
+	x := vgb - vfb
+	switch {
+	case x <= 0:
+		qb = -cgate * x // Accumulation
+	case m.GAMMA > 0:
+		x = math.Min(x, m.tPHI-vbs+m.GAMMA*sqrtPhi)
+		g2 := m.GAMMA * m.GAMMA
+		qb = -cgate * g2 / 2 * (math.Sqrt(1+4*x/g2) - 1)
+	}
+	qg := -(qi + qb)
 
-	// Junction capacitances with voltage dependence
-	if m.vbs < 0 {
-		// Reverse bias
-		cbs = m.CBS / math.Pow(1.0-m.vbs/m.PB, m.MJ)
-	} else {
-		// Forward bias
-		cbs = m.CBS * (1.0 + m.MJ*m.vbs/m.PB)
+	// Overlap and bulk junction charges
+	cgso, cgdo, cgbo := m.CGSO*m.W, m.CGDO*m.W, m.CGBO*m.L
+	qbsj, qbdj := m.junctionCharge(vbs, m.CBS), m.junctionCharge(vbd, m.CBD)
+	qg += cgso*vgs + cgdo*vgd + cgbo*vgb
+	qd += -cgdo*vgd - qbdj
+	qb += -cgbo*vgb + qbsj + qbdj
+
+	return [3]float64{qg, qd, qb}
+}
+
+// calculateCharges - Terminal charges and their capacitances at present voltages
+func (m *Mosfet) calculateCharges() {
+	m.calculateCapacitances() // Junction zero-bias capacitances from CJ, CJSW
+
+	v := [3]float64{m.vgs, m.vds, m.vbs}
+	m.q = m.terminalCharges(v)
+
+	delta := 1e-6
+	for j := range v {
+		dv := v
+		dv[j] += delta
+		q := m.terminalCharges(dv)
+		for i := range q {
+			m.cq[i][j] = (q[i] - m.q[i]) / delta
+		}
 	}
+}
 
-	if m.vbd < 0 {
-		// Reverse bias
-		cbd = m.CBD / math.Pow(1.0-m.vbd/m.PB, m.MJ)
-	} else {
-		// Forward bias
-		cbd = m.CBD * (1.0 + m.MJ*m.vbd/m.PB)
+// stampCharges - Backward Euler companion of terminal charges. Current into terminal is dq/dt,
+// source current is negative sum of gate, drain and bulk currents.
+func (m *Mosfet) stampCharges(matrix matrix.DeviceMatrix, dt float64) {
+	typeValue := 1.0
+	if m.Type == "PMOS" {
+		typeValue = -1.0
+	}
+
+	nodes := [3]int{m.Nodes[1], m.Nodes[0], m.Nodes[3]} // Gate, drain, bulk
+	ns := m.Nodes[2]
+	v := [3]float64{m.vgs, m.vds, m.vbs}
+
+	var sourceG [3]float64 // Source row by gate, drain, bulk columns
+	var sourceGss, sourceIeq float64
+	for i, row := range nodes {
+		ieq := (m.q[i] - m.prevQ[i]) / dt
+		rowGss := 0.0
+		for j, col := range nodes {
+			g := m.cq[i][j] / dt
+			ieq -= g * v[j]
+			rowGss += g
+			sourceG[j] += g
+			if row != 0 && col != 0 {
+				matrix.AddElement(row, col, g)
+			}
+		}
+		ieq *= typeValue
+		sourceGss += rowGss
+		sourceIeq += ieq
+		if row != 0 {
+			if ns != 0 {
+				matrix.AddElement(row, ns, -rowGss)
+			}
+			matrix.AddRHS(row, -ieq)
+		}
 	}
 
-	// Calculate charges
-	m.qbs = cbs * m.vbs
-	m.qbd = cbd * m.vbd
+	if ns != 0 {
+		for j, col := range nodes {
+			if col != 0 {
+				matrix.AddElement(ns, col, -sourceG[j])
+			}
+		}
+		matrix.AddElement(ns, ns, sourceGss)
+		matrix.AddRHS(ns, sourceIeq)
+	}
 }
 
 // UpdateVoltages from solution vector
@@ -689,14 +829,27 @@ func (m *Mosfet) Stamp(matrix matrix.DeviceMatrix, status *CircuitStatus) error
 		m.vbd = m.vbs - m.vds
 	}
 
-	// Calculate currents and determine region
-	m.id, m.region = m.calculateCurrents(m.vgs, m.vds, m.vbs, status.Temp)
-	m.prevId = m.id
+	temp := m.temperature(status)
+	m.temperatureAdjust(temp)
+	if m.bypass.canBypass(status, temp, m.vgs, m.vds, m.vbs) {
+		// Linearization of last evaluation at new voltages
+		v := m.bypass.voltages
+		m.id = m.bypassId + m.gm*(m.vgs-v[0]) + m.gds*(m.vds-v[1]) + m.gmbs*(m.vbs-v[2])
+	} else {
+		// Calculate currents and determine region
+		m.id, m.region = m.calculateCurrents(m.vgs, m.vds, m.vbs, temp)
 
-	m.calculateConductances()
-	m.calculateCapacitances()
+		m.calculateConductances(temp)
+		m.calculateCapacitances()
+		m.bypass.evaluated(temp, m.vgs, m.vds, m.vbs)
+		m.bypassId = m.id
+	}
+	m.prevId = m.id
 
 	gmin := status.Gmin
+	m.stampPower(matrix, status)
+	m.calculateJunctions(temp)
+	m.stampJunctions(matrix)
 
 	if nd != 0 {
 		// Drain
@@ -728,61 +881,133 @@ func (m *Mosfet) Stamp(matrix matrix.DeviceMatrix, status *CircuitStatus) error
 		matrix.AddRHS(ns, m.id-m.gds*m.vds-m.gm*m.vgs-m.gmbs*m.vbs)
 	}
 
-	// Gate and bulk
+	// Gate and bulk charges
 	if status.Mode == TransientAnalysis && status.TimeStep > 0 {
-		dt := status.TimeStep
-
 		m.calculateCharges()
+		m.stampCharges(matrix, status.TimeStep)
+	}
 
-		// Capacitive currents
-		icgs := (m.qgs - m.prevQgs) / dt
-		icgd := (m.qgd - m.prevQgd) / dt
-		icgb := (m.qgb - m.prevQgb) / dt
-		icbs := (m.qbs - m.prevQbs) / dt
-		icbd := (m.qbd - m.prevQbd) / dt
+	return nil
+}
 
-		// Gate
-		if ng != 0 {
-			if nd != 0 {
-				matrix.AddElement(ng, nd, m.cgd/dt)
-				matrix.AddElement(nd, ng, m.cgd/dt)
-				matrix.AddRHS(ng, icgd)
-				matrix.AddRHS(nd, -icgd)
-			}
-			if ns != 0 {
-				matrix.AddElement(ng, ns, m.cgs/dt)
-				matrix.AddElement(ns, ng, m.cgs/dt)
-				matrix.AddRHS(ng, icgs)
-				matrix.AddRHS(ns, -icgs)
-			}
-			if nb != 0 {
-				matrix.AddElement(ng, nb, m.cgb/dt)
-				matrix.AddElement(nb, ng, m.cgb/dt)
-				matrix.AddRHS(ng, icgb)
-				matrix.AddRHS(nb, -icgb)
-			}
-			matrix.AddElement(ng, ng, (m.cgd+m.cgs+m.cgb)/dt)
-		}
+// junctionSaturation - Saturation currents of bulk-source and bulk-drain junctions.
+// JS times diffusion area when both are given, otherwise IS
+func (m *Mosfet) junctionSaturation() (float64, float64) {
+	iss, isd := m.tIS, m.tIS
+	if m.tJS > 0 && m.AS > 0 {
+		iss = m.tJS * m.AS
+	}
+	if m.tJS > 0 && m.AD > 0 {
+		isd = m.tJS * m.AD
+	}
+	return iss, isd
+}
 
-		// Bulk
+// calculateJunctions - Bulk junction diode currents and conductances at present voltages
+func (m *Mosfet) calculateJunctions(temp float64) {
+	if temp <= 0 {
+		temp = consts.KELVIN + 27 // Structural stamp before analysis has no temperature
+	}
+	nvt := m.N * consts.BOLTZMANN * temp / consts.CHARGE
+	iss, isd := m.junctionSaturation()
+	m.ibs, m.gbs = junctionCurrent(m.vbs, iss, nvt)
+	m.ibd, m.gbd = junctionCurrent(m.vbd, isd, nvt)
+}
+
+// stampJunctions - Bulk-source and bulk-drain diodes linearized at present voltages.
+// Voltages and currents are of NMOS polarity, so PMOS current source is reversed.
+func (m *Mosfet) stampJunctions(matrix matrix.DeviceMatrix) {
+	typeValue := 1.0
+	if m.Type == "PMOS" {
+		typeValue = -1.0
+	}
+
+	nb := m.Nodes[3]
+	for _, j := range []struct {
+		node    int
+		i, g, v float64
+	}{
+		{m.Nodes[2], m.ibs, m.gbs, m.vbs},
+		{m.Nodes[0], m.ibd, m.gbd, m.vbd},
+	} {
+		ieq := typeValue * (j.i - j.g*j.v) // Flows bulk to source or drain
 		if nb != 0 {
-			if ns != 0 {
-				matrix.AddElement(nb, ns, m.CBS/dt)
-				matrix.AddElement(ns, nb, m.CBS/dt)
-				matrix.AddRHS(nb, icbs)
-				matrix.AddRHS(ns, -icbs)
+			matrix.AddElement(nb, nb, j.g)
+			if j.node != 0 {
+				matrix.AddElement(nb, j.node, -j.g)
 			}
-			if nd != 0 {
-				matrix.AddElement(nb, nd, m.CBD/dt)
-				matrix.AddElement(nd, nb, m.CBD/dt)
-				matrix.AddRHS(nb, icbd)
-				matrix.AddRHS(nd, -icbd)
+			matrix.AddRHS(nb, -ieq)
+		}
+		if j.node != 0 {
+			matrix.AddElement(j.node, j.node, j.g)
+			if nb != 0 {
+				matrix.AddElement(j.node, nb, -j.g)
 			}
-			matrix.AddElement(nb, nb, (m.CBD+m.CBS)/dt)
+			matrix.AddRHS(j.node, ieq)
 		}
 	}
+}
 
-	return nil
+// Circuit temperature. Thermal node adds its rise
+func (m *Mosfet) temperature(status *CircuitStatus) float64 {
+	return status.Temp + m.rise
+}
+
+// Energy gap of silicon (eV) at temperature
+func siliconEnergyGap(temp float64) float64 {
+	return 1.16 - 7.02e-4*temp*temp/(temp+1108)
+}
+
+// temperatureAdjust - VTO, KP, UO, PHI and junction saturation currents from TNOM to temp.
+// Mobility scales by (T/TNOM)^-1.5, surface potential and threshold follow energy gap of silicon.
+func (m *Mosfet) temperatureAdjust(temp float64) {
+	if temp <= 0 {
+		temp = m.TNOM
+	}
+	if temp == m.adjustedTemp {
+		return
+	}
+	m.adjustedTemp = temp
+
+	if temp == m.TNOM {
+		m.tVTO, m.tKP, m.tUO, m.tPHI, m.tIS, m.tJS = m.VTO, m.KP, m.UO, m.PHI, m.IS, m.JS
+		return
+	}
+
+	const reftemp = consts.KELVIN + 27
+	typeValue := 1.0
+	if m.Type == "PMOS" {
+		typeValue = -1.0
+	}
+
+	// Surface potential term of energy gap change relative to reference temperature
+	pbfact := func(t float64) float64 {
+		vt := consts.BOLTZMANN * t / consts.CHARGE
+		arg := -siliconEnergyGap(t)/(2*vt) + 1.1150877/(2*consts.BOLTZMANN*reftemp/consts.CHARGE)
+		return -2 * vt * (1.5*math.Log(t/reftemp) + arg)
+	}
+
+	ratio := temp / m.TNOM
+	ratio4 := ratio * math.Sqrt(ratio)
+	m.tKP = m.KP / ratio4
+	m.tUO = m.UO / ratio4
+
+	egnom, eg := siliconEnergyGap(m.TNOM), siliconEnergyGap(temp)
+	phio := (m.PHI - pbfact(m.TNOM)) / (m.TNOM / reftemp)
+	m.tPHI = temp/reftemp*phio + pbfact(temp)
+
+	vbi := m.VTO - typeValue*m.GAMMA*math.Sqrt(m.PHI) + 0.5*(egnom-eg) + typeValue*0.5*(m.tPHI-m.PHI)
+	m.tVTO = vbi + typeValue*m.GAMMA*math.Sqrt(m.tPHI)
+
+	vtnom := consts.BOLTZMANN * m.TNOM / consts.CHARGE
+	vt := consts.BOLTZMANN * temp / consts.CHARGE
+	factor := math.Exp(-eg/vt + egnom/vtnom)
+	m.tIS = m.IS * factor
+	m.tJS = m.JS * factor
+}
+
+func (m *Mosfet) UpdateThermal(voltages []float64, dt float64, status *CircuitStatus) {
+	m.accept(voltages, m.id*m.vds, dt)
 }
 
 func (m *Mosfet) StampAC(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
@@ -918,17 +1143,85 @@ func (m *Mosfet) LoadCurrent(matrix matrix.DeviceMatrix) error {
 	return nil
 }
 
+func (m *Mosfet) SetTimeStep(dt float64, status *CircuitStatus) { status.TimeStep = dt }
+
+func (m *Mosfet) LoadState(voltages []float64, status *CircuitStatus) {}
+
+// UpdateState - Charges at accepted timepoint for next timestep
 func (m *Mosfet) UpdateState(voltages []float64, status *CircuitStatus) {
-	// Charge
-	m.prevQgs = m.qgs
-	m.prevQgd = m.qgd
-	m.prevQgb = m.qgb
-	m.prevQbs = m.qbs
-	m.prevQbd = m.qbd
+	m.UpdateVoltages(voltages)
+	m.calculateCharges()
+	m.prevQ = m.q
 
 	m.prevId = m.id // Current
+}
+
+// TruncateTimeStep - Gate charges are not checked
+func (m *Mosfet) TruncateTimeStep(voltages []float64, status *CircuitStatus) float64 {
+	return math.Inf(1)
+}
+
+// InitDCState - Charges at operating point
+func (m *Mosfet) InitDCState(solution []float64, status *CircuitStatus) {
+	m.UpdateState(solution, status)
+}
+
+func (m *Mosfet) InitUICState(voltages []float64, status *CircuitStatus) {
+	m.UpdateState(voltages, status)
+}
+
+// Drain current at last evaluated operating point
+func (m *Mosfet) ProbeCurrent(voltages []float64, status *CircuitStatus) float64 {
+	return m.id
+}
+
+// Small-signal drain current. Same as drain row of AC stamp
+func (m *Mosfet) ProbeCurrentAC(voltages []complex128, status *CircuitStatus) complex128 {
+	vd := nodeVoltageAC(voltages, m.Nodes[0])
+	vg := nodeVoltageAC(voltages, m.Nodes[1])
+	vs := nodeVoltageAC(voltages, m.Nodes[2])
+	vb := nodeVoltageAC(voltages, m.Nodes[3])
+
+	omega := 2.0 * math.Pi * status.Frequency
+	return complex(m.gds, 0)*vd +
+		complex(m.gm, omega*m.cgd)*vg +
+		complex(-m.gds-m.gm-m.gmbs, 0)*vs +
+		complex(m.gmbs, omega*m.CBD)*vb
+}
+
+// drainTaylor - Derivatives of drain current by vgs, vds, vbs at operating point.
+// Finite differences of model current, so every level is covered. Junctions and charges are linear in distortion
+func (m *Mosfet) drainTaylor(status *CircuitStatus) taylor3 {
+	temp := m.temperature(status)
+	m.temperatureAdjust(temp)
+	return newTaylor3(func(v [3]float64) float64 {
+		id, _ := m.calculateCurrents(v[0], v[1], v[2], temp)
+		return id
+	}, [3]float64{m.vgs, m.vds, m.vbs})
+}
+
+// controlPhasors - vgs, vds, vbs phasors of node voltage phasors, signed by type as operating point voltages
+func (m *Mosfet) controlPhasors(voltages []complex128) [3]complex128 {
+	typeValue := complex(1, 0)
+	if m.Type == "PMOS" {
+		typeValue = -1
+	}
+	vs := nodeVoltageAC(voltages, m.Nodes[2])
+	return [3]complex128{
+		typeValue * (nodeVoltageAC(voltages, m.Nodes[1]) - vs),
+		typeValue * (nodeVoltageAC(voltages, m.Nodes[0]) - vs),
+		typeValue * (nodeVoltageAC(voltages, m.Nodes[3]) - vs),
+	}
+}
+
+func (m *Mosfet) Distortion2(currents, x, y []complex128, status *CircuitStatus) {
+	id := m.drainTaylor(status).quadratic(m.controlPhasors(x), m.controlPhasors(y))
+	addTerminalCurrent(currents, m.Nodes[0], m.Nodes[2], id)
+}
 
-	m.calculateCharges() // Update charges for next timestep
+func (m *Mosfet) Distortion3(currents, x, y, z []complex128, status *CircuitStatus) {
+	id := m.drainTaylor(status).cubic(m.controlPhasors(x), m.controlPhasors(y), m.controlPhasors(z))
+	addTerminalCurrent(currents, m.Nodes[0], m.Nodes[2], id)
 }
 
 func (m *Mosfet) GetVgs() float64 {
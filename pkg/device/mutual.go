@@ -5,6 +5,7 @@ import (
 	"math"
 
 	"github.com/edp1096/toy-spice/pkg/matrix"
+	"github.com/edp1096/toy-spice/pkg/util"
 )
 
 type Mutual struct {
@@ -54,68 +55,87 @@ func (m *Mutual) GetNumInductors() int {
 
 func (m *Mutual) GetCoefficient() float64 { return m.coefficient }
 
-func (m *Mutual) Stamp(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
-	if len(m.inductors) < 2 {
-		return fmt.Errorf("mutual coupling %s requires at least two inductors", m.Name)
-	}
+// coupledPair - Mutual inductance M = k*sqrt(Li*Lj) between two coupled inductors
+type coupledPair struct {
+	ind1, ind2 InductorComponent
+	value      float64
+}
 
-	// Only for transient
-	if status.Mode != TransientAnalysis {
-		return nil
+// pairs - All inductor pairs of coupling. Every inductor is coupled with every other by same k.
+// Windings on same magnetic core are coupled by core and skipped.
+func (m *Mutual) pairs() []coupledPair {
+	var pairs []coupledPair
+	for i := range m.inductors {
+		for j := i + 1; j < len(m.inductors); j++ {
+			if sharedCore(m.inductors[i], m.inductors[j]) != nil {
+				continue
+			}
+			Mij := m.coefficient * math.Sqrt(m.inductors[i].GetValue()*m.inductors[j].GetValue())
+			if Mij == 0.0 {
+				continue
+			}
+			pairs = append(pairs, coupledPair{ind1: m.inductors[i], ind2: m.inductors[j], value: Mij})
+		}
 	}
+	return pairs
+}
 
-	dt := status.TimeStep
-	if dt <= 0 {
+// sharedCore - Core of two windings if both are on same magnetic core
+func sharedCore(a, b InductorComponent) *MagneticCore {
+	ma, ok1 := a.(*MagneticInductor)
+	mb, ok2 := b.(*MagneticInductor)
+	if !ok1 || !ok2 || ma.core == nil || ma.core != mb.core {
 		return nil
 	}
+	return ma.core
+}
 
-	var indInfo []struct {
-		branchIdx int     // Branch index
-		value     float64 // Inductance value
-		current   float64 // Current for now
-		nodes     [2]int  // Node indices
-	}
-
-	for _, ind := range m.inductors {
-		branchIdx := 0
-		if indObj, ok := ind.(*Inductor); ok {
-			branchIdx = indObj.BranchIndex()
-		} else {
-			// MagneticInductor
-			if magInd, ok := ind.(*MagneticInductor); ok {
-				branchIdx = magInd.branchIdx
-			} else {
-				return fmt.Errorf("inductor %s does not support branch index", ind.GetName())
+// CoupleCores - Hand coefficient of windings on same core to core. Called after inductors are set
+func (m *Mutual) CoupleCores() {
+	for i := range m.inductors {
+		for j := i + 1; j < len(m.inductors); j++ {
+			if core := sharedCore(m.inductors[i], m.inductors[j]); core != nil {
+				core.SetCoupling(m.inductors[i].(*MagneticInductor), m.inductors[j].(*MagneticInductor), m.coefficient)
 			}
 		}
-
-		indInfo = append(indInfo, struct {
-			branchIdx int
-			value     float64
-			current   float64
-			nodes     [2]int
-		}{
-			branchIdx: branchIdx,
-			value:     ind.GetValue(),
-			current:   ind.GetCurrent(),
-			nodes:     [2]int{ind.GetNodes()[0], ind.GetNodes()[1]},
-		})
 	}
+}
 
-	// Mutual inductance processing for each inductor pair
-	for i := range indInfo {
-		for j := i + 1; j < len(indInfo); j++ {
-			Mij := m.coefficient * math.Sqrt(indInfo[i].value*indInfo[j].value) // M = k * sqrt(L1 * L2)
+func (m *Mutual) Stamp(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
+	if len(m.inductors) < 2 {
+		return fmt.Errorf("mutual coupling %s requires at least two inductors", m.Name)
+	}
+	for i, ind := range m.inductors {
+		if ind == nil {
+			return fmt.Errorf("inductor %s of mutual coupling %s not set", m.names[i], m.Name)
+		}
+	}
 
-			matrix.AddElement(indInfo[i].branchIdx, indInfo[j].branchIdx, -Mij/dt) // V1 = L1*di1/dt + M*di2/dt
-			matrix.AddElement(indInfo[j].branchIdx, indInfo[i].branchIdx, -Mij/dt) // V2 = L2*di2/dt + M*di1/dt
+	switch status.Mode {
+	case ACAnalysis:
+		return m.StampAC(matrix, status)
 
-			// RHS: Based on previous current
-			matrix.AddRHS(indInfo[i].branchIdx, -Mij*indInfo[j].current/dt)
-			matrix.AddRHS(indInfo[j].branchIdx, -Mij*indInfo[i].current/dt)
+	case TransientAnalysis:
+		dt := status.TimeStep
+		if dt <= 0 {
+			dt = 1e-9
+		}
+		// Same integration as self inductance of Inductor
+		coeffs := util.GetIntegratorCoeffs(util.GearMethod, 1, dt)
+
+		for _, pair := range m.pairs() {
+			// v_i - v_i' = L_i*di_i/dt + M_ij*di_j/dt
+			b1, b2 := pair.ind1.BranchIndex(), pair.ind2.BranchIndex()
+			matrix.AddElement(b1, b2, -coeffs[0]*pair.value)
+			matrix.AddElement(b2, b1, -coeffs[0]*pair.value)
+
+			// History term of previous currents. Same sign as self inductance history term
+			matrix.AddRHS(b1, coeffs[0]*pair.value*pair.ind2.GetPreviousCurrent())
+			matrix.AddRHS(b2, coeffs[0]*pair.value*pair.ind1.GetPreviousCurrent())
 		}
 	}
 
+	// Nothing at DC. Coupled inductors are shorts and di/dt = 0
 	return nil
 }
 
@@ -125,60 +145,12 @@ func (m *Mutual) StampAC(matrix matrix.DeviceMatrix, status *CircuitStatus) erro
 	}
 
 	omega := 2 * math.Pi * status.Frequency
-	n := len(m.inductors)
-
-	// Get all inductors info
-	L := make([]float64, n)
-	nodes := make([][2]int, n)
-	for i := range n {
-		L[i] = m.inductors[i].GetValue()
-		nodes[i] = [2]int{m.inductors[i].GetNodes()[0], m.inductors[i].GetNodes()[1]}
-	}
 
-	// AC Stamp
-	for i := range n {
-		for j := i + 1; j < n; j++ {
-			Mij := m.coefficient * math.Sqrt(L[i]*L[j])
-
-			if Mij != 0.0 {
-				// AC admitance (jωM)
-				yReal := 0.0
-				yImag := omega * Mij
-
-				if nodes[i][0] > 0 {
-					if nodes[j][0] > 0 {
-						matrix.AddComplexElement(nodes[i][0], nodes[j][0], yReal, yImag)
-					}
-					if nodes[j][1] > 0 {
-						matrix.AddComplexElement(nodes[i][0], nodes[j][1], -yReal, -yImag)
-					}
-				}
-				if nodes[i][1] > 0 {
-					if nodes[j][0] > 0 {
-						matrix.AddComplexElement(nodes[i][1], nodes[j][0], -yReal, -yImag)
-					}
-					if nodes[j][1] > 0 {
-						matrix.AddComplexElement(nodes[i][1], nodes[j][1], yReal, yImag)
-					}
-				}
-				if nodes[j][0] > 0 {
-					if nodes[i][0] > 0 {
-						matrix.AddComplexElement(nodes[j][0], nodes[i][0], yReal, yImag)
-					}
-					if nodes[i][1] > 0 {
-						matrix.AddComplexElement(nodes[j][0], nodes[i][1], -yReal, -yImag)
-					}
-				}
-				if nodes[j][1] > 0 {
-					if nodes[i][0] > 0 {
-						matrix.AddComplexElement(nodes[j][1], nodes[i][0], -yReal, -yImag)
-					}
-					if nodes[i][1] > 0 {
-						matrix.AddComplexElement(nodes[j][1], nodes[i][1], yReal, yImag)
-					}
-				}
-			}
-		}
+	// Branch equations v_i - v_i' = jωL_i*i_i + jωM_ij*i_j
+	for _, pair := range m.pairs() {
+		b1, b2 := pair.ind1.BranchIndex(), pair.ind2.BranchIndex()
+		matrix.AddComplexElement(b1, b2, 0, -omega*pair.value)
+		matrix.AddComplexElement(b2, b1, 0, -omega*pair.value)
 	}
 
 	return nil
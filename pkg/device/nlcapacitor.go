@@ -0,0 +1,177 @@
+package device
+
+import (
+	"math"
+
+	"github.com/edp1096/toy-spice/pkg/matrix"
+)
+
+// NonlinearCapacitor - Capacitor with voltage dependent C(V), eg. varactor.
+// Transient is stamped on charge Q(V) linearized at the latest Newton iterate.
+type NonlinearCapacitor struct {
+	BaseDevice
+	curve    *Curve
+	vd       float64 // Voltage of latest Newton iterate
+	Voltage0 float64 // Voltage of last accepted timepoint
+	Voltage1 float64 // Previous voltage
+	charge0  float64 // Charge of last accepted timepoint
+	charge1  float64 // Previous charge
+	history  stateHistory
+}
+
+var _ TimeDependent = (*NonlinearCapacitor)(nil)
+var _ NonLinearStorage = (*NonlinearCapacitor)(nil)
+var _ UICInitializer = (*NonlinearCapacitor)(nil)
+
+func NewNonlinearCapacitor(name string, nodeNames []string, curve *Curve) *NonlinearCapacitor {
+	return &NonlinearCapacitor{
+		BaseDevice: BaseDevice{
+			Name:      name,
+			Nodes:     make([]int, len(nodeNames)),
+			NodeNames: nodeNames,
+			Value:     curve.Value(0),
+		},
+		curve: curve,
+	}
+}
+
+func (c *NonlinearCapacitor) GetType() string { return "C" }
+
+// GetValue - Incremental capacitance at latest voltage
+func (c *NonlinearCapacitor) GetValue() float64 { return c.curve.Value(c.vd) }
+
+func (c *NonlinearCapacitor) SetTimeStep(dt float64, status *CircuitStatus) { status.TimeStep = dt }
+
+func (c *NonlinearCapacitor) Stamp(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
+	n1, n2 := c.Nodes[0], c.Nodes[1]
+
+	switch status.Mode {
+	case ACAnalysis:
+		return c.StampAC(matrix, status)
+
+	case OperatingPointAnalysis:
+		gmin := status.Gmin
+		if gmin < 1e-12 {
+			gmin = 1e-12
+		}
+		if n1 != 0 {
+			matrix.AddElement(n1, n1, gmin)
+			if n2 != 0 {
+				matrix.AddElement(n1, n2, -gmin)
+			}
+		}
+		if n2 != 0 {
+			matrix.AddElement(n2, n2, gmin)
+			if n1 != 0 {
+				matrix.AddElement(n2, n1, -gmin)
+			}
+		}
+
+	case TransientAnalysis:
+		dt := status.TimeStep
+		if dt <= 0 {
+			dt = 1e-9
+		}
+
+		// i = (Q(v) - Q_prev)/dt, Q(v) ~ Q(vd) + C(vd)*(v - vd)
+		// History is split in increments from last timepoint, which vanish at steady state without cancellation.
+		capacitance := c.curve.Value(c.vd)
+		geq := capacitance / dt
+		ieq := ((c.curve.Integral(c.vd) - c.charge0) - capacitance*(c.vd-c.Voltage0) - capacitance*c.Voltage0) / dt
+
+		if n1 != 0 {
+			matrix.AddElement(n1, n1, geq)
+			if n2 != 0 {
+				matrix.AddElement(n1, n2, -geq)
+			}
+			matrix.AddRHS(n1, -ieq)
+		}
+		if n2 != 0 {
+			matrix.AddElement(n2, n2, geq)
+			if n1 != 0 {
+				matrix.AddElement(n2, n1, -geq)
+			}
+			matrix.AddRHS(n2, ieq)
+		}
+	}
+
+	return nil
+}
+
+func (c *NonlinearCapacitor) StampAC(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
+	n1, n2 := c.Nodes[0], c.Nodes[1]
+
+	// Small signal capacitance at operating point
+	capConductanceImag := 2 * math.Pi * status.Frequency * c.curve.Value(c.vd)
+	if n1 != 0 {
+		matrix.AddComplexElement(n1, n1, 0, capConductanceImag)
+		if n2 != 0 {
+			matrix.AddComplexElement(n1, n2, 0, -capConductanceImag)
+		}
+	}
+	if n2 != 0 {
+		matrix.AddComplexElement(n2, n2, 0, capConductanceImag)
+		if n1 != 0 {
+			matrix.AddComplexElement(n2, n1, 0, -capConductanceImag)
+		}
+	}
+
+	return nil
+}
+
+// UpdateVoltages - Linearization point of next Newton iteration.
+// Changes at rounding noise level keep the point, so converged solution repeats exactly.
+func (c *NonlinearCapacitor) UpdateVoltages(voltages []float64) error {
+	if vd := nodeVoltage(voltages, c.Nodes[0]) - nodeVoltage(voltages, c.Nodes[1]); math.Abs(vd-c.vd) > 1e-12*math.Abs(vd) {
+		c.vd = vd
+	}
+	return nil
+}
+
+func (c *NonlinearCapacitor) LoadState(voltages []float64, status *CircuitStatus) {
+	c.vd = nodeVoltage(voltages, c.Nodes[0]) - nodeVoltage(voltages, c.Nodes[1])
+}
+
+func (c *NonlinearCapacitor) UpdateState(voltages []float64, status *CircuitStatus) {
+	vd := nodeVoltage(voltages, c.Nodes[0]) - nodeVoltage(voltages, c.Nodes[1])
+
+	c.Voltage1 = c.Voltage0
+	c.Voltage0 = vd
+	c.charge1 = c.charge0
+	c.charge0 = c.curve.Integral(vd)
+	c.history.accept(c.charge0, status.TimeStep)
+}
+
+// InitDCState - Start transient from DC operating point voltage
+func (c *NonlinearCapacitor) InitDCState(solution []float64, status *CircuitStatus) {
+	c.vd = nodeVoltage(solution, c.Nodes[0]) - nodeVoltage(solution, c.Nodes[1])
+	c.Voltage0 = c.vd
+	c.Voltage1 = c.vd
+	c.charge0 = c.curve.Integral(c.vd)
+	c.charge1 = c.charge0
+	c.history.reset(c.charge0)
+}
+
+// InitUICState - Start transient from .ic node voltages
+func (c *NonlinearCapacitor) InitUICState(voltages []float64, status *CircuitStatus) {
+	c.InitDCState(voltages, status)
+}
+
+func (c *NonlinearCapacitor) TruncateTimeStep(voltages []float64, status *CircuitStatus) float64 {
+	vd := nodeVoltage(voltages, c.Nodes[0]) - nodeVoltage(voltages, c.Nodes[1])
+	return c.history.truncate(c.curve.Integral(vd), status)
+}
+
+// Current from n1 to n2. Zero at DC
+func (c *NonlinearCapacitor) ProbeCurrent(voltages []float64, status *CircuitStatus) float64 {
+	if status.Mode != TransientAnalysis || status.TimeStep <= 0 {
+		return 0
+	}
+	return (c.charge0 - c.charge1) / status.TimeStep
+}
+
+func (c *NonlinearCapacitor) ProbeCurrentAC(voltages []complex128, status *CircuitStatus) complex128 {
+	vd := nodeVoltageAC(voltages, c.Nodes[0]) - nodeVoltageAC(voltages, c.Nodes[1])
+	omega := 2 * math.Pi * status.Frequency
+	return complex(0, omega*c.curve.Value(c.vd)) * vd
+}
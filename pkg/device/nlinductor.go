@@ -0,0 +1,167 @@
+package device
+
+import (
+	"math"
+
+	"github.com/edp1096/toy-spice/pkg/matrix"
+)
+
+// NonlinearInductor - Inductor with current dependent L(I), eg. saturating choke.
+// Transient is stamped on flux linkage λ(I) linearized at the latest Newton iterate.
+type NonlinearInductor struct {
+	BaseDevice
+	curve     *Curve
+	ik        float64 // Current of latest Newton iterate
+	Current0  float64 // Current of last accepted timepoint
+	Current1  float64 // Previous current
+	Voltage0  float64 // Current voltage
+	Voltage1  float64 // Previous voltage
+	flux0     float64 // Flux linkage of last accepted timepoint
+	flux1     float64 // Previous flux linkage
+	branchIdx int     // Branch index
+	history   stateHistory
+}
+
+var _ TimeDependent = (*NonlinearInductor)(nil)
+var _ NonLinearStorage = (*NonlinearInductor)(nil)
+var _ InductorComponent = (*NonlinearInductor)(nil)
+
+func NewNonlinearInductor(name string, nodeNames []string, curve *Curve) *NonlinearInductor {
+	return &NonlinearInductor{
+		BaseDevice: BaseDevice{
+			Name:      name,
+			Nodes:     make([]int, len(nodeNames)),
+			NodeNames: nodeNames,
+			Value:     curve.Value(0),
+		},
+		curve: curve,
+	}
+}
+
+func (l *NonlinearInductor) GetType() string { return "L" }
+
+// GetValue - Incremental inductance at latest current
+func (l *NonlinearInductor) GetValue() float64 { return l.curve.Value(l.ik) }
+
+func (l *NonlinearInductor) SetTimeStep(dt float64, status *CircuitStatus) { status.TimeStep = dt }
+
+func (l *NonlinearInductor) Stamp(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
+	n1, n2 := l.Nodes[0], l.Nodes[1]
+	bIdx := l.branchIdx
+
+	switch status.Mode {
+	case ACAnalysis:
+		return l.StampAC(matrix, status)
+
+	case OperatingPointAnalysis:
+		// Short at DC. Branch equation v1 - v2 = 0
+		if n1 != 0 {
+			matrix.AddElement(n1, bIdx, -1)
+			matrix.AddElement(bIdx, n1, -1)
+		}
+		if n2 != 0 {
+			matrix.AddElement(n2, bIdx, 1)
+			matrix.AddElement(bIdx, n2, 1)
+		}
+
+	default:
+		if n1 != 0 {
+			matrix.AddElement(n1, bIdx, -1)
+			matrix.AddElement(bIdx, n1, -1)
+		}
+		if n2 != 0 {
+			matrix.AddElement(n2, bIdx, 1)
+			matrix.AddElement(bIdx, n2, 1)
+		}
+
+		dt := status.TimeStep
+		if dt <= 0 {
+			dt = 1e-9
+		}
+
+		// v = (λ(i) - λ_prev)/dt, λ(i) ~ λ(ik) + L(ik)*(i - ik). Branch variable is -i.
+		// History is split in increments from last timepoint, which vanish at steady state without cancellation.
+		inductance := l.curve.Value(l.ik)
+		history := inductance*l.Current0 + inductance*(l.ik-l.Current0) - (l.curve.Integral(l.ik) - l.flux0)
+		matrix.AddElement(bIdx, bIdx, -inductance/dt)
+		matrix.AddRHS(bIdx, history/dt)
+	}
+
+	return nil
+}
+
+func (l *NonlinearInductor) StampAC(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
+	n1, n2 := l.Nodes[0], l.Nodes[1]
+	bIdx := l.branchIdx
+
+	// Branch equation v1 - v2 = jωL*i with small signal inductance at operating point
+	omega := 2 * math.Pi * status.Frequency
+	if n1 != 0 {
+		matrix.AddComplexElement(n1, bIdx, -1, 0)
+		matrix.AddComplexElement(bIdx, n1, -1, 0)
+	}
+	if n2 != 0 {
+		matrix.AddComplexElement(n2, bIdx, 1, 0)
+		matrix.AddComplexElement(bIdx, n2, 1, 0)
+	}
+	matrix.AddComplexElement(bIdx, bIdx, 0, -omega*l.curve.Value(l.ik))
+
+	return nil
+}
+
+// UpdateVoltages - Linearization point of next Newton iteration.
+// Changes at rounding noise level keep the point, so converged solution repeats exactly.
+func (l *NonlinearInductor) UpdateVoltages(voltages []float64) error {
+	if ik := -voltages[l.branchIdx]; math.Abs(ik-l.ik) > 1e-12*math.Abs(ik) {
+		l.ik = ik
+	}
+	return nil
+}
+
+func (l *NonlinearInductor) LoadState(voltages []float64, status *CircuitStatus) {
+	l.Current0 = -voltages[l.branchIdx]
+	l.ik = l.Current0
+}
+
+func (l *NonlinearInductor) UpdateState(voltages []float64, status *CircuitStatus) {
+	l.Voltage1 = l.Voltage0
+	l.Voltage0 = nodeVoltage(voltages, l.Nodes[0]) - nodeVoltage(voltages, l.Nodes[1])
+
+	l.Current1 = l.Current0
+	l.Current0 = -voltages[l.branchIdx] // Branch current from n1 to n2
+
+	l.flux1 = l.flux0
+	l.flux0 = l.curve.Integral(l.Current0)
+	l.history.accept(l.flux0, status.TimeStep)
+}
+
+// InitDCState - Start transient from DC operating point current
+func (l *NonlinearInductor) InitDCState(solution []float64, status *CircuitStatus) {
+	l.ik = -solution[l.branchIdx]
+	l.Current0 = l.ik
+	l.Current1 = l.ik
+	l.Voltage0 = 0
+	l.Voltage1 = 0
+	l.flux0 = l.curve.Integral(l.ik)
+	l.flux1 = l.flux0
+	l.history.reset(l.flux0)
+}
+
+func (l *NonlinearInductor) TruncateTimeStep(voltages []float64, status *CircuitStatus) float64 {
+	return l.history.truncate(l.curve.Integral(-voltages[l.branchIdx]), status)
+}
+
+func (l *NonlinearInductor) GetCurrent() float64 { return l.Current0 }
+
+func (l *NonlinearInductor) GetPreviousCurrent() float64 { return l.Current1 }
+
+func (l *NonlinearInductor) GetVoltage() float64 { return l.Voltage0 }
+
+func (l *NonlinearInductor) GetPreviousVoltage() float64 { return l.Voltage1 }
+
+func (l *NonlinearInductor) BranchIndex() int { return l.branchIdx }
+
+func (l *NonlinearInductor) SetBranchIndex(idx int) { l.branchIdx = idx }
+
+// BranchSign - Branch variable flows n2 to n1
+func (l *NonlinearInductor) BranchSign() float64 { return -1 }
@@ -0,0 +1,105 @@
+package device
+
+import (
+	"github.com/edp1096/toy-spice/pkg/matrix"
+)
+
+// Port - S-parameter port. Reference impedance Z0 terminating n+ and n-.
+// Excited port is driven by 1V source behind Z0, stamped as Norton equivalent.
+type Port struct {
+	BaseDevice
+	Number  int     // Port number
+	Z0      float64 // Reference impedance
+	excited bool
+}
+
+func NewPort(name string, nodeNames []string, number int, z0 float64) *Port {
+	return &Port{
+		BaseDevice: BaseDevice{
+			Name:      name,
+			Nodes:     make([]int, len(nodeNames)),
+			NodeNames: nodeNames,
+			Value:     z0,
+		},
+		Number: number,
+		Z0:     z0,
+	}
+}
+
+func (p *Port) GetType() string { return "P" }
+
+// SetExcited - Drive port by 1V source in AC. Other ports are only terminated
+func (p *Port) SetExcited(excited bool) { p.excited = excited }
+
+func (p *Port) Stamp(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
+	if status.Mode == ACAnalysis {
+		return p.StampAC(matrix, status)
+	}
+
+	n1, n2 := p.Nodes[0], p.Nodes[1]
+	g := 1.0 / p.Z0
+
+	// OP/Transient - Termination only
+	if n1 != 0 {
+		matrix.AddElement(n1, n1, g)
+		if n2 != 0 {
+			matrix.AddElement(n1, n2, -g)
+		}
+	}
+	if n2 != 0 {
+		if n1 != 0 {
+			matrix.AddElement(n2, n1, -g)
+		}
+		matrix.AddElement(n2, n2, g)
+	}
+
+	return nil
+}
+
+func (p *Port) StampAC(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
+	n1, n2 := p.Nodes[0], p.Nodes[1]
+	g := 1.0 / p.Z0
+
+	if n1 != 0 {
+		matrix.AddComplexElement(n1, n1, g, 0)
+		if n2 != 0 {
+			matrix.AddComplexElement(n1, n2, -g, 0)
+		}
+	}
+	if n2 != 0 {
+		if n1 != 0 {
+			matrix.AddComplexElement(n2, n1, -g, 0)
+		}
+		matrix.AddComplexElement(n2, n2, g, 0)
+	}
+
+	// Norton current of 1V source flows into n+
+	if p.excited {
+		if n1 != 0 {
+			matrix.AddComplexRHS(n1, g, 0)
+		}
+		if n2 != 0 {
+			matrix.AddComplexRHS(n2, -g, 0)
+		}
+	}
+	return nil
+}
+
+// PortVoltageAC - Complex voltage across port
+func (p *Port) PortVoltageAC(voltages []complex128) complex128 {
+	return nodeVoltageAC(voltages, p.Nodes[0]) - nodeVoltageAC(voltages, p.Nodes[1])
+}
+
+// Current from n1 to n2 through termination
+func (p *Port) ProbeCurrent(voltages []float64, status *CircuitStatus) float64 {
+	return (nodeVoltage(voltages, p.Nodes[0]) - nodeVoltage(voltages, p.Nodes[1])) / p.Z0
+}
+
+// Current from n1 to n2 through termination and source
+func (p *Port) ProbeCurrentAC(voltages []complex128, status *CircuitStatus) complex128 {
+	current := p.PortVoltageAC(voltages) / complex(p.Z0, 0)
+	if p.excited {
+		current -= complex(1/p.Z0, 0)
+	}
+	return current
+}
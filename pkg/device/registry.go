@@ -0,0 +1,76 @@
+package device
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Factory - Custom device type added by Register, instantiated by netlist parser by element prefix.
+// AC analyses require devices implementing ACElement
+type Factory struct {
+	Nodes     int    // Terminals of element, fields after element name
+	Branch    bool   // Branch current unknown in MNA, device must implement BranchDevice
+	ModelType string // Uppercase .model type of device, accepted with any parameters. Empty is without model
+	New       func(elem Element) (Device, error)
+}
+
+// Element - Netlist element of custom device
+type Element struct {
+	Name   string
+	Nodes  []string          // Node names
+	Args   []string          // Fields after nodes other than name=value. eg. value or model name
+	Params map[string]string // name=value parameters by lowercase name
+	Model  *ModelParam       // .model of first of Args, nil when not defined
+}
+
+// Element prefixes of built in devices, not registrable
+const builtinPrefixes = "RLCKVIEGADQMSWPY"
+
+var registry = struct {
+	sync.RWMutex
+	factories map[string]Factory
+}{factories: make(map[string]Factory)}
+
+// Register - Custom device type of element prefix letter. eg. Register("X", Factory{Nodes: 2, New: newMyDevice})
+func Register(prefix string, f Factory) error {
+	prefix = strings.ToUpper(prefix)
+	if len(prefix) != 1 || prefix[0] < 'A' || prefix[0] > 'Z' {
+		return fmt.Errorf("device prefix must be one letter: %q", prefix)
+	}
+	if strings.Contains(builtinPrefixes, prefix) {
+		return fmt.Errorf("device prefix %s is built in", prefix)
+	}
+	if f.New == nil || f.Nodes < 1 {
+		return fmt.Errorf("device prefix %s requires factory and at least one node", prefix)
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+	if _, exists := registry.factories[prefix]; exists {
+		return fmt.Errorf("device prefix %s already registered", prefix)
+	}
+	f.ModelType = strings.ToUpper(f.ModelType)
+	registry.factories[prefix] = f
+	return nil
+}
+
+// Registered - Custom device type of element prefix, case insensitive
+func Registered(prefix string) (Factory, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	f, ok := registry.factories[strings.ToUpper(prefix)]
+	return f, ok
+}
+
+// RegisteredModelType - Model type of any custom device
+func RegisteredModelType(modelType string) bool {
+	registry.RLock()
+	defer registry.RUnlock()
+	for _, f := range registry.factories {
+		if f.ModelType != "" && strings.EqualFold(f.ModelType, modelType) {
+			return true
+		}
+	}
+	return false
+}
@@ -3,14 +3,26 @@ package device
 import (
 	"fmt"
 
+	"github.com/edp1096/toy-spice/internal/consts"
 	"github.com/edp1096/toy-spice/pkg/matrix"
 )
 
 type Resistor struct {
 	BaseDevice
-	Tc1  float64
-	Tc2  float64
-	Tnom float64
+	Tc1   float64
+	Tc2   float64
+	Tnom  float64
+	Temp  float64 // Instance temperature. Circuit temperature if zero
+	Dtemp float64 // Instance temperature offset from circuit temperature (K)
+
+	thermalPort
+
+	// Semiconductor resistor model
+	Rsh    float64 // Sheet resistance
+	Defw   float64 // Default width
+	Narrow float64 // Narrowing due to side etching
+	L      float64 // Length
+	W      float64 // Width
 }
 
 func NewResistor(name string, nodeNames []string, value float64) *Resistor {
@@ -24,56 +36,141 @@ func NewResistor(name string, nodeNames []string, value float64) *Resistor {
 		Tc1:  0.0,
 		Tc2:  0.0,
 		Tnom: 300.15,
+		Defw: 10e-6,
 	}
 }
 
 func (r *Resistor) GetType() string { return "R" }
+func (r *Resistor) LinearStamp()    {}
+
+func (r *Resistor) SetModelParameters(params map[string]float64) {
+	paramsSet := map[string]*float64{
+		"rsh":    &r.Rsh,    // Rsh (Sheet resistance)
+		"defw":   &r.Defw,   // Defw (Default width)
+		"narrow": &r.Narrow, // Narrow (Narrowing due to side etching)
+		"tc1":    &r.Tc1,    // Tc1 (First order temp. coefficient)
+		"tc2":    &r.Tc2,    // Tc2 (Second order temp. coefficient)
+	}
+
+	for key, param := range paramsSet {
+		if value, ok := params[key]; ok {
+			*param = value
+		}
+	}
+	if tnom, ok := params["tnom"]; ok {
+		r.Tnom = tnom + consts.KELVIN // degC -> K
+	}
+}
+
+// SetInstanceParameters - Temperature coefficients, temperature and geometry. Value is R = Rsh*(L-Narrow)/(W-Narrow) unless given on instance
+func (r *Resistor) SetInstanceParameters(params map[string]float64) {
+	if tc1, ok := params["tc1"]; ok {
+		r.Tc1 = tc1
+	}
+	if tc2, ok := params["tc2"]; ok {
+		r.Tc2 = tc2
+	}
+	if tnom, ok := params["tnom"]; ok {
+		r.Tnom = tnom + consts.KELVIN // degC -> K
+	}
+	if temp, ok := params["temp"]; ok {
+		r.Temp = temp + consts.KELVIN // degC -> K
+	}
+	if dtemp, ok := params["dtemp"]; ok {
+		r.Dtemp = dtemp
+	}
+	r.setSelfHeating(params)
+	if l, ok := params["l"]; ok {
+		r.L = l
+	}
+	if w, ok := params["w"]; ok {
+		r.W = w
+	}
+
+	if r.Value == 0 && r.Rsh > 0 && r.L > 0 {
+		width := r.W
+		if width <= 0 {
+			width = r.Defw
+		}
+		r.Value = r.Rsh * (r.L - r.Narrow) / (width - r.Narrow)
+	}
+}
 
 func (r *Resistor) Stamp(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
 	if len(r.Nodes) != 2 {
 		return fmt.Errorf("resistor %s: requires exactly 2 nodes", r.Name)
 	}
+	if status.Mode == ACAnalysis {
+		return r.StampAC(matrix, status)
+	}
 
 	n1, n2 := r.Nodes[0], r.Nodes[1]
 
 	// g := 1.0 / r.Value // Conductance. G = 1/R
-	g := 1.0 / r.temperatureAdjustedValue(status.Temp)
+	g := 1.0 / r.temperatureAdjustedValue(r.temperature(status))
 
-	switch status.Mode {
-	case ACAnalysis:
-		// AC
-		if n1 != 0 {
-			matrix.AddComplexElement(n1, n1, g, 0)
-			if n2 != 0 {
-				matrix.AddComplexElement(n1, n2, -g, 0)
-			}
-		}
+	// OP/Transient
+	r.stampPower(matrix, status)
+	if n1 != 0 {
+		matrix.AddElement(n1, n1, g)
 		if n2 != 0 {
-			if n1 != 0 {
-				matrix.AddComplexElement(n2, n1, -g, 0)
-			}
-			matrix.AddComplexElement(n2, n2, g, 0)
+			matrix.AddElement(n1, n2, -g)
 		}
-
-	default:
-		// OP/Transient
+	}
+	if n2 != 0 {
 		if n1 != 0 {
-			matrix.AddElement(n1, n1, g)
-			if n2 != 0 {
-				matrix.AddElement(n1, n2, -g)
-			}
+			matrix.AddElement(n2, n1, -g)
 		}
+		matrix.AddElement(n2, n2, g)
+	}
+
+	return nil
+}
+
+func (r *Resistor) StampAC(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
+	n1, n2 := r.Nodes[0], r.Nodes[1]
+	g := 1.0 / r.temperatureAdjustedValue(r.temperature(status))
+
+	if n1 != 0 {
+		matrix.AddComplexElement(n1, n1, g, 0)
 		if n2 != 0 {
-			if n1 != 0 {
-				matrix.AddElement(n2, n1, -g)
-			}
-			matrix.AddElement(n2, n2, g)
+			matrix.AddComplexElement(n1, n2, -g, 0)
+		}
+	}
+	if n2 != 0 {
+		if n1 != 0 {
+			matrix.AddComplexElement(n2, n1, -g, 0)
 		}
+		matrix.AddComplexElement(n2, n2, g, 0)
 	}
 
 	return nil
 }
 
+// Current from n1 to n2
+func (r *Resistor) ProbeCurrent(voltages []float64, status *CircuitStatus) float64 {
+	vd := nodeVoltage(voltages, r.Nodes[0]) - nodeVoltage(voltages, r.Nodes[1])
+	return vd / r.temperatureAdjustedValue(r.temperature(status))
+}
+
+func (r *Resistor) ProbeCurrentAC(voltages []complex128, status *CircuitStatus) complex128 {
+	vd := nodeVoltageAC(voltages, r.Nodes[0]) - nodeVoltageAC(voltages, r.Nodes[1])
+	return vd / complex(r.temperatureAdjustedValue(r.temperature(status)), 0)
+}
+
+// Instance temperature if set, otherwise circuit temperature and dtemp. Thermal node or self-heating adds its rise
+func (r *Resistor) temperature(status *CircuitStatus) float64 {
+	if r.Temp > 0 {
+		return r.Temp + r.rise
+	}
+	return status.Temp + r.Dtemp + r.rise
+}
+
+func (r *Resistor) UpdateThermal(voltages []float64, dt float64, status *CircuitStatus) {
+	vd := nodeVoltage(voltages, r.Nodes[0]) - nodeVoltage(voltages, r.Nodes[1])
+	r.accept(voltages, vd*r.ProbeCurrent(voltages, status), dt)
+}
+
 func (r *Resistor) temperatureAdjustedValue(temp float64) float64 {
 	dt := temp - r.Tnom
 	factor := 1.0 + r.Tc1*dt + r.Tc2*dt*dt
@@ -0,0 +1,272 @@
+package device
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/edp1096/toy-spice/pkg/matrix"
+)
+
+// Switch - Relay contact between n+ and n- with hysteresis.
+// Closes when control exceeds Vt+Vh, opens when it falls below Vt-Vh, otherwise keeps state.
+// Control is v(nc+) - v(nc-) of S switch or current of controlling branch device of W switch.
+// In transient, state changes only at accepted timepoints and persists between timesteps.
+// With Bounce > 0, contact opens and closes Bounce times during TBounce after closing.
+type Switch struct {
+	BaseDevice
+	Vt, Vh, Ron, Roff float64
+	Bounce            int
+	TBounce           float64
+
+	controlName string
+	control     BranchDevice
+
+	on        bool    // Accepted state
+	closedAt  float64 // Time of last closing
+	bouncing  bool
+	transient bool
+}
+
+var _ TimeDependent = (*Switch)(nil)
+var _ NonLinear = (*Switch)(nil)
+var _ CurrentProbe = (*Switch)(nil)
+var _ StepLimiter = (*Switch)(nil)
+var _ ParameterValidator = (*Switch)(nil)
+var _ CurrentControlled = (*Switch)(nil)
+
+func newSwitch(name string, nodeNames []string) *Switch {
+	return &Switch{
+		BaseDevice: BaseDevice{
+			Name:      name,
+			Nodes:     make([]int, len(nodeNames)),
+			NodeNames: nodeNames,
+		},
+		Ron:  1,
+		Roff: 1e12,
+	}
+}
+
+// NewVoltageSwitch - S switch. Nodes are n+, n-, nc+, nc-
+func NewVoltageSwitch(name string, nodeNames []string) *Switch {
+	return newSwitch(name, nodeNames)
+}
+
+// NewCurrentSwitch - W switch. Nodes are n+, n-, control is current of named voltage source or inductor
+func NewCurrentSwitch(name string, nodeNames []string, controlName string) *Switch {
+	s := newSwitch(name, nodeNames)
+	s.controlName = controlName
+	return s
+}
+
+func (s *Switch) GetType() string {
+	if s.controlName != "" {
+		return "W"
+	}
+	return "S"
+}
+
+// SetModelParameters - SW model of vt, vh or CSW model of it, ih. ron, roff, bounce, tbounce of both
+func (s *Switch) SetModelParameters(params map[string]float64) {
+	set := map[string]*float64{"vt": &s.Vt, "vh": &s.Vh, "it": &s.Vt, "ih": &s.Vh, "ron": &s.Ron, "roff": &s.Roff, "tbounce": &s.TBounce}
+	for name, field := range set {
+		if value, ok := params[name]; ok {
+			*field = value
+		}
+	}
+	if value, ok := params["bounce"]; ok {
+		s.Bounce = int(value)
+	}
+}
+
+// SetInitialState - ON or OFF of instance, state before operating point
+func (s *Switch) SetInitialState(on bool) { s.on = on }
+
+func (s *Switch) ControlName() string { return s.controlName }
+
+func (s *Switch) SetControl(branch BranchDevice) { s.control = branch }
+
+// ValidateParameters - Contact resistances and bounce of model
+func (s *Switch) ValidateParameters() []ParameterIssue {
+	c := paramCheck{device: s.Name}
+	c.positive("ron", s.Ron)
+	c.positive("roff", s.Roff)
+	c.nonNegative("vh", s.Vh)
+	c.nonNegative("bounce", float64(s.Bounce))
+	c.nonNegative("tbounce", s.TBounce)
+	c.check("roff", s.Roff, s.Roff > s.Ron, "> ron", false)
+	return c.issues
+}
+
+func (s *Switch) validate() error {
+	if s.controlName != "" {
+		if len(s.Nodes) != 2 {
+			return fmt.Errorf("switch %s: requires 2 nodes and controlling device", s.Name)
+		}
+		if s.control == nil {
+			return fmt.Errorf("switch %s: controlling device %s not set", s.Name, s.controlName)
+		}
+		return nil
+	}
+	if len(s.Nodes) != 4 {
+		return fmt.Errorf("switch %s: requires 4 nodes", s.Name)
+	}
+	return nil
+}
+
+// controlValue - Control voltage or current
+func (s *Switch) controlValue(voltages []float64) float64 {
+	if s.control != nil {
+		return s.control.BranchSign() * voltages[s.control.BranchIndex()]
+	}
+	return nodeVoltage(voltages, s.Nodes[2]) - nodeVoltage(voltages, s.Nodes[3])
+}
+
+// next - State after control value
+func (s *Switch) next(control float64) bool {
+	if control > s.Vt+s.Vh {
+		return true
+	}
+	if control < s.Vt-s.Vh {
+		return false
+	}
+	return s.on
+}
+
+// bounceInterval - Duration of each open or closed interval of bounce
+func (s *Switch) bounceInterval() float64 {
+	return s.TBounce / float64(2*s.Bounce)
+}
+
+// closed - Contact state at time. Contact is open in odd intervals of bounce
+func (s *Switch) closed(time float64) bool {
+	if !s.on {
+		return false
+	}
+	if !s.transient || !s.bouncing {
+		return true
+	}
+	interval := int((time - s.closedAt) / s.bounceInterval())
+	return interval >= 2*s.Bounce || interval%2 == 0
+}
+
+func (s *Switch) conductance(status *CircuitStatus) float64 {
+	if s.closed(status.Time) {
+		return 1 / s.Ron
+	}
+	return 1 / s.Roff
+}
+
+func (s *Switch) Stamp(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
+	if status.Mode == ACAnalysis {
+		return s.StampAC(matrix, status)
+	}
+	err := s.validate()
+	if err != nil {
+		return err
+	}
+	s.transient = status.Mode == TransientAnalysis
+
+	g := s.conductance(status)
+	n1, n2 := s.Nodes[0], s.Nodes[1]
+	if n1 != 0 {
+		matrix.AddElement(n1, n1, g)
+		if n2 != 0 {
+			matrix.AddElement(n1, n2, -g)
+		}
+	}
+	if n2 != 0 {
+		if n1 != 0 {
+			matrix.AddElement(n2, n1, -g)
+		}
+		matrix.AddElement(n2, n2, g)
+	}
+	return nil
+}
+
+func (s *Switch) StampAC(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
+	err := s.validate()
+	if err != nil {
+		return err
+	}
+	s.transient = false
+
+	g := s.conductance(status)
+	n1, n2 := s.Nodes[0], s.Nodes[1]
+	if n1 != 0 {
+		matrix.AddComplexElement(n1, n1, g, 0)
+		if n2 != 0 {
+			matrix.AddComplexElement(n1, n2, -g, 0)
+		}
+	}
+	if n2 != 0 {
+		if n1 != 0 {
+			matrix.AddComplexElement(n2, n1, -g, 0)
+		}
+		matrix.AddComplexElement(n2, n2, g, 0)
+	}
+	return nil
+}
+
+// LoadConductance - Contact is stamped in Stamp by present state
+func (s *Switch) LoadConductance(matrix matrix.DeviceMatrix) error { return nil }
+
+func (s *Switch) LoadCurrent(matrix matrix.DeviceMatrix) error { return nil }
+
+// UpdateVoltages - Operating point and DC sweep follow control at Newton iterate.
+// Transient state changes at accepted timepoints only.
+func (s *Switch) UpdateVoltages(voltages []float64) error {
+	err := s.validate()
+	if err != nil {
+		return err
+	}
+	if !s.transient {
+		s.on = s.next(s.controlValue(voltages))
+	}
+	return nil
+}
+
+func (s *Switch) ProbeCurrent(voltages []float64, status *CircuitStatus) float64 {
+	v := nodeVoltage(voltages, s.Nodes[0]) - nodeVoltage(voltages, s.Nodes[1])
+	return s.conductance(status) * v
+}
+
+func (s *Switch) SetTimeStep(dt float64, status *CircuitStatus) { status.TimeStep = dt }
+
+func (s *Switch) LoadState(voltages []float64, status *CircuitStatus) {}
+
+// UpdateState - Accept state at end of timestep. Closing starts bounce
+func (s *Switch) UpdateState(voltages []float64, status *CircuitStatus) {
+	if s.bouncing && status.Time >= s.closedAt+s.TBounce {
+		s.bouncing = false
+	}
+	on := s.next(s.controlValue(voltages))
+	if on && !s.on {
+		s.closedAt = status.Time
+		s.bouncing = s.Bounce > 0 && s.TBounce > 0
+	}
+	s.on = on
+}
+
+// TruncateTimeStep - Contact has no charge state, bounce is limited by MaxTimeStep
+func (s *Switch) TruncateTimeStep(voltages []float64, status *CircuitStatus) float64 {
+	return math.Inf(1)
+}
+
+// MaxTimeStep - Resolve open and closed intervals of bounce
+func (s *Switch) MaxTimeStep() float64 {
+	if !s.bouncing {
+		return 0
+	}
+	return s.bounceInterval() / 4
+}
+
+// InitDCState - State from operating point control, contact starts without bounce
+func (s *Switch) InitDCState(solution []float64, status *CircuitStatus) {
+	s.on = s.next(s.controlValue(solution))
+	s.bouncing = false
+}
+
+// InitUICState - Without operating point, contact starts in ON or OFF state of instance
+func (s *Switch) InitUICState(voltages []float64, status *CircuitStatus) {
+	s.bouncing = false
+}
@@ -0,0 +1,69 @@
+package device
+
+import (
+	"math"
+
+	"github.com/edp1096/toy-spice/pkg/matrix"
+)
+
+// ThermalDevice - Power device coupled to thermal node of netlist.
+// Voltage of thermal node is temperature rise in K over device temperature, and dissipation in W is
+// injected into it as current, so thermal resistance and capacitance are R and C to ground.
+// Without thermal node, instance rth and cth give device its own first order thermal model.
+type ThermalDevice interface {
+	SetThermalNode(node int)
+	// HasThermalState - Coupled to thermal node or self-heating
+	HasThermalState() bool
+	// UpdateThermal - Dissipation and temperature rise at accepted solution. dt is 0 at initial point
+	UpdateThermal(voltages []float64, dt float64, status *CircuitStatus)
+}
+
+// thermalPort - Electro-thermal coupling by timestep. Dissipation of last accepted timepoint is
+// injected during next timestep, and temperature rise of thermal node is added to device temperature.
+// Operating point is solved at device temperature, it heats up in transient.
+type thermalPort struct {
+	thermalNode int
+	rth, cth    float64 // Self-heating thermal resistance (K/W) and capacitance (J/K)
+	power       float64 // Dissipation at last accepted timepoint
+	rise        float64 // Temperature rise at last accepted timepoint
+}
+
+func (t *thermalPort) SetThermalNode(node int) { t.thermalNode = node }
+
+func (t *thermalPort) HasThermalState() bool { return t.thermalNode != 0 || t.rth > 0 }
+
+// setSelfHeating - rth and cth of instance parameters
+func (t *thermalPort) setSelfHeating(params map[string]float64) {
+	if rth, ok := params["rth"]; ok && rth > 0 {
+		t.rth = rth
+	}
+	if cth, ok := params["cth"]; ok && cth > 0 {
+		t.cth = cth
+	}
+}
+
+// stampPower - Dissipation as current into thermal node
+func (t *thermalPort) stampPower(matrix matrix.DeviceMatrix, status *CircuitStatus) {
+	if t.thermalNode != 0 && status.Mode == TransientAnalysis {
+		matrix.AddRHS(t.thermalNode, t.power)
+	}
+}
+
+// accept - Temperature rise at end of timestep dt and dissipation for next timestep.
+// Self-heating rise relaxes to power*rth with time constant rth*cth, exactly for power held during step.
+func (t *thermalPort) accept(voltages []float64, power, dt float64) {
+	switch {
+	case t.thermalNode != 0:
+		t.rise = voltages[t.thermalNode]
+	case t.rth <= 0:
+		return
+	case dt <= 0:
+		t.rise = 0
+	case t.cth > 0:
+		steady := t.power * t.rth
+		t.rise = steady + (t.rise-steady)*math.Exp(-dt/(t.rth*t.cth))
+	default:
+		t.rise = t.power * t.rth
+	}
+	t.power = power
+}
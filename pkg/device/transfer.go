@@ -0,0 +1,359 @@
+package device
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"sort"
+)
+
+// Transfer - Frequency dependent transfer function of controlled source. y = H(s) u
+type Transfer interface {
+	DCGain() float64
+	Response(frequency float64) complex128
+
+	// Companion - Output at end of timestep is gain*u + offset, u is input at end of timestep
+	Companion(time, dt float64) (gain, offset float64)
+	// Reset - Steady state of constant input u, eg. operating point
+	Reset(u float64)
+	// Accept - Input u at end of accepted timestep
+	Accept(time, dt, u float64)
+}
+
+// laplaceTransfer - H(s) = N(s)/D(s) by coefficients in ascending powers of s.
+// Transient integrates controllable canonical state space by backward Euler.
+type laplaceTransfer struct {
+	num, den []float64
+
+	// x' = A x + B u, y = C x + D u. A is companion matrix of normalized den, B is last unit vector
+	a []float64 // Normalized den a0..a(n-1)
+	c []float64
+	d float64
+	x []float64
+
+	// Backward Euler of last dt. x(t+dt) = mx + gu*u
+	dt float64
+	mx []float64
+	gu []float64
+}
+
+// NewLaplaceTransfer - Proper rational transfer function. eg. num (1), den (1 1e-3) is 1/(1+s*1e-3)
+func NewLaplaceTransfer(num, den []float64) (Transfer, error) {
+	for len(den) > 0 && den[len(den)-1] == 0 {
+		den = den[:len(den)-1]
+	}
+	for len(num) > 0 && num[len(num)-1] == 0 {
+		num = num[:len(num)-1]
+	}
+	if len(den) == 0 {
+		return nil, fmt.Errorf("laplace denominator is zero")
+	}
+	if len(num) > len(den) {
+		return nil, fmt.Errorf("laplace transfer function must be proper, numerator order %d exceeds denominator order %d", len(num)-1, len(den)-1)
+	}
+	if den[0] == 0 {
+		return nil, fmt.Errorf("laplace denominator has pole at s=0, no DC operating point")
+	}
+
+	n := len(den) - 1
+	lead := den[n]
+	t := &laplaceTransfer{num: num, den: den, a: make([]float64, n), c: make([]float64, n), x: make([]float64, n)}
+	for i := range n {
+		t.a[i] = den[i] / lead
+	}
+	b := make([]float64, n+1)
+	for i, v := range num {
+		b[i] = v / lead
+	}
+	t.d = b[n]
+	for i := range n {
+		t.c[i] = b[i] - t.d*t.a[i]
+	}
+	return t, nil
+}
+
+func (t *laplaceTransfer) DCGain() float64 {
+	sum := 0.0
+	if len(t.num) > 0 {
+		sum = t.num[0]
+	}
+	return sum / t.den[0]
+}
+
+func (t *laplaceTransfer) Response(frequency float64) complex128 {
+	s := complex(0, 2*math.Pi*frequency)
+	return polyval(t.num, s) / polyval(t.den, s)
+}
+
+func polyval(coeffs []float64, s complex128) complex128 {
+	value := complex(0, 0)
+	for i := len(coeffs) - 1; i >= 0; i-- {
+		value = value*s + complex(coeffs[i], 0)
+	}
+	return value
+}
+
+func (t *laplaceTransfer) Reset(u float64) {
+	clear(t.x)
+	if len(t.x) > 0 {
+		t.x[0] = u / t.a[0]
+	}
+}
+
+// discretize - (I - dt*A) x(t+dt) = x(t) + dt*B*u
+func (t *laplaceTransfer) discretize(dt float64) {
+	if dt == t.dt && t.mx != nil {
+		return
+	}
+	n := len(t.x)
+	m := make([][]float64, n)
+	for i := range n {
+		m[i] = make([]float64, n)
+		m[i][i] = 1
+		if i < n-1 {
+			m[i][i+1] = -dt
+		}
+	}
+	for j := range n {
+		m[n-1][j] += dt * t.a[j]
+	}
+
+	// Solve for unit vectors of previous state and input
+	t.mx = make([]float64, n*n)
+	for j := range n {
+		e := make([]float64, n)
+		e[j] = 1
+		col := solveSmall(m, e)
+		for i := range n {
+			t.mx[i*n+j] = col[i]
+		}
+	}
+	e := make([]float64, n)
+	e[n-1] = dt
+	t.gu = solveSmall(m, e)
+	t.dt = dt
+}
+
+func (t *laplaceTransfer) next(u float64) []float64 {
+	n := len(t.x)
+	x := make([]float64, n)
+	for i := range n {
+		x[i] = t.gu[i] * u
+		for j := range n {
+			x[i] += t.mx[i*n+j] * t.x[j]
+		}
+	}
+	return x
+}
+
+func (t *laplaceTransfer) Companion(time, dt float64) (float64, float64) {
+	if len(t.x) == 0 {
+		return t.d, 0
+	}
+	t.discretize(dt)
+	gain, offset := t.d, 0.0
+	for i, x := range t.next(0) {
+		offset += t.c[i] * x
+	}
+	for i, g := range t.gu {
+		gain += t.c[i] * g
+	}
+	return gain, offset
+}
+
+func (t *laplaceTransfer) Accept(time, dt, u float64) {
+	if len(t.x) == 0 {
+		return
+	}
+	t.discretize(dt)
+	t.x = t.next(u)
+}
+
+// solveSmall - Gaussian elimination with partial pivoting of small dense system
+func solveSmall(m [][]float64, rhs []float64) []float64 {
+	n := len(rhs)
+	a := make([][]float64, n)
+	for i := range n {
+		a[i] = append(append([]float64{}, m[i]...), rhs[i])
+	}
+	for k := range n {
+		pivot := k
+		for i := k + 1; i < n; i++ {
+			if math.Abs(a[i][k]) > math.Abs(a[pivot][k]) {
+				pivot = i
+			}
+		}
+		a[k], a[pivot] = a[pivot], a[k]
+		for i := k + 1; i < n; i++ {
+			f := a[i][k] / a[k][k]
+			for j := k; j <= n; j++ {
+				a[i][j] -= f * a[k][j]
+			}
+		}
+	}
+	x := make([]float64, n)
+	for i := n - 1; i >= 0; i-- {
+		sum := a[i][n]
+		for j := i + 1; j < n; j++ {
+			sum -= a[i][j] * x[j]
+		}
+		x[i] = sum / a[i][i]
+	}
+	return x
+}
+
+// Maximum number of taps of FIR approximation of frequency table in transient
+const maxTableTaps = 4096
+
+// tableTransfer - Frequency table of magnitude dB and phase degree, interpolated on log frequency.
+// Transient convolves input history with impulse response of table, so table should describe causal response.
+type tableTransfer struct {
+	freqs, mags, phases []float64 // mags are linear, phases are radian unwrapped as given
+
+	taps    []float64 // Impulse response sampled at tapStep. taps[0] weights present input
+	tapStep float64
+
+	// Accepted input history. Before first point input is u0
+	times, inputs []float64
+	u0            float64
+}
+
+// NewTableTransfer - Table of (frequency, magnitude dB, phase degree) points
+func NewTableTransfer(freqs, magsDB, phasesDeg []float64) (Transfer, error) {
+	n := len(freqs)
+	if n == 0 || len(magsDB) != n || len(phasesDeg) != n {
+		return nil, fmt.Errorf("frequency table needs triples of frequency, magnitude dB and phase")
+	}
+
+	idx := make([]int, n)
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.Slice(idx, func(a, b int) bool { return freqs[idx[a]] < freqs[idx[b]] })
+
+	t := &tableTransfer{}
+	for i, j := range idx {
+		if freqs[j] < 0 || (i > 0 && freqs[j] == t.freqs[i-1]) {
+			return nil, fmt.Errorf("invalid or duplicate frequency %g in table", freqs[j])
+		}
+		t.freqs = append(t.freqs, freqs[j])
+		t.mags = append(t.mags, math.Pow(10, magsDB[j]/20))
+		t.phases = append(t.phases, phasesDeg[j]*math.Pi/180)
+	}
+	t.impulseResponse()
+	return t, nil
+}
+
+func (t *tableTransfer) Response(frequency float64) complex128 {
+	n := len(t.freqs)
+	if frequency <= t.freqs[0] {
+		return cmplx.Rect(t.mags[0], t.phases[0])
+	}
+	if frequency >= t.freqs[n-1] {
+		return cmplx.Rect(t.mags[n-1], t.phases[n-1])
+	}
+
+	i := sort.SearchFloat64s(t.freqs, frequency)
+	f1, f2 := t.freqs[i-1], t.freqs[i]
+	frac := (frequency - f1) / (f2 - f1)
+	if f1 > 0 {
+		frac = math.Log(frequency/f1) / math.Log(f2/f1)
+	}
+	mag := t.mags[i-1] * math.Pow(t.mags[i]/t.mags[i-1], frac) // Linear in dB
+	phase := t.phases[i-1] + frac*(t.phases[i]-t.phases[i-1])
+	return cmplx.Rect(mag, phase)
+}
+
+// DCGain - Real part of lowest table frequency
+func (t *tableTransfer) DCGain() float64 {
+	return real(t.Response(0))
+}
+
+// impulseResponse - Inverse DFT of table on uniform grid up to highest table frequency.
+// Grid step is lowest nonzero table frequency, so response is as long as slowest tabulated dynamics,
+// unless it needs more than maxTableTaps. Sum of taps is DC gain.
+func (t *tableTransfer) impulseResponse() {
+	fMax := t.freqs[len(t.freqs)-1]
+	if fMax <= 0 {
+		t.taps = []float64{t.DCGain()}
+		return
+	}
+
+	df := 2 * fMax / maxTableTaps
+	for _, f := range t.freqs {
+		if f > 0 {
+			df = math.Max(df, f)
+			break
+		}
+	}
+	n := 2 * int(math.Ceil(fMax/df))
+	t.tapStep = 1 / (float64(n) * df)
+	spectrum := make([]complex128, n/2+1)
+	for k := range spectrum {
+		spectrum[k] = t.Response(float64(k) * df)
+	}
+	// Nyquist bin of real signal
+	spectrum[n/2] = complex(real(spectrum[n/2]), 0)
+
+	// Twiddle factors by index k*i mod n
+	twiddle := make([]complex128, n)
+	for k := range twiddle {
+		twiddle[k] = cmplx.Exp(complex(0, 2*math.Pi*float64(k)/float64(n)))
+	}
+
+	t.taps = make([]float64, n)
+	for i := range n {
+		sum := real(spectrum[0]) + real(spectrum[n/2])*real(twiddle[(n/2*i)%n])
+		for k := 1; k < n/2; k++ {
+			sum += 2 * real(spectrum[k]*twiddle[(k*i)%n])
+		}
+		t.taps[i] = sum / float64(n)
+	}
+}
+
+// input - Input at time by linear interpolation of history
+func (t *tableTransfer) input(time float64) float64 {
+	n := len(t.times)
+	if n == 0 || time <= t.times[0] {
+		return t.u0
+	}
+	if time >= t.times[n-1] {
+		return t.inputs[n-1]
+	}
+	i := sort.SearchFloat64s(t.times, time)
+	frac := (time - t.times[i-1]) / (t.times[i] - t.times[i-1])
+	return t.inputs[i-1] + frac*(t.inputs[i]-t.inputs[i-1])
+}
+
+func (t *tableTransfer) Companion(time, dt float64) (float64, float64) {
+	offset := 0.0
+	for i := 1; i < len(t.taps); i++ {
+		offset += t.taps[i] * t.input(time-float64(i)*t.tapStep)
+	}
+	return t.taps[0], offset
+}
+
+func (t *tableTransfer) Reset(u float64) {
+	t.u0 = u
+	t.times, t.inputs = t.times[:0], t.inputs[:0]
+}
+
+func (t *tableTransfer) Accept(time, dt, u float64) {
+	if len(t.times) == 0 {
+		t.times = append(t.times, time-dt)
+		t.inputs = append(t.inputs, t.u0)
+	}
+	t.times = append(t.times, time)
+	t.inputs = append(t.inputs, u)
+
+	// History older than impulse response is not needed
+	oldest := time - float64(len(t.taps))*t.tapStep
+	drop := 0
+	for drop < len(t.times)-2 && t.times[drop+1] < oldest {
+		drop++
+	}
+	if drop > 0 {
+		t.times = append(t.times[:0], t.times[drop:]...)
+		t.inputs = append(t.inputs[:0], t.inputs[drop:]...)
+	}
+}
@@ -0,0 +1,46 @@
+package device
+
+import "math"
+
+// Tolerances of timestep truncation, SPICE3 defaults. Allowed error of state derivative, eg. capacitor current,
+// is reltol of derivative plus abstol, or reltol of state by timestep when larger, at least chgtol by timestep
+const (
+	truncReltol = 1e-3
+	truncAbstol = 1e-12
+	truncChgtol = 1e-14
+)
+
+// stateHistory - State of device, eg. charge or flux, at last two accepted timepoints for truncation error estimate
+type stateHistory struct {
+	values [2]float64 // Last and previous timepoint
+	step   float64    // Timestep between them, 0 until first timepoint is accepted
+}
+
+// reset - State of initial point, from DC operating point or initial conditions
+func (h *stateHistory) reset(value float64) {
+	h.values = [2]float64{value, value}
+	h.step = 0
+}
+
+// accept - State of accepted timepoint reached by timestep dt
+func (h *stateHistory) accept(value, dt float64) {
+	h.values = [2]float64{value, h.values[0]}
+	h.step = dt
+}
+
+// truncate - Largest timestep of backward Euler keeping local truncation error of state within tolerance,
+// at state value of new timepoint. Second divided difference estimate of SPICE CKTterr, +Inf without history
+func (h *stateHistory) truncate(value float64, status *CircuitStatus) float64 {
+	dt := status.TimeStep
+	if h.step <= 0 || dt <= 0 || status.Trtol <= 0 {
+		return math.Inf(1)
+	}
+
+	d0 := (value - h.values[0]) / dt
+	d1 := (h.values[0] - h.values[1]) / h.step
+	diff := math.Abs(d0-d1) / (dt + h.step)
+
+	derivTol := truncAbstol + truncReltol*math.Max(math.Abs(d0), math.Abs(d1))
+	stateTol := truncReltol * math.Max(math.Max(math.Abs(value), math.Abs(h.values[0])), truncChgtol) / dt
+	return status.Trtol * math.Max(derivTol, stateTol) / math.Max(truncAbstol, 0.5*diff)
+}
@@ -0,0 +1,51 @@
+package device
+
+import "fmt"
+
+// ParameterIssue - Model or instance parameter outside acceptable range
+type ParameterIssue struct {
+	Device string
+	Param  string
+	Value  float64
+	Range  string // Acceptable range, eg. "> 0" or "0 <= fc < 1"
+	Fatal  bool   // Device can not work with value. Otherwise value is only unusual
+}
+
+func (p ParameterIssue) String() string {
+	return fmt.Sprintf("%s: parameter %s = %g, acceptable range %s", p.Device, p.Param, p.Value, p.Range)
+}
+
+// ParameterValidator - Devices checking model and instance parameters after setup
+type ParameterValidator interface {
+	ValidateParameters() []ParameterIssue
+}
+
+// paramCheck - Parameter issues of one device
+type paramCheck struct {
+	device string
+	issues []ParameterIssue
+}
+
+func (c *paramCheck) check(param string, value float64, valid bool, valueRange string, fatal bool) {
+	if !valid {
+		c.issues = append(c.issues, ParameterIssue{Device: c.device, Param: param, Value: value, Range: valueRange, Fatal: fatal})
+	}
+}
+
+func (c *paramCheck) positive(param string, value float64) {
+	c.check(param, value, value > 0, "> 0", true)
+}
+
+func (c *paramCheck) nonNegative(param string, value float64) {
+	c.check(param, value, value >= 0, ">= 0", true)
+}
+
+// fraction - 0 <= value < 1, eg. grading and forward-bias depletion coefficients
+func (c *paramCheck) fraction(param string, value float64) {
+	c.check(param, value, value >= 0 && value < 1, "0 <= "+param+" < 1", true)
+}
+
+// usual - Warning outside range of physical devices
+func (c *paramCheck) usual(param string, value, lo, hi float64) {
+	c.check(param, value, value >= lo && value <= hi, fmt.Sprintf("%g to %g", lo, hi), false)
+}
@@ -14,6 +14,8 @@ type VoltageSource struct {
 	// SIN params
 	amplitude float64
 	freq      float64
+	sinDelay  float64
+	theta     float64 // Damping factor
 	phase     float64
 	// PULSE params
 	v1     float64
@@ -24,8 +26,13 @@ type VoltageSource struct {
 	pWidth float64
 	period float64
 	// PWL params
-	times  []float64
-	values []float64
+	times     []float64
+	values    []float64
+	pwlRepeat float64 // Repeat from this time after last point, negative for no repeat
+	pwlDelay  float64
+	// AM, TRNOISE and TRRANDOM params
+	am     *amWaveform
+	random *randomWaveform
 	// AC params
 	acMag   float64
 	acPhase float64
@@ -46,7 +53,8 @@ func NewDCVoltageSource(name string, nodeNames []string, value float64) *Voltage
 	}
 }
 
-func NewSinVoltageSource(name string, nodeNames []string, offset, amplitude, freq, phase float64) *VoltageSource {
+// NewSinVoltageSource - SIN(VO VA FREQ TD THETA PHASE)
+func NewSinVoltageSource(name string, nodeNames []string, offset, amplitude, freq, delay, theta, phase float64) *VoltageSource {
 	return &VoltageSource{
 		BaseDevice: BaseDevice{
 			Name:      name,
@@ -58,6 +66,8 @@ func NewSinVoltageSource(name string, nodeNames []string, offset, amplitude, fre
 		dcValue:   offset,
 		amplitude: amplitude,
 		freq:      freq,
+		sinDelay:  delay,
+		theta:     theta,
 		phase:     phase,
 	}
 }
@@ -89,9 +99,56 @@ func NewPWLVoltageSource(name string, nodeNames []string, times []float64, value
 			NodeNames: nodeNames,
 			Value:     values[0], // First value as initial value
 		},
-		vtype:  PWL,
-		times:  times,
-		values: values,
+		vtype:     PWL,
+		times:     times,
+		values:    values,
+		pwlRepeat: -1,
+	}
+}
+
+// SetPWLRepeat - Repeat PWL from time repeat after last point (r=), and delay whole waveform (td=).
+// Negative repeat is no repeat.
+func (v *VoltageSource) SetPWLRepeat(repeat, delay float64) {
+	v.pwlRepeat = repeat
+	v.pwlDelay = delay
+}
+
+// NewAMVoltageSource - AM(VA VO MF FC TD)
+func NewAMVoltageSource(name string, nodeNames []string, amplitude, offset, modFreq, carrierFreq, delay float64) *VoltageSource {
+	return &VoltageSource{
+		BaseDevice: BaseDevice{
+			Name:      name,
+			Nodes:     make([]int, len(nodeNames)),
+			NodeNames: nodeNames,
+		},
+		vtype: AM,
+		am:    &amWaveform{amplitude, offset, modFreq, carrierFreq, delay},
+	}
+}
+
+// NewNoiseVoltageSource - TRNOISE(NA NT). Gaussian noise of rms na and bandwidth 1/(2*nt). Seed 0 is from name
+func NewNoiseVoltageSource(name string, nodeNames []string, rms, step float64, seed int64) *VoltageSource {
+	return &VoltageSource{
+		BaseDevice: BaseDevice{
+			Name:      name,
+			Nodes:     make([]int, len(nodeNames)),
+			NodeNames: nodeNames,
+		},
+		vtype:  RANDOM,
+		random: newNoiseWaveform(name, rms, step, seed),
+	}
+}
+
+// NewRandomVoltageSource - TRRANDOM(TYPE TS TD PARAM1 PARAM2). Seed 0 is from name
+func NewRandomVoltageSource(name string, nodeNames []string, kind int, step, delay, param1, param2 float64, seed int64) *VoltageSource {
+	return &VoltageSource{
+		BaseDevice: BaseDevice{
+			Name:      name,
+			Nodes:     make([]int, len(nodeNames)),
+			NodeNames: nodeNames,
+		},
+		vtype:  RANDOM,
+		random: newRandomWaveform(name, kind, step, delay, param1, param2, seed),
 	}
 }
 
@@ -116,17 +173,39 @@ func (v *VoltageSource) GetVoltage(t float64) float64 {
 		return v.dcValue
 	case SIN:
 		phaseRad := v.phase * math.Pi / 180.0
-		return v.dcValue + v.amplitude*math.Sin(2.0*math.Pi*v.freq*t+phaseRad)
+		if t < v.sinDelay {
+			return v.dcValue + v.amplitude*math.Sin(phaseRad)
+		}
+		t -= v.sinDelay
+		return v.dcValue + v.amplitude*math.Exp(-v.theta*t)*math.Sin(2.0*math.Pi*v.freq*t+phaseRad)
 	case PULSE:
 		return v.getPulseVoltage(t)
 	case PWL:
 		return v.getPWLVoltage(t)
+	case AM:
+		return v.am.value(t)
+	case RANDOM:
+		return v.random.value(t)
 	default:
 		return 0
 	}
 }
 
+// FirstBreakpoint - First corner of PULSE or PWL waveform after t=0, 0 for others
+func (v *VoltageSource) FirstBreakpoint() float64 {
+	switch v.vtype {
+	case PULSE:
+		return pulseFirstBreakpoint(v.delay, v.rise, v.pWidth, v.fall)
+	case PWL:
+		return pwlFirstBreakpoint(v.times, v.pwlDelay)
+	case SIN:
+		return v.sinDelay
+	}
+	return 0
+}
+
 func (v *VoltageSource) GetType() string { return "V" }
+func (v *VoltageSource) LinearStamp()    {}
 
 func (v *VoltageSource) Stamp(matrix matrix.DeviceMatrix, status *CircuitStatus) error {
 	if status.Mode == ACAnalysis {
@@ -209,6 +288,7 @@ func (v *VoltageSource) getPulseVoltage(t float64) float64 {
 }
 
 func (v *VoltageSource) getPWLVoltage(t float64) float64 {
+	t = pwlTime(t, v.times, v.pwlRepeat, v.pwlDelay)
 	if t <= v.times[0] {
 		return v.values[0]
 	}
@@ -238,6 +318,9 @@ func (v *VoltageSource) SetBranchIndex(idx int) {
 	v.branchIdx = idx
 }
 
+// BranchSign - Branch variable flows n+ to n- through source
+func (v *VoltageSource) BranchSign() float64 { return 1 }
+
 func (v *VoltageSource) SetValue(value float64) {
 	v.Value = value
 	v.dcValue = value
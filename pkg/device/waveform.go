@@ -0,0 +1,149 @@
+package device
+
+import (
+	"hash/fnv"
+	"math"
+)
+
+// amWaveform - AM(VA VO MF FC TD). va*(vo + sin(2π*mf*(t-td)))*sin(2π*fc*(t-td)), zero before td
+type amWaveform struct {
+	amplitude   float64
+	offset      float64
+	modFreq     float64
+	carrierFreq float64
+	delay       float64
+}
+
+func (w *amWaveform) value(t float64) float64 {
+	if t < w.delay {
+		return 0
+	}
+	t -= w.delay
+	return w.amplitude * (w.offset + math.Sin(2*math.Pi*w.modFreq*t)) * math.Sin(2*math.Pi*w.carrierFreq*t)
+}
+
+// pulseFirstBreakpoint - First corner of PULSE after t=0, 0 when flat
+func pulseFirstBreakpoint(delay, rise, pWidth, fall float64) float64 {
+	corner := 0.0
+	for _, d := range []float64{delay, rise, pWidth, fall} {
+		corner += d
+		if corner > 0 {
+			return corner
+		}
+	}
+	return 0
+}
+
+// pwlFirstBreakpoint - First PWL point after t=0
+func pwlFirstBreakpoint(times []float64, delay float64) float64 {
+	for _, t := range times {
+		if t+delay > 0 {
+			return t + delay
+		}
+	}
+	return 0
+}
+
+// pwlTime - Time in PWL table after delay and repeat
+func pwlTime(t float64, times []float64, repeat, delay float64) float64 {
+	t -= delay
+	last := times[len(times)-1]
+	if repeat < 0 || t <= last {
+		return t
+	}
+
+	period := last - repeat
+	if period <= 0 {
+		return t
+	}
+	return repeat + math.Mod(t-repeat, period)
+}
+
+// Distributions of random waveform. Same numbering as TRRANDOM type
+const (
+	RandomUniform     = 1 // -param1..param1, offset param2
+	RandomGaussian    = 2 // Standard deviation param1, mean param2
+	RandomExponential = 3 // Mean param1, offset param2
+	RandomPoisson     = 4 // Lambda param1, offset param2
+)
+
+// randomWaveform - New random sample every step after delay, zero before delay.
+// Samples depend only on seed and sample index, so Newton iterations and rejected timesteps see same values.
+type randomWaveform struct {
+	kind        int
+	step        float64
+	delay       float64
+	param1      float64
+	param2      float64
+	seed        uint64
+	interpolate bool // Linear between samples (TRNOISE), otherwise held (TRRANDOM)
+}
+
+// seedFromName - Default seed. Sources without seed are uncorrelated each other
+func seedFromName(name string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return h.Sum64()
+}
+
+func (w *randomWaveform) value(t float64) float64 {
+	if t < w.delay || w.step <= 0 {
+		return 0
+	}
+
+	x := (t - w.delay) / w.step
+	k := uint64(x)
+	if !w.interpolate {
+		return w.sample(k)
+	}
+
+	frac := x - float64(k)
+	return w.sample(k) + frac*(w.sample(k+1)-w.sample(k))
+}
+
+func (w *randomWaveform) sample(k uint64) float64 {
+	switch w.kind {
+	case RandomUniform:
+		return w.param1*(2*w.uniform(k, 0)-1) + w.param2
+	case RandomExponential:
+		return -w.param1*math.Log(1-w.uniform(k, 0)) + w.param2
+	case RandomPoisson:
+		// Knuth. Product of uniforms until below exp(-lambda)
+		limit := math.Exp(-w.param1)
+		count := 0
+		for p := w.uniform(k, 0); p > limit && count < 1000; count++ {
+			p *= w.uniform(k, uint64(count+1))
+		}
+		return float64(count) + w.param2
+	default:
+		// Gaussian by Box-Muller
+		u1, u2 := w.uniform(k, 0), w.uniform(k, 1)
+		return w.param1*math.Sqrt(-2*math.Log(1-u1))*math.Cos(2*math.Pi*u2) + w.param2
+	}
+}
+
+// uniform - Uniform [0, 1) of sample k. splitmix64 of seed, sample and draw index
+func (w *randomWaveform) uniform(k, draw uint64) float64 {
+	z := w.seed + (k*4+draw+1)*0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	z ^= z >> 31
+	return float64(z>>11) / (1 << 53)
+}
+
+// newNoiseWaveform - TRNOISE(NA NT). Gaussian white noise of rms na, bandwidth 1/(2*nt)
+func newNoiseWaveform(name string, rms, step float64, seed int64) *randomWaveform {
+	return &randomWaveform{kind: RandomGaussian, step: step, param1: rms, seed: waveformSeed(name, seed), interpolate: true}
+}
+
+// newRandomWaveform - TRRANDOM(TYPE TS TD PARAM1 PARAM2)
+func newRandomWaveform(name string, kind int, step, delay, param1, param2 float64, seed int64) *randomWaveform {
+	return &randomWaveform{kind: kind, step: step, delay: delay, param1: param1, param2: param2, seed: waveformSeed(name, seed)}
+}
+
+func waveformSeed(name string, seed int64) uint64 {
+	if seed == 0 {
+		return seedFromName(name)
+	}
+	return uint64(seed)
+}
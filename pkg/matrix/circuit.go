@@ -2,286 +2,177 @@ package matrix
 
 import (
 	"fmt"
-
-	"github.com/edp1096/sparse"
 )
 
+// SingularError - Factorization failed at row and column of circuit equations, 1-based.
+// Zero is unknown row or column.
+type SingularError struct {
+	Row int
+	Col int
+	Err error
+}
+
+func (e *SingularError) Error() string {
+	return fmt.Sprintf("matrix factorization failed: singular at row %d, col %d: %v", e.Row, e.Col, e.Err)
+}
+
+func (e *SingularError) Unwrap() error { return e.Err }
+
+// CircuitMatrix - MNA system of circuit on swappable solver backend
 type CircuitMatrix struct {
-	Size         int
-	matrix       *sparse.Matrix
-	rhs          []float64
-	rhsImag      []float64
-	solution     []float64
-	solutionImag []float64
-	isComplex    bool
-	config       *sparse.Configuration
+	Size       int
+	isComplex  bool
+	solver     Solver
+	solverName string
+	strict     bool  // Out of bounds stamps are errors of Solve
+	stampErr   error // First out of bounds stamp since Clear in strict mode
+	scaling    bool  // Row and column equilibration before factorization
 }
 
+// NewMatrix - Circuit matrix on default sparse backend
 func NewMatrix(size int, isComplex bool) *CircuitMatrix {
-	config := &sparse.Configuration{
-		Real:                    true,
-		Complex:                 isComplex,
-		SeparatedComplexVectors: false,
-		Translate:               true,
-		Expandable:              true,
-		ModifiedNodal:           true,
-		TiesMultiplier:          5,
-		PrinterWidth:            140,
-		Annotate:                0,
-	}
-
-	mat, err := sparse.Create(int64(size), config)
+	m, err := NewMatrixWithSolver(DefaultSolver, size, isComplex)
 	if err != nil {
-		fmt.Printf("Error creating sparse matrix: %v\n", err)
+		fmt.Printf("Error creating matrix: %v\n", err)
 		return nil
 	}
+	return m
+}
 
-	vectorSize := size + 1 // rhs, solution size
-	vectorSizeImag := size + 1
-	if isComplex && !config.SeparatedComplexVectors {
-		vectorSize *= 2
-		vectorSizeImag = 1
-	}
+// NewMatrixDense - Circuit matrix on dense LU instead of sparse. For tiny circuits and tests
+func NewMatrixDense(size int, isComplex bool) *CircuitMatrix {
+	m, _ := NewMatrixWithSolver("dense", size, isComplex)
+	return m
+}
 
-	return &CircuitMatrix{
-		Size:         size,
-		matrix:       mat,
-		rhs:          make([]float64, vectorSize), // 1-based indexing
-		rhsImag:      make([]float64, vectorSizeImag),
-		solution:     make([]float64, vectorSize),
-		solutionImag: make([]float64, vectorSizeImag),
-		config:       config,
+// NewMatrixWithSolver - Circuit matrix on registered backend. Empty name is default
+func NewMatrixWithSolver(name string, size int, isComplex bool) (*CircuitMatrix, error) {
+	solver, err := newSolver(name, size, isComplex)
+	if err != nil {
+		return nil, err
 	}
+	if name == "" {
+		name = DefaultSolver
+	}
+	return &CircuitMatrix{Size: size, isComplex: isComplex, solver: solver, solverName: name}, nil
 }
 
-func (m *CircuitMatrix) SetupElements() {
-	for i := 1; i <= m.Size; i++ {
-		for j := 1; j <= m.Size; j++ {
-			m.matrix.GetElement(int64(i), int64(j))
-		}
+// SolverName - Name of backend
+func (m *CircuitMatrix) SolverName() string { return m.solverName }
+
+// Solver - Backend for direct access
+func (m *CircuitMatrix) Solver() Solver { return m.solver }
+
+// SetStrict - Out of bounds stamps are returned as error by Solve instead of logged warnings
+func (m *CircuitMatrix) SetStrict(strict bool) { m.strict = strict }
+
+// inBounds - Index of stamp is in matrix, j is 1 for RHS. Out of bounds stamp is logged or kept as error
+func (m *CircuitMatrix) inBounds(what string, i, j int) bool {
+	if i > 0 && j > 0 && i <= m.Size && j <= m.Size {
+		return true
 	}
+
+	var err error
+	if what == "RHS" {
+		err = fmt.Errorf("RHS index out of bounds (i=%d, size=%d)", i, m.Size)
+	} else {
+		err = fmt.Errorf("matrix index out of bounds (i=%d, j=%d, size=%d)", i, j, m.Size)
+	}
+	if !m.strict {
+		Logger.Print(err)
+	} else if m.stampErr == nil {
+		m.stampErr = err
+	}
+	return false
 }
 
 func (m *CircuitMatrix) AddElement(i, j int, value float64) {
-	if i <= 0 || j <= 0 || i > m.Size || j > m.Size {
-		fmt.Printf("Warning: Matrix index out of bounds (i=%d, j=%d, size=%d)\n", i, j, m.Size)
-		return
+	if m.inBounds("matrix", i, j) {
+		m.solver.AddElement(i, j, value)
 	}
-	m.matrix.GetElement(int64(i), int64(j)).Real += value
 }
 
 func (m *CircuitMatrix) AddComplexElement(i, j int, real, imag float64) {
-	if i <= 0 || j <= 0 || i > m.Size || j > m.Size {
-		fmt.Printf("Warning: Matrix index out of bounds (i=%d, j=%d, size=%d)\n", i, j, m.Size)
-		return
+	if m.inBounds("matrix", i, j) {
+		m.solver.AddComplexElement(i, j, real, imag)
 	}
-
-	element := m.matrix.GetElement(int64(i), int64(j))
-	element.Real += real
-	element.Imag += imag
 }
 
 func (m *CircuitMatrix) AddComplexRHS(i int, real, imag float64) {
-	if i <= 0 || i > m.Size {
-		fmt.Printf("Warning: RHS index out of bounds (i=%d, size=%d)\n", i, m.Size)
-		return
-	}
-
-	if m.config.SeparatedComplexVectors {
-		m.rhs[i] += real
-		m.rhsImag[i] += imag
-	} else {
-		m.rhs[2*i] += real
-		m.rhs[2*i+1] += imag
+	if m.inBounds("RHS", i, 1) {
+		m.solver.AddComplexRHS(i, real, imag)
 	}
 }
 
 func (m *CircuitMatrix) AddRHS(i int, value float64) {
-	if i <= 0 || i > m.Size {
-		fmt.Printf("Warning: RHS index out of bounds (i=%d, size=%d)\n", i, m.Size)
-		return
+	if m.inBounds("RHS", i, 1) {
+		m.solver.AddRHS(i, value)
 	}
-	m.rhs[i] += value
 }
 
 func (m *CircuitMatrix) LoadGmin(gmin float64) {
-	size := m.Size
-	for i := 1; i <= size; i++ {
-		if diag := m.GetDiagElement(i); diag != nil {
-			diag.Real += gmin
-		}
-	}
+	m.solver.LoadGmin(gmin)
 }
 
 func (m *CircuitMatrix) Clear() {
-	m.matrix.Clear()
-	for i := range m.rhs {
-		m.rhs[i] = 0
-	}
-	for i := range m.rhsImag {
-		m.rhsImag[i] = 0
-	}
+	m.stampErr = nil
+	m.solver.Clear()
 }
 
-func (m *CircuitMatrix) Solve() error {
-	var err error
+// ClearRHS - Zero right hand side and keep matrix elements
+func (m *CircuitMatrix) ClearRHS() {
+	m.solver.ClearRHS()
+}
 
-	if m.config.Complex {
-		err = m.matrix.FactorComplex()
-	} else {
-		err = m.matrix.Factor()
+// Solve - Factor and solve stamped system
+func (m *CircuitMatrix) Solve() error {
+	if m.stampErr != nil {
+		return m.stampErr
 	}
-
-	if err != nil {
-		return fmt.Errorf("matrix factorization failed: %v", err)
+	var col []float64
+	if eq, ok := m.solver.(equilibrator); ok && m.scaling {
+		col = eq.Equilibrate()
 	}
-
-	if m.config.Complex {
-		m.solution, m.solutionImag, err = m.matrix.SolveComplex(m.rhs, m.rhsImag)
-	} else {
-		m.solution, err = m.matrix.Solve(m.rhs)
+	err := m.solver.Factor()
+	if err != nil {
+		return err
 	}
-
+	err = m.solver.Solve()
 	if err != nil {
-		return fmt.Errorf("matrix solve failed: %v", err)
+		return err
 	}
-
-	return nil
-}
-
-func (m *CircuitMatrix) GetDiagElement(i int) *sparse.Element {
-	if i <= 0 || i > m.Size {
-		fmt.Printf("Warning: Diagonal index out of bounds (i=%d, size=%d)\n", i, m.Size)
-		return nil
+	if col != nil {
+		m.unscale(col)
 	}
-	return m.matrix.Diags[i]
+	return nil
 }
 
-func (m *CircuitMatrix) RHS() []float64 {
-	return m.rhs
+func (m *CircuitMatrix) Solution() []float64 {
+	return m.solver.Solution()
 }
 
-func (m *CircuitMatrix) Solution() []float64 {
-	return m.solution
+func (m *CircuitMatrix) SolutionImag() []float64 {
+	return m.solver.SolutionImag()
 }
 
 func (m *CircuitMatrix) GetComplexSolution(i int) (float64, float64) {
-	if !m.config.Complex || i <= 0 || i > m.Size {
+	if !m.isComplex || i <= 0 || i > m.Size {
 		return 0, 0
 	}
-	return m.solution[i], m.solution[i+m.Size]
-}
-
-func (m *CircuitMatrix) SolutionImag() []float64 {
-	return m.solutionImag
+	return m.solver.Solution()[i], m.solver.SolutionImag()[i]
 }
 
+// PrintSystem - Equations of backends which can print them
 func (m *CircuitMatrix) PrintSystem() {
-	fmt.Printf("\nCircuit Equations (%dx%d):\n", m.Size, m.Size)
-	fmt.Println("Node equations 1..n, followed by branch equations")
-
-	for i := 1; i <= m.Size; i++ {
-		fmt.Printf("Equation %d:\n", i)
-		rowHasElements := false
-		for j := 1; j <= m.Size; j++ {
-			element := m.matrix.GetElement(int64(i), int64(j))
-			if m.config.Complex {
-				if element.Real != 0 || element.Imag != 0 {
-					if element.Imag == 0 {
-						fmt.Printf("  %+g*x%d ", element.Real, j)
-					} else {
-						fmt.Printf("  (%g + j%g)*x%d ", element.Real, element.Imag, j)
-					}
-					rowHasElements = true
-				}
-			} else {
-				if element.Real != 0 {
-					fmt.Printf("  %+g*x%d ", element.Real, j)
-					rowHasElements = true
-				}
-			}
-		}
-		if rowHasElements {
-			if !m.config.Complex {
-				fmt.Printf(" = %g\n", m.rhs[i])
-			} else {
-				if !m.config.SeparatedComplexVectors {
-					fmt.Printf(" = %g + j%g\n", m.rhs[i], m.rhs[i+m.Size])
-				} else {
-					fmt.Printf(" = %g + j%g\n", m.rhs[i], m.rhsImag[i])
-				}
-			}
-		}
-	}
-
-	m.matrix.Print(false, true, true)
-
-	fmt.Printf("RHS:\n")
-	for i := 1; i <= m.Size; i++ {
-		if !m.config.Complex {
-			fmt.Printf("  x%d = %g\n", i, m.rhs[i])
-		} else {
-			if !m.config.SeparatedComplexVectors {
-				fmt.Printf("  x%d = %g + j%g\n", i, m.rhs[i], m.rhs[i+m.Size])
-			} else {
-				fmt.Printf("  x%d = %g + j%g\n", i, m.rhs[i], m.rhsImag[i])
-			}
-		}
-	}
-}
-
-func (m *CircuitMatrix) printMatrixSummary() {
-	fmt.Println("\nMATRIX SUMMARY")
-	fmt.Printf("Size of matrix = %d x %d\n", m.Size, m.Size)
-
-	maxElement := 0.0
-	minElement := 1.79e+308
-	elementCount := 0
-	maxPivot := 0.0
-	minPivot := 1.79e+308
-
-	fmt.Println("Matrix before factorization:")
-	fmt.Printf("%3s", "")
-	for j := 1; j <= m.Size; j++ {
-		fmt.Printf("%10d", j)
-	}
-	fmt.Println()
-
-	for i := 1; i <= m.Size; i++ {
-		fmt.Printf("%4d", i)
-		for j := 1; j <= m.Size; j++ {
-			value := m.matrix.GetElement(int64(i), int64(j)).Real
-			fmt.Printf("%10.3f", value)
-
-			if value != 0 {
-				elementCount++
-				if value > maxElement {
-					maxElement = value
-				}
-				if value < minElement {
-					minElement = value
-				}
-				if i == j && value > maxPivot {
-					maxPivot = value
-				}
-				if i == j && value < minPivot {
-					minPivot = value
-				}
-			}
-		}
-		fmt.Println()
+	if printer, ok := m.solver.(interface{ PrintSystem() }); ok {
+		printer.PrintSystem()
+		return
 	}
-
-	fmt.Printf("Largest element in matrix = %.3f\n", maxElement)
-	fmt.Printf("Smallest element in matrix = %.3f\n", minElement)
-	fmt.Printf("Largest pivot element = %.3f\n", maxPivot)
-	fmt.Printf("Smallest pivot element = %.3f\n", minPivot)
-	fmt.Printf("Density = %.2f%%\n", float64(elementCount)*100/float64(m.Size*m.Size))
-	fmt.Println()
+	fmt.Printf("\nCircuit Equations (%dx%d): not printable by %s solver\n", m.Size, m.Size, m.solverName)
 }
 
 func (m *CircuitMatrix) Destroy() {
-	if m.matrix != nil {
-		m.matrix.Destroy()
+	if destroyer, ok := m.solver.(interface{ Destroy() }); ok {
+		destroyer.Destroy()
 	}
 }
@@ -0,0 +1,278 @@
+package matrix
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"slices"
+)
+
+// DenseMatrix - In-memory dense MNA system with LU solve. No sparse dependency.
+// For tiny circuits and device stamp tests. Same 1-based indexing as CircuitMatrix.
+// Solver backend "dense".
+type DenseMatrix struct {
+	Size         int
+	isComplex    bool
+	a            []complex128 // Row major, (Size+1)x(Size+1), row and column 0 unused
+	rhs          []complex128
+	lu           []complex128 // Factors of Factor
+	perm         []int        // Row permutation of factors
+	rowScale     []float64    // Equilibration applied to factors and RHS, nil without scaling
+	colScale     []float64
+	norm         float64 // Infinity norm of factored matrix
+	solution     []float64
+	solutionImag []float64
+}
+
+var _ Solver = (*DenseMatrix)(nil)
+
+func NewDense(size int, isComplex bool) *DenseMatrix {
+	n := size + 1
+	return &DenseMatrix{
+		Size:         size,
+		isComplex:    isComplex,
+		a:            make([]complex128, n*n),
+		rhs:          make([]complex128, n),
+		solution:     make([]float64, n),
+		solutionImag: make([]float64, n),
+	}
+}
+
+func (m *DenseMatrix) inBounds(i, j int) bool {
+	if i <= 0 || j <= 0 || i > m.Size || j > m.Size {
+		Logger.Printf("Matrix index out of bounds (i=%d, j=%d, size=%d)", i, j, m.Size)
+		return false
+	}
+	return true
+}
+
+func (m *DenseMatrix) AddElement(i, j int, value float64) {
+	if m.inBounds(i, j) {
+		m.a[i*(m.Size+1)+j] += complex(value, 0)
+	}
+}
+
+func (m *DenseMatrix) AddComplexElement(i, j int, real, imag float64) {
+	if m.inBounds(i, j) {
+		m.a[i*(m.Size+1)+j] += complex(real, imag)
+	}
+}
+
+func (m *DenseMatrix) AddRHS(i int, value float64) {
+	if m.inBounds(i, i) {
+		m.rhs[i] += complex(value, 0)
+	}
+}
+
+func (m *DenseMatrix) AddComplexRHS(i int, real, imag float64) {
+	if m.inBounds(i, i) {
+		m.rhs[i] += complex(real, imag)
+	}
+}
+
+// Element - Stamped value at row i, column j
+func (m *DenseMatrix) Element(i, j int) (float64, float64) {
+	if i <= 0 || j <= 0 || i > m.Size || j > m.Size {
+		return 0, 0
+	}
+	value := m.a[i*(m.Size+1)+j]
+	return real(value), imag(value)
+}
+
+// RHSValue - Stamped right hand side at row i
+func (m *DenseMatrix) RHSValue(i int) (float64, float64) {
+	if i <= 0 || i > m.Size {
+		return 0, 0
+	}
+	return real(m.rhs[i]), imag(m.rhs[i])
+}
+
+func (m *DenseMatrix) LoadGmin(gmin float64) {
+	for i := 1; i <= m.Size; i++ {
+		m.a[i*(m.Size+1)+i] += complex(gmin, 0)
+	}
+}
+
+func (m *DenseMatrix) Clear() {
+	clear(m.a)
+	clear(m.rhs)
+	m.rowScale, m.colScale = nil, nil
+}
+
+func (m *DenseMatrix) ClearRHS() {
+	clear(m.rhs)
+}
+
+// Factor - LU with partial pivoting on copy of stamped system, so stamps stay readable after solve
+func (m *DenseMatrix) Factor() error {
+	n := m.Size + 1
+	m.lu = make([]complex128, len(m.a))
+	copy(m.lu, m.a)
+	lu := m.lu
+
+	m.norm = 0
+	for i := 1; i <= m.Size; i++ {
+		rowSum := 0.0
+		for j := 1; j <= m.Size; j++ {
+			if m.rowScale != nil {
+				lu[i*n+j] *= complex(m.rowScale[i]*m.colScale[j], 0)
+			}
+			rowSum += cmplx.Abs(lu[i*n+j])
+		}
+		m.norm = math.Max(m.norm, rowSum)
+	}
+
+	m.perm = make([]int, n) // Original row of each pivot row
+	for i := range m.perm {
+		m.perm[i] = i
+	}
+
+	for k := 1; k <= m.Size; k++ {
+		pivot, largest := k, 0.0
+		for i := k; i <= m.Size; i++ {
+			if mag := cmplx.Abs(lu[i*n+k]); mag > largest {
+				pivot, largest = i, mag
+			}
+		}
+		if largest == 0 {
+			// No pivot left for unknown k
+			m.lu = nil
+			return &SingularError{Row: k, Col: k, Err: fmt.Errorf("zero pivot at step %d", k)}
+		}
+
+		if pivot != k {
+			for j := 1; j <= m.Size; j++ {
+				lu[k*n+j], lu[pivot*n+j] = lu[pivot*n+j], lu[k*n+j]
+			}
+			m.perm[k], m.perm[pivot] = m.perm[pivot], m.perm[k]
+		}
+
+		// L below diagonal, U on and above
+		for i := k + 1; i <= m.Size; i++ {
+			factor := lu[i*n+k] / lu[k*n+k]
+			lu[i*n+k] = factor
+			if factor == 0 {
+				continue
+			}
+			for j := k + 1; j <= m.Size; j++ {
+				lu[i*n+j] -= factor * lu[k*n+j]
+			}
+		}
+	}
+
+	return nil
+}
+
+// Solve - Forward and back substitution of right hand side with factors of Factor
+func (m *DenseMatrix) Solve() error {
+	if m.lu == nil {
+		return fmt.Errorf("matrix is not factored")
+	}
+
+	x := make([]complex128, m.Size+1)
+	for i := 1; i <= m.Size; i++ {
+		x[i] = m.rhs[m.perm[i]]
+		if m.rowScale != nil {
+			x[i] *= complex(m.rowScale[m.perm[i]], 0)
+		}
+	}
+	m.substitute(x)
+
+	for i := 1; i <= m.Size; i++ {
+		m.solution[i] = real(x[i])
+		m.solutionImag[i] = imag(x[i])
+	}
+
+	return nil
+}
+
+// substitute - Solution of factored system in place of permuted right hand side x
+func (m *DenseMatrix) substitute(x []complex128) {
+	n := m.Size + 1
+	lu := m.lu
+	for i := 2; i <= m.Size; i++ {
+		for j := 1; j < i; j++ {
+			x[i] -= lu[i*n+j] * x[j]
+		}
+	}
+	for i := m.Size; i >= 1; i-- {
+		for j := i + 1; j <= m.Size; j++ {
+			x[i] -= lu[i*n+j] * x[j]
+		}
+		x[i] /= lu[i*n+i]
+	}
+}
+
+// Equilibrate - Scales are kept and applied to copy of Factor and Solve, so stamps stay readable
+func (m *DenseMatrix) Equilibrate() []float64 {
+	m.rowScale, m.colScale = equilibrate(m.Size, func(visit func(i, j int, mag float64)) {
+		for i := 1; i <= m.Size; i++ {
+			for j := 1; j <= m.Size; j++ {
+				if value := m.a[i*(m.Size+1)+j]; value != 0 {
+					visit(i, j, cmplx.Abs(value))
+				}
+			}
+		}
+	})
+	return m.colScale
+}
+
+// ConditionEstimate - Exact infinity norm condition number by inverse from factors
+func (m *DenseMatrix) ConditionEstimate() (float64, error) {
+	if m.lu == nil {
+		return 0, fmt.Errorf("matrix is not factored")
+	}
+
+	rowSums := make([]float64, m.Size+1)
+	x := make([]complex128, m.Size+1)
+	for k := 1; k <= m.Size; k++ {
+		for i := 1; i <= m.Size; i++ {
+			x[i] = 0
+			if m.perm[i] == k {
+				x[i] = 1
+			}
+		}
+		m.substitute(x)
+		for i := 1; i <= m.Size; i++ {
+			rowSums[i] += cmplx.Abs(x[i])
+		}
+	}
+	return m.norm * slices.Max(rowSums), nil
+}
+
+func (m *DenseMatrix) Solution() []float64 {
+	return m.solution
+}
+
+func (m *DenseMatrix) SolutionImag() []float64 {
+	return m.solutionImag
+}
+
+func (m *DenseMatrix) GetComplexSolution(i int) (float64, float64) {
+	if !m.isComplex || i <= 0 || i > m.Size {
+		return 0, 0
+	}
+	return m.solution[i], m.solutionImag[i]
+}
+
+func (m *DenseMatrix) PrintSystem() {
+	fmt.Printf("\nCircuit Equations (%dx%d, dense):\n", m.Size, m.Size)
+	for i := 1; i <= m.Size; i++ {
+		fmt.Printf("Equation %d:\n", i)
+		for j := 1; j <= m.Size; j++ {
+			value := m.a[i*(m.Size+1)+j]
+			switch {
+			case value == 0:
+			case imag(value) == 0:
+				fmt.Printf("  %+g*x%d ", real(value), j)
+			default:
+				fmt.Printf("  (%g + j%g)*x%d ", real(value), imag(value), j)
+			}
+		}
+		if m.isComplex {
+			fmt.Printf(" = %g + j%g\n", real(m.rhs[i]), imag(m.rhs[i]))
+		} else {
+			fmt.Printf(" = %g\n", real(m.rhs[i]))
+		}
+	}
+}
@@ -0,0 +1,9 @@
+package matrix
+
+import (
+	"log"
+	"os"
+)
+
+// Logger - Solver warnings, eg. out of bounds stamps. SetOutput(io.Discard) suppresses them
+var Logger = log.New(os.Stderr, "Warning: ", 0)
@@ -0,0 +1,73 @@
+package matrix
+
+import (
+	"fmt"
+	"math"
+)
+
+// equilibrator - Backends which scale stamped system in place before factorization
+type equilibrator interface {
+	// Equilibrate - Scale rows and columns of stamped matrix and rows of RHS. Returns column scales,
+	// solution of stamped system is solution of scaled system times column scale
+	Equilibrate() []float64
+}
+
+// conditionEstimator - Backends which estimate condition number of last factored matrix
+type conditionEstimator interface {
+	ConditionEstimate() (float64, error)
+}
+
+// equilibrate - Row and column scales making largest magnitude of every row, then every column 1.
+// each visits row, column and magnitude of stamped elements. Scales are powers of 2, so scaling is exact.
+// Empty rows and columns keep scale 1
+func equilibrate(size int, each func(visit func(i, j int, mag float64))) (row, col []float64) {
+	row = make([]float64, size+1)
+	col = make([]float64, size+1)
+	each(func(i, j int, mag float64) {
+		row[i] = math.Max(row[i], mag)
+	})
+	for i := range row {
+		row[i] = powerOfTwoInverse(row[i])
+	}
+	each(func(i, j int, mag float64) {
+		col[j] = math.Max(col[j], mag*row[i])
+	})
+	for j := range col {
+		col[j] = powerOfTwoInverse(col[j])
+	}
+	return row, col
+}
+
+// powerOfTwoInverse - Power of 2 nearest to 1/x, 1 for zero, Inf and NaN
+func powerOfTwoInverse(x float64) float64 {
+	if x == 0 || math.IsInf(x, 0) || math.IsNaN(x) {
+		return 1
+	}
+	_, exp := math.Frexp(x)
+	return math.Ldexp(1, 1-exp)
+}
+
+// SetScaling - Equilibrate rows and columns before factorization, for circuits mixing tiny and huge
+// conductances. eg. 1fF capacitor with 1MEG resistor. No effect on backends without scaling
+func (m *CircuitMatrix) SetScaling(scaling bool) { m.scaling = scaling }
+
+// ConditionEstimate - Infinity norm condition number of last factored matrix, after scaling when enabled
+func (m *CircuitMatrix) ConditionEstimate() (float64, error) {
+	estimator, ok := m.solver.(conditionEstimator)
+	if !ok {
+		return 0, fmt.Errorf("condition estimate not supported by %s solver", m.solverName)
+	}
+	return estimator.ConditionEstimate()
+}
+
+// unscale - Solution of stamped system from solution of scaled system
+func (m *CircuitMatrix) unscale(col []float64) {
+	solution := m.solver.Solution()
+	solutionImag := m.solver.SolutionImag()
+	for j := 1; j < len(col); j++ {
+		solution[j] *= col[j]
+		if m.isComplex {
+			solutionImag[j] *= col[j]
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package matrix
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Solver - Linear solver backend of CircuitMatrix. Devices stamp, then Factor and Solve
+type Solver interface {
+	DeviceMatrix
+	LoadGmin(gmin float64)
+	Clear()    // Zero matrix and right hand side
+	ClearRHS() // Zero right hand side only
+	Factor() error
+	Solve() error
+	Solution() []float64     // 1-based, real part
+	SolutionImag() []float64 // 1-based, imaginary part of complex system
+}
+
+// SolverFactory - Creates backend for system of size unknowns
+type SolverFactory func(size int, isComplex bool) (Solver, error)
+
+// DefaultSolver - Backend of NewMatrix
+const DefaultSolver = "sparse"
+
+var solvers = map[string]SolverFactory{
+	"sparse": newSparseSolver,
+	"dense": func(size int, isComplex bool) (Solver, error) {
+		return NewDense(size, isComplex), nil
+	},
+}
+
+// RegisterSolver - Add or replace backend selectable by name. eg. KLU binding, iterative solver
+func RegisterSolver(name string, factory SolverFactory) {
+	solvers[name] = factory
+}
+
+// SolverNames - Registered backend names, sorted
+func SolverNames() []string {
+	names := make([]string, 0, len(solvers))
+	for name := range solvers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func newSolver(name string, size int, isComplex bool) (Solver, error) {
+	if name == "" {
+		name = DefaultSolver
+	}
+	factory, ok := solvers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown solver %q, available: %v", name, SolverNames())
+	}
+	return factory(size, isComplex)
+}
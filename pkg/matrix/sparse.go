@@ -0,0 +1,397 @@
+package matrix
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/edp1096/sparse"
+)
+
+// sparseSolver - Default backend on edp1096/sparse
+type sparseSolver struct {
+	Size         int
+	matrix       *sparse.Matrix
+	rhs          []float64
+	rhsImag      []float64
+	solution     []float64
+	solutionImag []float64
+	isComplex    bool
+	config       *sparse.Configuration
+	norm         float64 // Infinity norm of matrix before last factorization
+}
+
+var _ Solver = (*sparseSolver)(nil)
+
+func newSparseSolver(size int, isComplex bool) (Solver, error) {
+	config := &sparse.Configuration{
+		Real:                    true,
+		Complex:                 isComplex,
+		SeparatedComplexVectors: false,
+		Translate:               true,
+		Expandable:              true,
+		ModifiedNodal:           true,
+		TiesMultiplier:          5,
+		PrinterWidth:            140,
+		Annotate:                0,
+	}
+
+	mat, err := sparse.Create(int64(size), config)
+	if err != nil {
+		return nil, fmt.Errorf("creating sparse matrix: %v", err)
+	}
+
+	vectorSize := size + 1 // rhs, solution size
+	vectorSizeImag := size + 1
+	if isComplex && !config.SeparatedComplexVectors {
+		vectorSize *= 2
+		vectorSizeImag = 1
+	}
+
+	return &sparseSolver{
+		Size:         size,
+		matrix:       mat,
+		rhs:          make([]float64, vectorSize), // 1-based indexing
+		rhsImag:      make([]float64, vectorSizeImag),
+		solution:     make([]float64, vectorSize),
+		solutionImag: make([]float64, vectorSizeImag),
+		config:       config,
+	}, nil
+}
+
+func (m *sparseSolver) AddElement(i, j int, value float64) {
+	if i <= 0 || j <= 0 || i > m.Size || j > m.Size {
+		Logger.Printf("Matrix index out of bounds (i=%d, j=%d, size=%d)", i, j, m.Size)
+		return
+	}
+	m.matrix.GetElement(int64(i), int64(j)).Real += value
+}
+
+func (m *sparseSolver) AddComplexElement(i, j int, real, imag float64) {
+	if i <= 0 || j <= 0 || i > m.Size || j > m.Size {
+		Logger.Printf("Matrix index out of bounds (i=%d, j=%d, size=%d)", i, j, m.Size)
+		return
+	}
+
+	element := m.matrix.GetElement(int64(i), int64(j))
+	element.Real += real
+	element.Imag += imag
+}
+
+func (m *sparseSolver) AddComplexRHS(i int, real, imag float64) {
+	if i <= 0 || i > m.Size {
+		Logger.Printf("RHS index out of bounds (i=%d, size=%d)", i, m.Size)
+		return
+	}
+
+	if m.config.SeparatedComplexVectors {
+		m.rhs[i] += real
+		m.rhsImag[i] += imag
+	} else {
+		m.rhs[2*i] += real
+		m.rhs[2*i+1] += imag
+	}
+}
+
+func (m *sparseSolver) AddRHS(i int, value float64) {
+	if i <= 0 || i > m.Size {
+		Logger.Printf("RHS index out of bounds (i=%d, size=%d)", i, m.Size)
+		return
+	}
+	if m.config.Complex && !m.config.SeparatedComplexVectors {
+		m.rhs[2*i] += value
+		return
+	}
+	m.rhs[i] += value
+}
+
+func (m *sparseSolver) LoadGmin(gmin float64) {
+	size := m.Size
+	for i := 1; i <= size; i++ {
+		if diag := m.diagElement(i); diag != nil {
+			diag.Real += gmin
+		}
+	}
+}
+
+func (m *sparseSolver) Clear() {
+	m.matrix.Clear()
+	for i := range m.rhs {
+		m.rhs[i] = 0
+	}
+	for i := range m.rhsImag {
+		m.rhsImag[i] = 0
+	}
+}
+
+func (m *sparseSolver) Factor() error {
+	m.norm = m.matrix.Norm()
+
+	// Pivots of last ordering are reused while they pass threshold, otherwise matrix is reordered.
+	// Elements are created by stamps, new ones after ordering also reorder
+	err := m.matrix.OrderAndFactor(nil, 0, 0, true)
+	if err != nil {
+		if singular := m.singularError(err); singular != nil {
+			return singular
+		}
+		return fmt.Errorf("matrix factorization failed: %v", err)
+	}
+	return nil
+}
+
+func (m *sparseSolver) Solve() error {
+	var err error
+
+	if m.config.Complex {
+		m.solution, m.solutionImag, err = m.matrix.SolveComplex(m.rhs, m.rhsImag)
+		if err == nil && !m.config.SeparatedComplexVectors {
+			m.splitComplexSolution()
+		}
+	} else {
+		m.solution, err = m.matrix.Solve(m.rhs)
+	}
+
+	if err != nil {
+		return fmt.Errorf("matrix solve failed: %v", err)
+	}
+
+	return nil
+}
+
+// Equilibrate - Scale elements in place. Factorization overwrites them anyway
+func (m *sparseSolver) Equilibrate() []float64 {
+	each := func(visit func(e *sparse.Element, i, j int)) {
+		for col := 1; col <= m.Size; col++ {
+			for e := m.matrix.FirstInCol[col]; e != nil; e = e.NextInCol {
+				visit(e, int(m.matrix.IntToExtRowMap[e.Row]), int(m.matrix.IntToExtColMap[e.Col]))
+			}
+		}
+	}
+	row, col := equilibrate(m.Size, func(visit func(i, j int, mag float64)) {
+		each(func(e *sparse.Element, i, j int) {
+			if m.config.Complex {
+				visit(i, j, math.Hypot(e.Real, e.Imag))
+			} else {
+				visit(i, j, math.Abs(e.Real))
+			}
+		})
+	})
+	each(func(e *sparse.Element, i, j int) {
+		e.Real *= row[i] * col[j]
+		e.Imag *= row[i] * col[j]
+	})
+
+	for i := 1; i <= m.Size; i++ {
+		switch {
+		case m.config.Complex && !m.config.SeparatedComplexVectors:
+			m.rhs[2*i] *= row[i]
+			m.rhs[2*i+1] *= row[i]
+		case m.config.Complex:
+			m.rhs[i] *= row[i]
+			m.rhsImag[i] *= row[i]
+		default:
+			m.rhs[i] *= row[i]
+		}
+	}
+	return col
+}
+
+// ConditionEstimate - Condition number by estimate of sparse package on factors
+func (m *sparseSolver) ConditionEstimate() (float64, error) {
+	rcond, err := m.matrix.Condition(m.norm)
+	if err != nil {
+		return 0, fmt.Errorf("condition estimate: %v", err)
+	}
+	if rcond == 0 {
+		return math.Inf(1), nil
+	}
+	return 1 / rcond, nil
+}
+
+// singularError - Singular row and column of factorization mapped back to circuit equations.
+// Nil when factorization reports no location.
+func (m *sparseSolver) singularError(err error) *SingularError {
+	row, col := m.matrix.SingularRow, m.matrix.SingularCol
+	if row == 0 && col == 0 {
+		// Direct factorization reports only step of zero pivot
+		var step int64
+		if _, scanErr := fmt.Sscanf(err.Error(), "zero pivot at step %d", &step); scanErr != nil {
+			return nil
+		}
+		row, col = step, step
+	}
+
+	singular := &SingularError{Err: err}
+	if row > 0 && int(row) < len(m.matrix.IntToExtRowMap) {
+		singular.Row = int(m.matrix.IntToExtRowMap[row])
+	}
+	if col > 0 && int(col) < len(m.matrix.IntToExtColMap) {
+		singular.Col = int(m.matrix.IntToExtColMap[col])
+	}
+	return singular
+}
+
+// splitComplexSolution - Interleaved solution [re, im, re, im, ...] to real and imaginary vectors
+func (m *sparseSolver) splitComplexSolution() {
+	interleaved := m.solution
+	m.solution = make([]float64, m.Size+1)
+	m.solutionImag = make([]float64, m.Size+1)
+	for i := 1; i <= m.Size; i++ {
+		m.solution[i] = interleaved[2*i]
+		m.solutionImag[i] = interleaved[2*i+1]
+	}
+}
+
+func (m *sparseSolver) diagElement(i int) *sparse.Element {
+	if i <= 0 || i > m.Size {
+		Logger.Printf("Diagonal index out of bounds (i=%d, size=%d)", i, m.Size)
+		return nil
+	}
+	return m.matrix.Diags[i]
+}
+
+// findElement - Existing element of row i and column j, nil when not stamped. GetElement would create it
+func (m *sparseSolver) findElement(i, j int) *sparse.Element {
+	if int64(i) > m.matrix.ExtSize || int64(j) > m.matrix.ExtSize {
+		return nil
+	}
+	row, col := m.matrix.ExtToIntRowMap[i], m.matrix.ExtToIntColMap[j]
+	if row <= 0 || col <= 0 {
+		return nil
+	}
+	for element := m.matrix.FirstInCol[col]; element != nil; element = element.NextInCol {
+		if element.Row == row {
+			return element
+		}
+	}
+	return nil
+}
+
+// ClearRHS - Zero right hand side and keep matrix elements
+func (m *sparseSolver) ClearRHS() {
+	clear(m.rhs)
+	clear(m.rhsImag)
+}
+
+func (m *sparseSolver) Solution() []float64 {
+	return m.solution
+}
+
+func (m *sparseSolver) SolutionImag() []float64 {
+	return m.solutionImag
+}
+
+func (m *sparseSolver) PrintSystem() {
+	fmt.Printf("\nCircuit Equations (%dx%d):\n", m.Size, m.Size)
+	fmt.Println("Node equations 1..n, followed by branch equations")
+
+	for i := 1; i <= m.Size; i++ {
+		fmt.Printf("Equation %d:\n", i)
+		rowHasElements := false
+		for j := 1; j <= m.Size; j++ {
+			element := m.findElement(i, j)
+			if element == nil {
+				continue
+			}
+			if m.config.Complex {
+				if element.Real != 0 || element.Imag != 0 {
+					if element.Imag == 0 {
+						fmt.Printf("  %+g*x%d ", element.Real, j)
+					} else {
+						fmt.Printf("  (%g + j%g)*x%d ", element.Real, element.Imag, j)
+					}
+					rowHasElements = true
+				}
+			} else {
+				if element.Real != 0 {
+					fmt.Printf("  %+g*x%d ", element.Real, j)
+					rowHasElements = true
+				}
+			}
+		}
+		if rowHasElements {
+			if !m.config.Complex {
+				fmt.Printf(" = %g\n", m.rhs[i])
+			} else {
+				if !m.config.SeparatedComplexVectors {
+					fmt.Printf(" = %g + j%g\n", m.rhs[2*i], m.rhs[2*i+1])
+				} else {
+					fmt.Printf(" = %g + j%g\n", m.rhs[i], m.rhsImag[i])
+				}
+			}
+		}
+	}
+
+	m.matrix.Print(false, true, true)
+
+	fmt.Printf("RHS:\n")
+	for i := 1; i <= m.Size; i++ {
+		if !m.config.Complex {
+			fmt.Printf("  x%d = %g\n", i, m.rhs[i])
+		} else {
+			if !m.config.SeparatedComplexVectors {
+				fmt.Printf("  x%d = %g + j%g\n", i, m.rhs[2*i], m.rhs[2*i+1])
+			} else {
+				fmt.Printf("  x%d = %g + j%g\n", i, m.rhs[i], m.rhsImag[i])
+			}
+		}
+	}
+}
+
+func (m *sparseSolver) printMatrixSummary() {
+	fmt.Println("\nMATRIX SUMMARY")
+	fmt.Printf("Size of matrix = %d x %d\n", m.Size, m.Size)
+
+	maxElement := 0.0
+	minElement := 1.79e+308
+	elementCount := 0
+	maxPivot := 0.0
+	minPivot := 1.79e+308
+
+	fmt.Println("Matrix before factorization:")
+	fmt.Printf("%3s", "")
+	for j := 1; j <= m.Size; j++ {
+		fmt.Printf("%10d", j)
+	}
+	fmt.Println()
+
+	for i := 1; i <= m.Size; i++ {
+		fmt.Printf("%4d", i)
+		for j := 1; j <= m.Size; j++ {
+			value := 0.0
+			if element := m.findElement(i, j); element != nil {
+				value = element.Real
+			}
+			fmt.Printf("%10.3f", value)
+
+			if value != 0 {
+				elementCount++
+				if value > maxElement {
+					maxElement = value
+				}
+				if value < minElement {
+					minElement = value
+				}
+				if i == j && value > maxPivot {
+					maxPivot = value
+				}
+				if i == j && value < minPivot {
+					minPivot = value
+				}
+			}
+		}
+		fmt.Println()
+	}
+
+	fmt.Printf("Largest element in matrix = %.3f\n", maxElement)
+	fmt.Printf("Smallest element in matrix = %.3f\n", minElement)
+	fmt.Printf("Largest pivot element = %.3f\n", maxPivot)
+	fmt.Printf("Smallest pivot element = %.3f\n", minPivot)
+	fmt.Printf("Density = %.2f%%\n", float64(elementCount)*100/float64(m.Size*m.Size))
+	fmt.Println()
+}
+
+func (m *sparseSolver) Destroy() {
+	if m.matrix != nil {
+		m.matrix.Destroy()
+	}
+}
@@ -0,0 +1,69 @@
+package netlist
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// addAnalysis - Keep analysis directive of line for Split. Directive of analysis already in netlist replaces it
+func addAnalysis(netlistData *NetlistData, line string) {
+	name := strings.TrimPrefix(strings.ToLower(strings.Fields(line)[0]), ".")
+	i := slices.Index(analysisNames, name)
+	if i < 0 {
+		return
+	}
+	if j := slices.Index(netlistData.Analyses, AnalysisType(i)); j >= 0 {
+		netlistData.analysisLines[j] = line
+		return
+	}
+	netlistData.Analyses = append(netlistData.Analyses, AnalysisType(i))
+	netlistData.analysisLines = append(netlistData.analysisLines, line)
+}
+
+// Split - Netlist of each analysis in order of netlist, with Analysis and its parameters of that analysis.
+// names selects analyses by name, eg. op, tran, all of netlist when empty. Netlist without analysis is
+// one operating point. Outputs are of .print and .plot of analysis or without analysis keyword.
+// Elements, models and options are shared with netlist
+func (n *NetlistData) Split(names ...string) ([]*NetlistData, error) {
+	if len(n.Analyses) == 0 {
+		if len(names) > 0 && !slices.Equal(names, []string{AnalysisOP.String()}) {
+			return nil, fmt.Errorf("netlist has no analysis %s", strings.Join(names, ", "))
+		}
+		return []*NetlistData{n}, nil
+	}
+
+	for _, name := range names {
+		i := slices.Index(analysisNames, strings.ToLower(name))
+		if i < 0 {
+			return nil, fmt.Errorf("unknown analysis %s", name)
+		}
+		if !slices.Contains(n.Analyses, AnalysisType(i)) {
+			return nil, fmt.Errorf("netlist has no analysis %s", name)
+		}
+	}
+
+	var netlists []*NetlistData
+	var zero NetlistData
+	for i, analysis := range n.Analyses {
+		if len(names) > 0 && !slices.ContainsFunc(names, func(name string) bool { return strings.EqualFold(name, analysis.String()) }) {
+			continue
+		}
+		c := *n
+		c.TranParam, c.ACParam, c.HBParam, c.DCParam = zero.TranParam, zero.ACParam, zero.HBParam, zero.DCParam
+		err := parseDotOperator(&c, n.analysisLines[i])
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %v", analysis, err)
+		}
+		c.Outputs = nil
+		for _, keyword := range []string{"", analysis.String()} {
+			for _, output := range n.analysisOutputs[keyword] {
+				if !slices.Contains(c.Outputs, output) {
+					c.Outputs = append(c.Outputs, output)
+				}
+			}
+		}
+		netlists = append(netlists, &c)
+	}
+	return netlists, nil
+}
@@ -0,0 +1,179 @@
+package netlist
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const maxIncludeDepth = 16
+
+// fileResolver - Files referenced by netlist. eg. .include, .lib and PWL FILE=
+type fileResolver interface {
+	// resolve - Name of fileName referenced from file in dir
+	resolve(dir, fileName string) (string, error)
+	readFile(name string) ([]byte, error)
+}
+
+// osResolver - Files of operating system. Parent and absolute paths are allowed
+type osResolver struct{}
+
+func (osResolver) resolve(dir, fileName string) (string, error) {
+	if filepath.IsAbs(fileName) {
+		return filepath.Clean(fileName), nil
+	}
+	return filepath.Join(dir, fileName), nil
+}
+
+func (osResolver) readFile(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+// fsResolver - Files of fsys. Paths must stay in fsys root, no file is resolved when fsys is nil
+type fsResolver struct {
+	fsys fs.FS
+}
+
+func (r fsResolver) resolve(dir, fileName string) (string, error) {
+	if r.fsys == nil {
+		return "", fmt.Errorf("no file system to resolve %s", fileName)
+	}
+	name := path.Join(dir, fileName)
+	if !fs.ValidPath(name) {
+		return "", fmt.Errorf("invalid include path: %s", fileName)
+	}
+	return name, nil
+}
+
+func (r fsResolver) readFile(name string) ([]byte, error) {
+	return fs.ReadFile(r.fsys, name)
+}
+
+// dirOf - Directory of resolved name, for files referenced from it
+func dirOf(files fileResolver, name string) string {
+	if _, ok := files.(osResolver); ok {
+		return filepath.Dir(name)
+	}
+	return path.Dir(name)
+}
+
+// expandIncludes - Replace .include and .lib references with file contents read from files.
+// dir is the directory of the file being expanded, referenced files are relative to it.
+func expandIncludes(input string, files fileResolver, dir string, depth int) (string, error) {
+	if depth > maxIncludeDepth {
+		return "", fmt.Errorf("include nesting too deep (max %d)", maxIncludeDepth)
+	}
+
+	var out strings.Builder
+	scanner := bufio.NewScanner(strings.NewReader(input))
+	for scanner.Scan() {
+		line := scanner.Text()
+		fields := strings.Fields(stripComment(line))
+		if len(fields) == 0 {
+			out.WriteString(line + "\n")
+			continue
+		}
+
+		switch strings.ToLower(fields[0]) {
+		case ".include", ".inc":
+			if len(fields) < 2 {
+				return "", fmt.Errorf("missing file name in %s", fields[0])
+			}
+			content, name, err := readIncludeFile(files, dir, fields[1])
+			if err != nil {
+				return "", err
+			}
+			expanded, err := expandIncludes(content, files, dirOf(files, name), depth+1)
+			if err != nil {
+				return "", fmt.Errorf("%s: %v", name, err)
+			}
+			out.WriteString(expanded)
+
+		case ".lib":
+			if len(fields) < 3 {
+				return "", fmt.Errorf("invalid .lib statement, need file name and section: %s", line)
+			}
+			content, name, err := readIncludeFile(files, dir, fields[1])
+			if err != nil {
+				return "", err
+			}
+			section, err := extractLibSection(content, fields[2])
+			if err != nil {
+				return "", fmt.Errorf("%s: %v", name, err)
+			}
+			expanded, err := expandIncludes(section, files, dirOf(files, name), depth+1)
+			if err != nil {
+				return "", fmt.Errorf("%s: %v", name, err)
+			}
+			out.WriteString(expanded)
+
+		default:
+			out.WriteString(line + "\n")
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
+
+// readIncludeFile - Content and resolved name of fileName referenced from file in dir
+func readIncludeFile(files fileResolver, dir, fileName string) (string, string, error) {
+	fileName = strings.Trim(fileName, `"'`)
+	name, err := files.resolve(dir, fileName)
+	if err != nil {
+		return "", "", err
+	}
+
+	content, err := files.readFile(name)
+	if err != nil {
+		return "", "", fmt.Errorf("reading include file %s: %v", fileName, err)
+	}
+
+	return string(content), name, nil
+}
+
+// extractLibSection - Return lines between ".lib <section>" and ".endl"
+func extractLibSection(content, section string) (string, error) {
+	var out strings.Builder
+	inSection := false
+	found := false
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		fields := strings.Fields(line)
+
+		if len(fields) > 0 {
+			keyword := strings.ToLower(fields[0])
+			if !inSection && keyword == ".lib" && len(fields) == 2 && strings.EqualFold(fields[1], section) {
+				inSection = true
+				found = true
+				continue
+			}
+			if inSection && keyword == ".endl" {
+				inSection = false
+				continue
+			}
+		}
+
+		if inSection {
+			out.WriteString(line + "\n")
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	if !found {
+		return "", fmt.Errorf("library section %s not found", section)
+	}
+
+	return out.String(), nil
+}
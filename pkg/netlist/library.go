@@ -0,0 +1,32 @@
+package netlist
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+)
+
+//go:embed models.lib
+var defaultLibrary string
+
+// DefaultLibrary - Bundled models of D, 1N4148, 1N4007, 2N2222, 2N7000, IRF540 and OPAMP are usable without .model card.
+// Set false to require .model card for every model
+var DefaultLibrary = true
+
+// loadDefaultModels - Add bundled models not defined by netlist. Model names are case-insensitive
+func loadDefaultModels(netlistData *NetlistData) error {
+	library, err := parse(defaultLibrary, fsResolver{}, ".")
+	if err != nil {
+		return fmt.Errorf("default model library: %v", err)
+	}
+	for name, model := range library.Models {
+		if _, ok := lookupModel(netlistData.Models, name); ok {
+			continue
+		}
+		netlistData.Models[name] = model
+	}
+	if len(library.Warnings) > 0 {
+		return fmt.Errorf("default model library: %s", strings.Join(library.Warnings, "; "))
+	}
+	return nil
+}
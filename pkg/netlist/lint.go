@@ -0,0 +1,170 @@
+package netlist
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/edp1096/toy-spice/pkg/device"
+)
+
+// Lint - Sanity check of parsed netlist before analysis.
+// Returns warnings for suspicious topology and an error for circuits which can not be solved.
+func Lint(netlistData *NetlistData) ([]string, error) {
+	var warnings []string
+
+	// Duplicate element names
+	names := make(map[string]string)
+	for _, elem := range netlistData.Elements {
+		key := strings.ToLower(elem.Name)
+		if prev, exists := names[key]; exists {
+			return warnings, fmt.Errorf("duplicate element name: %s and %s", prev, elem.Name)
+		}
+		names[key] = elem.Name
+	}
+
+	// Shorted voltage sources and loops of voltage sources/inductors
+	loops := newNodeSet()
+	for _, elem := range netlistData.Elements {
+		if !HasBranch(elem) {
+			continue
+		}
+		if len(elem.Nodes) < 2 {
+			continue
+		}
+
+		n1, n2 := lintNodeName(elem.Nodes[0]), lintNodeName(elem.Nodes[1])
+		if n1 == n2 {
+			if elem.Type != "L" {
+				return warnings, fmt.Errorf("voltage source %s is shorted (both terminals on node %s)", elem.Name, n1)
+			}
+			warnings = append(warnings, fmt.Sprintf("inductor %s is shorted (both terminals on node %s)", elem.Name, n1))
+			continue
+		}
+		if loops.connected(n1, n2) {
+			return warnings, fmt.Errorf("%s closes a loop of voltage sources and/or inductors", elem.Name)
+		}
+		loops.union(n1, n2)
+	}
+
+	// Floating nodes - only one connection
+	connections := make(map[string]int)
+	for _, elem := range netlistData.Elements {
+		for _, node := range ElementNodes(elem) {
+			connections[lintNodeName(node)]++
+		}
+	}
+
+	var nodes []string
+	for node := range connections {
+		if node != "0" {
+			nodes = append(nodes, node)
+		}
+	}
+	sort.Strings(nodes)
+
+	for _, node := range nodes {
+		if connections[node] == 1 {
+			warnings = append(warnings, fmt.Sprintf("node %s has only one connection", node))
+		}
+	}
+
+	// DC path to ground
+	for _, node := range FloatingNodes(netlistData.Elements) {
+		warnings = append(warnings, fmt.Sprintf("node %s has no DC path to ground", node))
+	}
+
+	return warnings, nil
+}
+
+// FloatingNodes - Sorted nodes without DC path to ground, eg. between capacitors or at current source only
+func FloatingNodes(elements []Element) []string {
+	dcPaths := newNodeSet()
+	seen := make(map[string]bool)
+	var nodes []string
+	for _, elem := range elements {
+		terminals := dcTerminals(elem)
+		for i := 1; i < len(terminals); i++ {
+			dcPaths.union(lintNodeName(terminals[0]), lintNodeName(terminals[i]))
+		}
+		for _, node := range ElementNodes(elem) {
+			node = lintNodeName(node)
+			if node != "0" && !seen[node] {
+				seen[node] = true
+				nodes = append(nodes, node)
+			}
+		}
+	}
+	sort.Strings(nodes)
+
+	var floating []string
+	for _, node := range nodes {
+		if !dcPaths.connected(node, "0") {
+			floating = append(floating, node)
+		}
+	}
+	return floating
+}
+
+// dcTerminals - Terminals of element which are connected each other at DC
+func dcTerminals(elem Element) []string {
+	switch elem.Type {
+	case "R", "L", "V", "D", "Q", "P", "W", "Y":
+		return elem.Nodes
+	case "S":
+		// Contact conducts at least by roff, control nodes are sensed only
+		if len(elem.Nodes) == 4 {
+			return elem.Nodes[:2]
+		}
+	case "E", "A":
+		// Output is voltage source, control nodes are sensed only
+		if len(elem.Nodes) >= 4 {
+			return elem.Nodes[:2]
+		}
+	case "M":
+		// Gate is insulated. Drain, source and bulk are connected by channel and junctions
+		if len(elem.Nodes) == 4 {
+			return []string{elem.Nodes[0], elem.Nodes[2], elem.Nodes[3]}
+		}
+	}
+	if _, ok := device.Registered(elem.Type); ok {
+		// Custom devices are taken as conducting between all terminals
+		return elem.Nodes
+	}
+	return nil
+}
+
+func lintNodeName(name string) string {
+	if IsGround(name) {
+		return "0"
+	}
+	return name
+}
+
+// Disjoint set of node names
+type nodeSet map[string]string
+
+func newNodeSet() nodeSet {
+	return make(nodeSet)
+}
+
+func (s nodeSet) find(node string) string {
+	parent, ok := s[node]
+	if !ok || parent == node {
+		return node
+	}
+	root := s.find(parent)
+	s[node] = root
+	return root
+}
+
+func (s nodeSet) union(a, b string) {
+	rootA, rootB := s.find(a), s.find(b)
+	if rootA != rootB {
+		s[rootA] = rootB
+	}
+}
+
+func (s nodeSet) connected(a, b string) bool {
+	return s.find(a) == s.find(b)
+}
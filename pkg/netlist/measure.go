@@ -0,0 +1,92 @@
+package netlist
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Measure - Scalar measurement of .measure over results of analysis
+type Measure struct {
+	Analysis string  // Lowercase analysis of results. tran or dc
+	Name     string  // Name of measurement
+	Kind     string  // Uppercase operation. FIND, DERIV, INTEG, AVG, RMS or PP
+	Expr     string  // Expression of output variables. eg. v(out), v(1)*i(R1), (v(2)-v(1))/1k
+	At       float64 // Time or sweep value of FIND and DERIV
+	From     float64 // Start of INTEG, AVG, RMS and PP window, NaN is first point
+	To       float64 // End of INTEG, AVG, RMS and PP window, NaN is last point
+}
+
+// Operations of .measure
+var measureKinds = []string{"FIND", "DERIV", "INTEG", "AVG", "RMS", "PP"}
+
+// OutputVariables - Output variables in expression of measurement. eg. V(1), I(R1) of v(1)*i(R1)
+func (m Measure) OutputVariables() []string {
+	return parseOutputVariables(m.Expr)
+}
+
+// parseMeasure - .measure tran|dc name FIND|DERIV expr AT=val, .measure tran|dc name INTEG|AVG|RMS|PP expr [FROM=val] [TO=val]
+func parseMeasure(netlistData *NetlistData, fields []string) error {
+	body := regexp.MustCompile(`\s*=\s*`).ReplaceAllString(strings.Join(fields, " "), "=")
+	fields = strings.Fields(body)
+	if len(fields) < 4 {
+		return fmt.Errorf("insufficient measure parameters, need analysis, name, operation and expression")
+	}
+
+	m := Measure{
+		Analysis: strings.ToLower(fields[0]),
+		Name:     fields[1],
+		Kind:     strings.ToUpper(fields[2]),
+		At:       math.NaN(),
+		From:     math.NaN(),
+		To:       math.NaN(),
+	}
+	if m.Analysis != "tran" && m.Analysis != "dc" {
+		return fmt.Errorf("unsupported measure analysis %s of %s", fields[0], m.Name)
+	}
+	if !slices.Contains(measureKinds, m.Kind) {
+		return fmt.Errorf("unsupported measure operation %s of %s", fields[2], m.Name)
+	}
+
+	// Expression ends at first name=value parameter
+	var expr []string
+	for i, field := range fields[3:] {
+		name, valueText, ok := strings.Cut(field, "=")
+		if !ok || strings.Contains(name, "(") {
+			if i > len(expr) {
+				return fmt.Errorf("invalid measure parameter %s of %s", field, m.Name)
+			}
+			expr = append(expr, field)
+			continue
+		}
+		value, err := ParseValue(valueText)
+		if err != nil {
+			return fmt.Errorf("invalid measure %s of %s: %v", strings.ToLower(name), m.Name, err)
+		}
+		switch strings.ToLower(name) {
+		case "at":
+			m.At = value
+		case "from":
+			m.From = value
+		case "to":
+			m.To = value
+		default:
+			return fmt.Errorf("unsupported measure parameter %s of %s", name, m.Name)
+		}
+	}
+	m.Expr = strings.Join(expr, " ")
+
+	switch {
+	case m.Expr == "":
+		return fmt.Errorf("measure %s requires expression", m.Name)
+	case (m.Kind == "FIND" || m.Kind == "DERIV") && math.IsNaN(m.At):
+		return fmt.Errorf("measure %s %s requires AT", m.Name, m.Kind)
+	case m.From > m.To:
+		return fmt.Errorf("measure %s requires FROM <= TO", m.Name)
+	}
+
+	netlistData.Measures = append(netlistData.Measures, m)
+	return nil
+}
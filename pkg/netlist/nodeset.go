@@ -0,0 +1,48 @@
+package netlist
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WriteNodeSet - .nodeset block of node voltages, one node per line, read back by .include or embedded in netlist
+func WriteNodeSet(w io.Writer, voltages map[string]float64) error {
+	names := make([]string, 0, len(voltages))
+	for name := range voltages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString("* Operating point\n")
+	for _, name := range names {
+		fmt.Fprintf(&sb, ".nodeset v(%s)=%s\n", name, strconv.FormatFloat(voltages[name], 'g', -1, 64))
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// EmbedNodeSet - Netlist with its .nodeset lines and continuations replaced by block of voltages at end
+func EmbedNodeSet(input string, voltages map[string]float64) string {
+	var out strings.Builder
+	lines := strings.Split(strings.TrimRight(input, "\n"), "\n")
+	inNodeSet := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if i > 0 && inNodeSet && strings.HasPrefix(trimmed, "+") {
+			continue
+		}
+		fields := strings.Fields(trimmed)
+		inNodeSet = i > 0 && len(fields) > 0 && strings.EqualFold(fields[0], ".nodeset")
+		if inNodeSet || (i > 0 && trimmed == "* Operating point") {
+			continue
+		}
+		out.WriteString(line + "\n")
+	}
+
+	WriteNodeSet(&out, voltages)
+	return out.String()
+}
@@ -0,0 +1,62 @@
+package netlist
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Parasitic resistance parameters by element type, LTspice style
+//
+//	Vname n+ n- <source> rser=<ohm>   Series resistance of voltage source
+//	Cname n+ n- <value> rser=<ohm>    Equivalent series resistance of capacitor
+//	Lname n+ n- <value> rpar=<ohm>    Parallel resistance of inductor
+//
+// Series resistance is resistor "<name>.RSER" from n+ to internal node "<name>#rser" where element is moved,
+// so branch current and IC= of element are kept. Parallel resistance is resistor "<name>.RPAR" across element
+var parasiticParams = map[string]string{"V": "rser", "C": "rser", "L": "rpar"}
+
+// expandParasitic - Element and resistor of its rser= or rpar=, element only when not given or zero
+func expandParasitic(elem Element) ([]Element, error) {
+	param, ok := parasiticParams[elem.Type]
+	if !ok {
+		return []Element{elem}, nil
+	}
+	valueStr, ok := elem.Params[param]
+	if !ok {
+		return []Element{elem}, nil
+	}
+	value, err := ParseValue(valueStr)
+	if err != nil || value < 0 {
+		return nil, fmt.Errorf("%s: invalid %s=%s", elem.Name, param, valueStr)
+	}
+	delete(elem.Params, param)
+	if value == 0 {
+		return []Element{elem}, nil
+	}
+
+	resistor := Element{
+		Type:   "R",
+		Name:   elem.Name + "." + strings.ToUpper(param),
+		Nodes:  []string{elem.Nodes[0], elem.Nodes[1]},
+		Value:  value,
+		Params: make(map[string]string),
+	}
+	if param == "rser" {
+		internal := elem.Name + "#" + param
+		resistor.Nodes[1] = internal
+		elem.Nodes = []string{internal, elem.Nodes[1]}
+	}
+	return []Element{elem, resistor}, nil
+}
+
+// cutParam - Value of name=value field of fields and fields without it. eg. rser=1 of voltage source
+func cutParam(fields []string, name string) (string, []string, bool) {
+	for i, field := range fields {
+		key, value, found := strings.Cut(field, "=")
+		if found && strings.EqualFold(key, name) {
+			rest := append(append([]string{}, fields[:i]...), fields[i+1:]...)
+			return value, rest, true
+		}
+	}
+	return "", fields, false
+}
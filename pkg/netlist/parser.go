@@ -3,6 +3,8 @@ package netlist
 import (
 	"bufio"
 	"fmt"
+	"io/fs"
+	"maps"
 	"regexp"
 	"slices"
 	"strconv"
@@ -18,13 +20,33 @@ const (
 	AnalysisTRAN
 	AnalysisAC
 	AnalysisDC
+	AnalysisSP
+	AnalysisDISTO
+	AnalysisHB
+	AnalysisSTB
 )
 
+// Analysis names by type, also directive without dot. eg. tran of .tran
+var analysisNames = []string{"op", "tran", "ac", "dc", "sp", "disto", "hb", "stb"}
+
+func (t AnalysisType) String() string {
+	if int(t) < len(analysisNames) {
+		return analysisNames[t]
+	}
+	return fmt.Sprintf("AnalysisType(%d)", int(t))
+}
+
 type NetlistData struct {
+	files           fileResolver        // Files referenced by netlist. eg. PWL FILE=
+	dir             string              // Directory of netlist, files are resolved from it
+	analysisLines   []string            // Directive of each of Analyses, parsed again by Split
+	analysisOutputs map[string][]string // Outputs of .print and .plot by analysis keyword
+
 	Elements  []Element                    // Circuit elements
 	Nodes     map[string]int               // Node name and index
-	Models    map[string]device.ModelParam // Model parameters
-	Analysis  AnalysisType                 // Analysis type
+	Models    map[string]device.ModelParam // Model parameters by ModelKey of name
+	Analysis  AnalysisType                 // Analysis type, last analysis of netlist
+	Analyses  []AnalysisType               // Analyses of netlist in order, later directive of same type replaces earlier
 	TranParam struct {
 		TStep  float64 // timestep
 		TStop  float64 // stop time
@@ -33,10 +55,19 @@ type NetlistData struct {
 		UIC    bool    // Use Initial Conditions
 	}
 	ACParam struct {
-		Sweep  string  // DEC, OCT, LIN
+		Sweep  string  // DEC, OCT, LIN, LIST
 		FStart float64 // start frequency
 		Points int     // points per decade
 		FStop  float64 // stop frequency
+
+		Frequencies []float64 // frequencies of LIST sweep
+
+		F2OverF1 float64 // Second tone of .disto by f1, 0 is harmonics only
+		Probe    string  // Loop probe of .stb, empty is only probe of circuit
+	} // Also frequency sweep of .sp, .disto and .stb
+	HBParam struct {
+		F0        float64 // fundamental frequency
+		Harmonics int     // number of harmonics
 	}
 	DCParam struct {
 		Source1    string
@@ -48,7 +79,16 @@ type NetlistData struct {
 		Stop2      float64
 		Increment2 float64
 	}
-	Title string // Circuit title
+	Outputs  []string  // Output variables from .print and .plot. eg. V(1), V(1,2), I(V1)
+	Saves    []string  // Output variables kept by transient from .save, empty keeps all. eg. V(out), I(Vin)
+	SaveAll  bool      // .save all, Saves are ignored
+	Measures []Measure // Measurements from .measure. eg. .meas tran pavg AVG v(1)*i(R1) FROM=1m TO=2m
+	Title    string    // Circuit title
+	Warnings []string  // Non-fatal parse warnings
+
+	InitialConditions map[string]float64 // Node voltages from .ic. eg. v(1)=5
+	NodeSets          map[string]float64 // Initial guess of operating point from .nodeset. eg. v(1)=5
+	Options           map[string]float64 // Simulator options from .options by lowercase name. eg. maxpoints=100000
 }
 
 type Element struct {
@@ -59,31 +99,73 @@ type Element struct {
 	Params map[string]string // Parameter values
 }
 
+// Scale factors by lowercase suffix, case insensitive like SPICE. M is milli, MEG is mega
 var unitMap = map[string]float64{
-	"T":   1e12,  // tera
-	"G":   1e9,   // giga
-	"meg": 1e6,   // mega
-	"K":   1e3,   // kilo
-	"k":   1e3,   // kilo
-	"m":   1e-3,  // milli
-	"u":   1e-6,  // micro
-	"n":   1e-9,  // nano
-	"p":   1e-12, // pico
-	"f":   1e-15, // femto
+	"t":   1e12,    // tera
+	"g":   1e9,     // giga
+	"meg": 1e6,     // mega
+	"k":   1e3,     // kilo
+	"m":   1e-3,    // milli
+	"mil": 25.4e-6, // 1/1000 inch
+	"u":   1e-6,    // micro
+	"n":   1e-9,    // nano
+	"p":   1e-12,   // pico
+	"f":   1e-15,   // femto
+	"%":   1e-2,    // percent
 }
 
+// Number, scale factor and optional unit. eg. 10k, 1MEG, 5mil, 1uF, 2.2kOhm, 10ns, 5%
+var valueRegexp = regexp.MustCompile(`(?i)^([-+]?\d*\.?\d+(?:e[-+]?\d+)?)(?:(%)|(meg|mil|[tgkmunpf])?(v|a|f|h|ohms?|s|hz)?)$`)
+
+// Parse - Parse netlist. .include and .lib files are resolved from current directory
 func Parse(input string) (*NetlistData, error) {
-	scanner := bufio.NewScanner(strings.NewReader(input))
+	return ParseDir(input, ".")
+}
+
+// ParseDir - Parse netlist of file in dir. .include and .lib files are resolved from dir,
+// nested ones from directory of including file. Parent and absolute paths are allowed
+func ParseDir(input, dir string) (*NetlistData, error) {
+	return parseWith(input, osResolver{}, dir)
+}
+
+// ParseFS - Parse netlist. .include and .lib files are resolved from fsys root, nil fsys rejects them
+func ParseFS(input string, fsys fs.FS) (*NetlistData, error) {
+	return parseWith(input, fsResolver{fsys}, ".")
+}
+
+func parseWith(input string, files fileResolver, dir string) (*NetlistData, error) {
+	netlistData, err := parse(input, files, dir)
+	if err != nil {
+		return nil, err
+	}
+	if DefaultLibrary {
+		err = loadDefaultModels(netlistData)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return netlistData, nil
+}
+
+func parse(input string, files fileResolver, dir string) (*NetlistData, error) {
 	netlistData := &NetlistData{
-		Nodes:  make(map[string]int),
-		Models: make(map[string]device.ModelParam),
+		files:   files,
+		dir:     dir,
+		Nodes:   make(map[string]int),
+		Models:  make(map[string]device.ModelParam),
+		Options: make(map[string]float64),
 	}
 
 	// Title or comment
-	if scanner.Scan() {
-		netlistData.Title = strings.TrimPrefix(scanner.Text(), "*")
-		netlistData.Title = strings.TrimSpace(netlistData.Title)
+	title, body, _ := strings.Cut(input, "\n")
+	netlistData.Title = strings.TrimPrefix(strings.TrimRight(title, "\r"), "*")
+	netlistData.Title = strings.TrimSpace(netlistData.Title)
+
+	body, err := expandIncludes(body, files, dir, 0)
+	if err != nil {
+		return nil, err
 	}
+	scanner := bufio.NewScanner(strings.NewReader(body))
 
 	var currentLine string
 	var continuationMode bool
@@ -93,7 +175,7 @@ func Parse(input string) (*NetlistData, error) {
 		line = strings.TrimSpace(line)
 
 		if len(line) == 0 {
-			if currentLine != "" {
+			if currentLine != "" && !hasOpenModelBody(currentLine) {
 				if err := parseLine(netlistData, currentLine); err != nil {
 					return nil, err
 				}
@@ -103,23 +185,21 @@ func Parse(input string) (*NetlistData, error) {
 			continue
 		}
 
-		// Remove comment part in line
-		if idx := strings.Index(line, "*"); idx >= 0 {
-			line = strings.TrimSpace(line[:idx])
-			if len(line) == 0 {
-				continue
-			}
+		// Remove comment line. Continuation may follow, so pending line is kept
+		if strings.HasPrefix(line, "*") {
+			continue
 		}
 
-		// Remove comment line
-		if strings.HasPrefix(line, "*") {
-			if currentLine != "" {
-				if err := parseLine(netlistData, currentLine); err != nil {
-					return nil, err
-				}
-				currentLine = ""
-				continuationMode = false
-			}
+		// Remove end of line comment. * is multiplication of expressions
+		line = stripComment(line)
+		owner := line
+		if strings.HasPrefix(line, "+") || (continuationMode && strings.HasPrefix(scanner.Text(), " ")) {
+			owner = currentLine
+		}
+		if !hasExpression(owner) {
+			line = stripStarComment(line)
+		}
+		if len(line) == 0 {
 			continue
 		}
 
@@ -142,6 +222,15 @@ func Parse(input string) (*NetlistData, error) {
 			continue
 		}
 
+		// Parenthesized .model body over multiple lines
+		if hasOpenModelBody(currentLine) {
+			if !isModelBodyLine(line) {
+				return nil, fmt.Errorf("unclosed parenthesis in model: %s", currentLine)
+			}
+			currentLine += " " + line
+			continue
+		}
+
 		// New line
 		if currentLine != "" {
 			if err := parseLine(netlistData, currentLine); err != nil {
@@ -153,6 +242,9 @@ func Parse(input string) (*NetlistData, error) {
 	}
 
 	// Last line - TODO: .END
+	if hasOpenModelBody(currentLine) {
+		return nil, fmt.Errorf("unclosed parenthesis in model: %s", currentLine)
+	}
 	if currentLine != "" {
 		if err := parseLine(netlistData, currentLine); err != nil {
 			return nil, err
@@ -162,28 +254,151 @@ func Parse(input string) (*NetlistData, error) {
 	return netlistData, nil
 }
 
+// stripComment - Line without ; comment or $ comment at start or after whitespace. eg. R1 1 2 1k ; load
+func stripComment(line string) string {
+	if idx := strings.Index(line, ";"); idx >= 0 {
+		line = line[:idx]
+	}
+	for i := range len(line) {
+		if line[i] == '$' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t') {
+			line = line[:i]
+			break
+		}
+	}
+	return strings.TrimSpace(line)
+}
+
+// stripStarComment - Line without * comment at start or after whitespace, outside {} expressions. eg. R1 1 0 1k * load
+func stripStarComment(line string) string {
+	depth := 0
+	for i := range len(line) {
+		switch line[i] {
+		case '{':
+			depth++
+		case '}':
+			depth = max(depth-1, 0)
+		case '*':
+			if depth == 0 && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t') {
+				return strings.TrimSpace(line[:i])
+			}
+		}
+	}
+	return line
+}
+
+// hasExpression - Line whose values are expressions with * multiplication. .measure, .param, .func and B sources
+func hasExpression(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToLower(fields[0]) {
+	case ".meas", ".measure", ".param", ".func":
+		return true
+	}
+	return strings.EqualFold(fields[0][:1], "B")
+}
+
+// IsGround - Ground node names. "0", "gnd", "GND", ...
+func IsGround(name string) bool {
+	return name == "0" || strings.EqualFold(name, "gnd")
+}
+
+// HasBranch - Element with branch current unknown in MNA. Voltage sources, inductors, VCVS, behavioral blocks
+// and custom devices registered with branch
+func HasBranch(elem Element) bool {
+	if f, ok := device.Registered(elem.Type); ok {
+		return f.Branch
+	}
+	return elem.Type == "V" || elem.Type == "L" || elem.Type == "E" || elem.Type == "A" || elem.Type == "Y"
+}
+
+// ThermalNode - Thermal node of power device by tj=node, empty when not coupled
+func ThermalNode(elem Element) string {
+	switch elem.Type {
+	case "R", "D", "Q", "M":
+		return elem.Params["tj"]
+	}
+	return ""
+}
+
+// ElementNodes - Nodes of element including thermal node
+func ElementNodes(elem Element) []string {
+	if node := ThermalNode(elem); node != "" {
+		return append(slices.Clone(elem.Nodes), node)
+	}
+	return elem.Nodes
+}
+
+// hasOpenModelBody - .model line with unclosed parenthesis
+func hasOpenModelBody(line string) bool {
+	if !strings.HasPrefix(strings.ToLower(line), ".model") {
+		return false
+	}
+	return strings.Count(line, "(") > strings.Count(line, ")")
+}
+
+// isModelBodyLine - Line of parameters continuing parenthesized .model body. eg. "Is=1n N=2)", "N = 2", ")"
+func isModelBodyLine(line string) bool {
+	fields := strings.Fields(strings.NewReplacer("(", " ( ", ")", " ) ").Replace(line))
+	if len(fields) == 0 {
+		return false
+	}
+	first := fields[0]
+	return first == "(" || first == ")" || strings.Contains(first, "=") || (len(fields) > 1 && strings.HasPrefix(fields[1], "="))
+}
+
 func parseLine(netlistData *NetlistData, line string) error {
 	line = regexp.MustCompile(`\s+`).ReplaceAllString(line, " ") // Remove multiple spaces
 
 	if strings.HasPrefix(line, ".") {
-		return parseDotOperator(netlistData, line)
+		err := parseDotOperator(netlistData, line)
+		if err == nil {
+			addAnalysis(netlistData, line)
+		}
+		return err
+	}
+
+	if fields := strings.Fields(line); len(fields) > 0 && isTransformer(fields[0]) {
+		elements, err := parseTransformer(fields)
+		if err != nil {
+			return err
+		}
+		for _, element := range elements {
+			addElement(netlistData, element)
+		}
+		return nil
 	}
 
 	element, err := parseElement(line)
 	if err != nil {
 		return err
 	}
+	err = loadPWLFile(netlistData, element)
+	if err != nil {
+		return err
+	}
+
+	elements, err := expandParasitic(*element)
+	if err != nil {
+		return err
+	}
+	for _, element := range elements {
+		addElement(netlistData, element)
+	}
+	return nil
+}
 
-	netlistData.Elements = append(netlistData.Elements, *element)
+func addElement(netlistData *NetlistData, element Element) {
+	netlistData.Elements = append(netlistData.Elements, element)
 	for _, node := range element.Nodes {
 		if _, exists := netlistData.Nodes[node]; !exists {
 			netlistData.Nodes[node] = len(netlistData.Nodes)
 		}
 	}
-	return nil
 }
 
-// Parse .op, .tran, .ac, .model
+// Parse .op, .tran, .ac, .sp, .hb, .stb, .ic, .nodeset, .model, .print, .plot, .save, .measure
 func parseDotOperator(netlistData *NetlistData, line string) error {
 	var err error
 
@@ -196,9 +411,55 @@ func parseDotOperator(netlistData *NetlistData, line string) error {
 	case ".model":
 		return parseModel(netlistData, fields[1:])
 
+	case ".global":
+		// Without subcircuits every node is global already
+		netlistData.Warnings = append(netlistData.Warnings, fmt.Sprintf("unsupported %s ignored, subcircuits are not supported", line))
+
+	case ".print", ".plot":
+		// Outputs by analysis keyword for Split, empty keyword is of every analysis
+		keyword := ""
+		if len(fields) > 1 && slices.Contains(analysisNames, strings.ToLower(fields[1])) {
+			keyword = strings.ToLower(fields[1])
+		}
+		if netlistData.analysisOutputs == nil {
+			netlistData.analysisOutputs = make(map[string][]string)
+		}
+		for _, output := range parseOutputVariables(strings.Join(fields[1:], " ")) {
+			if !slices.Contains(netlistData.Outputs, output) {
+				netlistData.Outputs = append(netlistData.Outputs, output)
+			}
+			if !slices.Contains(netlistData.analysisOutputs[keyword], output) {
+				netlistData.analysisOutputs[keyword] = append(netlistData.analysisOutputs[keyword], output)
+			}
+		}
+
+	case ".save":
+		for _, field := range fields[1:] {
+			if strings.EqualFold(field, "all") {
+				netlistData.SaveAll = true
+			}
+		}
+		for _, output := range parseOutputVariables(strings.Join(fields[1:], " ")) {
+			if !slices.Contains(netlistData.Saves, output) {
+				netlistData.Saves = append(netlistData.Saves, output)
+			}
+		}
+
+	case ".measure", ".meas":
+		return parseMeasure(netlistData, fields[1:])
+
 	case ".op":
 		netlistData.Analysis = AnalysisOP
 
+	case ".ic":
+		return parseInitialConditions(netlistData, strings.Join(fields[1:], " "))
+
+	case ".nodeset":
+		return parseNodeSets(netlistData, strings.Join(fields[1:], " "))
+
+	case ".options", ".option", ".opt":
+		return parseOptions(netlistData, strings.Join(fields[1:], " "))
+
 	case ".tran":
 		netlistData.Analysis = AnalysisTRAN
 		if len(fields) < 3 {
@@ -214,7 +475,7 @@ func parseDotOperator(netlistData *NetlistData, line string) error {
 		}
 
 		for i := 3; i < len(fields); i++ {
-			if fields[i] == "uic" {
+			if strings.EqualFold(fields[i], "uic") {
 				netlistData.TranParam.UIC = true
 				continue
 			}
@@ -235,8 +496,45 @@ func parseDotOperator(netlistData *NetlistData, line string) error {
 			netlistData.TranParam.TMax = netlistData.TranParam.TStep
 		}
 
-	case ".ac":
+	case ".ac", ".sp", ".disto", ".stb":
 		netlistData.Analysis = AnalysisAC
+		if strings.EqualFold(fields[0], ".sp") {
+			netlistData.Analysis = AnalysisSP
+		}
+		if strings.EqualFold(fields[0], ".disto") {
+			netlistData.Analysis = AnalysisDISTO
+		}
+
+		// .stb dec nd fstart fstop <probe>
+		if strings.EqualFold(fields[0], ".stb") {
+			netlistData.Analysis = AnalysisSTB
+			netlistData.ACParam.Probe = ""
+			if last := fields[len(fields)-1]; len(fields) > 1 && strings.EqualFold(last[:1], "Y") {
+				netlistData.ACParam.Probe = last
+				fields = fields[:len(fields)-1]
+			}
+		}
+
+		// LIST f1 f2 ...
+		if len(fields) > 1 && strings.EqualFold(fields[1], "LIST") {
+			if len(fields) < 3 {
+				return fmt.Errorf("insufficient AC parameters, need at least one frequency for list")
+			}
+			netlistData.ACParam.Sweep = "LIST"
+			netlistData.ACParam.Frequencies = nil
+			for _, field := range fields[2:] {
+				freq, err := ParseValue(field)
+				if err != nil || freq <= 0 {
+					return fmt.Errorf("invalid list frequency: %s", field)
+				}
+				netlistData.ACParam.Frequencies = append(netlistData.ACParam.Frequencies, freq)
+			}
+			netlistData.ACParam.Points = len(netlistData.ACParam.Frequencies)
+			netlistData.ACParam.FStart = netlistData.ACParam.Frequencies[0]
+			netlistData.ACParam.FStop = netlistData.ACParam.Frequencies[len(netlistData.ACParam.Frequencies)-1]
+			break
+		}
+
 		if len(fields) < 5 {
 			return fmt.Errorf("insufficient AC parameters, need sweep type, points, fstart, and fstop")
 		}
@@ -251,6 +549,9 @@ func parseDotOperator(netlistData *NetlistData, line string) error {
 		if err != nil {
 			return fmt.Errorf("invalid points number: %v", err)
 		}
+		if netlistData.ACParam.Points < 1 {
+			return fmt.Errorf("invalid points number: %d", netlistData.ACParam.Points)
+		}
 		netlistData.ACParam.FStart, err = ParseValue(fields[3])
 		if err != nil {
 			return fmt.Errorf("invalid fstart: %v", err)
@@ -259,6 +560,31 @@ func parseDotOperator(netlistData *NetlistData, line string) error {
 		if err != nil {
 			return fmt.Errorf("invalid fstop: %v", err)
 		}
+		if netlistData.ACParam.Sweep != "LIN" && (netlistData.ACParam.FStart <= 0 || netlistData.ACParam.FStop < netlistData.ACParam.FStart) {
+			return fmt.Errorf("invalid %s sweep, need 0 < fstart <= fstop: %s %s", strings.ToLower(netlistData.ACParam.Sweep), fields[3], fields[4])
+		}
+
+		// .disto dec nd fstart fstop <f2overf1>
+		if netlistData.Analysis == AnalysisDISTO && len(fields) > 5 {
+			netlistData.ACParam.F2OverF1, err = ParseValue(fields[5])
+			if err != nil || netlistData.ACParam.F2OverF1 <= 0 || netlistData.ACParam.F2OverF1 >= 1 {
+				return fmt.Errorf("invalid f2overf1, need 0 < f2overf1 < 1: %s", fields[5])
+			}
+		}
+
+	case ".hb":
+		netlistData.Analysis = AnalysisHB
+		if len(fields) < 3 {
+			return fmt.Errorf("insufficient HB parameters, need fundamental frequency and harmonics")
+		}
+		netlistData.HBParam.F0, err = ParseValue(fields[1])
+		if err != nil || netlistData.HBParam.F0 <= 0 {
+			return fmt.Errorf("invalid fundamental frequency: %s", fields[1])
+		}
+		netlistData.HBParam.Harmonics, err = strconv.Atoi(fields[2])
+		if err != nil || netlistData.HBParam.Harmonics < 1 {
+			return fmt.Errorf("invalid harmonics number: %s", fields[2])
+		}
 
 	case ".dc":
 		netlistData.Analysis = AnalysisDC
@@ -289,6 +615,29 @@ func parseDotOperator(netlistData *NetlistData, line string) error {
 	return nil
 }
 
+// parseOutputVariables - V(n), V(n1,n2), I(element), AC variants (VDB, VP, VG, VM, ...)
+// and magnetic B(winding), H(winding), PLOSS(core) in .print/.plot line.
+// Analysis type keyword(tran, ac, dc, op) is skipped.
+func parseOutputVariables(line string) []string {
+	var outputs []string
+
+	matches := regexp.MustCompile(`(?i)\b([vi](?:db|m|p|g)?|b|h|ploss)\s*\(([^)]*)\)`).FindAllStringSubmatch(line, -1)
+	for _, match := range matches {
+		var args []string
+		for _, arg := range strings.Split(match[2], ",") {
+			if arg = strings.TrimSpace(arg); arg != "" {
+				args = append(args, arg)
+			}
+		}
+		if len(args) == 0 {
+			continue
+		}
+		outputs = append(outputs, strings.ToUpper(match[1])+"("+strings.Join(args, ",")+")")
+	}
+
+	return outputs
+}
+
 func parseModel(netlistData *NetlistData, fields []string) error {
 	if len(fields) < 2 {
 		return fmt.Errorf("insufficient model parameters")
@@ -296,56 +645,53 @@ func parseModel(netlistData *NetlistData, fields []string) error {
 
 	modelName := fields[0]
 
-	// Model type
-	typeField := fields[1]
-	modelType := ""
-	hasOpenParen := false
-
-	if strings.Contains(typeField, "(") {
-		parts := strings.SplitN(typeField, "(", 2)
-		modelType = strings.ToUpper(parts[0])
-		hasOpenParen = true
-
-		if len(parts) > 1 {
-			fields = append(fields[:2], append([]string{parts[1]}, fields[2:]...)...)
-		}
-	} else {
-		modelType = strings.ToUpper(typeField)
+	// Model body. eg. "D(Is=1n N=2)", "D (Is = 1n", "N=2 )"
+	body := strings.Join(fields[1:], " ")
+	body = strings.NewReplacer("(", " ", ")", " ").Replace(body)
+	body = regexp.MustCompile(`\s*=\s*`).ReplaceAllString(body, "=")
+	bodyFields := strings.Fields(body)
+	if len(bodyFields) == 0 {
+		return fmt.Errorf("missing model type: %s", modelName)
 	}
 
-	var supportedModelTypes = []string{"D", "CORE", "NPN", "PNP", "NMOS", "PMOS"}
+	// Model type
+	modelType := strings.ToUpper(bodyFields[0])
 
-	if !slices.Contains(supportedModelTypes, modelType) {
-		return fmt.Errorf("unsupported model type: %s", modelType)
-	}
+	var supportedModelTypes = []string{"R", "C", "D", "CORE", "NPN", "PNP", "NMOS", "PMOS", "COMP", "VCO", "SW", "CSW"}
 
-	// Model parameters
-	var paramStr string
-	if hasOpenParen {
-		// If parentheses
-		paramParts := fields[2:]
-		if len(paramParts) > 0 {
-			// Trim trailing parenthesis
-			last := paramParts[len(paramParts)-1]
-			if strings.HasSuffix(last, ")") {
-				paramParts[len(paramParts)-1] = strings.TrimSuffix(last, ")")
-			}
-		}
-		paramStr = strings.Join(paramParts, " ")
-	} else if len(fields) > 2 {
-		// If no parentheses
-		paramStr = strings.Join(fields[2:], " ")
-		paramStr = strings.TrimSuffix(paramStr, ")") // Trim trailing parenthesis
+	custom := device.RegisteredModelType(modelType)
+	if !slices.Contains(supportedModelTypes, modelType) && !custom {
+		return fmt.Errorf("unsupported model type: %s", modelType)
 	}
 
 	// Remove comments
+	paramStr := strings.Join(bodyFields[1:], " ")
 	paramStr = regexp.MustCompile(`\*.*$`).ReplaceAllString(paramStr, "")
 	paramStr = strings.TrimSpace(paramStr)
 
-	params := make(map[string]float64)
+	params := make(map[string]float64) // Defaults, replaced by parameters of card
 
 	// Default model parameters
 	switch modelType {
+	case "R":
+		// Semiconductor resistor
+		params["rsh"] = 0.0    // Sheet resistance
+		params["defw"] = 10e-6 // Default width
+		params["narrow"] = 0.0 // Narrowing due to side etching
+		params["tc1"] = 0.0    // First order temp. coefficient
+		params["tc2"] = 0.0    // Second order temp. coefficient
+		params["tnom"] = 27.0  // Parameter measurement temperature
+
+	case "C":
+		// Semiconductor capacitor
+		params["cj"] = 0.0     // Junction bottom capacitance
+		params["cjsw"] = 0.0   // Junction sidewall capacitance
+		params["defw"] = 10e-6 // Default width
+		params["narrow"] = 0.0 // Narrowing due to side etching
+		params["tc1"] = 0.0    // First order temp. coefficient
+		params["tc2"] = 0.0    // Second order temp. coefficient
+		params["tnom"] = 27.0  // Parameter measurement temperature
+
 	case "D":
 		params["is"] = 1e-14 // Saturation current
 		params["n"] = 1.0    // Emission coefficient
@@ -396,6 +742,9 @@ func parseModel(netlistData *NetlistData, fields []string) error {
 		params["xtb"] = 0.0   // Forward and reverse beta temp. exp
 		params["eg"] = 1.11   // Energy gap
 		params["xti"] = 3.0   // Temp. exponent for Is
+		params["ptf"] = 0.0   // Excess phase at 1/(2pi TF)
+		params["rco"] = 0.0   // Epitaxial region resistance
+		params["vo"] = 10.0   // Epitaxial carrier velocity saturation voltage
 
 		if modelType == "PNP" {
 			params["type"] = 1.0 // PNP = 1, NPN = 0
@@ -428,9 +777,44 @@ func parseModel(netlistData *NetlistData, fields []string) error {
 		if modelType == "PMOS" {
 			params["type"] = 1.0 // PMOS = 1, NMOS = 0
 		}
+
+	case "COMP":
+		// Behavioral comparator
+		params["voh"] = 1.0    // Output high
+		params["vol"] = 0.0    // Output low
+		params["vth"] = 0.0    // Input threshold
+		params["vhys"] = 0.0   // Hysteresis width
+		params["width"] = 1e-3 // Input transition width of unclocked output
+		params["vclk"] = 0.5   // Clock threshold of clocked comparator
+		params["ro"] = 0.0     // Output resistance
+
+	case "VCO":
+		// Behavioral voltage controlled oscillator
+		params["f0"] = 1e3   // Frequency at zero control voltage
+		params["kv"] = 0.0   // Frequency per control voltage, Hz/V
+		params["fmin"] = 0.0 // Lowest frequency
+		params["vo"] = 0.0   // Output offset
+		params["va"] = 1.0   // Output amplitude
+		params["shape"] = 0  // 0 sine, 1 square
+		params["ro"] = 0.0   // Output resistance
+
+	case "SW", "CSW":
+		// Voltage or current controlled switch
+		if modelType == "SW" {
+			params["vt"] = 0.0 // Threshold voltage
+			params["vh"] = 0.0 // Hysteresis voltage
+		} else {
+			params["it"] = 0.0 // Threshold current
+			params["ih"] = 0.0 // Hysteresis current
+		}
+		params["ron"] = 1.0     // On resistance
+		params["roff"] = 1e12   // Off resistance
+		params["bounce"] = 0    // Number of contact bounces after closing
+		params["tbounce"] = 0.0 // Duration of bounce
 	}
 
 	// Parse parameters
+	given := make(map[string]float64)
 	paramPairs := strings.Fields(paramStr)
 	for _, pair := range paramPairs {
 		parts := strings.Split(pair, "=")
@@ -439,14 +823,40 @@ func parseModel(netlistData *NetlistData, fields []string) error {
 		}
 
 		paramName := strings.ToLower(strings.TrimSpace(parts[0]))
+		if alias, ok := modelParamAliases[modelType][paramName]; ok {
+			paramName = alias
+		}
+		known := custom || slices.Contains(modelParamNames[modelType], paramName)
+
 		value, err := ParseValue(strings.TrimSpace(parts[1]))
 		if err != nil {
+			if !known {
+				netlistData.Warnings = append(netlistData.Warnings,
+					fmt.Sprintf("model %s: unsupported parameter %s ignored", modelName, pair))
+				continue
+			}
 			return fmt.Errorf("invalid parameter value %s: %v", pair, err)
 		}
-		params[paramName] = value
+
+		if !known {
+			netlistData.Warnings = append(netlistData.Warnings,
+				fmt.Sprintf("model %s: unsupported parameter %s ignored", modelName, parts[0]))
+			continue
+		}
+		given[paramName] = value
+	}
+
+	// Ebers-Moll parameters replace Gummel-Poon defaults, which would overwrite them
+	if modelType == "NPN" || modelType == "PNP" {
+		for name, ebersMoll := range bjtEbersMollParams {
+			if slices.ContainsFunc(ebersMoll, func(p string) bool { _, ok := given[p]; return ok }) {
+				delete(params, name)
+			}
+		}
 	}
+	maps.Copy(params, given)
 
-	netlistData.Models[modelName] = device.ModelParam{
+	netlistData.Models[ModelKey(modelName)] = device.ModelParam{
 		Type:   modelType,
 		Name:   modelName,
 		Params: params,
@@ -455,6 +865,89 @@ func parseModel(netlistData *NetlistData, fields []string) error {
 	return nil
 }
 
+// Alternative parameter names used by vendor model cards
+var modelParamAliases = map[string]map[string]string{
+	"D": {
+		"cjo": "cj0",
+		"cj":  "cj0",
+		"pb":  "vj",
+		"mj":  "m",
+	},
+	"NPN": {
+		"va": "vaf",
+		"vb": "var",
+		"ik": "ikf",
+		"pe": "vje",
+		"me": "mje",
+		"pc": "vjc",
+		"mc": "mjc",
+	},
+	"PNP": {
+		"va": "vaf",
+		"vb": "var",
+		"ik": "ikf",
+		"pe": "vje",
+		"me": "mje",
+		"pc": "vjc",
+		"mc": "mjc",
+	},
+	"NMOS": {"u0": "uo"},
+	"PMOS": {"u0": "uo"},
+}
+
+// Ebers-Moll parameters of BJT by Gummel-Poon parameter setting same device values
+var bjtEbersMollParams = map[string][]string{
+	"is": {"ies", "ics"},
+	"bf": {"alphaf"},
+	"br": {"alphar"},
+}
+
+var bjtModelParamNames = []string{
+	"is", "bf", "br", "nf", "nr", "vaf", "var", "ikf", "ikr", "rc", "re", "rb",
+	"cje", "vje", "mje", "cjc", "vjc", "mjc", "tf", "tr", "xtb", "eg", "xti",
+	"ptf", "rco", "vo",
+	"ies", "ics", "alphaf", "alphar",
+}
+
+var mosModelParamNames = []string{
+	"level", "l", "w", "ad", "as", "pd", "ps", "nrd", "nrs",
+	"vto", "kp", "gamma", "phi", "lambda", "rd", "rs", "rsh", "is", "js", "n",
+	"cbd", "cbs", "cgso", "cgdo", "cgbo", "cj", "mj", "cjsw", "mjsw", "pb", "fc",
+	"tox", "nsub", "nss", "nfs", "tpg", "xj", "ld", "uo", "ucrit", "uexp", "utra", "vmax", "neff", "xqc",
+	"delta", "theta", "eta", "kappa",
+	"tnom", "kf", "af",
+}
+
+// Parameter names accepted for each model type
+var modelParamNames = map[string][]string{
+	"R":    {"rsh", "defw", "narrow", "tc1", "tc2", "tnom"},
+	"C":    {"cj", "cjsw", "defw", "narrow", "tc1", "tc2", "tnom"},
+	"D":    {"is", "n", "rs", "cj0", "m", "vj", "bv", "eg", "xti", "tt", "fc"},
+	"CORE": {"ms", "alpha", "a", "c", "k", "tc", "beta", "area", "len"},
+	"NPN":  bjtModelParamNames,
+	"PNP":  bjtModelParamNames,
+	"NMOS": mosModelParamNames,
+	"PMOS": mosModelParamNames,
+	"COMP": {"voh", "vol", "vth", "vhys", "width", "vclk", "ro"},
+	"VCO":  {"f0", "kv", "fmin", "vo", "va", "shape", "ro"},
+	"SW":   {"vt", "vh", "ron", "roff", "bounce", "tbounce"},
+	"CSW":  {"it", "ih", "ron", "roff", "bounce", "tbounce"},
+}
+
+// ModelKey - Key of model name in NetlistData.Models. Model names are case-insensitive
+func ModelKey(name string) string {
+	return strings.ToLower(name)
+}
+
+// lookupModel - Find model by name, exact key of models built without netlist first
+func lookupModel(models map[string]device.ModelParam, name string) (device.ModelParam, bool) {
+	if model, ok := models[name]; ok {
+		return model, true
+	}
+	model, ok := models[ModelKey(name)]
+	return model, ok
+}
+
 // Parse circuit element
 func parseElement(line string) (*Element, error) {
 	fields := strings.Fields(line)
@@ -468,6 +961,10 @@ func parseElement(line string) (*Element, error) {
 		Params: make(map[string]string),
 	}
 
+	if f, ok := device.Registered(elem.Type); ok {
+		return parseCustomElement(elem, fields, f)
+	}
+
 	switch elem.Type {
 	case "V":
 		return parseVoltageSource(fields)
@@ -478,6 +975,9 @@ func parseElement(line string) (*Element, error) {
 	case "L":
 		elem.Nodes = fields[1:3]
 		elem.Params = make(map[string]string)
+		if parseCurve(elem, fields[3:]) {
+			return elem, nil
+		}
 
 		for i := 3; i < len(fields); i++ {
 			pair := strings.Split(fields[i], "=")
@@ -527,8 +1027,8 @@ func parseElement(line string) (*Element, error) {
 	case "D":
 		elem.Nodes = fields[1:3]
 		if len(fields) > 3 {
-			// TODO: Inline parameters
 			elem.Params["model"] = fields[3]
+			parseInstanceParams(elem, fields[4:])
 		}
 
 		return elem, nil
@@ -540,6 +1040,7 @@ func parseElement(line string) (*Element, error) {
 		elem.Nodes = fields[1:4] // Collector, Base, Emitter
 		if len(fields) > 4 {
 			elem.Params["model"] = fields[4]
+			parseInstanceParams(elem, fields[5:])
 		}
 		return elem, nil
 
@@ -562,6 +1063,119 @@ func parseElement(line string) (*Element, error) {
 
 		return elem, nil
 
+	case "P":
+		// Port. eg. "P1 in 0 port=1 z0=50"
+		elem.Nodes = fields[1:3]
+		for _, field := range fields[3:] {
+			key, value, found := strings.Cut(field, "=")
+			if !found {
+				return nil, fmt.Errorf("invalid port parameter for %s: %s", elem.Name, field)
+			}
+			elem.Params[strings.ToLower(key)] = value
+		}
+		return elem, nil
+
+	case "Y":
+		// Loop gain probe. eg. "Y1 out fb"
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("loop probe %s takes two nodes only", elem.Name)
+		}
+		elem.Nodes = fields[1:3]
+		return elem, nil
+
+	case "E", "G":
+		// Controlled source. eg. "E1 out 0 in 0 10", "E1 out 0 in 0 LAPLACE NUM=(1) DEN=(1 1m)",
+		// "G1 out 0 in 0 FREQ (0 0 0 1k -3 -45 10k -20 -90)"
+		if len(fields) < 6 {
+			return nil, fmt.Errorf("insufficient parameters for %s: need output nodes, control nodes and gain", elem.Name)
+		}
+		elem.Nodes = fields[1:5]
+		rest := strings.Join(fields[5:], " ")
+		listOf := func(s string) string {
+			return strings.Join(strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == ',' }), " ")
+		}
+		if match := regexp.MustCompile(`(?i)^laplace\s+num\s*=\s*\(([^)]*)\)\s+den\s*=\s*\(([^)]*)\)$`).FindStringSubmatch(rest); match != nil {
+			elem.Params["num"], elem.Params["den"] = listOf(match[1]), listOf(match[2])
+			return elem, nil
+		}
+		if match := regexp.MustCompile(`(?i)^freq\s*\((.*)\)$`).FindStringSubmatch(rest); match != nil {
+			elem.Params["freq"] = listOf(match[1])
+			return elem, nil
+		}
+		if len(fields) != 6 {
+			return nil, fmt.Errorf("invalid controlled source %s: expected gain, LAPLACE NUM=(...) DEN=(...) or FREQ (...)", elem.Name)
+		}
+		value, err := ParseValue(fields[5])
+		if err != nil {
+			return nil, fmt.Errorf("invalid gain of %s: %v", elem.Name, err)
+		}
+		elem.Value = value
+		return elem, nil
+
+	case "A":
+		// Behavioral block. eg. "A1 out 0 in ref CMP", "A1 out 0 in ref clk CMP", "A1 out 0 ctrl 0 OSC"
+		if len(fields) < 6 {
+			return nil, fmt.Errorf("insufficient parameters for %s: need output nodes, input nodes and model", elem.Name)
+		}
+		elem.Nodes = fields[1 : len(fields)-1]
+		elem.Params["model"] = fields[len(fields)-1]
+		return elem, nil
+
+	case "S", "W":
+		// Switch. eg. "S1 a b ctl 0 SMOD", "W1 a b vcoil WMOD ON"
+		nodeCount, required := 4, 6
+		if elem.Type == "W" {
+			nodeCount, required = 2, 5
+		}
+		if len(fields) < required {
+			return nil, fmt.Errorf("insufficient parameters for %s: need nodes, control and model", elem.Name)
+		}
+		elem.Nodes = fields[1 : nodeCount+1]
+		rest := fields[nodeCount+1:]
+		if elem.Type == "W" {
+			elem.Params["control"] = rest[0]
+			rest = rest[1:]
+		}
+		elem.Params["model"] = rest[0]
+		for _, field := range rest[1:] {
+			switch strings.ToUpper(field) {
+			case "ON":
+				elem.Params["on"] = "1"
+			case "OFF":
+				elem.Params["on"] = "0"
+			default:
+				return nil, fmt.Errorf("invalid parameter for %s: %s", elem.Name, field)
+			}
+		}
+		return elem, nil
+
+	case "R", "C":
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("insufficient parameters for %s: need nodes and value or model", elem.Name)
+		}
+		elem.Nodes = fields[1:3]
+		if elem.Type == "C" && parseCurve(elem, fields[3:]) {
+			return elem, nil
+		}
+
+		// Value and/or model name, then parameters. eg. "1k tc1=0.001 tnom=25", "RMOD L=10u W=2u"
+		for _, field := range fields[3:] {
+			if key, value, found := strings.Cut(field, "="); found {
+				elem.Params[strings.ToLower(key)] = value
+				continue
+			}
+			if value, err := ParseValue(field); err == nil {
+				elem.Value = value
+				continue
+			}
+			if _, exists := elem.Params["model"]; exists {
+				return nil, fmt.Errorf("invalid parameter for %s: %s", elem.Name, field)
+			}
+			elem.Params["model"] = field
+		}
+
+		return elem, nil
+
 	default:
 		// Parts - RLC..
 		elem.Nodes = fields[1 : len(fields)-1]
@@ -576,24 +1190,316 @@ func parseElement(line string) (*Element, error) {
 	}
 }
 
-func parseVoltageSource(fields []string) (*Element, error) {
-	if len(fields) < 4 {
-		return nil, fmt.Errorf("insufficient voltage source parameters")
+// parseCustomElement - Element of custom device. Nodes, then name=value parameters and args in any order.
+// First numeric arg is value, first other arg is model name. Args are kept in order by space in args parameter
+func parseCustomElement(elem *Element, fields []string, f device.Factory) (*Element, error) {
+	if len(fields) < f.Nodes+1 {
+		return nil, fmt.Errorf("insufficient nodes for %s: need %d", elem.Name, f.Nodes)
 	}
+	elem.Nodes = fields[1 : f.Nodes+1]
 
-	elem := &Element{
-		Name:   fields[0],
-		Type:   "V",
-		Nodes:  []string{fields[1], fields[2]},
-		Params: make(map[string]string),
+	var args []string
+	hasValue := false
+	for _, field := range fields[f.Nodes+1:] {
+		if key, value, found := strings.Cut(field, "="); found {
+			elem.Params[strings.ToLower(key)] = value
+			continue
+		}
+		args = append(args, field)
+		if value, err := ParseValue(field); err == nil {
+			if !hasValue {
+				elem.Value, hasValue = value, true
+			}
+			continue
+		}
+		if _, exists := elem.Params["model"]; !exists {
+			elem.Params["model"] = field
+		}
 	}
-
-	remaining := strings.Join(fields[3:], " ")
-	remaining = strings.ReplaceAll(remaining, "(", " ( ") // Append whitespace around parentheses
-	remaining = strings.ReplaceAll(remaining, ")", " ) ")
-	words := strings.Fields(remaining)
-	if len(words) == 0 {
-		return nil, fmt.Errorf("missing voltage source type")
+	if len(args) > 0 {
+		elem.Params["args"] = strings.Join(args, " ")
+	}
+	return elem, nil
+}
+
+// createCustomDevice - Device of element by its registered factory
+func createCustomDevice(elem Element, models map[string]device.ModelParam, f device.Factory) (device.Device, error) {
+	custom := device.Element{
+		Name:   elem.Name,
+		Nodes:  elem.Nodes,
+		Args:   strings.Fields(elem.Params["args"]),
+		Params: make(map[string]string),
+	}
+	for key, value := range elem.Params {
+		if key != "args" && key != "model" {
+			custom.Params[key] = value
+		}
+	}
+	if name, ok := elem.Params["model"]; ok {
+		if model, exists := lookupModel(models, name); exists {
+			custom.Model = &model
+		}
+	}
+	if f.ModelType != "" {
+		if custom.Model == nil {
+			return nil, fmt.Errorf("undefined model for %s: %s", elem.Name, elem.Params["model"])
+		}
+		if custom.Model.Type != f.ModelType {
+			return nil, fmt.Errorf("invalid model type for %s: %s, expected %s", elem.Name, custom.Model.Type, f.ModelType)
+		}
+	}
+
+	dev, err := f.New(custom)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", elem.Name, err)
+	}
+	if _, ok := dev.(device.BranchDevice); f.Branch && !ok {
+		return nil, fmt.Errorf("%s: device with branch must implement branch device", elem.Name)
+	}
+	return dev, nil
+}
+
+// parseCurve - Nonlinear value of C or L. "poly(p0 p1 p2 ...)" or "table(x1 y1 x2 y2 ...)"
+func parseCurve(elem *Element, fields []string) bool {
+	match := regexp.MustCompile(`(?i)^(poly|table)\s*\((.*)\)$`).FindStringSubmatch(strings.Join(fields, " "))
+	if match == nil {
+		return false
+	}
+	elem.Params[strings.ToLower(match[1])] = strings.Join(strings.FieldsFunc(match[2], func(r rune) bool {
+		return r == ' ' || r == ','
+	}), " ")
+	return true
+}
+
+// curveValue - Curve of nonlinear C or L element, nil for linear element
+func curveValue(elem Element) (*device.Curve, error) {
+	var values []float64
+	kind := ""
+	for _, name := range []string{"poly", "table"} {
+		if list, ok := elem.Params[name]; ok {
+			kind = name
+			for _, field := range strings.Fields(list) {
+				value, err := ParseValue(field)
+				if err != nil {
+					return nil, fmt.Errorf("invalid %s value of %s: %v", name, elem.Name, err)
+				}
+				values = append(values, value)
+			}
+		}
+	}
+
+	switch kind {
+	case "poly":
+		return device.NewPolyCurve(values)
+	case "table":
+		if len(values)%2 != 0 {
+			return nil, fmt.Errorf("table of %s needs pairs of values", elem.Name)
+		}
+		var xs, ys []float64
+		for i := 0; i < len(values); i += 2 {
+			xs = append(xs, values[i])
+			ys = append(ys, values[i+1])
+		}
+		return device.NewTableCurve(xs, ys)
+	}
+	return nil, nil
+}
+
+// transferValue - LAPLACE or FREQ table of E/G element, nil for constant gain
+func transferValue(elem Element) (device.Transfer, error) {
+	values := func(name string) ([]float64, error) {
+		var list []float64
+		for _, field := range strings.Fields(elem.Params[name]) {
+			value, err := ParseValue(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s value of %s: %v", name, elem.Name, err)
+			}
+			list = append(list, value)
+		}
+		return list, nil
+	}
+
+	if _, ok := elem.Params["den"]; ok {
+		num, err := values("num")
+		if err != nil {
+			return nil, err
+		}
+		den, err := values("den")
+		if err != nil {
+			return nil, err
+		}
+		return device.NewLaplaceTransfer(num, den)
+	}
+
+	if _, ok := elem.Params["freq"]; ok {
+		table, err := values("freq")
+		if err != nil {
+			return nil, err
+		}
+		if len(table)%3 != 0 {
+			return nil, fmt.Errorf("frequency table of %s needs triples of frequency, magnitude dB and phase", elem.Name)
+		}
+		var freqs, mags, phases []float64
+		for i := 0; i < len(table); i += 3 {
+			freqs = append(freqs, table[i])
+			mags = append(mags, table[i+1])
+			phases = append(phases, table[i+2])
+		}
+		return device.NewTableTransfer(freqs, mags, phases)
+	}
+
+	return nil, nil
+}
+
+// parseInstanceParams - Semiconductor instance parameters. eg. "2", "area=2", "off", "temp=50"
+func parseInstanceParams(elem *Element, fields []string) {
+	for _, field := range fields {
+		if key, value, found := strings.Cut(field, "="); found {
+			elem.Params[strings.ToLower(key)] = value
+			continue
+		}
+
+		if strings.EqualFold(field, "off") {
+			elem.Params["off"] = "1"
+			continue
+		}
+
+		// Positional area factor
+		if _, exists := elem.Params["area"]; !exists {
+			elem.Params["area"] = field
+		}
+	}
+}
+
+// instanceParamValues - Numeric values of instance parameters which exist in elem
+// initialCondition - IC= of capacitor voltage or inductor current
+func initialCondition(elem Element) (float64, bool, error) {
+	valueStr, ok := elem.Params["ic"]
+	if !ok {
+		return 0, false, nil
+	}
+	value, err := ParseValue(valueStr)
+	if err != nil {
+		return 0, false, fmt.Errorf("%s: invalid ic value: %v", elem.Name, err)
+	}
+	return value, true, nil
+}
+
+// multiplier - Instance multiplier M= of parallel devices. 1 when not given
+func multiplier(elem Element) (float64, error) {
+	valueStr, ok := elem.Params["m"]
+	if !ok {
+		return 1, nil
+	}
+	value, err := ParseValue(valueStr)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("%s: invalid multiplier m=%s", elem.Name, valueStr)
+	}
+	return value, nil
+}
+
+// parseInitialConditions - .ic v(node)=value ...
+// Options of .options. Transient results decimation by maxpoints=<n> and savestep=<interval>,
+// circuit temperature of all analyses by temp=<degC>, shunt resistance of nodes without DC path by dcshunt=<ohm>
+var supportedOptions = []string{"maxpoints", "savestep", "temp", "dcshunt"}
+
+// parseOptions - name=value pairs of .options. eg. ".options maxpoints=100000 savestep=1u"
+func parseOptions(netlistData *NetlistData, body string) error {
+	body = regexp.MustCompile(`\s*=\s*`).ReplaceAllString(body, "=")
+	for _, pair := range strings.Fields(body) {
+		name, valueText, ok := strings.Cut(pair, "=")
+		name = strings.ToLower(name)
+		if !slices.Contains(supportedOptions, name) {
+			netlistData.Warnings = append(netlistData.Warnings, fmt.Sprintf("unsupported option %s ignored", pair))
+			continue
+		}
+		if !ok {
+			return fmt.Errorf("option %s requires value", name)
+		}
+		value, err := ParseValue(valueText)
+		if err != nil || (value < 0 && name != "temp") || value <= -273.15 {
+			return fmt.Errorf("invalid option %s: %s", name, valueText)
+		}
+		netlistData.Options[name] = value
+	}
+	return nil
+}
+
+func parseInitialConditions(netlistData *NetlistData, body string) error {
+	if netlistData.InitialConditions == nil {
+		netlistData.InitialConditions = make(map[string]float64)
+	}
+	return parseNodeVoltages(netlistData.InitialConditions, ".ic", body)
+}
+
+// parseNodeSets - .nodeset v(node)=value ...
+func parseNodeSets(netlistData *NetlistData, body string) error {
+	if netlistData.NodeSets == nil {
+		netlistData.NodeSets = make(map[string]float64)
+	}
+	return parseNodeVoltages(netlistData.NodeSets, ".nodeset", body)
+}
+
+// parseNodeVoltages - v(node)=value pairs of card into voltages. Ground is skipped
+func parseNodeVoltages(voltages map[string]float64, card, body string) error {
+	matches := regexp.MustCompile(`(?i)v\(\s*([^)\s,]+)\s*\)\s*=\s*(\S+)`).FindAllStringSubmatch(body, -1)
+	if len(matches) == 0 {
+		return fmt.Errorf("invalid %s, need v(node)=value", card)
+	}
+
+	for _, match := range matches {
+		value, err := ParseValue(match[2])
+		if err != nil {
+			return fmt.Errorf("invalid %s value of v(%s): %v", card, match[1], err)
+		}
+		if IsGround(match[1]) {
+			continue
+		}
+		voltages[match[1]] = value
+	}
+	return nil
+}
+
+func instanceParamValues(elem Element, names ...string) (map[string]float64, error) {
+	values := make(map[string]float64)
+	for _, name := range names {
+		valueStr, ok := elem.Params[name]
+		if !ok {
+			continue
+		}
+		value, err := ParseValue(valueStr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid %s value: %v", elem.Name, name, err)
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+func parseVoltageSource(fields []string) (*Element, error) {
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("insufficient voltage source parameters")
+	}
+
+	elem := &Element{
+		Name:   fields[0],
+		Type:   "V",
+		Nodes:  []string{fields[1], fields[2]},
+		Params: make(map[string]string),
+	}
+
+	// Series resistance anywhere after nodes. eg. "V1 in 0 SIN(0 1 1k) rser=50"
+	rser, rest, ok := cutParam(fields[3:], "rser")
+	if ok {
+		elem.Params["rser"] = rser
+	}
+
+	remaining := strings.Join(rest, " ")
+	remaining = strings.ReplaceAll(remaining, "(", " ( ") // Append whitespace around parentheses
+	remaining = strings.ReplaceAll(remaining, ")", " ) ")
+	words := strings.Fields(remaining)
+	if len(words) == 0 {
+		return nil, fmt.Errorf("missing voltage source type")
 	}
 
 	switch strings.ToUpper(words[0]) {
@@ -626,6 +1532,11 @@ func parseVoltageSource(fields []string) (*Element, error) {
 		pwlParams = strings.Trim(pwlParams, "() ")
 		elem.Params["pwl"] = pwlParams
 
+	case "AM", "TRNOISE", "TRRANDOM":
+		kind := strings.ToLower(words[0])
+		elem.Params["type"] = kind
+		elem.Params[kind] = strings.Trim(strings.Join(words[1:], " "), "() ")
+
 	case "AC":
 		if len(words) < 2 {
 			return nil, fmt.Errorf("missing AC magnitude")
@@ -700,6 +1611,11 @@ func parseCurrentSource(fields []string) (*Element, error) {
 		pwlParams = strings.Trim(pwlParams, "() ")
 		elem.Params["pwl"] = pwlParams
 
+	case "AM", "TRNOISE", "TRRANDOM":
+		kind := strings.ToLower(words[0])
+		elem.Params["type"] = kind
+		elem.Params[kind] = strings.Trim(strings.Join(words[1:], " "), "() ")
+
 	case "AC":
 		if len(words) < 2 {
 			return nil, fmt.Errorf("missing AC magnitude")
@@ -723,10 +1639,9 @@ func parseCurrentSource(fields []string) (*Element, error) {
 	return elem, nil
 }
 
-// ParseValue - Parse value and factor. 1k -> 1000
+// ParseValue - Parse value, factor and unit. 1k -> 1000, 1MEG -> 1e6, 1mF -> 1e-3, 5% -> 0.05
 func ParseValue(val string) (float64, error) {
-	re := regexp.MustCompile(`^([-+]?\d*\.?\d+(?:[eE][-+]?\d+)?)(meg|[TGMKkmunpf])?s?$`)
-	matches := re.FindStringSubmatch(strings.TrimSpace(val))
+	matches := valueRegexp.FindStringSubmatch(strings.TrimSpace(val))
 
 	if matches == nil {
 		return 0, fmt.Errorf("invalid value format: %s", val)
@@ -738,26 +1653,60 @@ func ParseValue(val string) (float64, error) {
 	}
 
 	// factor
-	if len(matches) > 2 && matches[2] != "" {
-		if multiplier, ok := unitMap[matches[2]]; ok {
-			num *= multiplier
-		}
+	if factor := matches[2] + matches[3]; factor != "" {
+		num *= unitMap[strings.ToLower(factor)]
 	}
 
 	return num, nil
 }
 
-var magneticCores = make(map[string]*device.MagneticCore)
-
 func CreateDevice(elem Element, nodeMap map[string]int, models map[string]device.ModelParam) (device.Device, error) {
 	switch elem.Type {
 	case "R":
-		return device.NewResistor(elem.Name, elem.Nodes, elem.Value), nil
+		resistor := device.NewResistor(elem.Name, elem.Nodes, elem.Value)
+		if modelName, ok := elem.Params["model"]; ok {
+			model, exists := lookupModel(models, modelName)
+			if !exists || model.Type != "R" {
+				return nil, fmt.Errorf("undefined resistor model for %s: %s", elem.Name, modelName)
+			}
+			resistor.SetModelParameters(model.Params)
+		}
+		instParams, err := instanceParamValues(elem, "l", "w", "tc1", "tc2", "tnom", "temp", "dtemp", "rth", "cth")
+		if err != nil {
+			return nil, err
+		}
+		resistor.SetInstanceParameters(instParams)
+		m, err := multiplier(elem)
+		if err != nil {
+			return nil, err
+		}
+		resistor.Value /= m // m resistors in parallel
+		if resistor.Value <= 0 {
+			return nil, fmt.Errorf("resistor %s: value or model with rsh and length required", elem.Name)
+		}
+		return resistor, nil
 
 	case "L":
+		m, err := multiplier(elem)
+		if err != nil {
+			return nil, err
+		}
+
+		// Saturating inductor L(I)
+		curve, err := curveValue(elem)
+		if err != nil {
+			return nil, fmt.Errorf("inductor %s: %v", elem.Name, err)
+		}
+		if (curve != nil || elem.Params["core"] != "") && m != 1 {
+			return nil, fmt.Errorf("inductor %s: multiplier m is not supported for nonlinear or core inductors", elem.Name)
+		}
+		if curve != nil {
+			return device.NewNonlinearInductor(elem.Name, elem.Nodes, curve), nil
+		}
+
 		// Transformer - Magnetic Core
 		if coreName, ok := elem.Params["core"]; ok {
-			if model, exists := models[coreName]; exists {
+			if model, exists := lookupModel(models, coreName); exists {
 				if model.Type == "CORE" {
 					// Parse turns of winding
 					turns := 100 // Default winding
@@ -767,15 +1716,10 @@ func CreateDevice(elem Element, nodeMap map[string]int, models map[string]device
 						}
 					}
 
+					// Windings of same core model are put on one core by circuit setup
 					inductor := device.NewMagneticInductor(elem.Name, elem.Nodes, turns)
-
-					if core, exists := magneticCores[coreName]; exists {
-						inductor.SetCore(model.Params)
-						core.AddInductor(inductor)
-					} else {
-						inductor.SetCore(model.Params)
-						magneticCores[coreName] = inductor.GetCore()
-					}
+					inductor.SetCore(model.Params)
+					inductor.SetCoreName(coreName)
 
 					return inductor, nil
 				}
@@ -785,10 +1729,84 @@ func CreateDevice(elem Element, nodeMap map[string]int, models map[string]device
 		}
 
 		// Inductor
-		return device.NewInductor(elem.Name, elem.Nodes, elem.Value), nil
+		inductor := device.NewInductor(elem.Name, elem.Nodes, elem.Value/m) // m inductors in parallel
+		ic, ok, err := initialCondition(elem)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			inductor.SetInitialCondition(ic)
+		}
+		return inductor, nil
 
 	case "C":
-		return device.NewCapacitor(elem.Name, elem.Nodes, elem.Value), nil
+		m, err := multiplier(elem)
+		if err != nil {
+			return nil, err
+		}
+
+		// Varactor C(V)
+		curve, err := curveValue(elem)
+		if err != nil {
+			return nil, fmt.Errorf("capacitor %s: %v", elem.Name, err)
+		}
+		if curve != nil && m != 1 {
+			return nil, fmt.Errorf("capacitor %s: multiplier m is not supported for nonlinear capacitors", elem.Name)
+		}
+		if curve != nil {
+			return device.NewNonlinearCapacitor(elem.Name, elem.Nodes, curve), nil
+		}
+
+		capacitor := device.NewCapacitor(elem.Name, elem.Nodes, elem.Value)
+		if modelName, ok := elem.Params["model"]; ok {
+			model, exists := lookupModel(models, modelName)
+			if !exists || model.Type != "C" {
+				return nil, fmt.Errorf("undefined capacitor model for %s: %s", elem.Name, modelName)
+			}
+			capacitor.SetModelParameters(model.Params)
+		}
+		instParams, err := instanceParamValues(elem, "l", "w", "tc1", "tc2", "tnom", "temp")
+		if err != nil {
+			return nil, err
+		}
+		capacitor.SetInstanceParameters(instParams)
+		capacitor.Value *= m // m capacitors in parallel
+		ic, ok, err := initialCondition(elem)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			capacitor.SetInitialCondition(ic)
+		}
+		return capacitor, nil
+
+	case "P":
+		number, z0 := 0, 50.0
+		if numStr, ok := elem.Params["port"]; ok {
+			n, err := strconv.Atoi(numStr)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("port %s: invalid port number %s", elem.Name, numStr)
+			}
+			number = n
+		} else {
+			// Without port=, number from element name. eg. P2 -> 2
+			n, err := strconv.Atoi(elem.Name[1:])
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("port %s: port number required", elem.Name)
+			}
+			number = n
+		}
+		if z0Str, ok := elem.Params["z0"]; ok {
+			value, err := ParseValue(z0Str)
+			if err != nil || value <= 0 {
+				return nil, fmt.Errorf("port %s: invalid z0 %s", elem.Name, z0Str)
+			}
+			z0 = value
+		}
+		return device.NewPort(elem.Name, elem.Nodes, number, z0), nil
+
+	case "Y":
+		return device.NewLoopProbe(elem.Name, elem.Nodes), nil
 
 	case "K":
 		var indNames []string
@@ -804,30 +1822,108 @@ func CreateDevice(elem Element, nodeMap map[string]int, models map[string]device
 		}
 		return device.NewMutual(elem.Name, indNames, elem.Value), nil
 
+	case "E", "G":
+		transfer, err := transferValue(elem)
+		if err != nil {
+			return nil, fmt.Errorf("controlled source %s: %v", elem.Name, err)
+		}
+		if elem.Type == "E" {
+			return device.NewVCVS(elem.Name, elem.Nodes, elem.Value, transfer), nil
+		}
+		return device.NewVCCS(elem.Name, elem.Nodes, elem.Value, transfer), nil
+
+	case "A":
+		model, exists := lookupModel(models, elem.Params["model"])
+		if !exists {
+			return nil, fmt.Errorf("undefined model for %s: %s", elem.Name, elem.Params["model"])
+		}
+		switch model.Type {
+		case "COMP":
+			if len(elem.Nodes) != 4 && len(elem.Nodes) != 5 {
+				return nil, fmt.Errorf("comparator %s: requires output, input and optional clock nodes", elem.Name)
+			}
+			comparator := device.NewComparator(elem.Name, elem.Nodes)
+			comparator.SetModelParameters(model.Params)
+			return comparator, nil
+		case "VCO":
+			if len(elem.Nodes) != 4 {
+				return nil, fmt.Errorf("VCO %s: requires output and control nodes", elem.Name)
+			}
+			vco := device.NewVCO(elem.Name, elem.Nodes)
+			vco.SetModelParameters(model.Params)
+			return vco, nil
+		}
+		return nil, fmt.Errorf("invalid model type for %s: %s", elem.Name, model.Type)
+
+	case "S", "W":
+		model, exists := lookupModel(models, elem.Params["model"])
+		if !exists {
+			return nil, fmt.Errorf("undefined model for %s: %s", elem.Name, elem.Params["model"])
+		}
+		var sw *device.Switch
+		if elem.Type == "S" {
+			if model.Type != "SW" {
+				return nil, fmt.Errorf("invalid model type for switch %s: %s, expected SW", elem.Name, model.Type)
+			}
+			sw = device.NewVoltageSwitch(elem.Name, elem.Nodes)
+		} else {
+			if model.Type != "CSW" {
+				return nil, fmt.Errorf("invalid model type for switch %s: %s, expected CSW", elem.Name, model.Type)
+			}
+			sw = device.NewCurrentSwitch(elem.Name, elem.Nodes, elem.Params["control"])
+		}
+		sw.SetModelParameters(model.Params)
+		if sw.Ron <= 0 || sw.Roff <= 0 {
+			return nil, fmt.Errorf("switch %s: ron and roff must be positive", elem.Name)
+		}
+		sw.SetInitialState(elem.Params["on"] == "1")
+		return sw, nil
+
 	case "D":
 		diode := device.NewDiode(elem.Name, elem.Nodes)
 		if modelName, ok := elem.Params["model"]; ok {
-			if model, exists := models[modelName]; exists {
-				diode.SetModelParameters(model.Params)
+			model, exists := lookupModel(models, modelName)
+			if !exists {
+				return nil, fmt.Errorf("%s: model %q not found", elem.Name, modelName)
 			}
+			diode.SetModelParameters(model.Params)
+		}
+		instParams, err := instanceParamValues(elem, "area", "off", "temp", "dtemp", "rth", "cth")
+		if err != nil {
+			return nil, err
 		}
+		diode.SetInstanceParameters(instParams)
+		m, err := multiplier(elem)
+		if err != nil {
+			return nil, err
+		}
+		diode.Area *= m // m diodes in parallel scale Is and Cj0 as area
 		return diode, nil
 
 	case "Q":
 		bjt := device.NewBJT(elem.Name, elem.Nodes)
 		if modelName, ok := elem.Params["model"]; ok {
-			if model, exists := models[modelName]; exists {
-				bjt.SetModelParameters(model.Params)
+			model, exists := lookupModel(models, modelName)
+			if !exists {
+				return nil, fmt.Errorf("%s: model %q not found", elem.Name, modelName)
 			}
+			bjt.SetModelParameters(model.Params)
 		}
+		instParams, err := instanceParamValues(elem, "area", "off", "temp", "dtemp", "rth", "cth")
+		if err != nil {
+			return nil, err
+		}
+		bjt.SetInstanceParameters(instParams)
 		return bjt, nil
 
 	case "M":
 		if modelName, ok := elem.Params["model"]; ok {
 			mosfet := device.NewMosfet(elem.Name, elem.Nodes)
-			if model, exists := models[modelName]; exists {
-				mosfet.SetModelParameters(model.Params)
+			model, exists := lookupModel(models, modelName)
+			if !exists {
+				return nil, fmt.Errorf("%s: model %q not found", elem.Name, modelName)
 			}
+			mosfet.SetModelParameters(model.Params)
 
 			if l, ok := elem.Params["l"]; ok {
 				if lVal, err := ParseValue(l); err == nil {
@@ -851,11 +1947,11 @@ func CreateDevice(elem Element, nodeMap map[string]int, models map[string]device
 			return device.NewDCVoltageSource(elem.Name, elem.Nodes, elem.Value), nil
 
 		case "sin":
-			offset, amplitude, freq, phase, err := parseSinParams(elem.Params["sin"])
+			offset, amplitude, freq, delay, theta, phase, err := parseSinParams(elem.Params["sin"])
 			if err != nil {
 				return nil, err
 			}
-			return device.NewSinVoltageSource(elem.Name, elem.Nodes, offset, amplitude, freq, phase), nil
+			return device.NewSinVoltageSource(elem.Name, elem.Nodes, offset, amplitude, freq, delay, theta, phase), nil
 
 		case "pulse":
 			v1, v2, delay, rise, fall, pWidth, period, err := parsePulseParams(elem.Params["pulse"])
@@ -865,11 +1961,34 @@ func CreateDevice(elem Element, nodeMap map[string]int, models map[string]device
 			return device.NewPulseVoltageSource(elem.Name, elem.Nodes, v1, v2, delay, rise, fall, pWidth, period), nil
 
 		case "pwl":
-			times, values, err := parsePWLParams(elem.Params["pwl"])
+			times, values, repeat, delay, err := parsePWLParams(elem.Params["pwl"])
+			if err != nil {
+				return nil, err
+			}
+			source := device.NewPWLVoltageSource(elem.Name, elem.Nodes, times, values)
+			source.SetPWLRepeat(repeat, delay)
+			return source, nil
+
+		case "am":
+			va, vo, mf, fc, td, err := parseAMParams(elem.Params["am"])
 			if err != nil {
 				return nil, err
 			}
-			return device.NewPWLVoltageSource(elem.Name, elem.Nodes, times, values), nil
+			return device.NewAMVoltageSource(elem.Name, elem.Nodes, va, vo, mf, fc, td), nil
+
+		case "trnoise":
+			rms, step, seed, err := parseNoiseParams(elem.Params["trnoise"])
+			if err != nil {
+				return nil, err
+			}
+			return device.NewNoiseVoltageSource(elem.Name, elem.Nodes, rms, step, seed), nil
+
+		case "trrandom":
+			kind, step, delay, param1, param2, seed, err := parseRandomParams(elem.Params["trrandom"])
+			if err != nil {
+				return nil, err
+			}
+			return device.NewRandomVoltageSource(elem.Name, elem.Nodes, kind, step, delay, param1, param2, seed), nil
 
 		case "ac":
 			phase, err := ParseValue(elem.Params["phase"])
@@ -886,11 +2005,11 @@ func CreateDevice(elem Element, nodeMap map[string]int, models map[string]device
 		case "dc":
 			return device.NewDCCurrentSource(elem.Name, elem.Nodes, elem.Value), nil
 		case "sin":
-			offset, amplitude, freq, phase, err := parseSinParams(elem.Params["sin"])
+			offset, amplitude, freq, delay, theta, phase, err := parseSinParams(elem.Params["sin"])
 			if err != nil {
 				return nil, err
 			}
-			return device.NewSinCurrentSource(elem.Name, elem.Nodes, offset, amplitude, freq, phase), nil
+			return device.NewSinCurrentSource(elem.Name, elem.Nodes, offset, amplitude, freq, delay, theta, phase), nil
 		case "pulse":
 			i1, i2, delay, rise, fall, pWidth, period, err := parsePulseParams(elem.Params["pulse"])
 			if err != nil {
@@ -898,11 +2017,31 @@ func CreateDevice(elem Element, nodeMap map[string]int, models map[string]device
 			}
 			return device.NewPulseCurrentSource(elem.Name, elem.Nodes, i1, i2, delay, rise, fall, pWidth, period), nil
 		case "pwl":
-			times, values, err := parsePWLParams(elem.Params["pwl"])
+			times, values, repeat, delay, err := parsePWLParams(elem.Params["pwl"])
+			if err != nil {
+				return nil, err
+			}
+			source := device.NewPWLCurrentSource(elem.Name, elem.Nodes, times, values)
+			source.SetPWLRepeat(repeat, delay)
+			return source, nil
+		case "am":
+			ia, io, mf, fc, td, err := parseAMParams(elem.Params["am"])
+			if err != nil {
+				return nil, err
+			}
+			return device.NewAMCurrentSource(elem.Name, elem.Nodes, ia, io, mf, fc, td), nil
+		case "trnoise":
+			rms, step, seed, err := parseNoiseParams(elem.Params["trnoise"])
+			if err != nil {
+				return nil, err
+			}
+			return device.NewNoiseCurrentSource(elem.Name, elem.Nodes, rms, step, seed), nil
+		case "trrandom":
+			kind, step, delay, param1, param2, seed, err := parseRandomParams(elem.Params["trrandom"])
 			if err != nil {
 				return nil, err
 			}
-			return device.NewPWLCurrentSource(elem.Name, elem.Nodes, times, values), nil
+			return device.NewRandomCurrentSource(elem.Name, elem.Nodes, kind, step, delay, param1, param2, seed), nil
 		case "ac":
 			phase, err := ParseValue(elem.Params["phase"])
 			if err != nil {
@@ -914,43 +2053,160 @@ func CreateDevice(elem Element, nodeMap map[string]int, models map[string]device
 			return nil, fmt.Errorf("unsupported current source type: %s", elem.Params["type"])
 		}
 	}
+	if f, ok := device.Registered(elem.Type); ok {
+		return createCustomDevice(elem, models, f)
+	}
 	return nil, fmt.Errorf("unsupported device type: %s", elem.Type)
 }
 
-func parseSinParams(params string) (offset, amplitude, freq, phase float64, err error) {
-	sinParams := strings.Fields(params)
-	if len(sinParams) < 3 {
-		return 0, 0, 0, 0, fmt.Errorf("insufficient SIN parameters")
+// parseSinParams - SIN(VO VA FREQ [TD [THETA [PHASE]]])
+func parseSinParams(params string) (offset, amplitude, freq, delay, theta, phase float64, err error) {
+	values, err := parseSourceValues("SIN", params, 3, 6)
+	if err != nil {
+		return 0, 0, 0, 0, 0, 0, err
+	}
+	return values[0], values[1], values[2], values[3], values[4], values[5], nil
+}
+
+// loadPWLFile - Replace FILE=<name> of PWL source by time-value pairs of file.
+// Lines of file are "time value" separated by comma, semicolon or space. Other lines are skipped as header/comment.
+func loadPWLFile(netlistData *NetlistData, elem *Element) error {
+	params, ok := elem.Params["pwl"]
+	if !ok {
+		return nil
+	}
+
+	var fileName string
+	var rest []string
+	for _, field := range strings.Fields(params) {
+		if key, value, found := strings.Cut(field, "="); found && strings.EqualFold(key, "file") {
+			fileName = value
+			continue
+		}
+		rest = append(rest, field)
+	}
+	if fileName == "" {
+		return nil
 	}
 
-	// DC offset
-	offset, err = ParseValue(sinParams[0])
+	content, _, err := readIncludeFile(netlistData.files, netlistData.dir, fileName)
 	if err != nil {
-		return 0, 0, 0, 0, fmt.Errorf("invalid SIN offset: %v", err)
+		return fmt.Errorf("%s: %v", elem.Name, err)
+	}
+
+	var pairs []string
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || r == ';' || r == ' ' || r == '\t' || r == '\r'
+		})
+		if len(fields) < 2 {
+			continue
+		}
+		if _, err := ParseValue(fields[0]); err != nil {
+			continue
+		}
+		pairs = append(pairs, fields[0], fields[1])
+	}
+	if len(pairs) == 0 {
+		return fmt.Errorf("%s: no time-value pairs in PWL file %s", elem.Name, fileName)
 	}
 
-	// Amplitude
-	amplitude, err = ParseValue(sinParams[1])
+	elem.Params["pwl"] = strings.Join(append(pairs, rest...), " ")
+	return nil
+}
+
+// parseAMParams - AM(VA VO MF FC [TD])
+func parseAMParams(params string) (amplitude, offset, modFreq, carrierFreq, delay float64, err error) {
+	values, err := parseSourceValues("AM", params, 4, 5)
 	if err != nil {
-		return 0, 0, 0, 0, fmt.Errorf("invalid SIN amplitude: %v", err)
+		return 0, 0, 0, 0, 0, err
 	}
+	return values[0], values[1], values[2], values[3], values[4], nil
+}
 
-	// Frequency
-	freq, err = ParseValue(sinParams[2])
+// parseNoiseParams - TRNOISE(NA NT [seed=N]). Optional 1/f and RTS terms are not supported
+func parseNoiseParams(params string) (rms, step float64, seed int64, err error) {
+	params, seed, err = cutSeed(params)
 	if err != nil {
-		return 0, 0, 0, 0, fmt.Errorf("invalid SIN frequency: %v", err)
+		return 0, 0, 0, err
 	}
+	values, err := parseSourceValues("TRNOISE", params, 2, 2)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if values[1] <= 0 {
+		return 0, 0, 0, fmt.Errorf("TRNOISE time step must be positive")
+	}
+	return values[0], values[1], seed, nil
+}
 
-	// Phase
-	phase = 0.0
-	if len(sinParams) > 3 {
-		phase, err = ParseValue(sinParams[3])
+// parseRandomParams - TRRANDOM(TYPE TS [TD [PARAM1 [PARAM2]]] [seed=N])
+func parseRandomParams(params string) (kind int, step, delay, param1, param2 float64, seed int64, err error) {
+	params, seed, err = cutSeed(params)
+	if err != nil {
+		return 0, 0, 0, 0, 0, 0, err
+	}
+	values, err := parseSourceValues("TRRANDOM", params, 2, 5)
+	if err != nil {
+		return 0, 0, 0, 0, 0, 0, err
+	}
+
+	kind = int(values[0])
+	if float64(kind) != values[0] || kind < device.RandomUniform || kind > device.RandomPoisson {
+		return 0, 0, 0, 0, 0, 0, fmt.Errorf("invalid TRRANDOM type: %g", values[0])
+	}
+	if values[1] <= 0 {
+		return 0, 0, 0, 0, 0, 0, fmt.Errorf("TRRANDOM time step must be positive")
+	}
+
+	// Default PARAM1 is 1
+	param1 = 1
+	if len(strings.Fields(params)) > 3 {
+		param1 = values[3]
+	}
+	return kind, values[1], values[2], param1, values[4], seed, nil
+}
+
+// parseSourceValues - At least min and at most max values. Missing optional values are zero
+func parseSourceValues(name, params string, min, max int) ([]float64, error) {
+	// Text after closing parenthesis is not part of parameters. eg. trailing comment
+	params, _, _ = strings.Cut(params, ")")
+	fields := strings.Fields(params)
+	if len(fields) < min {
+		return nil, fmt.Errorf("insufficient %s parameters", name)
+	}
+	if len(fields) > max {
+		return nil, fmt.Errorf("too many %s parameters", name)
+	}
+
+	values := make([]float64, max)
+	for i, field := range fields {
+		value, err := ParseValue(field)
 		if err != nil {
-			return 0, 0, 0, 0, fmt.Errorf("invalid SIN phase: %v", err)
+			return nil, fmt.Errorf("invalid %s parameter %d: %v", name, i+1, err)
 		}
+		values[i] = value
 	}
+	return values, nil
+}
 
-	return offset, amplitude, freq, phase, nil
+// cutSeed - Remove "seed=N" from parameters of random source
+func cutSeed(params string) (string, int64, error) {
+	var rest []string
+	var seed int64
+	for _, field := range strings.Fields(params) {
+		key, value, found := strings.Cut(field, "=")
+		if !found || !strings.EqualFold(key, "seed") {
+			rest = append(rest, field)
+			continue
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", 0, fmt.Errorf("invalid seed: %s", value)
+		}
+		seed = n
+	}
+	return strings.Join(rest, " "), seed, nil
 }
 
 func parsePulseParams(params string) (v1, v2, delay, rise, fall, pWidth, period float64, err error) {
@@ -1004,8 +2260,44 @@ func parsePulseParams(params string) (v1, v2, delay, rise, fall, pWidth, period
 	return v1, v2, delay, rise, fall, pWidth, period, nil
 }
 
-func parsePWLParams(params string) (times []float64, values []float64, err error) {
-	pwlParams := strings.Fields(params)
+// parsePWLParams - Time-value pairs with options r=<repeat from time> and td=<delay>.
+// repeat is negative without r=.
+func parsePWLParams(params string) (times []float64, values []float64, repeat, delay float64, err error) {
+	repeat = -1
+
+	var pwlParams []string
+	for _, field := range strings.Fields(params) {
+		key, valueStr, found := strings.Cut(field, "=")
+		if !found {
+			pwlParams = append(pwlParams, field)
+			continue
+		}
+		value, err := ParseValue(valueStr)
+		if err != nil {
+			return nil, nil, 0, 0, fmt.Errorf("invalid PWL option %s: %v", field, err)
+		}
+		switch strings.ToLower(key) {
+		case "r":
+			repeat = value
+		case "td":
+			delay = value
+		default:
+			return nil, nil, 0, 0, fmt.Errorf("unsupported PWL option: %s", field)
+		}
+	}
+
+	times, values, err = parsePWLPairs(pwlParams)
+	if err != nil {
+		return nil, nil, 0, 0, err
+	}
+	if repeat >= times[len(times)-1] {
+		return nil, nil, 0, 0, fmt.Errorf("PWL repeat time %g must be before last time point %g", repeat, times[len(times)-1])
+	}
+
+	return times, values, repeat, delay, nil
+}
+
+func parsePWLPairs(pwlParams []string) (times []float64, values []float64, err error) {
 	if len(pwlParams) < 4 || len(pwlParams)%2 != 0 {
 		return nil, nil, fmt.Errorf("insufficient or invalid PWL parameters, need pairs of time-value")
 	}
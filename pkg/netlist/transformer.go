@@ -0,0 +1,104 @@
+package netlist
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Element names starting with XFMR are transformers
+const transformerPrefix = "XFMR"
+
+// Default coupling of transformer windings. Small leakage keeps inductance matrix invertible
+const defaultTransformerCoupling = 0.999
+
+func isTransformer(name string) bool {
+	return len(name) > len(transformerPrefix) && strings.EqualFold(name[:len(transformerPrefix)], transformerPrefix)
+}
+
+// parseTransformer - Expand transformer to primary and secondary inductors and their coupling
+//
+//	XFMRname p+ p- s+ s- ratio=<Np/Ns> lm=<H> [k=<coupling>]
+//	XFMRname p+ p- s+ s- ratio=<Np/Ns> core=<model> turns=<Np> [k=<coupling>]
+//
+// Windings are named "<name>.LP" and "<name>.LS", coupling is "<name>.K".
+// lm is magnetizing inductance seen from primary, secondary is lm/ratio^2.
+func parseTransformer(fields []string) ([]Element, error) {
+	name := fields[0]
+	if len(fields) < 6 {
+		return nil, fmt.Errorf("insufficient transformer parameters for %s: need 4 nodes and ratio", name)
+	}
+
+	nodes := fields[1:5]
+	params := make(map[string]string)
+	for _, field := range fields[5:] {
+		key, value, found := strings.Cut(field, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid transformer parameter for %s: %s", name, field)
+		}
+		params[strings.ToLower(key)] = value
+	}
+
+	ratioStr, ok := params["ratio"]
+	if !ok {
+		return nil, fmt.Errorf("transformer %s requires ratio", name)
+	}
+	ratio, err := ParseValue(ratioStr)
+	if err != nil || ratio <= 0 {
+		return nil, fmt.Errorf("invalid turns ratio for transformer %s: %s", name, ratioStr)
+	}
+
+	k := defaultTransformerCoupling
+	if kStr, ok := params["k"]; ok {
+		k, err = ParseValue(kStr)
+		if err != nil || k <= 0 || k > 1 {
+			return nil, fmt.Errorf("invalid coupling coefficient for transformer %s: %s", name, kStr)
+		}
+	}
+
+	primary := Element{Type: "L", Name: name + ".LP", Nodes: nodes[0:2], Params: make(map[string]string)}
+	secondary := Element{Type: "L", Name: name + ".LS", Nodes: nodes[2:4], Params: make(map[string]string)}
+
+	if coreName, ok := params["core"]; ok {
+		// Windings on Jiles-Atherton core. Inductance follows from turns and core
+		turnsStr, ok := params["turns"]
+		if !ok {
+			return nil, fmt.Errorf("transformer %s with core requires primary turns", name)
+		}
+		turns, err := strconv.Atoi(turnsStr)
+		if err != nil || turns <= 0 {
+			return nil, fmt.Errorf("invalid primary turns for transformer %s: %s", name, turnsStr)
+		}
+		secondaryTurns := int(math.Round(float64(turns) / ratio))
+		if secondaryTurns < 1 {
+			return nil, fmt.Errorf("turns ratio of transformer %s leaves no secondary turns", name)
+		}
+
+		primary.Params["core"] = coreName
+		primary.Params["turns"] = strconv.Itoa(turns)
+		secondary.Params["core"] = coreName
+		secondary.Params["turns"] = strconv.Itoa(secondaryTurns)
+	} else {
+		lmStr, ok := params["lm"]
+		if !ok {
+			return nil, fmt.Errorf("transformer %s requires magnetizing inductance lm or core", name)
+		}
+		lm, err := ParseValue(lmStr)
+		if err != nil || lm <= 0 {
+			return nil, fmt.Errorf("invalid magnetizing inductance for transformer %s: %s", name, lmStr)
+		}
+
+		primary.Value = lm
+		secondary.Value = lm / (ratio * ratio)
+	}
+
+	coupling := Element{
+		Type:   "K",
+		Name:   name + ".K",
+		Value:  k,
+		Params: map[string]string{"ind1": primary.Name, "ind2": secondary.Name},
+	}
+
+	return []Element{primary, secondary, coupling}, nil
+}
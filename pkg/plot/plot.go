@@ -0,0 +1,345 @@
+package plot
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/edp1096/toy-spice/pkg/analysis"
+)
+
+// Series - One curve of a panel
+type Series struct {
+	Name string
+	X    []float64
+	Y    []float64
+}
+
+// Panel - One chart. Series in a panel share axes
+type Panel struct {
+	Title  string
+	XLabel string
+	YLabel string
+	LogX   bool
+	Series []Series
+}
+
+// Figure - Panels stacked vertically
+type Figure struct {
+	Title  string
+	Panels []Panel
+}
+
+// Bode - Magnitude(dB) and phase panels of AC results.
+// names are result base names like "V(2)". Empty names plot all node voltages.
+func Bode(results map[string][]float64, names []string) (*Figure, error) {
+	freqs, ok := results["FREQ"]
+	if !ok {
+		return nil, fmt.Errorf("bode plot needs AC results")
+	}
+
+	if len(names) == 0 {
+		for key := range results {
+			if name, ok := strings.CutSuffix(key, "_MAG"); ok && strings.HasPrefix(name, "V(") {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+	}
+
+	xLabel := analysis.DescribeResult("FREQ").Label
+	magnitude := Panel{Title: "Magnitude", XLabel: xLabel, YLabel: "dB", LogX: true}
+	phase := Panel{Title: "Phase", XLabel: xLabel, YLabel: "deg", LogX: true}
+	for _, name := range names {
+		mag, ok := results[name+"_MAG"]
+		if !ok {
+			return nil, fmt.Errorf("no AC result for %s", name)
+		}
+
+		db, ok := results[name+"_DB"]
+		if !ok {
+			db = make([]float64, len(mag))
+			for i, m := range mag {
+				db[i] = 20.0 * math.Log10(m)
+			}
+		}
+		ph, ok := results[name+"_PHASE_UNWRAPPED"]
+		if !ok {
+			ph = results[name+"_PHASE"]
+		}
+
+		magnitude.Series = append(magnitude.Series, Series{Name: name, X: freqs, Y: db})
+		phase.Series = append(phase.Series, Series{Name: name, X: freqs, Y: ph})
+	}
+
+	return &Figure{Title: "Bode Plot", Panels: []Panel{magnitude, phase}}, nil
+}
+
+// Waveform - Voltage and current panels of TRAN or DC sweep results.
+// Empty names plot all node voltages and branch currents.
+func Waveform(results map[string][]float64, names []string) (*Figure, error) {
+	xKey := "TIME"
+	if _, isDC := results["SWEEP1"]; isDC {
+		xKey = "SWEEP1"
+	}
+	xLabel := analysis.DescribeResult(xKey).Label
+	xs, ok := results[xKey]
+	if !ok || len(xs) < 2 {
+		return nil, fmt.Errorf("waveform plot needs transient or DC sweep results")
+	}
+
+	if len(names) == 0 {
+		for key := range results {
+			if strings.HasPrefix(key, "V(") || strings.HasPrefix(key, "I(") {
+				names = append(names, key)
+			}
+		}
+		sort.Strings(names)
+	}
+
+	voltages := Panel{Title: "Voltages", XLabel: xLabel, YLabel: "V"}
+	currents := Panel{Title: "Currents", XLabel: xLabel, YLabel: "A"}
+	for _, name := range names {
+		ys, ok := results[name]
+		if !ok {
+			return nil, fmt.Errorf("no result for %s", name)
+		}
+
+		series := Series{Name: name, X: xs, Y: ys}
+		if analysis.DescribeResult(name).Unit == "A" {
+			currents.Series = append(currents.Series, series)
+		} else {
+			voltages.Series = append(voltages.Series, series)
+		}
+	}
+
+	figure := &Figure{Title: "Waveforms"}
+	if xKey == "SWEEP1" {
+		figure.Title = "DC Sweep"
+	}
+	for _, panel := range []Panel{voltages, currents} {
+		if len(panel.Series) > 0 {
+			figure.Panels = append(figure.Panels, panel)
+		}
+	}
+
+	return figure, nil
+}
+
+// Save - Write figure to .svg or .png file by file extension.
+// .gp and .py are gnuplot and matplotlib scripts, with data in .dat file of same name
+func (f *Figure) Save(fileName string, width, height int) error {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".svg":
+		return f.saveSVG(fileName, width, height)
+	case ".png":
+		return f.savePNG(fileName, width, height)
+	case ".gp", ".gnuplot":
+		return f.saveScript(fileName, width, height, f.Gnuplot)
+	case ".py":
+		return f.saveScript(fileName, width, height, f.Matplotlib)
+	default:
+		return fmt.Errorf("unsupported plot file type: %s", fileName)
+	}
+}
+
+// Drawing primitives shared by SVG and PNG output
+type canvas interface {
+	line(x1, y1, x2, y2 float64, color rgb)
+	polyline(xs, ys []float64, color rgb)
+	text(x, y float64, s string, anchor textAnchor)
+}
+
+type rgb struct{ r, g, b uint8 }
+
+type textAnchor int
+
+const (
+	anchorStart textAnchor = iota
+	anchorMiddle
+	anchorEnd
+)
+
+var (
+	colorAxis = rgb{0, 0, 0}
+	colorGrid = rgb{220, 220, 220}
+	palette   = []rgb{
+		{31, 119, 180}, {214, 39, 40}, {44, 160, 44}, {255, 127, 14},
+		{148, 103, 189}, {140, 86, 75}, {227, 119, 194}, {127, 127, 127},
+	}
+)
+
+const (
+	marginLeft   = 80.0
+	marginRight  = 20.0
+	marginTop    = 30.0
+	marginBottom = 45.0
+	titleHeight  = 30.0
+	fontHeight   = 13.0
+)
+
+// draw - Layout of figure on canvas
+func (f *Figure) draw(c canvas, width, height int) {
+	w, h := float64(width), float64(height)
+	c.text(w/2, titleHeight-8, f.Title, anchorMiddle)
+
+	if len(f.Panels) == 0 {
+		return
+	}
+	panelHeight := (h - titleHeight) / float64(len(f.Panels))
+	for i, panel := range f.Panels {
+		top := titleHeight + float64(i)*panelHeight
+		panel.draw(c, marginLeft, top+marginTop, w-marginRight, top+panelHeight-marginBottom)
+	}
+}
+
+func (p *Panel) draw(c canvas, left, top, right, bottom float64) {
+	xMin, xMax, yMin, yMax := p.bounds()
+	xTicks := linearTicks(xMin, xMax)
+	if p.LogX {
+		xTicks = logTicks(xMin, xMax)
+	}
+	yTicks := linearTicks(yMin, yMax)
+
+	mapX := func(x float64) float64 {
+		if p.LogX {
+			x = math.Log10(x)
+		}
+		return left + (x-xMin)/(xMax-xMin)*(right-left)
+	}
+	mapY := func(y float64) float64 {
+		return bottom - (y-yMin)/(yMax-yMin)*(bottom-top)
+	}
+
+	// Grid and tick labels
+	for _, tick := range xTicks {
+		px := mapX(tick)
+		c.line(px, top, px, bottom, colorGrid)
+		c.text(px, bottom+fontHeight+2, formatTick(tick), anchorMiddle)
+	}
+	for _, tick := range yTicks {
+		py := mapY(tick)
+		c.line(left, py, right, py, colorGrid)
+		c.text(left-5, py+fontHeight/3, formatTick(tick), anchorEnd)
+	}
+
+	// Frame
+	c.line(left, top, right, top, colorAxis)
+	c.line(left, bottom, right, bottom, colorAxis)
+	c.line(left, top, left, bottom, colorAxis)
+	c.line(right, top, right, bottom, colorAxis)
+
+	// Labels
+	c.text((left+right)/2, top-6, p.Title, anchorMiddle)
+	c.text((left+right)/2, bottom+2*fontHeight+6, p.XLabel, anchorMiddle)
+	c.text(left-5, top-6, p.YLabel, anchorEnd)
+
+	// Curves and legend
+	for i, series := range p.Series {
+		color := palette[i%len(palette)]
+
+		var xs, ys []float64
+		for j := range series.X {
+			if j >= len(series.Y) {
+				break
+			}
+			x, y := series.X[j], series.Y[j]
+			if (p.LogX && x <= 0) || math.IsNaN(y) || math.IsInf(y, 0) {
+				continue
+			}
+			xs = append(xs, mapX(x))
+			ys = append(ys, mapY(y))
+		}
+		c.polyline(xs, ys, color)
+
+		ly := top + float64(i+1)*(fontHeight+4)
+		c.line(right-110, ly-fontHeight/3, right-90, ly-fontHeight/3, color)
+		c.text(right-85, ly, series.Name, anchorStart)
+	}
+}
+
+// bounds - Data range of panel. X is log10 for LogX
+func (p *Panel) bounds() (xMin, xMax, yMin, yMax float64) {
+	xMin, yMin = math.Inf(1), math.Inf(1)
+	xMax, yMax = math.Inf(-1), math.Inf(-1)
+	for _, series := range p.Series {
+		for j, x := range series.X {
+			if j >= len(series.Y) {
+				break
+			}
+			y := series.Y[j]
+			if p.LogX {
+				if x <= 0 {
+					continue
+				}
+				x = math.Log10(x)
+			}
+			if math.IsNaN(y) || math.IsInf(y, 0) {
+				continue
+			}
+			xMin, xMax = math.Min(xMin, x), math.Max(xMax, x)
+			yMin, yMax = math.Min(yMin, y), math.Max(yMax, y)
+		}
+	}
+
+	if math.IsInf(xMin, 0) {
+		return 0, 1, 0, 1
+	}
+	if xMax == xMin {
+		xMin, xMax = xMin-0.5, xMax+0.5
+	}
+	if yMax == yMin {
+		delta := math.Max(math.Abs(yMin)*0.1, 1e-12)
+		yMin, yMax = yMin-delta, yMax+delta
+	}
+
+	// Round Y range to tick steps
+	step := niceStep(yMax - yMin)
+	yMin, yMax = math.Floor(yMin/step)*step, math.Ceil(yMax/step)*step
+
+	return xMin, xMax, yMin, yMax
+}
+
+// niceStep - 1, 2, 5 x 10^n step for about 5 divisions
+func niceStep(span float64) float64 {
+	raw := span / 5
+	exp := math.Pow(10, math.Floor(math.Log10(raw)))
+	switch frac := raw / exp; {
+	case frac <= 1:
+		return exp
+	case frac <= 2:
+		return 2 * exp
+	case frac <= 5:
+		return 5 * exp
+	default:
+		return 10 * exp
+	}
+}
+
+func linearTicks(lo, hi float64) []float64 {
+	step := niceStep(hi - lo)
+	var ticks []float64
+	for tick := math.Ceil(lo/step) * step; tick <= hi+step*1e-9; tick += step {
+		if math.Abs(tick) < step*1e-9 {
+			tick = 0
+		}
+		ticks = append(ticks, tick)
+	}
+	return ticks
+}
+
+// logTicks - Decades between log10 bounds, returned as real values
+func logTicks(logLo, logHi float64) []float64 {
+	var ticks []float64
+	for exp := math.Ceil(logLo - 1e-9); exp <= logHi+1e-9; exp++ {
+		ticks = append(ticks, math.Pow(10, exp))
+	}
+	return ticks
+}
+
+func formatTick(value float64) string {
+	return strconv.FormatFloat(value, 'g', 3, 64)
+}
@@ -0,0 +1,110 @@
+package plot
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"os"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+type pngCanvas struct {
+	img *image.RGBA
+}
+
+func (c *pngCanvas) line(x1, y1, x2, y2 float64, clr rgb) {
+	c.drawLine(x1, y1, x2, y2, clr.rgba())
+}
+
+func (c *pngCanvas) polyline(xs, ys []float64, clr rgb) {
+	for i := 1; i < len(xs); i++ {
+		c.drawLine(xs[i-1], ys[i-1], xs[i], ys[i], clr.rgba())
+		// Thicker curve than grid
+		c.drawLine(xs[i-1], ys[i-1]+1, xs[i], ys[i]+1, clr.rgba())
+	}
+}
+
+func (c *pngCanvas) text(x, y float64, s string, anchor textAnchor) {
+	drawer := &font.Drawer{
+		Dst:  c.img,
+		Src:  image.NewUniform(color.Black),
+		Face: basicfont.Face7x13,
+	}
+
+	width := float64(drawer.MeasureString(s).Round())
+	switch anchor {
+	case anchorMiddle:
+		x -= width / 2
+	case anchorEnd:
+		x -= width
+	}
+
+	drawer.Dot = fixed.P(int(math.Round(x)), int(math.Round(y)))
+	drawer.DrawString(s)
+}
+
+// drawLine - Bresenham line
+func (c *pngCanvas) drawLine(x1, y1, x2, y2 float64, clr color.RGBA) {
+	ix1, iy1 := int(math.Round(x1)), int(math.Round(y1))
+	ix2, iy2 := int(math.Round(x2)), int(math.Round(y2))
+
+	dx, dy := abs(ix2-ix1), -abs(iy2-iy1)
+	sx, sy := 1, 1
+	if ix1 > ix2 {
+		sx = -1
+	}
+	if iy1 > iy2 {
+		sy = -1
+	}
+
+	e := dx + dy
+	for {
+		c.img.SetRGBA(ix1, iy1, clr)
+		if ix1 == ix2 && iy1 == iy2 {
+			return
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			ix1 += sx
+		}
+		if e2 <= dx {
+			e += dx
+			iy1 += sy
+		}
+	}
+}
+
+func (clr rgb) rgba() color.RGBA {
+	return color.RGBA{clr.r, clr.g, clr.b, 255}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// Image - Figure as raster image
+func (f *Figure) Image(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	f.draw(&pngCanvas{img: img}, width, height)
+	return img
+}
+
+func (f *Figure) savePNG(fileName string, width, height int) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return png.Encode(file, f.Image(width, height))
+}
@@ -0,0 +1,117 @@
+package plot
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Data - Series of figure as whitespace separated x y blocks. Blocks are separated by two blank lines,
+// so block index of series is gnuplot index
+func (f *Figure) Data() string {
+	var out strings.Builder
+	for i, series := range f.series() {
+		if i > 0 {
+			out.WriteString("\n\n")
+		}
+		fmt.Fprintf(&out, "# %s\n", series.Name)
+		for j := range min(len(series.X), len(series.Y)) {
+			out.WriteString(strconv.FormatFloat(series.X[j], 'g', -1, 64))
+			out.WriteByte(' ')
+			out.WriteString(strconv.FormatFloat(series.Y[j], 'g', -1, 64))
+			out.WriteByte('\n')
+		}
+	}
+	return out.String()
+}
+
+// series - All series in order of panels
+func (f *Figure) series() []Series {
+	var all []Series
+	for _, panel := range f.Panels {
+		all = append(all, panel.Series...)
+	}
+	return all
+}
+
+// Gnuplot - Script plotting data file written by Data. Run by "gnuplot script.gp" in directory of data file
+func (f *Figure) Gnuplot(dataFile string, width, height int) string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "# %s\neval sprintf(\"set terminal %%s size %d,%d\", GPVAL_TERM)\nset grid\n", f.Title, width, height)
+	fmt.Fprintf(&out, "set multiplot layout %d,1 title %s\n", max(len(f.Panels), 1), strconv.Quote(f.Title))
+
+	index := 0
+	for _, panel := range f.Panels {
+		fmt.Fprintf(&out, "\nset title %s\n", strconv.Quote(panel.Title))
+		fmt.Fprintf(&out, "set xlabel %s\nset ylabel %s\n", strconv.Quote(panel.XLabel), strconv.Quote(panel.YLabel))
+		if panel.LogX {
+			out.WriteString("set logscale x\n")
+		} else {
+			out.WriteString("unset logscale x\n")
+		}
+
+		var plots []string
+		for _, series := range panel.Series {
+			plots = append(plots, fmt.Sprintf("%s index %d using 1:2 with lines title %s",
+				strconv.Quote(dataFile), index, strconv.Quote(series.Name)))
+			index++
+		}
+		if len(plots) > 0 {
+			fmt.Fprintf(&out, "plot %s\n", strings.Join(plots, ", \\\n     "))
+		}
+	}
+
+	out.WriteString("\nunset multiplot\npause mouse close\n")
+	return out.String()
+}
+
+// Matplotlib - Python script plotting data file written by Data. Data file is found next to script
+func (f *Figure) Matplotlib(dataFile string, width, height int) string {
+	var out strings.Builder
+	fmt.Fprintf(&out, `# %s
+import os
+
+import matplotlib.pyplot as plt
+import numpy as np
+
+
+def blocks(file_name):
+    with open(file_name) as f:
+        return [np.atleast_2d(np.loadtxt(block.splitlines())) for block in f.read().split("\n\n\n") if block.strip()]
+
+
+data = blocks(os.path.join(os.path.dirname(os.path.abspath(__file__)), %s))
+fig, axes = plt.subplots(%d, 1, figsize=(%g, %g), squeeze=False)
+fig.suptitle(%s)
+`, f.Title, strconv.Quote(dataFile), max(len(f.Panels), 1), float64(width)/100, float64(height)/100, strconv.Quote(f.Title))
+
+	index := 0
+	for i, panel := range f.Panels {
+		fmt.Fprintf(&out, "\nax = axes[%d][0]\n", i)
+		for _, series := range panel.Series {
+			fmt.Fprintf(&out, "ax.plot(data[%d][:, 0], data[%d][:, 1], label=%s)\n", index, index, strconv.Quote(series.Name))
+			index++
+		}
+		fmt.Fprintf(&out, "ax.set_title(%s)\nax.set_xlabel(%s)\nax.set_ylabel(%s)\n",
+			strconv.Quote(panel.Title), strconv.Quote(panel.XLabel), strconv.Quote(panel.YLabel))
+		if panel.LogX {
+			out.WriteString("ax.set_xscale(\"log\")\n")
+		}
+		out.WriteString("ax.grid(True)\nax.legend()\n")
+	}
+
+	out.WriteString("\nplt.tight_layout()\nplt.show()\n")
+	return out.String()
+}
+
+// saveScript - Data file next to script, named as script with .dat extension
+func (f *Figure) saveScript(fileName string, width, height int, script func(dataFile string, width, height int) string) error {
+	dataPath := strings.TrimSuffix(fileName, filepath.Ext(fileName)) + ".dat"
+	err := os.WriteFile(dataPath, []byte(f.Data()), 0644)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, []byte(script(filepath.Base(dataPath), width, height)), 0644)
+}
@@ -0,0 +1,57 @@
+package plot
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"strings"
+)
+
+type svgCanvas struct {
+	body strings.Builder
+}
+
+func (c *svgCanvas) line(x1, y1, x2, y2 float64, color rgb) {
+	fmt.Fprintf(&c.body, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="1"/>`+"\n",
+		x1, y1, x2, y2, color.hex())
+}
+
+func (c *svgCanvas) polyline(xs, ys []float64, color rgb) {
+	if len(xs) == 0 {
+		return
+	}
+	var points strings.Builder
+	for i := range xs {
+		fmt.Fprintf(&points, "%.1f,%.1f ", xs[i], ys[i])
+	}
+	fmt.Fprintf(&c.body, `<polyline points="%s" fill="none" stroke="%s" stroke-width="1.5"/>`+"\n",
+		strings.TrimSpace(points.String()), color.hex())
+}
+
+func (c *svgCanvas) text(x, y float64, s string, anchor textAnchor) {
+	anchorName := [...]string{"start", "middle", "end"}[anchor]
+	fmt.Fprintf(&c.body, `<text x="%.1f" y="%.1f" text-anchor="%s">%s</text>`+"\n",
+		x, y, anchorName, html.EscapeString(s))
+}
+
+func (color rgb) hex() string {
+	return fmt.Sprintf("#%02x%02x%02x", color.r, color.g, color.b)
+}
+
+// SVG - Figure as SVG document
+func (f *Figure) SVG(width, height int) string {
+	c := &svgCanvas{}
+	f.draw(c, width, height)
+
+	var out strings.Builder
+	fmt.Fprintf(&out, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`+"\n",
+		width, height, width, height)
+	fmt.Fprintf(&out, `<rect width="%d" height="%d" fill="white"/>`+"\n", width, height)
+	out.WriteString(c.body.String())
+	out.WriteString("</svg>\n")
+	return out.String()
+}
+
+func (f *Figure) saveSVG(fileName string, width, height int) error {
+	return os.WriteFile(fileName, []byte(f.SVG(width, height)), 0644)
+}
@@ -0,0 +1,80 @@
+package post
+
+import (
+	"fmt"
+	"sort"
+)
+
+// CheckSweep - x has 2 or more points in nondecreasing order and y has value at every point
+func CheckSweep(x, y []float64) error {
+	if len(x) < 2 {
+		return fmt.Errorf("requires at least 2 points")
+	}
+	if len(y) != len(x) {
+		return fmt.Errorf("has %d values for %d points", len(y), len(x))
+	}
+	for k := 1; k < len(x); k++ {
+		if x[k] < x[k-1] {
+			return fmt.Errorf("requires monotonic sweep")
+		}
+	}
+	return nil
+}
+
+// Interpolate - y at x = at by linear interpolation. Error outside of x
+func Interpolate(x, y []float64, at float64) (float64, error) {
+	if at < x[0] || at > x[len(x)-1] {
+		return 0, fmt.Errorf("%g outside of results %g to %g", at, x[0], x[len(x)-1])
+	}
+	k := sort.SearchFloat64s(x, at)
+	if x[k] == at || k == 0 {
+		return y[k], nil
+	}
+	t := (at - x[k-1]) / (x[k] - x[k-1])
+	return y[k-1] + t*(y[k]-y[k-1]), nil
+}
+
+// Derivative - dy/dx at every point. Three point formula of unequal spacing inside, one sided at ends
+// and next to repeated points. eg. breakpoints of transient
+func Derivative(x, y []float64) []float64 {
+	n := len(x)
+	d := make([]float64, n)
+	for i := range n {
+		var h1, h2 float64
+		if i > 0 {
+			h1 = x[i] - x[i-1]
+		}
+		if i < n-1 {
+			h2 = x[i+1] - x[i]
+		}
+		switch {
+		case h1 > 0 && h2 > 0:
+			d[i] = -h2/(h1*(h1+h2))*y[i-1] + (h2-h1)/(h1*h2)*y[i] + h1/(h2*(h1+h2))*y[i+1]
+		case h2 > 0:
+			d[i] = (y[i+1] - y[i]) / h2
+		case h1 > 0:
+			d[i] = (y[i] - y[i-1]) / h1
+		case i > 0:
+			d[i] = d[i-1]
+		}
+	}
+	return d
+}
+
+// Integral - Integral of y over x from from to to by trapezoidal rule. Window ends are interpolated
+func Integral(x, y []float64, from, to float64) (float64, error) {
+	if from < x[0] || to > x[len(x)-1] {
+		return 0, fmt.Errorf("window %g to %g outside of results %g to %g", from, to, x[0], x[len(x)-1])
+	}
+	sum := 0.0
+	for k := 1; k < len(x); k++ {
+		a, b := max(x[k-1], from), min(x[k], to)
+		if b <= a {
+			continue
+		}
+		ya, _ := Interpolate(x[k-1:k+1], y[k-1:k+1], a)
+		yb, _ := Interpolate(x[k-1:k+1], y[k-1:k+1], b)
+		sum += (b - a) * (ya + yb) / 2
+	}
+	return sum, nil
+}
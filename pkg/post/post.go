@@ -0,0 +1,171 @@
+// Package post - Measurements of result vectors y over sweep x, eg. TIME of transient. x is nondecreasing,
+// values between points are linear interpolation, so crossings and window ends fall between points
+package post
+
+import (
+	"fmt"
+	"math"
+)
+
+// Edge - Direction of level crossing
+type Edge int
+
+const (
+	Rising Edge = iota
+	Falling
+	EitherEdge
+)
+
+// Average - Mean of y over window from to to. Value at point for empty window
+func Average(x, y []float64, from, to float64) (float64, error) {
+	if to == from {
+		return Interpolate(x, y, from)
+	}
+	sum, err := Integral(x, y, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return sum / (to - from), nil
+}
+
+// RMS - Root mean square of y over window from to to
+func RMS(x, y []float64, from, to float64) (float64, error) {
+	square := make([]float64, len(y))
+	for i, v := range y {
+		square[i] = v * v
+	}
+	mean, err := Average(x, square, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return math.Sqrt(mean), nil
+}
+
+// Extremes - Lowest and highest y over window from to to, including interpolated window ends
+func Extremes(x, y []float64, from, to float64) (low, high float64, err error) {
+	low, err = Interpolate(x, y, from)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := Interpolate(x, y, to)
+	if err != nil {
+		return 0, 0, err
+	}
+	low, high = min(low, end), max(low, end)
+	for k := range x {
+		if x[k] > from && x[k] < to {
+			low, high = min(low, y[k]), max(high, y[k])
+		}
+	}
+	return low, high, nil
+}
+
+// PeakToPeak - Highest minus lowest y over window from to to
+func PeakToPeak(x, y []float64, from, to float64) (float64, error) {
+	low, high, err := Extremes(x, y, from, to)
+	return high - low, err
+}
+
+// Cross - x of first crossing of level by y in direction of edge at or after from
+func Cross(x, y []float64, level float64, edge Edge, from float64) (float64, error) {
+	for k := 1; k < len(x); k++ {
+		if x[k] < from {
+			continue
+		}
+		a, b := y[k-1]-level, y[k]-level
+		rising := a < 0 && b >= 0
+		falling := a > 0 && b <= 0
+		if !(rising && edge != Falling) && !(falling && edge != Rising) {
+			continue
+		}
+		at := x[k-1] + a/(a-b)*(x[k]-x[k-1])
+		if at >= from {
+			return at, nil
+		}
+	}
+	return 0, fmt.Errorf("no crossing of %g after %g", level, from)
+}
+
+// step - Initial and final value of step response, error for flat response
+func step(y []float64) (initial, final float64, err error) {
+	initial, final = y[0], y[len(y)-1]
+	if initial == final {
+		return 0, 0, fmt.Errorf("no step, initial and final values are %g", initial)
+	}
+	return initial, final, nil
+}
+
+// RiseTime - Time of rising step from low to high fraction of step from first to last value. eg. 0.1, 0.9
+func RiseTime(x, y []float64, low, high float64) (float64, error) {
+	initial, final, err := step(y)
+	if err != nil {
+		return 0, err
+	}
+	if final < initial {
+		return 0, fmt.Errorf("no rising step, final value %g below initial value %g", final, initial)
+	}
+	return transitionTime(x, y, initial, final, low, high, Rising)
+}
+
+// FallTime - Time of falling step from high to low fraction of step from last to first value. eg. 0.1, 0.9
+func FallTime(x, y []float64, low, high float64) (float64, error) {
+	initial, final, err := step(y)
+	if err != nil {
+		return 0, err
+	}
+	if final > initial {
+		return 0, fmt.Errorf("no falling step, final value %g above initial value %g", final, initial)
+	}
+	return transitionTime(x, y, final, initial, high, low, Falling)
+}
+
+// transitionTime - Time between crossings of fractions from and to of range bottom to top
+func transitionTime(x, y []float64, bottom, top, from, to float64, edge Edge) (float64, error) {
+	t1, err := Cross(x, y, bottom+from*(top-bottom), edge, x[0])
+	if err != nil {
+		return 0, err
+	}
+	t2, err := Cross(x, y, bottom+to*(top-bottom), edge, t1)
+	if err != nil {
+		return 0, err
+	}
+	return t2 - t1, nil
+}
+
+// Overshoot - Peak beyond last value in percent of step from first to last value. 0 without overshoot
+func Overshoot(x, y []float64) (float64, error) {
+	initial, final, err := step(y)
+	if err != nil {
+		return 0, err
+	}
+	low, high, err := Extremes(x, y, x[0], x[len(x)-1])
+	if err != nil {
+		return 0, err
+	}
+	peak := high - final
+	if final < initial {
+		peak = final - low
+	}
+	return 100 * peak / math.Abs(final-initial), nil
+}
+
+// SettlingTime - Time from first point until y stays within tolerance fraction of step from last value.
+// eg. 0.02 for 2% band
+func SettlingTime(x, y []float64, tolerance float64) (float64, error) {
+	initial, final, err := step(y)
+	if err != nil {
+		return 0, err
+	}
+	band := tolerance * math.Abs(final-initial)
+	for k := len(y) - 1; k > 0; k-- {
+		d := y[k-1] - final
+		if math.Abs(d) <= band {
+			continue
+		}
+		// Entry into band between k-1 and k
+		edge := math.Copysign(band, d)
+		b := y[k] - final
+		return x[k-1] + (d-edge)/(d-b)*(x[k]-x[k-1]) - x[0], nil
+	}
+	return 0, nil
+}
@@ -0,0 +1,137 @@
+// Package table - Column aligned text tables with header repeated by page, eg. results of CLI
+package table
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Table - Rows of cells under header. Columns of numbers are right aligned except first column, others left aligned
+type Table struct {
+	Header    []string
+	Rows      [][]string
+	PageRows  int  // Rows between repeated headers, 0 prints header once. Columns by page when transposed
+	Transpose bool // Header as first column and each row as column. eg. many nodes at few points
+}
+
+func New(header ...string) *Table {
+	return &Table{Header: header}
+}
+
+// AddRow - Row of cells, missing cells are empty and extra cells are dropped
+func (t *Table) AddRow(cells ...string) {
+	row := make([]string, len(t.Header))
+	copy(row, cells)
+	t.Rows = append(t.Rows, row)
+}
+
+// Write - Table to w with dashed line under each header
+func (t *Table) Write(w io.Writer) error {
+	if !t.Transpose {
+		return writePages(w, t.Header, t.Rows, t.PageRows)
+	}
+
+	// Transposed by pages of columns, first column is header repeated on every page
+	page := t.PageRows
+	if page <= 0 {
+		page = max(len(t.Rows), 1)
+	}
+	for start := 0; start < len(t.Rows) || start == 0; start += page {
+		end := min(start+page, len(t.Rows))
+		if start > 0 {
+			_, err := fmt.Fprintln(w)
+			if err != nil {
+				return err
+			}
+		}
+		header := []string{t.Header[0]}
+		for _, row := range t.Rows[start:end] {
+			header = append(header, row[0])
+		}
+		var rows [][]string
+		for j := 1; j < len(t.Header); j++ {
+			row := []string{t.Header[j]}
+			for _, r := range t.Rows[start:end] {
+				row = append(row, r[j])
+			}
+			rows = append(rows, row)
+		}
+		err := writePages(w, header, rows, 0)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writePages(w io.Writer, header []string, rows [][]string, pageRows int) error {
+	widths := make([]int, len(header))
+	for j, cell := range header {
+		widths[j] = utf8.RuneCountInString(cell)
+	}
+	for _, row := range rows {
+		for j, cell := range row {
+			widths[j] = max(widths[j], utf8.RuneCountInString(cell))
+		}
+	}
+
+	right := make([]bool, len(header))
+	for j := 1; j < len(header); j++ {
+		right[j] = true
+		for _, row := range rows {
+			if row[j] != "" && !isNumber(row[j]) {
+				right[j] = false
+				break
+			}
+		}
+	}
+
+	total := 0
+	for _, width := range widths {
+		total += width + 2
+	}
+	rule := strings.Repeat("-", max(total-2, 0))
+
+	var sb strings.Builder
+	writeRow := func(row []string) {
+		var line strings.Builder
+		for j, cell := range row {
+			pad := strings.Repeat(" ", widths[j]-utf8.RuneCountInString(cell))
+			if j > 0 {
+				line.WriteString("  ")
+			}
+			if right[j] {
+				line.WriteString(pad + cell)
+			} else {
+				line.WriteString(cell + pad)
+			}
+		}
+		sb.WriteString(strings.TrimRight(line.String(), " ") + "\n")
+	}
+
+	for i, row := range rows {
+		if i == 0 || (pageRows > 0 && i%pageRows == 0) {
+			if i > 0 {
+				sb.WriteString("\n")
+			}
+			writeRow(header)
+			sb.WriteString(rule + "\n")
+		}
+		writeRow(row)
+	}
+	if len(rows) == 0 {
+		writeRow(header)
+		sb.WriteString(rule + "\n")
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// isNumber - Cell starting with number. eg. 1.5 mV, -2.0e-3, 0.333<-0.1deg
+func isNumber(cell string) bool {
+	cell = strings.TrimLeft(cell, "+-")
+	return cell != "" && (cell[0] == '.' || (cell[0] >= '0' && cell[0] <= '9'))
+}
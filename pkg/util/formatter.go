@@ -3,24 +3,72 @@ package util
 import (
 	"fmt"
 	"math"
+	"strconv"
 )
 
+// NumberFormat - Formatting of values by FormatValueFactor
+type NumberFormat struct {
+	Digits     int  // Significant digits, 0 is 3 decimals
+	Scientific bool // Plain scientific notation instead of factor. eg. 1.500e-03 V
+}
+
+// valueFormat - Format of FormatValueFactor, set by SetNumberFormat
+var valueFormat NumberFormat
+
+// SetNumberFormat - Format of FormatValueFactor, eg. by flags of CLI. Digits below 0 are taken as 0
+func SetNumberFormat(f NumberFormat) {
+	f.Digits = max(f.Digits, 0)
+	valueFormat = f
+}
+
+// Engineering factors from tera to pico, value scaled into [1, 1000)
+var valueFactors = []struct {
+	scale  float64
+	prefix string
+}{
+	{1e12, "T"}, {1e9, "G"}, {1e6, "M"}, {1e3, "k"}, {1, ""}, {1e-3, "m"}, {1e-6, "u"}, {1e-9, "n"}, {1e-12, "p"},
+}
+
+// FormatValueFactor - Value with engineering factor of unit by format of SetNumberFormat. eg. 4.700 kV, 5.302 mA
 func FormatValueFactor(value float64, unit string) string {
+	return valueFormat.Format(value, unit)
+}
+
+// Format - Value with engineering factor of unit. Zero has no factor, values out of tera to pico are scientific
+func (f NumberFormat) Format(value float64, unit string) string {
+	decimals := 3
+	if f.Digits > 0 {
+		decimals = f.Digits - 1
+	}
+	if f.Scientific || math.IsNaN(value) || math.IsInf(value, 0) {
+		return fmt.Sprintf("%.*e %s", decimals, value, unit)
+	}
+	if value == 0 {
+		return fmt.Sprintf("%.*f %s", max(decimals, 0), 0.0, unit)
+	}
+
 	absValue := math.Abs(value)
-	switch {
-	case absValue >= 1:
-		return fmt.Sprintf("%.3f %s", value, unit)
-	case absValue >= 1e-3:
-		return fmt.Sprintf("%.3f m%s", value*1e3, unit)
-	case absValue >= 1e-6:
-		return fmt.Sprintf("%.3f u%s", value*1e6, unit)
-	case absValue >= 1e-9:
-		return fmt.Sprintf("%.3f n%s", value*1e9, unit)
-	case absValue >= 1e-12:
-		return fmt.Sprintf("%.3f p%s", value*1e12, unit)
-	default:
-		return fmt.Sprintf("%.3e %s", value, unit)
+	if f.Digits > 0 {
+		// Rounded first, so 999.96 with 4 digits is 1.000 k instead of 1000
+		absValue, _ = strconv.ParseFloat(strconv.FormatFloat(absValue, 'e', decimals, 64), 64)
+	}
+	for _, factor := range valueFactors {
+		if f.Digits == 0 {
+			// Scaled value rounded to decimals, so 0.9999996 is 1.000 instead of 1000.000 m
+			scaled, _ := strconv.ParseFloat(strconv.FormatFloat(absValue/factor.scale, 'f', decimals, 64), 64)
+			if scaled < 1 || scaled >= 1000 {
+				continue
+			}
+		} else if absValue < factor.scale || absValue >= 1000*factor.scale {
+			continue
+		}
+		scaled := value / factor.scale
+		if f.Digits > 0 {
+			decimals = max(f.Digits-len(strconv.Itoa(int(absValue/factor.scale))), 0)
+		}
+		return fmt.Sprintf("%.*f %s%s", decimals, scaled, factor.prefix, unit)
 	}
+	return fmt.Sprintf("%.*e %s", decimals, value, unit)
 }
 
 func FormatFrequency(freq float64) string {
@@ -0,0 +1,151 @@
+package verify
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/edp1096/toy-spice/internal/consts"
+)
+
+// Cases - Standard set of canonical circuits
+func Cases() []Case {
+	return []Case{
+		DividerChain(),
+		RCStep(),
+		RLCResonance(),
+		DiodeForward(),
+	}
+}
+
+// DividerChain - Resistor chain 1k, 2k, 3k, 4k from 10V. V(n) = 10 * (resistance below n) / 10k
+func DividerChain() Case {
+	return Case{
+		Name: "divider chain",
+		Netlist: `* Divider chain
+.op
+v1 1 0 DC 10
+r1 1 2 1k
+r2 2 3 2k
+r3 3 4 3k
+r4 4 0 4k
+`,
+		Check: func(results map[string][]float64) error {
+			want := map[string]float64{"V(1)": 10, "V(2)": 9, "V(3)": 7, "V(4)": 4, "I(r1)": 1e-3}
+			for name, v := range want {
+				values, err := value(results, name)
+				if err != nil {
+					return err
+				}
+				err = Near(name, values[0], v, 1e-9, 1e-12)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// RCStep - Step response of RC with tau 1ms. V(2) = 1 - exp(-t/tau)
+func RCStep() Case {
+	const tau = 1e-3
+	return Case{
+		Name: "RC step",
+		Netlist: `* RC step
+.tran 10u 5m
+v1 1 0 PULSE(0 1 0 1n 1n 10 20)
+r1 1 2 1k
+c1 2 0 1u
+`,
+		Check: func(results map[string][]float64) error {
+			times, err := value(results, "TIME")
+			if err != nil {
+				return err
+			}
+			out, err := value(results, "V(2)")
+			if err != nil {
+				return err
+			}
+			for i, t := range times {
+				// Backward Euler and trapezoidal stay well within 1% of step
+				err = Near(fmt.Sprintf("V(2) at t=%g", t), out[i], 1-math.Exp(-t/tau), 0, 0.01)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// RLCResonance - Series RLC, V(3) across R. Peak of gain 1 at f0 = 1/(2*pi*sqrt(LC))
+func RLCResonance() Case {
+	const l, c = 1e-3, 1e-6
+	const pointsPerDecade = 100
+	const fstart, fstop = 1e3, 30e3
+	f0 := 1 / (2 * math.Pi * math.Sqrt(l*c))
+	return Case{
+		Name: "RLC resonance",
+		Netlist: fmt.Sprintf(`* Series RLC
+.ac dec %d %g %g
+v1 1 0 AC 1
+l1 1 2 %g
+c1 2 3 %g
+r1 3 0 10
+`, pointsPerDecade, fstart, fstop, l, c),
+		Check: func(results map[string][]float64) error {
+			freqs, err := value(results, "FREQ")
+			if err != nil {
+				return err
+			}
+			mags, err := value(results, "V(3)_MAG")
+			if err != nil {
+				return err
+			}
+
+			// Points per decade from fstart, not above fstop
+			points := int(math.Floor(math.Log10(fstop/fstart)*pointsPerDecade)) + 1
+			if len(freqs) != points {
+				return fmt.Errorf("%d frequency points, expected %d", len(freqs), points)
+			}
+
+			peak := 0
+			for i := range mags {
+				if mags[i] > mags[peak] {
+					peak = i
+				}
+			}
+
+			// Peak is nearest frequency point to f0, within half step
+			step := math.Pow(10, 0.5/pointsPerDecade)
+			if freqs[peak] < f0/step || freqs[peak] > f0*step {
+				return fmt.Errorf("resonance at %g Hz, expected %g Hz", freqs[peak], f0)
+			}
+			return Near("|V(3)| at resonance", mags[peak], 1, 0.02, 0)
+		},
+	}
+}
+
+// DiodeForward - Diode with Is=1e-14 and N=1.5 biased by 10V through 10k. V = N*Vt*ln(I/Is + 1), I = (10 - V)/10k
+func DiodeForward() Case {
+	const is, n, vs, r = 1e-14, 1.5, 10.0, 10e3
+	vt := consts.BOLTZMANN * 300.15 / consts.CHARGE
+	return Case{
+		Name: "diode forward",
+		Netlist: fmt.Sprintf(`* Diode forward bias
+.op
+v1 1 0 DC %g
+r1 1 2 %g
+d1 2 0 DMOD
+.model DMOD D(Is=%g N=%g)
+`, vs, r, is, n),
+		Check: func(results map[string][]float64) error {
+			values, err := value(results, "V(2)")
+			if err != nil {
+				return err
+			}
+			v := values[0]
+			return Near("V(2)", v, n*vt*math.Log((vs-v)/r/is+1), 0, 1e-6)
+		},
+	}
+}
@@ -0,0 +1,71 @@
+// Package verify - Canonical circuits with closed form answers to check analyses and device models
+package verify
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/edp1096/toy-spice/pkg/analysis"
+)
+
+// Case - Netlist and check of its results against analytic solution
+type Case struct {
+	Name    string
+	Netlist string
+	Check   func(results map[string][]float64) error
+}
+
+// Run - Simulate netlist of case and check results. Warnings are of parse, netlist check and analysis
+func (c Case) Run() ([]string, error) {
+	result, err := Simulate(c.Netlist)
+	if err != nil {
+		return nil, err
+	}
+	return result.Warnings, c.Check(result.Values)
+}
+
+// Result - Outcome of one case. Err is nil when passed
+type Result struct {
+	Name     string
+	Err      error
+	Warnings []string
+}
+
+// RunAll - Run cases in order
+func RunAll(cases []Case) []Result {
+	results := make([]Result, len(cases))
+	for i, c := range cases {
+		warnings, err := c.Run()
+		results[i] = Result{Name: c.Name, Err: err, Warnings: warnings}
+	}
+	return results
+}
+
+// Simulate - Run netlist of one analysis by analysis.RunNetlist with default options
+func Simulate(content string) (*analysis.Result, error) {
+	results, err := analysis.RunNetlist(content)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) != 1 {
+		return nil, fmt.Errorf("expected one analysis, got %d", len(results))
+	}
+	return results[0], nil
+}
+
+// Near - Error when got differs from want by more than abstol + reltol*|want|
+func Near(name string, got, want, reltol, abstol float64) error {
+	if math.IsNaN(got) || math.Abs(got-want) > abstol+reltol*math.Abs(want) {
+		return fmt.Errorf("%s = %g, expected %g", name, got, want)
+	}
+	return nil
+}
+
+// Result value by name, error when missing
+func value(results map[string][]float64, name string) ([]float64, error) {
+	values, ok := results[name]
+	if !ok || len(values) == 0 {
+		return nil, fmt.Errorf("missing result %s", name)
+	}
+	return values, nil
+}
@@ -0,0 +1,18 @@
+package verify
+
+import "testing"
+
+// TestCases - Canonical circuits against their analytic solutions, as spice verify
+func TestCases(t *testing.T) {
+	for _, c := range Cases() {
+		t.Run(c.Name, func(t *testing.T) {
+			warnings, err := c.Run()
+			for _, warning := range warnings {
+				t.Log("warning:", warning)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}